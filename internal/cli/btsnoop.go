@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+func runBtsnoop(a *app, args []string) error {
+	fs := a.newFlagSet()
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 2, "a capture file and an output file"); err != nil {
+		return err
+	}
+
+	in, err := os.Open(rest[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	capture, err := sony_remote_ble.ReadCapture(in)
+	if err != nil {
+		return fmt.Errorf("%s: %w", rest[0], err)
+	}
+
+	out, err := os.Create(rest[1])
+	if err != nil {
+		return err
+	}
+	if err := capture.WriteBtsnoop(out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "wrote %d records to %s\n", len(capture.Records), rest[1])
+	return nil
+}
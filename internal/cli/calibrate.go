@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/known"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// calibrationJSON is the JSON representation of a calibration printed by calibrate.
+type calibrationJSON struct {
+	Device   string       `json:"device"`
+	Address  string       `json:"address"`
+	Trials   int          `json:"trials"`
+	Write    *latencyJSON `json:"write,omitempty"`
+	Focus    *latencyJSON `json:"focus,omitempty"`
+	Shutter  *latencyJSON `json:"shutter,omitempty"`
+	OffsetMS float64      `json:"offset_ms"`
+	JitterMS float64      `json:"jitter_ms"`
+	Saved    bool         `json:"saved"`
+}
+
+type latencyJSON struct {
+	Samples  int     `json:"samples"`
+	MinMS    float64 `json:"min_ms"`
+	MedianMS float64 `json:"median_ms"`
+	P95MS    float64 `json:"p95_ms"`
+	MaxMS    float64 `json:"max_ms"`
+	JitterMS float64 `json:"jitter_ms"`
+}
+
+func newLatencyJSON(stats sony_remote_ble.LatencyStats) *latencyJSON {
+	if stats.Samples == 0 {
+		return nil
+	}
+	return &latencyJSON{
+		Samples:  stats.Samples,
+		MinMS:    durationMS(stats.Min),
+		MedianMS: durationMS(stats.Median),
+		P95MS:    durationMS(stats.P95),
+		MaxMS:    durationMS(stats.Max),
+		JitterMS: durationMS(stats.Jitter),
+	}
+}
+
+func runCalibrate(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	trials := fs.Int("trials", sony_remote_ble.DefaultCalibrateTrials, "number of photos to take")
+	hold := fs.Duration("hold", 0, "how long to half-press before each photo (default command delay)")
+	pause := fs.Duration("pause", sony_remote_ble.DefaultCalibratePause, "pause between photos")
+	noSave := fs.Bool("no-save", false, "do not store the offset with the known camera")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+	if *trials < 1 {
+		return usageError("--trials must be at least 1")
+	}
+
+	sess, device, err := a.connect(flags)
+	if err != nil {
+		return err
+	}
+	defer sess.Disconnect(context.Background())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !*asJSON {
+		fmt.Fprintf(a.stderr, "Taking %d photo(s) with %s...\n", *trials, device.Name)
+	}
+	var cal sony_remote_ble.Calibration
+	err = sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+		if flags.commandDelay > 0 {
+			client.SetCommandDelay(flags.commandDelay)
+		}
+		var err error
+		cal, err = sony_remote_ble.Calibrate(ctx, client, sony_remote_ble.CalibrateOptions{
+			Trials: *trials,
+			Hold:   *hold,
+			Pause:  *pause,
+		})
+		return err
+	})
+	if err != nil && len(cal.Trials) == 0 {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(a.stderr, "sony-remote calibrate: stopped after %d trial(s): %v\n", len(cal.Trials), err)
+	}
+
+	// A partial run is reported but never replaces a stored calibration
+	saved := false
+	if err == nil && !*noSave {
+		store := sess.Known()
+		if store == nil {
+			fmt.Fprintln(a.stderr, "sony-remote calibrate: known cameras unavailable, offset not saved")
+		} else {
+			saveErr := store.SetCalibration(device.AddressStr, known.Calibration{
+				Offset: cal.Offset(),
+				Jitter: cal.Jitter(),
+				Trials: len(cal.Trials),
+				At:     time.Now(),
+			})
+			if saveErr != nil {
+				fmt.Fprintf(a.stderr, "sony-remote calibrate: offset not saved: %v\n", saveErr)
+			}
+			saved = saveErr == nil
+		}
+	}
+
+	if *asJSON {
+		if printErr := a.printJSON(calibrationJSON{
+			Device:   device.Name,
+			Address:  device.AddressStr,
+			Trials:   len(cal.Trials),
+			Write:    newLatencyJSON(cal.Write),
+			Focus:    newLatencyJSON(cal.Focus),
+			Shutter:  newLatencyJSON(cal.Shutter),
+			OffsetMS: durationMS(cal.Offset()),
+			JitterMS: durationMS(cal.Jitter()),
+			Saved:    saved,
+		}); printErr != nil {
+			return printErr
+		}
+		return err
+	}
+
+	tw := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LATENCY\tSAMPLES\tMIN\tMEDIAN\tP95\tMAX\tJITTER")
+	for _, row := range []struct {
+		name  string
+		stats sony_remote_ble.LatencyStats
+	}{
+		{"write", cal.Write},
+		{"focus", cal.Focus},
+		{"shutter", cal.Shutter},
+	} {
+		s := row.stats
+		if s.Samples == 0 {
+			fmt.Fprintf(tw, "%s\t0\t-\t-\t-\t-\t-\n", row.name)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%v\t%v\t%v\t%v\n", row.name, s.Samples,
+			s.Min.Round(time.Microsecond), s.Median.Round(time.Microsecond), s.P95.Round(time.Microsecond),
+			s.Max.Round(time.Microsecond), s.Jitter.Round(time.Microsecond))
+	}
+	if flushErr := tw.Flush(); flushErr != nil {
+		return flushErr
+	}
+
+	if cal.Shutter.Samples == 0 {
+		fmt.Fprintln(a.stdout, "The camera did not report its shutter; the offset is the write latency")
+	}
+	fmt.Fprintf(a.stdout, "Offset: %v (jitter %v)", cal.Offset().Round(time.Microsecond), cal.Jitter().Round(time.Microsecond))
+	if saved {
+		fmt.Fprintf(a.stdout, ", saved for %s", device.Name)
+	}
+	fmt.Fprintln(a.stdout)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,239 @@
+// Package cli implements the headless subcommands of the sony-remote binary.
+// Each subcommand performs a single camera operation and exits, which makes the
+// remote usable from shell scripts and CI jobs without the terminal UI.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+)
+
+// Exit codes returned by Run.
+const (
+	// ExitOK indicates the command completed successfully
+	ExitOK = 0
+	// ExitFailure indicates a generic failure such as a failed command write
+	ExitFailure = 1
+	// ExitUsage indicates invalid arguments or an unknown subcommand
+	ExitUsage = 2
+	// ExitNotFound indicates the requested camera was not found during scanning
+	ExitNotFound = 3
+	// ExitConnect indicates the camera was found but the connection failed
+	ExitConnect = 4
+)
+
+// exitError carries the process exit code alongside the error that caused it.
+type exitError struct {
+	code int
+	err  error
+	// reported is set when the error has already been printed, e.g. by the flag package
+	reported bool
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// usageError wraps an error so that it is reported with ExitUsage.
+func usageError(format string, args ...any) error {
+	return &exitError{code: ExitUsage, err: fmt.Errorf(format, args...)}
+}
+
+// command describes a single subcommand.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(a *app, args []string) error
+}
+
+var commands = []command{
+	{"scan", "scan [--timeout 10s] [--json]", "List nearby Sony cameras", runScan},
+	{"shoot", "shoot [--wait-focus 2s] [--device D]", "Take a photo", runShoot},
+	{"record", "record start|stop|toggle [--device D]", "Start or stop video recording", runRecord},
+	{"zoom", "zoom in|out [--hold 500ms] [--device D]", "Zoom the lens in or out", runZoom},
+	{"focus", "focus [--hold 500ms] [--device D]", "Half-press to focus", runFocus},
+	{"send", "send --hex 0107 [--device D]", "Send a raw command frame", runSend},
+	{"bulb", "bulb 30s [--device D]", "Take a bulb exposure", runBulb},
+	{"info", "info [--device D] [--json]", "Show camera and connection details", runInfo},
+	{"calibrate", "calibrate [--trials 10] [--device D]", "Measure the camera's shutter latency", runCalibrate},
+	{"serve", "serve [--http :8080] [--device D]", "Serve a JSON REST API for the camera", runServe},
+	{"mqtt", "mqtt [--broker URL] --device D", "Bridge the camera to an MQTT broker", runMQTT},
+	{"osc", "osc [--listen :53000] [--reply-to H:P]", "Control the camera with OSC messages", runOSC},
+	{"daemon", "daemon [--socket PATH] [--device D]", "Own the camera and serve local clients", runDaemon},
+	{"ctl", "ctl ACTION [ARGS] [--socket PATH]", "Send a command to the daemon", runCtl},
+	{"leader", "leader [--listen :47800] [--expect N]", "Fire cameras on several hosts together", runLeader},
+	{"follow", "follow --leader H:P [--device D]", "Fire the camera when the leader says so", runFollow},
+	{"presence", "presence [--near -65] [--json]", "Run rules as cameras come into range and leave", runPresence},
+	{"adapters", "adapters [--json]", "List the host's Bluetooth adapters", runAdapters},
+	{"btsnoop", "btsnoop CAPTURE OUT", "Convert a traffic capture for Wireshark", runBtsnoop},
+}
+
+// app holds the state shared by all subcommands.
+type app struct {
+	version string
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	cfg     *config.Config
+	logger  *slog.Logger
+	clients ClientOptions
+
+	// cmd is the subcommand being executed
+	cmd command
+}
+
+// Run executes the subcommand named by args[0] and returns the process exit code.
+// It is called by main when the binary is started with a subcommand; without
+// one main launches the terminal UI instead. The configuration supplies the
+// defaults for flags that are not given on the command line, and clients
+// selects whether commands use Bluetooth or replay a capture.
+func Run(args []string, version string, cfg *config.Config, logger *slog.Logger, clients ClientOptions) int {
+	a := &app{
+		version: version,
+		stdin:   os.Stdin,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+		cfg:     cfg,
+		logger:  logger,
+		clients: clients,
+	}
+	return a.run(args)
+}
+
+func (a *app) run(args []string) int {
+	if len(args) == 0 {
+		Usage(a.stderr, a.version)
+		return ExitUsage
+	}
+
+	name := args[0]
+	switch name {
+	case "help", "-h", "--help":
+		Usage(a.stdout, a.version)
+		return ExitOK
+	case "version", "--version":
+		fmt.Fprintln(a.stdout, a.version)
+		return ExitOK
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		a.cmd = cmd
+		err := cmd.run(a, args[1:])
+		if err == nil {
+			return ExitOK
+		}
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+
+		var exitErr *exitError
+		if errors.As(err, &exitErr) && exitErr.reported {
+			return exitErr.code
+		}
+		fmt.Fprintf(a.stderr, "sony-remote %s: %v\n", name, err)
+		if errors.As(err, &exitErr) {
+			if exitErr.code == ExitUsage {
+				fmt.Fprintf(a.stderr, "usage: sony-remote %s\n", cmd.usage)
+			}
+			return exitErr.code
+		}
+		return ExitFailure
+	}
+
+	fmt.Fprintf(a.stderr, "sony-remote: unknown command %q\n\n", name)
+	Usage(a.stderr, a.version)
+	return ExitUsage
+}
+
+// Usage prints the list of subcommands and global flags.
+func Usage(w io.Writer, version string) {
+	fmt.Fprintf(w, "Sony Camera Remote %s\n\n", version)
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintln(w, "  sony-remote [global flags]  Launch the terminal UI")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  sony-remote %-40s %s\n", cmd.usage, cmd.summary)
+	}
+	fmt.Fprintln(w, "\nGlobal flags (before the subcommand):")
+	fmt.Fprintln(w, "  --config PATH      configuration file")
+	fmt.Fprintln(w, "  --log-level LEVEL  debug, info, warn or error")
+	fmt.Fprintln(w, "  --log-file PATH    append logs to a file")
+	fmt.Fprintln(w, "  --log-format FMT   text, json or journal")
+	fmt.Fprintln(w, "  --capture PATH     record Bluetooth traffic to a JSONL file")
+	fmt.Fprintln(w, "  --replay PATH      replay a capture instead of using Bluetooth")
+	fmt.Fprintln(w, "  --simulate         use a simulated camera instead of Bluetooth")
+	fmt.Fprintln(w, "  --adapter IDS      Bluetooth adapters to use, e.g. hci1 or hci0,hci1")
+	fmt.Fprintln(w, "\nThe --device flag accepts a camera name, Bluetooth address or saved alias.")
+	fmt.Fprintln(w, "Without --device the configured default camera, or else the first camera found, is used.")
+}
+
+// newFlagSet creates a flag set for the current subcommand that reports errors instead of exiting.
+func (a *app) newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(a.cmd.name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "usage: sony-remote %s\n\n", a.cmd.usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// deviceFlags are the flags shared by every subcommand that talks to a camera.
+type deviceFlags struct {
+	device       string
+	scanTimeout  time.Duration
+	commandDelay time.Duration
+}
+
+// register adds the device flags to fs, using the configuration for their defaults.
+func (d *deviceFlags) register(fs *flag.FlagSet, cfg *config.Config) {
+	fs.StringVar(&d.device, "device", cfg.DefaultCamera, "camera name, address or alias")
+	fs.DurationVar(&d.scanTimeout, "scan-timeout", cfg.Scan.Timeout, "how long to scan for the camera")
+	fs.DurationVar(&d.commandDelay, "command-delay", 0, "pause between sequence commands (default from config)")
+}
+
+// parseArgs parses flags interleaved with positional arguments, so that both
+// "bulb 30s --device A7" and "bulb --device A7 30s" are accepted.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, &exitError{code: ExitUsage, err: err, reported: true}
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// expectArgs validates the number of positional arguments.
+func expectArgs(args []string, n int, what string) error {
+	if len(args) != n {
+		if n == 0 {
+			return usageError("unexpected arguments: %s", strings.Join(args, " "))
+		}
+		return usageError("expected %s", what)
+	}
+	return nil
+}
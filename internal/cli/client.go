@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"io"
+
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/simulator"
+)
+
+// ClientOptions selects how clients reach the camera. The zero value uses the
+// host's Bluetooth adapter.
+type ClientOptions struct {
+	// Replay plays a capture back instead of using Bluetooth
+	Replay *sony_remote_ble.Capture
+	// Simulate connects to a simulated camera instead of using Bluetooth
+	Simulate bool
+	// Capture receives the protocol traffic of every client as JSON lines
+	Capture io.Writer
+	// Adapters lists the Bluetooth adapters to use by ID, address or name;
+	// empty uses the default adapter
+	Adapters []string
+}
+
+// NewClient creates a client as selected by the options, on the first adapter.
+func (o ClientOptions) NewClient() (*sony_remote_ble.Client, error) {
+	return o.NewClientOn(0)
+}
+
+// NewClientOn creates a client as selected by the options, on the n-th
+// adapter counting round the list, so that consecutive clients are spread
+// across all adapters.
+func (o ClientOptions) NewClientOn(n int) (*sony_remote_ble.Client, error) {
+	var client *sony_remote_ble.Client
+	switch {
+	case o.Replay != nil:
+		client = sony_remote_ble.NewClientWithTransport(sony_remote_ble.NewReplayTransport(o.Replay))
+	case o.Simulate:
+		client = sony_remote_ble.NewClientWithTransport(simulator.New(simulator.DefaultOptions()))
+	default:
+		var adapter string
+		if len(o.Adapters) > 0 {
+			adapter = o.Adapters[n%len(o.Adapters)]
+		}
+		var err error
+		if client, err = sony_remote_ble.NewClientWithAdapter(adapter); err != nil {
+			return nil, err
+		}
+	}
+	if o.Capture != nil {
+		// Recording stops with the process; the caller closes the file
+		client.Record(o.Capture)
+	}
+	return client, nil
+}
+
+// NewScanner creates a client for scanning next to client, on the second
+// adapter. A simulated camera is shared with client instead, since a new
+// simulator would be a different camera.
+func (o ClientOptions) NewScanner(client *sony_remote_ble.Client) (*sony_remote_ble.Client, error) {
+	if !o.Simulate {
+		return o.NewClientOn(1)
+	}
+	scanner := sony_remote_ble.NewClientWithTransport(client.Transport())
+	if o.Capture != nil {
+		scanner.Record(o.Capture)
+	}
+	return scanner, nil
+}
+
+// Virtual reports whether clients reach something other than real cameras.
+// Cameras seen through a replay or the simulator are not remembered.
+func (o ClientOptions) Virtual() bool {
+	return o.Replay != nil || o.Simulate
+}
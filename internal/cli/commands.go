@@ -0,0 +1,431 @@
+package cli
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/control"
+	"github.com/smazurov/sony_remote_ble/internal/daemon"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// deviceJSON is the JSON representation of a camera printed by scan and info.
+type deviceJSON struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	RSSI    int16  `json:"rssi"`
+}
+
+// infoJSON is the JSON representation printed by the info subcommand.
+type infoJSON struct {
+	deviceJSON
+	State          string `json:"state"`
+	Service        string `json:"service_uuid"`
+	Characteristic string `json:"characteristic_uuid"`
+}
+
+func runScan(a *app, args []string) error {
+	fs := a.newFlagSet()
+	timeout := fs.Duration("timeout", a.cfg.Scan.Timeout, "how long to scan")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+
+	sess, err := a.newSession()
+	if err != nil {
+		return err
+	}
+
+	devices, err := sess.Scan(context.Background(), *timeout)
+	if err != nil {
+		return err
+	}
+
+	found := make([]deviceJSON, 0, len(devices))
+	for _, device := range devices {
+		found = append(found, deviceJSON{Name: device.Name, Address: device.AddressStr, RSSI: device.RSSI})
+	}
+
+	if *asJSON {
+		if err := a.printJSON(found); err != nil {
+			return err
+		}
+	} else {
+		tw := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tADDRESS\tRSSI")
+		for _, device := range found {
+			fmt.Fprintf(tw, "%s\t%s\t%d\n", device.Name, device.Address, device.RSSI)
+		}
+		tw.Flush()
+	}
+
+	if len(found) == 0 {
+		return &exitError{code: ExitNotFound, err: fmt.Errorf("no Sony camera found within %s", *timeout)}
+	}
+	return nil
+}
+
+// focusPolicies maps the values of shoot --on-miss to focus policies.
+var focusPolicies = map[string]sony_remote_ble.FocusPolicy{
+	"abort": sony_remote_ble.FocusAbort,
+	"retry": sony_remote_ble.FocusRetry,
+	"fire":  sony_remote_ble.FocusFire,
+}
+
+func runShoot(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	waitFocus := fs.Duration("wait-focus", 0, "only fire once focus locks, waiting up to this long per attempt")
+	onMiss := fs.String("on-miss", "abort", "what to do when focus does not lock: abort, retry or fire")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+	policy, ok := focusPolicies[*onMiss]
+	if !ok {
+		return usageError("--on-miss must be abort, retry or fire, got %q", *onMiss)
+	}
+	if *waitFocus < 0 {
+		return usageError("--wait-focus must not be negative")
+	}
+
+	return a.withCamera(flags, func(client *sony_remote_ble.Client, device sony_remote_ble.DeviceInfo) error {
+		if *waitFocus == 0 {
+			if err := client.TakePhoto(); err != nil {
+				return err
+			}
+			fmt.Fprintf(a.stdout, "Photo taken on %s\n", device.Name)
+			return nil
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		result, err := client.TakePhotoWhenFocused(ctx, *waitFocus, policy)
+		if errors.Is(err, sony_remote_ble.ErrFocusTimeout) {
+			return fmt.Errorf("no photo taken on %s: focus did not lock after %d attempt(s)", device.Name, result.Attempts)
+		}
+		if err != nil {
+			return err
+		}
+		if result.Outcome == sony_remote_ble.FocusFiredUnlocked {
+			fmt.Fprintf(a.stdout, "Photo taken on %s without focus lock after %d attempt(s)\n", device.Name, result.Attempts)
+			return nil
+		}
+		fmt.Fprintf(a.stdout, "Photo taken on %s, focus locked in %v\n", device.Name, result.FocusLatency.Round(time.Millisecond))
+		return nil
+	})
+}
+
+func runRecord(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	socket := fs.String("socket", "", "Unix socket of the daemon, used when it runs (default "+daemon.DefaultSocketPath()+")")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 1, "start, stop or toggle"); err != nil {
+		return err
+	}
+	action := "record_" + rest[0]
+	switch rest[0] {
+	case "start", "stop", "toggle":
+	default:
+		return usageError("expected start, stop or toggle, got %q", rest[0])
+	}
+
+	// A running daemon owns the camera and knows whether it is recording
+	if client := a.dialDaemon(*socket); client != nil {
+		defer client.Close()
+		reply, err := client.Call(context.Background(), control.Request{Action: action}, nil)
+		if err != nil {
+			return err
+		}
+		if !reply.OK {
+			return fmt.Errorf("%s", reply.Error)
+		}
+		fmt.Fprintf(a.stdout, "Record %s through the daemon: %s\n", recordVerb(rest[0]), describeState(reply.State))
+		return nil
+	}
+
+	return a.withCamera(flags, func(client *sony_remote_ble.Client, device sony_remote_ble.DeviceInfo) error {
+		if rest[0] == "toggle" {
+			if err := client.Press("record", sony_remote_ble.DefaultCommandDelay); err != nil {
+				return err
+			}
+			fmt.Fprintf(a.stdout, "Record toggled on %s\n", device.Name)
+			return nil
+		}
+		err := client.SetRecording(context.Background(), rest[0] == "start")
+		if errors.Is(err, sony_remote_ble.ErrRecordingUnknown) {
+			return fmt.Errorf("%s sends no notifications, so whether it records cannot be known; use record toggle", device.Name)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.stdout, "Recording %s on %s\n", recordVerb(rest[0]), device.Name)
+		return nil
+	})
+}
+
+// recordVerb returns the past tense of a record subcommand.
+func recordVerb(sub string) string {
+	switch sub {
+	case "start":
+		return "started"
+	case "stop":
+		return "stopped"
+	}
+	return "toggled"
+}
+
+func runZoom(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	hold := fs.Duration("hold", 0, "how long to keep zooming (default from config)")
+	speed := fs.Int("speed", 0, "zoom speed from 1 to 127 (default from config)")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 1, "in or out"); err != nil {
+		return err
+	}
+	direction := rest[0]
+	if direction != "in" && direction != "out" {
+		return usageError("expected in or out, got %q", direction)
+	}
+
+	if *speed < 0 || *speed > 127 {
+		return usageError("--speed must be between 1 and 127")
+	}
+
+	return a.withCamera(flags, func(client *sony_remote_ble.Client, device sony_remote_ble.DeviceInfo) error {
+		timing := a.cfg.TimingFor(device.Name, device.AddressStr)
+		if *hold > 0 {
+			timing.ZoomHold = *hold
+		}
+		if *speed > 0 {
+			timing.ZoomSpeed = *speed
+		}
+
+		if err := client.Zoom(direction == "in", byte(timing.ZoomSpeed), timing.ZoomHold); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.stdout, "Zoomed %s for %s on %s\n", direction, timing.ZoomHold, device.Name)
+		return nil
+	})
+}
+
+func runFocus(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	hold := fs.Duration("hold", 0, "how long to hold the half-press (default from config)")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+
+	return a.withCamera(flags, func(client *sony_remote_ble.Client, device sony_remote_ble.DeviceInfo) error {
+		timing := a.cfg.TimingFor(device.Name, device.AddressStr)
+		if *hold > 0 {
+			timing.FocusHold = *hold
+		}
+
+		if err := client.Press("focus", timing.FocusHold); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.stdout, "Focused %s\n", device.Name)
+		return nil
+	})
+}
+
+func runSend(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	hexCode := fs.String("hex", "", "command frame as hex, e.g. 0107")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+
+	code, err := parseHex(*hexCode)
+	if err != nil {
+		return usageError("invalid --hex: %v", err)
+	}
+	cmd := sony_remote_ble.SonyCommand{
+		Name: "Raw " + hex.EncodeToString(code),
+		Code: code,
+	}
+
+	return a.withCamera(flags, func(client *sony_remote_ble.Client, device sony_remote_ble.DeviceInfo) error {
+		if err := client.SendCommand(cmd); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.stdout, "Sent %s to %s\n", hex.EncodeToString(code), device.Name)
+		return nil
+	})
+}
+
+func runBulb(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 1, "an exposure time such as 30s"); err != nil {
+		return err
+	}
+	exposure, err := parseExposure(rest[0])
+	if err != nil {
+		return usageError("invalid exposure %q: %v", rest[0], err)
+	}
+
+	return a.withCamera(flags, func(client *sony_remote_ble.Client, device sony_remote_ble.DeviceInfo) error {
+		fmt.Fprintf(a.stdout, "Exposing for %s on %s...\n", exposure, device.Name)
+		if err := client.Bulb(exposure); err != nil {
+			return err
+		}
+		fmt.Fprintln(a.stdout, "Bulb exposure complete")
+		return nil
+	})
+}
+
+func runInfo(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	asJSON := fs.Bool("json", false, "print details as JSON")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+
+	return a.withCamera(flags, func(client *sony_remote_ble.Client, device sony_remote_ble.DeviceInfo) error {
+		info := infoJSON{
+			deviceJSON:     deviceJSON{Name: device.Name, Address: device.AddressStr, RSSI: device.RSSI},
+			State:          client.State().String(),
+			Service:        sony_remote_ble.SonyServiceUUID,
+			Characteristic: sony_remote_ble.CommandCharUUID,
+		}
+		if *asJSON {
+			return a.printJSON(info)
+		}
+
+		tw := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Name:\t%s\n", info.Name)
+		fmt.Fprintf(tw, "Address:\t%s\n", info.Address)
+		fmt.Fprintf(tw, "RSSI:\t%d dBm\n", info.RSSI)
+		fmt.Fprintf(tw, "State:\t%s\n", info.State)
+		fmt.Fprintf(tw, "Service:\t%s\n", info.Service)
+		fmt.Fprintf(tw, "Characteristic:\t%s\n", info.Characteristic)
+		return tw.Flush()
+	})
+}
+
+// adapterJSON is the JSON representation of a Bluetooth adapter printed by adapters.
+type adapterJSON struct {
+	ID      string `json:"id"`
+	Address string `json:"address,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Powered bool   `json:"powered"`
+	Default bool   `json:"default"`
+}
+
+func runAdapters(a *app, args []string) error {
+	fs := a.newFlagSet()
+	asJSON := fs.Bool("json", false, "print adapters as JSON")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+
+	adapters, err := sony_remote_ble.ListAdapters()
+	if err != nil {
+		return err
+	}
+
+	list := make([]adapterJSON, 0, len(adapters))
+	for _, adapter := range adapters {
+		list = append(list, adapterJSON(adapter))
+	}
+	if *asJSON {
+		return a.printJSON(list)
+	}
+
+	tw := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tADDRESS\tNAME\tPOWERED\tDEFAULT")
+	for _, adapter := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%t\n", adapter.ID, adapter.Address, adapter.Name, adapter.Powered, adapter.Default)
+	}
+	return tw.Flush()
+}
+
+func (a *app) printJSON(v any) error {
+	enc := json.NewEncoder(a.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// parseHex decodes a command frame, ignoring an optional 0x prefix and
+// common byte separators such as spaces and colons.
+func parseHex(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.ToLower(s), "0x")
+	s = strings.NewReplacer(" ", "", ":", "", "-", "").Replace(s)
+	if s == "" {
+		return nil, fmt.Errorf("empty command")
+	}
+	return hex.DecodeString(s)
+}
+
+// parseExposure accepts a Go duration ("30s", "2m") or a plain number of seconds.
+func parseExposure(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if seconds, floatErr := strconv.ParseFloat(s, 64); floatErr == nil {
+		d, err = time.Duration(seconds*float64(time.Second)), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("exposure must be positive")
+	}
+	return d, nil
+}
@@ -0,0 +1,233 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/control"
+	"github.com/smazurov/sony_remote_ble/internal/daemon"
+	"github.com/smazurov/sony_remote_ble/internal/systemd"
+)
+
+// socketPath returns the daemon socket from the flag, the configuration or the default.
+func (a *app) socketPath(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	if a.cfg.Daemon.Socket != "" {
+		return a.cfg.Daemon.Socket
+	}
+	return daemon.DefaultSocketPath()
+}
+
+// dialDaemon connects to the daemon when one is running, and returns nil
+// otherwise or when clients reach simulated or replayed cameras.
+func (a *app) dialDaemon(socket string) *daemon.Client {
+	if a.clients.Virtual() {
+		return nil
+	}
+	client, err := daemon.Dial(a.socketPath(socket))
+	if err != nil {
+		a.logger.Debug("daemon not running", "error", err)
+		return nil
+	}
+	return client
+}
+
+func runDaemon(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	socket := fs.String("socket", "", "Unix socket to listen on (default "+daemon.DefaultSocketPath()+")")
+	queueTimeout := fs.Duration("queue-timeout", 30*time.Second, "how long a request waits for the camera before failing as busy")
+	linkGrace := fs.Duration("link-grace", 2*time.Minute, "how long the camera may stay disconnected before the systemd watchdog stops being pinged (0 disables)")
+	metricsAddr := metricsFlag(fs)
+	keepAwake := keepAwakeFlag(fs, a.cfg)
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+
+	sess, err := a.newSession()
+	if err != nil {
+		return err
+	}
+	sess.SetQueueTimeout(*queueTimeout)
+
+	ln, err := a.daemonListener(*socket)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	defer sess.Disconnect(context.Background())
+	defer a.serveMetrics(ctx, stop, sess, *metricsAddr)()
+	a.keepAwake(ctx, sess, *keepAwake)
+
+	return daemon.New(sess, daemon.Options{
+		Device:      flags.device,
+		ScanTimeout: flags.scanTimeout,
+		LinkGrace:   *linkGrace,
+	}, a.logger).Serve(ctx, ln)
+}
+
+// daemonListener returns the socket passed by systemd socket activation, or
+// creates one when the daemon was started directly.
+func (a *app) daemonListener(socket string) (net.Listener, error) {
+	listeners, err := systemd.Listeners()
+	if err != nil {
+		return nil, err
+	}
+	if len(listeners) == 0 {
+		return daemon.Listen(a.socketPath(socket))
+	}
+	for _, ln := range listeners[1:] {
+		a.logger.Warn("ignoring extra socket passed by systemd", "addr", ln.Addr().String())
+		ln.Close()
+	}
+	return listeners[0], nil
+}
+
+func runCtl(a *app, args []string) error {
+	fs := a.newFlagSet()
+	socket := fs.String("socket", "", "Unix socket of the daemon (default "+daemon.DefaultSocketPath()+")")
+	asJSON := fs.Bool("json", false, "print replies and events as JSON")
+	timeout := fs.String("timeout", "", "scan timeout for scan and connect")
+	hold := fs.String("hold", "", "how long press holds the button")
+	delay := fs.String("delay", "", "pause between the commands of a sequence")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return usageError("expected an action: %s or events", strings.Join(control.Actions, ", "))
+	}
+
+	req := control.Request{Action: rest[0], Timeout: *timeout, Hold: *hold, Delay: *delay}
+	params := rest[1:]
+	switch req.Action {
+	case "connect":
+		if len(params) > 1 {
+			return usageError("connect takes at most one camera")
+		}
+		if len(params) == 1 {
+			req.Device = params[0]
+		}
+	case "press":
+		if len(params) != 1 {
+			return usageError("press expects a button name such as c1")
+		}
+		req.Button = params[0]
+	case "sequence":
+		if len(params) == 0 {
+			return usageError("sequence expects command names or hex frames")
+		}
+		req.Commands = params
+	default:
+		if len(params) > 0 {
+			return usageError("%s takes no arguments", req.Action)
+		}
+	}
+
+	client, err := daemon.Dial(a.socketPath(*socket))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if req.Action == "events" {
+		enc := json.NewEncoder(a.stdout)
+		return client.Subscribe(ctx, func(ev control.Event) {
+			if *asJSON {
+				enc.Encode(ev)
+				return
+			}
+			fmt.Fprintln(a.stdout, describeEvent(ev))
+		})
+	}
+
+	reply, err := client.Call(ctx, req, nil)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if err := a.printJSON(reply); err != nil {
+			return err
+		}
+	}
+	if !reply.OK {
+		return fmt.Errorf("%s", reply.Error)
+	}
+	if *asJSON {
+		return nil
+	}
+
+	if req.Action == "scan" {
+		tw := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tADDRESS\tRSSI\tKNOWN")
+		for _, device := range reply.Devices {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%t\n", device.Name, device.Address, device.RSSI, device.Known)
+		}
+		return tw.Flush()
+	}
+	fmt.Fprintln(a.stdout, describeState(reply.State))
+	return nil
+}
+
+// describeState summarises the daemon state in one line.
+func describeState(state *control.State) string {
+	if state == nil {
+		return ""
+	}
+	s := state.State
+	if state.Device != nil {
+		s += fmt.Sprintf(": %s (%s)", state.Device.Name, state.Device.Address)
+	}
+	if state.Recording != nil && *state.Recording {
+		s += " [recording]"
+	}
+	if state.Busy {
+		s += " [busy]"
+	}
+	return s
+}
+
+// describeEvent formats an event for humans, e.g. "12:04:05.120 #42 state_changed Connecting -> Connected".
+func describeEvent(ev control.Event) string {
+	line := fmt.Sprintf("%s #%d %s", ev.Time.Local().Format("15:04:05.000"), ev.Seq, ev.Type)
+	switch {
+	case ev.State != "":
+		line += fmt.Sprintf(" %s -> %s", ev.Previous, ev.State)
+	case ev.Device != nil:
+		line += fmt.Sprintf(" %s (%s) %d dBm", ev.Device.Name, ev.Device.Address, ev.Device.RSSI)
+	case ev.Command != "":
+		line += fmt.Sprintf(" %s [%s]", ev.Command, ev.Bytes)
+		if ev.LatencyMS != nil {
+			line += fmt.Sprintf(" %.1fms", *ev.LatencyMS)
+		}
+		if ev.ReconnectMS != nil {
+			line += fmt.Sprintf(" after %.0fms reconnect", *ev.ReconnectMS)
+		}
+	case ev.Notification != nil:
+		line += " " + ev.Notification.Message
+	}
+	if ev.Error != "" {
+		line += ": " + ev.Error
+	}
+	return line
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/smazurov/sony_remote_ble/internal/known"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// newSession creates a client and wraps it in a session that applies the
+// configuration and records connected cameras as known.
+func (a *app) newSession() (*session.Session, error) {
+	client, err := a.clients.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	client.SetLogger(a.logger.With("component", "ble"))
+
+	// Failing to load known cameras only means the camera is not remembered
+	var store *known.Store
+	if path, err := known.DefaultPath(); err == nil && !a.clients.Virtual() {
+		if store, err = known.Open(path); err != nil {
+			a.logger.Warn("known cameras unavailable", "error", err)
+		}
+	}
+
+	return session.New(client, a.cfg, store, a.logger), nil
+}
+
+// connect finds the camera selected by the device flags and connects to it.
+// The caller is responsible for disconnecting the returned session.
+func (a *app) connect(flags deviceFlags) (*session.Session, sony_remote_ble.DeviceInfo, error) {
+	sess, err := a.newSession()
+	if err != nil {
+		return nil, sony_remote_ble.DeviceInfo{}, err
+	}
+
+	device, err := sess.Connect(context.Background(), flags.device, flags.scanTimeout)
+	switch {
+	case errors.Is(err, session.ErrNotFound):
+		return nil, device, &exitError{code: ExitNotFound, err: err}
+	case errors.Is(err, session.ErrConnect):
+		return nil, device, &exitError{code: ExitConnect, err: err}
+	case err != nil:
+		return nil, device, err
+	}
+
+	return sess, device, nil
+}
+
+// withCamera connects to the selected camera, runs fn and disconnects again.
+func (a *app) withCamera(flags deviceFlags, fn func(client *sony_remote_ble.Client, device sony_remote_ble.DeviceInfo) error) error {
+	sess, device, err := a.connect(flags)
+	if err != nil {
+		return err
+	}
+	defer sess.Disconnect(context.Background())
+
+	return sess.Do(context.Background(), func(client *sony_remote_ble.Client) error {
+		if flags.commandDelay > 0 {
+			client.SetCommandDelay(flags.commandDelay)
+		}
+		return fn(client, device)
+	})
+}
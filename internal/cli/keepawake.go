@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+)
+
+// keepAwakeFlag registers the --keep-awake flag of the long-running
+// subcommands, which defaults to the configured interval when keep-awake is enabled.
+func keepAwakeFlag(fs *flag.FlagSet, cfg *config.Config) *time.Duration {
+	var interval time.Duration
+	if cfg.KeepAwake.Enabled {
+		interval = cfg.KeepAwake.Interval
+	}
+	return fs.Duration("keep-awake", interval, "signal the camera after this long without commands so it stays awake (0 disables)")
+}
+
+// keepAwake signals the camera of sess whenever it was idle for interval,
+// in the background until ctx ends. A zero interval does nothing.
+func (a *app) keepAwake(ctx context.Context, sess *session.Session, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	opts := a.cfg.KeepAwake.Options()
+	opts.Interval = interval
+	a.logger.Info("keep-awake enabled", "interval", interval, "signal", opts.Signal.String())
+	go sess.KeepAwake(ctx, opts)
+}
@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/lansync"
+)
+
+// reportTimeout is how long the leader waits for followers to report after the fire time.
+const reportTimeout = 10 * time.Second
+
+// fireJSON is the JSON representation of a fire printed by leader.
+type fireJSON struct {
+	ID        uint64           `json:"id"`
+	Action    string           `json:"action"`
+	At        time.Time        `json:"at"`
+	SkewMS    float64          `json:"skew_ms"`
+	Followers []fireReportJSON `json:"followers"`
+}
+
+type fireReportJSON struct {
+	Name   string     `json:"name"`
+	At     *time.Time `json:"at,omitempty"`
+	LateMS float64    `json:"late_ms"`
+	Error  string     `json:"error,omitempty"`
+}
+
+func runLeader(a *app, args []string) error {
+	fs := a.newFlagSet()
+	lan := a.cfg.LAN
+	fs.StringVar(&lan.Listen, "listen", lan.Listen, "TCP address to accept followers on")
+	fs.DurationVar(&lan.Lead, "lead", lan.Lead, "how far ahead of the fire time triggers are sent")
+	expect := fs.Int("expect", 0, "wait for this many followers before reading actions")
+	asJSON := fs.Bool("json", false, "print fire results as JSON")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+
+	// Flags may have replaced validated settings from the file
+	cfg := *a.cfg
+	cfg.LAN = lan
+	if err := cfg.Validate(); err != nil {
+		return &exitError{code: ExitUsage, err: err}
+	}
+
+	ln, err := net.Listen("tcp", lan.Listen)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	leader := lansync.NewLeader(lansync.Options{Lead: lan.Lead, ReportTimeout: reportTimeout}, a.logger)
+	served := make(chan error, 1)
+	go func() { served <- leader.Serve(ctx, ln) }()
+	defer func() {
+		stop()
+		<-served
+	}()
+
+	if *expect > 0 {
+		fmt.Fprintf(a.stderr, "Waiting for %d follower(s) on %s...\n", *expect, ln.Addr())
+		if err := leader.WaitFollowers(ctx, *expect); err != nil {
+			return nil
+		}
+	}
+
+	// Actions are read from stdin, one per line, until it ends
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(a.stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	failed := 0
+	for {
+		var line string
+		var ok bool
+		select {
+		case line, ok = <-lines:
+		case err := <-served:
+			served <- err
+			return err
+		case <-ctx.Done():
+			ok = false
+		}
+		if !ok {
+			break
+		}
+
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "followers":
+			a.printFollowers(leader.Followers())
+		case slices.Contains(lansync.Actions, fields[0]):
+			result, err := leader.Fire(ctx, fields[0])
+			if errors.Is(err, lansync.ErrNoFollowers) {
+				fmt.Fprintf(a.stderr, "sony-remote leader: %v\n", err)
+				failed++
+				continue
+			}
+			if err != nil {
+				failed++
+			}
+			if err := a.printFire(result, *asJSON); err != nil {
+				return err
+			}
+		default:
+			fmt.Fprintf(a.stderr, "sony-remote leader: unknown action %q (expected followers, %s)\n",
+				fields[0], strings.Join(lansync.Actions, " or "))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d fire(s) failed", failed)
+	}
+	return nil
+}
+
+// printFollowers prints the registered followers and their clock offsets.
+func (a *app) printFollowers(followers []lansync.FollowerInfo) {
+	tw := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tADDRESS\tDEVICE\tOFFSET\tRTT")
+	for _, f := range followers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%v\t%v\n", f.Name, f.Addr, f.Device, f.Offset, f.RTT)
+	}
+	tw.Flush()
+}
+
+// printFire prints when each follower fired.
+func (a *app) printFire(result lansync.FireResult, asJSON bool) error {
+	if asJSON {
+		out := fireJSON{
+			ID:        result.ID,
+			Action:    result.Action,
+			At:        result.At,
+			SkewMS:    durationMS(result.Skew()),
+			Followers: make([]fireReportJSON, len(result.Reports)),
+		}
+		for i, report := range result.Reports {
+			out.Followers[i] = fireReportJSON{Name: report.Name}
+			if report.Err != nil {
+				out.Followers[i].Error = report.Err.Error()
+				continue
+			}
+			at := report.At
+			out.Followers[i].At = &at
+			out.Followers[i].LateMS = durationMS(report.Late)
+		}
+		return a.printJSON(out)
+	}
+
+	fmt.Fprintf(a.stdout, "%s #%d at %s, skew %v\n", result.Action, result.ID, result.At.Format("15:04:05.000000"), result.Skew())
+	tw := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tFIRED\tLATE\tERROR")
+	for _, report := range result.Reports {
+		if report.Err != nil {
+			fmt.Fprintf(tw, "%s\t-\t-\t%v\n", report.Name, report.Err)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%+v\t\n", report.Name, report.At.Format("15:04:05.000000"), report.Late)
+	}
+	return tw.Flush()
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func runFollow(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	lan := a.cfg.LAN
+	fs.StringVar(&lan.Leader, "leader", lan.Leader, "host:port of the leader")
+	fs.StringVar(&lan.Name, "name", lan.Name, "name to register with (default host name)")
+	metricsAddr := metricsFlag(fs)
+	keepAwake := keepAwakeFlag(fs, a.cfg)
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+	if lan.Leader == "" {
+		return usageError("--leader is required (or set lan.leader)")
+	}
+
+	cfg := *a.cfg
+	cfg.LAN = lan
+	if err := cfg.Validate(); err != nil {
+		return &exitError{code: ExitUsage, err: err}
+	}
+	if lan.Name == "" {
+		if lan.Name, err = os.Hostname(); err != nil {
+			return usageError("--name is required: %v", err)
+		}
+	}
+
+	sess, err := a.newSession()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	defer sess.Disconnect(context.Background())
+	defer a.serveMetrics(ctx, stop, sess, *metricsAddr)()
+	a.keepAwake(ctx, sess, *keepAwake)
+
+	// Connecting up front keeps the scan out of the first fire; fires
+	// connect on demand if this fails or the camera drops out later
+	if device, err := sess.Connect(ctx, flags.device, flags.scanTimeout); err != nil {
+		a.logger.Warn("initial connection failed", "device", flags.device, "error", err)
+	} else {
+		a.logger.Info("connected", "name", device.Name, "address", device.AddressStr)
+	}
+
+	return lansync.NewFollower(sess, lansync.FollowerOptions{
+		Leader:      lan.Leader,
+		Name:        lan.Name,
+		Device:      flags.device,
+		ScanTimeout: flags.scanTimeout,
+	}, a.logger).Run(ctx)
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"context"
+	"flag"
+
+	"github.com/smazurov/sony_remote_ble/internal/metrics"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+)
+
+// metricsFlag registers --metrics for commands without an HTTP server of their own.
+func metricsFlag(fs *flag.FlagSet) *string {
+	return fs.String("metrics", "", "address to serve Prometheus metrics on, e.g. :9100 (default off)")
+}
+
+// serveMetrics starts collecting metrics for sess and serves them on addr in
+// the background. A server that cannot listen cancels the command through stop.
+// The returned function stops collecting.
+func (a *app) serveMetrics(ctx context.Context, stop context.CancelFunc, sess *session.Session, addr string) func() {
+	if addr == "" {
+		return func() {}
+	}
+	m := metrics.New(sess)
+	go func() {
+		if err := m.ListenAndServe(ctx, addr, a.logger); err != nil {
+			a.logger.Error("metrics server failed", "addr", addr, "error", err)
+			stop()
+		}
+	}()
+	return m.Close
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/smazurov/sony_remote_ble/internal/mqttbridge"
+)
+
+func runMQTT(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	mqttCfg := a.cfg.MQTT
+	fs.StringVar(&mqttCfg.Broker, "broker", mqttCfg.Broker, "MQTT broker URL")
+	fs.StringVar(&mqttCfg.Username, "username", mqttCfg.Username, "MQTT username")
+	fs.StringVar(&mqttCfg.TopicPrefix, "topic-prefix", mqttCfg.TopicPrefix, "first level of the command and state topics")
+	fs.BoolVar(&mqttCfg.Discovery, "discovery", mqttCfg.Discovery, "publish Home Assistant discovery payloads")
+	name := fs.String("name", "", "camera name used in topics (default: the --device value)")
+	metricsAddr := metricsFlag(fs)
+	keepAwake := keepAwakeFlag(fs, a.cfg)
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+	if flags.device == "" {
+		return usageError("--device or default_camera is required so the bridge knows which camera to control")
+	}
+	if *name == "" {
+		*name = flags.device
+	}
+
+	// Flags may have replaced validated settings from the file
+	cfg := *a.cfg
+	cfg.MQTT = mqttCfg
+	if err := cfg.Validate(); err != nil {
+		return &exitError{code: ExitUsage, err: err}
+	}
+
+	sess, err := a.newSession()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	defer sess.Disconnect(context.Background())
+	defer a.serveMetrics(ctx, stop, sess, *metricsAddr)()
+	a.keepAwake(ctx, sess, *keepAwake)
+
+	bridge := mqttbridge.New(sess, mqttbridge.Options{
+		MQTT:        mqttCfg,
+		Camera:      *name,
+		Device:      flags.device,
+		ScanTimeout: flags.scanTimeout,
+	}, a.logger, a.version)
+	return bridge.Run(ctx)
+}
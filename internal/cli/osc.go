@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/smazurov/sony_remote_ble/internal/osc"
+)
+
+func runOSC(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	oscCfg := a.cfg.OSC
+	fs.StringVar(&oscCfg.Listen, "listen", oscCfg.Listen, "UDP address to receive OSC messages on")
+	fs.StringVar(&oscCfg.ReplyTo, "reply-to", oscCfg.ReplyTo, "host:port to send replies and status broadcasts to")
+	metricsAddr := metricsFlag(fs)
+	keepAwake := keepAwakeFlag(fs, a.cfg)
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+
+	// Flags may have replaced validated settings from the file
+	cfg := *a.cfg
+	cfg.OSC = oscCfg
+	if err := cfg.Validate(); err != nil {
+		return &exitError{code: ExitUsage, err: err}
+	}
+
+	sess, err := a.newSession()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	defer sess.Disconnect(context.Background())
+	defer a.serveMetrics(ctx, stop, sess, *metricsAddr)()
+	a.keepAwake(ctx, sess, *keepAwake)
+
+	// Connecting up front avoids a scan delaying the first cue; commands
+	// connect on demand if this fails or the camera drops out later
+	if flags.device != "" {
+		if device, err := sess.Connect(ctx, flags.device, flags.scanTimeout); err != nil {
+			a.logger.Warn("initial connection failed", "device", flags.device, "error", err)
+		} else {
+			a.logger.Info("connected", "name", device.Name, "address", device.AddressStr)
+		}
+	}
+
+	return osc.New(sess, osc.Options{
+		OSC:         oscCfg,
+		Device:      flags.device,
+		ScanTimeout: flags.scanTimeout,
+	}, a.logger).ListenAndServe(ctx)
+}
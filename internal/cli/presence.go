@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/smazurov/sony_remote_ble/internal/presence"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+func runPresence(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	presenceCfg := a.cfg.Presence
+	near := fs.Int("near", int(presenceCfg.Near), "smoothed RSSI in dBm at which a camera is near")
+	far := fs.Int("far", int(presenceCfg.Far), "smoothed RSSI in dBm below which a near camera is far again")
+	asJSON := fs.Bool("json", false, "print events as JSON lines")
+	metricsAddr := metricsFlag(fs)
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+
+	// Flags may have replaced validated settings from the file
+	presenceCfg.Near, presenceCfg.Far = int16(*near), int16(*far)
+	cfg := *a.cfg
+	cfg.Presence = presenceCfg
+	if err := cfg.Validate(); err != nil {
+		return &exitError{code: ExitUsage, err: err}
+	}
+	if len(presenceCfg.Rules) == 0 {
+		a.logger.Info("no presence rules configured, only printing events")
+	}
+
+	sess, err := a.newSession()
+	if err != nil {
+		return err
+	}
+	// Scanning ties up a client, so the watcher gets one of its own
+	var scanner *sony_remote_ble.Client
+	err = sess.Do(context.Background(), func(client *sony_remote_ble.Client) error {
+		scanner, err = a.clients.NewScanner(client)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	scanner.SetLogger(a.logger.With("component", "scan"))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	defer sess.Disconnect(context.Background())
+	defer a.serveMetrics(ctx, stop, sess, *metricsAddr)()
+
+	if flags.device != "" {
+		if device, err := sess.Connect(ctx, flags.device, flags.scanTimeout); err != nil {
+			a.logger.Warn("initial connection failed", "device", flags.device, "error", err)
+		} else {
+			a.logger.Info("connected", "name", device.Name, "address", device.AddressStr)
+		}
+	}
+
+	enc := json.NewEncoder(a.stdout)
+	watcher := presence.New(sess, scanner, presenceCfg, a.logger)
+	return watcher.Run(ctx, func(ev sony_remote_ble.PresenceEvent) {
+		if *asJSON {
+			enc.Encode(presence.EventOf(ev))
+			return
+		}
+		fmt.Fprintln(a.stdout, describePresence(ev))
+	})
+}
+
+// describePresence formats a presence event for humans, e.g.
+// "12:04:05.120 near ILCE-7M4 (AA:BB:CC:DD:EE:FF) -58 dBm".
+func describePresence(ev sony_remote_ble.PresenceEvent) string {
+	return fmt.Sprintf("%s %s %s (%s) %.0f dBm",
+		ev.Time.Local().Format("15:04:05.000"), ev.Type, ev.Device.Name, ev.Device.AddressStr, ev.RSSI)
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/server"
+)
+
+func runServe(a *app, args []string) error {
+	var flags deviceFlags
+	fs := a.newFlagSet()
+	flags.register(fs, a.cfg)
+	addr := fs.String("http", ":8080", "address for the REST API to listen on")
+	queueTimeout := fs.Duration("queue-timeout", 5*time.Second, "how long a request waits for the camera before failing as busy")
+	allowOrigin := fs.String("allow-origin", "", "comma-separated browser origins besides the server's own allowed to open the event WebSocket")
+	keepAwake := keepAwakeFlag(fs, a.cfg)
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(rest, 0, ""); err != nil {
+		return err
+	}
+
+	sess, err := a.newSession()
+	if err != nil {
+		return err
+	}
+	sess.SetQueueTimeout(*queueTimeout)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Create the server first so that its metrics see the initial connection
+	srv := server.New(sess, a.logger, a.version)
+	if *allowOrigin != "" {
+		srv.SetAllowedOrigins(strings.Split(*allowOrigin, ","))
+	}
+
+	// Connecting up front is a convenience; clients can still POST /connect later
+	if flags.device != "" {
+		if device, err := sess.Connect(ctx, flags.device, flags.scanTimeout); err != nil {
+			a.logger.Warn("initial connection failed", "device", flags.device, "error", err)
+		} else {
+			a.logger.Info("connected", "name", device.Name, "address", device.AddressStr)
+		}
+	}
+	defer sess.Disconnect(context.Background())
+	a.keepAwake(ctx, sess, *keepAwake)
+
+	return srv.ListenAndServe(ctx, *addr)
+}
@@ -0,0 +1,514 @@
+// Package config loads the sony-remote configuration file.
+//
+// The file lives at $XDG_CONFIG_HOME/sony-remote/config.toml (falling back to
+// ~/.config/sony-remote/config.toml) and is optional; a missing file yields the
+// built-in defaults. Values from the file can be overridden by environment
+// variables and, for the CLI, by command-line flags.
+//
+// Example file:
+//
+//	default_camera = "studio"
+//
+//	[aliases]
+//	studio = "AA:BB:CC:DD:EE:FF"
+//
+//	[scan]
+//	timeout = "20s"
+//
+//	[timing]
+//	command_delay = "50ms"
+//	zoom_speed = 32
+//	zoom_hold = "500ms"
+//	focus_hold = "500ms"
+//
+//	[cameras.studio]
+//	command_delay = "120ms"
+//
+//	[cameras."ILCE-7M3"]
+//	release_gap = "150ms"
+//	adaptive = true
+//
+//	[auto_connect]
+//	enabled = true
+//	camera = "studio"
+//
+//	[keys]
+//	shutter = ["s", "1"]
+//
+//	[log]
+//	level = "debug"
+//	file = "/tmp/sony-remote.log"
+//
+//	[mqtt]
+//	broker = "tcp://localhost:1883"
+//	username = "camera"
+//	topic_prefix = "sony"
+//
+//	[osc]
+//	listen = ":53000"
+//	reply_to = "192.168.1.20:53001"
+//
+//	[osc.addresses]
+//	"/cue/shoot" = "shoot"
+//	"/cue/c1" = "button c1"
+//
+//	[daemon]
+//	socket = "/run/sony-remote/control.sock"
+//
+//	[lan]
+//	leader = "192.168.1.10:47800"
+//	name = "left"
+//
+//	[keep_awake]
+//	interval = "20s"
+//
+//	[idle_disconnect]
+//	after = "5m"
+//
+//	[presence]
+//	near = -60
+//	far = -75
+//
+//	[[presence.rules]]
+//	camera = "studio"
+//	on = "near"
+//	action = "connect"
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/smazurov/sony_remote_ble/internal/systemd"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// Environment variables that override values from the configuration file.
+const (
+	EnvConfig       = "SONY_REMOTE_CONFIG"
+	EnvDevice       = "SONY_REMOTE_DEVICE"
+	EnvScanTimeout  = "SONY_REMOTE_SCAN_TIMEOUT"
+	EnvCommandDelay = "SONY_REMOTE_COMMAND_DELAY"
+	EnvLogLevel     = "SONY_REMOTE_LOG_LEVEL"
+	EnvLogFile      = "SONY_REMOTE_LOG_FILE"
+	EnvLogFormat    = "SONY_REMOTE_LOG_FORMAT"
+	EnvMQTTPassword = "SONY_REMOTE_MQTT_PASSWORD"
+)
+
+// Config is the complete sony-remote configuration.
+type Config struct {
+	// DefaultCamera is the camera name, address or alias used when none is given
+	DefaultCamera string `toml:"default_camera"`
+	// Aliases maps short names to camera addresses
+	Aliases map[string]string `toml:"aliases"`
+	// Scan controls device discovery
+	Scan Scan `toml:"scan"`
+	// Timing holds the command timings used for every camera
+	Timing Timing `toml:"timing"`
+	// Cameras holds per-camera timing overrides keyed by model code, alias,
+	// name or address
+	Cameras map[string]Timing `toml:"cameras"`
+	// AutoConnect controls connecting to a known camera when the TUI starts
+	AutoConnect AutoConnect `toml:"auto_connect"`
+	// Keys maps TUI actions to the keys that trigger them
+	Keys map[string][]string `toml:"keys"`
+	// Log configures diagnostic logging
+	Log Log `toml:"log"`
+	// MQTT configures the MQTT bridge
+	MQTT MQTT `toml:"mqtt"`
+	// OSC configures the OSC server
+	OSC OSC `toml:"osc"`
+	// Daemon configures the daemon and its control socket
+	Daemon Daemon `toml:"daemon"`
+	// LAN configures synchronized triggering across hosts
+	LAN LAN `toml:"lan"`
+	// KeepAwake configures the signals that keep an idle camera from powering off
+	KeepAwake KeepAwake `toml:"keep_awake"`
+	// IdleDisconnect closes the link to an idle camera to save its battery
+	IdleDisconnect IdleDisconnect `toml:"idle_disconnect"`
+	// Presence configures the rules run as cameras come and go
+	Presence Presence `toml:"presence"`
+
+	// path is the file the configuration was loaded from, empty if none
+	path string
+}
+
+// Scan controls device discovery.
+type Scan struct {
+	// Timeout is how long to scan for a camera before giving up
+	Timeout time.Duration `toml:"timeout"`
+}
+
+// Timing holds command timings. In per-camera sections zero values inherit
+// the global setting.
+type Timing struct {
+	// CommandDelay is the pause between commands in sequences such as taking a photo
+	CommandDelay time.Duration `toml:"command_delay"`
+	// ZoomSpeed is the speed byte sent with zoom commands (1-127)
+	ZoomSpeed int `toml:"zoom_speed"`
+	// ZoomHold is how long a zoom command is held by default
+	ZoomHold time.Duration `toml:"zoom_hold"`
+	// FocusHold is how long a focus half-press is held by default
+	FocusHold time.Duration `toml:"focus_hold"`
+	// HalfPressSettle, FullPressHold and ReleaseGap are the pauses after the
+	// half press, full press and full release of a photo; zero uses the
+	// built-in profile of the camera model or the command delay
+	HalfPressSettle time.Duration `toml:"half_press_settle"`
+	FullPressHold   time.Duration `toml:"full_press_hold"`
+	ReleaseGap      time.Duration `toml:"release_gap"`
+	// ZoomStop is the pause after a zoom while the lens stops
+	ZoomStop time.Duration `toml:"zoom_stop"`
+	// Adaptive ends the pauses of a photo early when the camera reports focus
+	// and the shutter; nil inherits the global setting or built-in profile
+	Adaptive *bool `toml:"adaptive"`
+}
+
+// Profile returns the photo and zoom pauses as a timing profile for the client.
+func (t Timing) Profile() sony_remote_ble.TimingProfile {
+	return sony_remote_ble.TimingProfile{
+		HalfPressSettle: t.HalfPressSettle,
+		FullPressHold:   t.FullPressHold,
+		ReleaseGap:      t.ReleaseGap,
+		ZoomStop:        t.ZoomStop,
+		Adaptive:        t.Adaptive != nil && *t.Adaptive,
+	}
+}
+
+// AutoConnect controls connecting to a known camera when the TUI starts.
+type AutoConnect struct {
+	// Enabled starts a scan on launch and connects as soon as the camera advertises
+	Enabled bool `toml:"enabled"`
+	// Camera is the alias, name or address to connect to; empty selects the
+	// most recently connected camera
+	Camera string `toml:"camera"`
+}
+
+// Log configures diagnostic logging.
+type Log struct {
+	// Level is one of debug, info, warn or error
+	Level string `toml:"level"`
+	// File is the path logs are appended to; empty logs to stderr for the CLI
+	// and disables logging for the TUI, which owns the terminal
+	File string `toml:"file"`
+	// Format is text, json or journal. The journal format sends structured
+	// records straight to journald, falling back to text when it is not running
+	Format string `toml:"format"`
+}
+
+// MQTT configures the MQTT bridge started by the mqtt subcommand.
+type MQTT struct {
+	// Broker is the broker URL, e.g. tcp://localhost:1883 or ssl://broker:8883
+	Broker string `toml:"broker"`
+	// Username and Password authenticate with the broker; both may be empty
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	// ClientID identifies the bridge to the broker; empty derives one from the camera name
+	ClientID string `toml:"client_id"`
+	// TopicPrefix is the first level of every command and state topic
+	TopicPrefix string `toml:"topic_prefix"`
+	// Discovery publishes Home Assistant MQTT discovery payloads
+	Discovery bool `toml:"discovery"`
+	// DiscoveryPrefix is the Home Assistant discovery topic prefix
+	DiscoveryPrefix string `toml:"discovery_prefix"`
+}
+
+// Daemon configures the daemon started by the daemon subcommand.
+type Daemon struct {
+	// Socket is the Unix socket the daemon listens on and ctl connects to; empty
+	// selects $XDG_RUNTIME_DIR/sony-remote.sock
+	Socket string `toml:"socket"`
+}
+
+// Default returns the built-in configuration used when no file exists.
+func Default() *Config {
+	return &Config{
+		Aliases: make(map[string]string),
+		Scan: Scan{
+			Timeout: 15 * time.Second,
+		},
+		Timing: Timing{
+			CommandDelay: sony_remote_ble.DefaultCommandDelay,
+			ZoomSpeed:    int(sony_remote_ble.DefaultZoomSpeed),
+			ZoomHold:     500 * time.Millisecond,
+			FocusHold:    500 * time.Millisecond,
+		},
+		Cameras: make(map[string]Timing),
+		Keys:    DefaultKeys(),
+		Log: Log{
+			Level:  "info",
+			Format: "text",
+		},
+		MQTT: MQTT{
+			Broker:          "tcp://localhost:1883",
+			TopicPrefix:     "sony",
+			Discovery:       true,
+			DiscoveryPrefix: "homeassistant",
+		},
+		OSC: OSC{
+			Listen:        ":53000",
+			ReplyAddress:  "/camera/reply",
+			StatusAddress: "/camera/status",
+			Addresses:     DefaultOSCAddresses(),
+		},
+		LAN: LAN{
+			Listen: ":47800",
+			Lead:   500 * time.Millisecond,
+		},
+		KeepAwake: KeepAwake{
+			Interval: sony_remote_ble.DefaultKeepAwakeInterval,
+			Signal:   sony_remote_ble.KeepAwakeHalfPress.String(),
+		},
+		IdleDisconnect: IdleDisconnect{
+			ReconnectTimeout: sony_remote_ble.DefaultReconnectTimeout,
+		},
+		Presence: Presence{
+			Near:      -65,
+			Far:       -80,
+			Smoothing: sony_remote_ble.DefaultPresenceSmoothing,
+			Timeout:   sony_remote_ble.DefaultPresenceTimeout,
+		},
+	}
+}
+
+// DefaultPath returns the location of the configuration file, honouring XDG_CONFIG_HOME.
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot locate config directory: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "sony-remote", "config.toml"), nil
+}
+
+// Load reads the configuration file at path, applies environment overrides and
+// validates the result. An empty path selects SONY_REMOTE_CONFIG or DefaultPath.
+// A missing file is not an error unless the path was given explicitly.
+func Load(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv(EnvConfig)
+		explicit = path != ""
+	}
+	if !explicit {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := Default()
+	if err := cfg.decodeFile(path); err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return cfg.finish()
+		}
+		return nil, err
+	}
+	cfg.path = path
+	return cfg.finish()
+}
+
+// finish applies environment overrides and validates the configuration.
+func (c *Config) finish() (*Config, error) {
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) decodeFile(path string) error {
+	// Keys from the file are merged over the defaults rather than replacing them,
+	// while an OSC address section replaces the default mapping
+	defaultKeys := c.Keys
+	c.Keys = nil
+	defaultOSC := c.OSC.Addresses
+	c.OSC.Addresses = nil
+
+	md, err := toml.DecodeFile(path, c)
+	if err != nil {
+		c.Keys = defaultKeys
+		c.OSC.Addresses = defaultOSC
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return fmt.Errorf("config %s: %s", path, parseErr.ErrorWithPosition())
+		}
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return fmt.Errorf("config %s: unknown setting(s): %s", path, strings.Join(keys, ", "))
+	}
+
+	for action, keys := range c.Keys {
+		defaultKeys[action] = keys
+	}
+	c.Keys = defaultKeys
+	if c.Aliases == nil {
+		c.Aliases = make(map[string]string)
+	}
+	if c.Cameras == nil {
+		c.Cameras = make(map[string]Timing)
+	}
+	if c.OSC.Addresses == nil {
+		c.OSC.Addresses = defaultOSC
+	}
+	return nil
+}
+
+func (c *Config) applyEnv() error {
+	if v := os.Getenv(EnvDevice); v != "" {
+		c.DefaultCamera = v
+	}
+	if v := os.Getenv(EnvScanTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvScanTimeout, err)
+		}
+		c.Scan.Timeout = d
+	}
+	if v := os.Getenv(EnvCommandDelay); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvCommandDelay, err)
+		}
+		c.Timing.CommandDelay = d
+	}
+	if v := os.Getenv(EnvLogLevel); v != "" {
+		c.Log.Level = v
+	}
+	if v := os.Getenv(EnvLogFile); v != "" {
+		c.Log.File = v
+	}
+	if v := os.Getenv(EnvLogFormat); v != "" {
+		c.Log.Format = v
+	}
+	if v := os.Getenv(EnvMQTTPassword); v != "" {
+		c.MQTT.Password = v
+	}
+	return nil
+}
+
+// Path returns the file the configuration was loaded from, or an empty string
+// if the defaults are in use.
+func (c *Config) Path() string {
+	return c.path
+}
+
+// ResolveAlias returns the address for an alias, or the query unchanged if it is not an alias.
+// Alias names are matched case-insensitively.
+func (c *Config) ResolveAlias(query string) string {
+	if address, ok := c.Aliases[query]; ok {
+		return address
+	}
+	for name, address := range c.Aliases {
+		if strings.EqualFold(name, query) {
+			return address
+		}
+	}
+	return query
+}
+
+// TimingFor returns the timings for a camera. The built-in profile of its
+// model fills the pauses the global timings leave unset; then a per-camera
+// section matching its model code, and finally one matching its name, address
+// or an alias of its address, are applied over the result.
+func (c *Config) TimingFor(name, address string) Timing {
+	builtin := sony_remote_ble.ProfileFor(name)
+	timing := Timing{
+		HalfPressSettle: builtin.HalfPressSettle,
+		FullPressHold:   builtin.FullPressHold,
+		ReleaseGap:      builtin.ReleaseGap,
+		ZoomStop:        builtin.ZoomStop,
+		Adaptive:        &builtin.Adaptive,
+	}
+	timing.merge(c.Timing)
+
+	model := sony_remote_ble.ModelFromName(name)
+	for _, key := range sortedKeys(c.Cameras) {
+		if model != "" && strings.EqualFold(key, model) {
+			timing.merge(c.Cameras[key])
+		}
+	}
+	for _, key := range sortedKeys(c.Cameras) {
+		if strings.EqualFold(key, name) || strings.EqualFold(c.ResolveAlias(key), address) {
+			timing.merge(c.Cameras[key])
+		}
+	}
+	return timing
+}
+
+// merge applies the non-zero settings of override, and its adaptive setting
+// if set.
+func (t *Timing) merge(override Timing) {
+	for _, d := range []struct {
+		dst *time.Duration
+		src time.Duration
+	}{
+		{&t.CommandDelay, override.CommandDelay},
+		{&t.ZoomHold, override.ZoomHold},
+		{&t.FocusHold, override.FocusHold},
+		{&t.HalfPressSettle, override.HalfPressSettle},
+		{&t.FullPressHold, override.FullPressHold},
+		{&t.ReleaseGap, override.ReleaseGap},
+		{&t.ZoomStop, override.ZoomStop},
+	} {
+		if d.src != 0 {
+			*d.dst = d.src
+		}
+	}
+	if override.ZoomSpeed != 0 {
+		t.ZoomSpeed = override.ZoomSpeed
+	}
+	if override.Adaptive != nil {
+		t.Adaptive = override.Adaptive
+	}
+}
+
+// NewLogger builds the logger described by the log settings. When no file is
+// configured, records are written to fallback. The returned close function
+// releases the log file and is safe to call when no file was opened.
+func (l Log) NewLogger(fallback io.Writer) (*slog.Logger, func() error, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		return nil, nil, fmt.Errorf("log level: %w", err)
+	}
+
+	w := fallback
+	closeFn := func() error { return nil }
+	if l.File != "" {
+		f, err := os.OpenFile(l.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open log file: %w", err)
+		}
+		w = f
+		closeFn = f.Close
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	switch l.Format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), closeFn, nil
+	case "journal":
+		if h, err := systemd.NewJournalHandler("sony-remote", level); err == nil {
+			return slog.New(h), closeFn, nil
+		}
+	}
+	return slog.New(slog.NewTextHandler(w, opts)), closeFn, nil
+}
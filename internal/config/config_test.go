@@ -0,0 +1,374 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// isolate keeps the environment of the test run out of Load.
+func isolate(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	for _, name := range []string{
+		config.EnvConfig, config.EnvDevice, config.EnvScanTimeout, config.EnvCommandDelay,
+		config.EnvLogLevel, config.EnvLogFile, config.EnvLogFormat, config.EnvMQTTPassword,
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+// write saves content as a configuration file and returns its path.
+func write(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// load loads content as the configuration file, failing the test on error.
+func load(t *testing.T, content string) *config.Config {
+	t.Helper()
+	isolate(t)
+	cfg, err := config.Load(write(t, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	isolate(t)
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("missing default file: %v", err)
+	}
+	if cfg.Path() != "" || cfg.Scan.Timeout != config.Default().Scan.Timeout {
+		t.Errorf("loaded %q with scan timeout %s, want the defaults", cfg.Path(), cfg.Scan.Timeout)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.toml")
+	if _, err := config.Load(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing explicit file: %v, want not exist", err)
+	}
+	t.Setenv(config.EnvConfig, missing)
+	if _, err := config.Load(""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file named by %s: %v, want not exist", config.EnvConfig, err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	isolate(t)
+	path := write(t, `
+default_camera = "studio"
+
+[aliases]
+studio = "C0:FF:EE:00:00:01"
+
+[scan]
+timeout = "20s"
+
+[timing]
+command_delay = "80ms"
+zoom_speed = 64
+`)
+	t.Setenv(config.EnvConfig, path)
+	t.Setenv(config.EnvScanTimeout, "3s")
+	t.Setenv(config.EnvMQTTPassword, "secret")
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Path() != path || cfg.DefaultCamera != "studio" {
+		t.Errorf("loaded %q with default camera %q", cfg.Path(), cfg.DefaultCamera)
+	}
+	if got := cfg.ResolveAlias("STUDIO"); got != "C0:FF:EE:00:00:01" {
+		t.Errorf("alias resolved to %q", got)
+	}
+	if cfg.Scan.Timeout != 3*time.Second || cfg.MQTT.Password != "secret" {
+		t.Errorf("scan timeout %s and password %q, want the environment's", cfg.Scan.Timeout, cfg.MQTT.Password)
+	}
+	if cfg.Timing.CommandDelay != 80*time.Millisecond || cfg.Timing.ZoomSpeed != 64 || cfg.Timing.ZoomHold != 500*time.Millisecond {
+		t.Errorf("timing %+v, want the file's merged over the defaults", cfg.Timing)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name, content, env, error string
+	}{
+		{"syntax", "[scan\ntimeout = 1", "", "At line 2, column 6"},
+		{"unknown setting", "[scan]\ntimeout = \"1s\"\nretries = 3\n", "", "unknown setting(s): scan.retries"},
+		{"wrong type", "[scan]\ntimeout = true\n", "", "timeout"},
+		{"environment", "", config.EnvCommandDelay, config.EnvCommandDelay + ": "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			path := write(t, tt.content)
+			if tt.env != "" {
+				t.Setenv(tt.env, "soon")
+			}
+			_, err := config.Load(path)
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("error %v, want one mentioning %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		content, problem string
+	}{
+		{"[aliases]\n\"\" = \"C0:FF:EE:00:00:01\"", "aliases: alias names must not be empty"},
+		{"[aliases]\nstudio = \"nope\"", `aliases.studio: "nope" is not a Bluetooth address (expected AA:BB:CC:DD:EE:FF or a UUID)`},
+		{"[scan]\ntimeout = \"0s\"", "scan.timeout: must be greater than zero"},
+		{"[timing]\ncommand_delay = \"-1ms\"", "timing.command_delay: must not be negative"},
+		{"[timing]\nzoom_speed = 0", "timing.zoom_speed: 0 must be between 1 and 127"},
+		{"[cameras.studio]\nzoom_speed = 200", "cameras.studio.zoom_speed: 200 must be between 1 and 127"},
+		{"[cameras.studio]\nrelease_gap = \"-5ms\"", "cameras.studio.release_gap: must not be negative"},
+		{"[keys]\nteleport = [\"t\"]", "keys.teleport: unknown action"},
+		{"[keys]\nshutter = []", "keys.shutter: at least one key is required"},
+		{"[keys]\nshutter = [\"\"]", "keys.shutter: key names must not be empty"},
+		{"[keys]\nfocus = [\"s\"]", `keys.shutter: "s" is already bound to focus on the control screen`},
+		{"[log]\nlevel = \"loud\"", `log.level: "loud" must be one of debug, info, warn or error`},
+		{"[log]\nformat = \"xml\"", `log.format: "xml" must be text, json or journal`},
+		{"[log]\nformat = \"journal\"\nfile = \"/tmp/sony-remote.log\"", "log.file: cannot be combined with the journal format"},
+		{"[mqtt]\nbroker = \"localhost\"", `mqtt.broker: "localhost" is not a broker URL (expected e.g. tcp://localhost:1883)`},
+		{"[mqtt]\nbroker = \"http://localhost:1883\"", `mqtt.broker: unsupported scheme "http"`},
+		{"[mqtt]\ntopic_prefix = \"sony/#\"", `mqtt.topic_prefix: "sony/#" must be non-empty and must not contain + or #`},
+		{"[mqtt]\ndiscovery_prefix = \"\"", `mqtt.discovery_prefix: "" must be non-empty and must not contain + or #`},
+		{"[osc]\nlisten = \"53000\"", `osc.listen: "53000" must be host:port, e.g. :53000`},
+		{"[osc]\nreply_to = \":53001\"", `osc.reply_to: ":53001" must be host:port`},
+		{"[osc]\nreply_address = \"reply\"", `osc.reply_address: "reply" must start with /`},
+		{"[osc]\nstatus_address = \"status\"", `osc.status_address: "status" must start with /`},
+		{"[osc.addresses]\n\"cue\" = \"shoot\"", `osc.addresses: "cue" must start with /`},
+		{"[osc.addresses]\n\"/cue/*/go\" = \"shoot\"", `osc.addresses: "/cue/*/go" may only use * as its last segment`},
+		{"[osc.addresses]\n\"/cue\" = \"dance\"", `osc.addresses."/cue": unknown action "dance" (expected one of ` + strings.Join(config.OSCActions, ", ") + ")"},
+		{"[osc.addresses]\n\"/cue\" = \"button\"", `osc.addresses."/cue": button needs a name, e.g. "button c1", or an address ending in /*`},
+		{"[osc.addresses]\n\"/cue\" = \"shoot now\"", `osc.addresses."/cue": shoot does not take an argument`},
+		{"[lan]\nlisten = \"47800\"", `lan.listen: "47800" must be host:port, e.g. :47800`},
+		{"[lan]\nleader = \":47800\"", `lan.leader: ":47800" must be host:port`},
+		{"[lan]\nlead = \"2m\"", "lan.lead: must be greater than zero and at most 1m0s"},
+		{"[keep_awake]\ninterval = \"0s\"", "keep_awake.interval: must be greater than zero"},
+		{"[keep_awake]\nsignal = \"wave\"", `keep_awake.signal: "wave" must be one of half_press, release`},
+		{"[idle_disconnect]\nafter = \"-1s\"", "idle_disconnect.after: must not be negative"},
+		{"[idle_disconnect]\nreconnect_timeout = \"0s\"", "idle_disconnect.reconnect_timeout: must be greater than zero"},
+		{"[keep_awake]\nenabled = true\ninterval = \"20s\"\n[idle_disconnect]\nafter = \"5m\"", "idle_disconnect.after: 5m0s never passes while keep_awake signals the camera every 20s"},
+		{"[presence]\nnear = 0", "presence.near: 0 must be a signal strength between -127 and -1 dBm"},
+		{"[presence]\nfar = -60", "presence.far: -60 must be between -127 dBm and presence.near"},
+		{"[presence]\nsmoothing = 1.5", "presence.smoothing: must be greater than zero and at most 1"},
+		{"[presence]\ntimeout = \"0s\"", "presence.timeout: must be greater than zero"},
+		{"[[presence.rules]]\non = \"close\"\naction = \"connect\"", `presence.rules[0].on: "close" must be one of appeared, far, gone, near`},
+		{"[[presence.rules]]\non = \"gone\"\naction = \"disconnect\"", "presence.rules[0].action: disconnect cannot act on a gone camera, whose link is already lost; use far"},
+		{"[[presence.rules]]\non = \"near\"\naction = \"webhook\"\nurl = \"ftp://nas/hook\"", `presence.rules[0].url: "ftp://nas/hook" must be an http or https URL`},
+		{"[[presence.rules]]\non = \"near\"\naction = \"exec\"\ncommand = \" \"", "presence.rules[0].command: must not be empty"},
+		{"[[presence.rules]]\non = \"near\"\naction = \"dance\"", `presence.rules[0].action: "dance" must be one of connect, disconnect, stop_recording, webhook, exec`},
+		{"[[presence.rules]]\non = \"far\"\naction = \"stop_recording\"", "presence.rules[0].on: far never fires for the connected camera, which stops advertising, unless idle_disconnect.after is set"},
+	}
+	for _, tt := range tests {
+		isolate(t)
+		path := write(t, tt.content)
+		_, err := config.Load(path)
+		var verr *config.ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%q: error %v, want a validation error", tt.content, err)
+			continue
+		}
+		if verr.Path != path || !slices.Equal(verr.Problems, []string{tt.problem}) {
+			t.Errorf("%q: problems %q in %q, want only %q", tt.content, verr.Problems, verr.Path, tt.problem)
+		}
+		if want := "config " + path + ": " + tt.problem; err.Error() != want {
+			t.Errorf("%q: error %q, want %q", tt.content, err.Error(), want)
+		}
+	}
+}
+
+func TestValidationListsEveryProblem(t *testing.T) {
+	isolate(t)
+	t.Setenv(config.EnvLogLevel, "loud")
+	_, err := config.Load(write(t, "[scan]\ntimeout = \"0s\"\n"))
+	var verr *config.ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 2 {
+		t.Fatalf("error %v, want two problems", err)
+	}
+	if lines := strings.Split(err.Error(), "\n  "); len(lines) != 3 || !strings.HasPrefix(lines[1], "scan.timeout") || !strings.HasPrefix(lines[2], "log.level") {
+		t.Errorf("error %q, want one problem per indented line", err.Error())
+	}
+}
+
+func TestLogValidate(t *testing.T) {
+	tests := []struct {
+		log   config.Log
+		error string
+	}{
+		{config.Log{Level: "debug", Format: "json"}, ""},
+		{config.Log{Level: "warn", Format: "journal"}, ""},
+		{config.Log{Level: "loud", Format: "text"}, `log.level: "loud" must be one of debug, info, warn or error`},
+		{config.Log{Level: "info", Format: "xml"}, `log.format: "xml" must be text, json or journal`},
+		{config.Log{Level: "info", Format: "journal", File: "/tmp/sony-remote.log"}, "log.file: cannot be combined with the journal format"},
+	}
+	for _, tt := range tests {
+		err := tt.log.Validate()
+		if tt.error == "" && err != nil || tt.error != "" && (err == nil || err.Error() != tt.error) {
+			t.Errorf("%+v: %v, want %q", tt.log, err, tt.error)
+		}
+	}
+}
+
+func TestKeyBindingsMergeOverDefaults(t *testing.T) {
+	cfg := load(t, `
+[keys]
+shutter = ["b", "space"]
+photo = ["p"]
+`)
+	defaults := config.DefaultKeys()
+	if got := cfg.Keys[config.ActionShutter]; !slices.Equal(got, []string{"b", "space"}) {
+		t.Errorf("shutter bound to %q, want the file's keys", got)
+	}
+	for action, keys := range defaults {
+		if action == config.ActionShutter || action == config.ActionPhoto {
+			continue
+		}
+		if !slices.Equal(cfg.Keys[action], keys) {
+			t.Errorf("%s bound to %q, want the default %q", action, cfg.Keys[action], keys)
+		}
+	}
+	if got := config.KeyName(cfg.Keys[config.ActionShutter][1]); got != " " {
+		t.Errorf("space names key %q", got)
+	}
+	if got := config.Default().Keys[config.ActionShutter]; !slices.Equal(got, defaults[config.ActionShutter]) {
+		t.Errorf("loading changed the defaults to %q", got)
+	}
+}
+
+func TestTimingForPrecedence(t *testing.T) {
+	cfg := load(t, `
+[aliases]
+studio = "C0:FF:EE:00:00:01"
+
+[timing]
+command_delay = "60ms"
+release_gap = "120ms"
+
+[cameras."ILCE-7M3"]
+half_press_settle = "150ms"
+release_gap = "130ms"
+zoom_speed = 40
+
+[cameras."Studio A"]
+release_gap = "140ms"
+
+[cameras.studio]
+full_press_hold = "160ms"
+`)
+	builtin := sony_remote_ble.TimingProfiles["ILCE-7M3"]
+
+	tests := []struct {
+		name, camera, address string
+		want                  config.Timing
+	}{
+		{
+			// Globals only, over the zero profile of an unlisted model
+			name: "unlisted", camera: "ILCE-7M4", address: "C0:FF:EE:00:00:09",
+			want: config.Timing{CommandDelay: 60 * time.Millisecond, ReleaseGap: 120 * time.Millisecond},
+		},
+		{
+			// The built-in profile fills what the globals leave unset
+			name: "built-in", camera: "ILCE-6400", address: "C0:FF:EE:00:00:09",
+			want: config.Timing{
+				CommandDelay:    60 * time.Millisecond,
+				HalfPressSettle: builtin.HalfPressSettle,
+				FullPressHold:   builtin.FullPressHold,
+				ReleaseGap:      120 * time.Millisecond,
+			},
+		},
+		{
+			// The model section applies over the globals
+			name: "model", camera: "ILCE-7M3", address: "C0:FF:EE:00:00:09",
+			want: config.Timing{
+				CommandDelay:    60 * time.Millisecond,
+				ZoomSpeed:       40,
+				HalfPressSettle: 150 * time.Millisecond,
+				FullPressHold:   builtin.FullPressHold,
+				ReleaseGap:      130 * time.Millisecond,
+			},
+		},
+		{
+			// Name and alias sections apply over the model's
+			name: "name and alias", camera: "Studio A", address: "C0:FF:EE:00:00:01",
+			want: config.Timing{
+				CommandDelay:  60 * time.Millisecond,
+				FullPressHold: 160 * time.Millisecond,
+				ReleaseGap:    140 * time.Millisecond,
+			},
+		},
+		{
+			name: "model and alias", camera: "ILCE-7M3", address: "c0:ff:ee:00:00:01",
+			want: config.Timing{
+				CommandDelay:    60 * time.Millisecond,
+				ZoomSpeed:       40,
+				HalfPressSettle: 150 * time.Millisecond,
+				FullPressHold:   160 * time.Millisecond,
+				ReleaseGap:      130 * time.Millisecond,
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := cfg.TimingFor(tt.camera, tt.address)
+		want := tt.want
+		if want.ZoomSpeed == 0 {
+			want.ZoomSpeed = cfg.Timing.ZoomSpeed
+		}
+		want.ZoomHold, want.FocusHold = cfg.Timing.ZoomHold, cfg.Timing.FocusHold
+		if got.Adaptive == nil || *got.Adaptive {
+			t.Errorf("%s: adaptive %v, want the built-in off", tt.name, got.Adaptive)
+		}
+		got.Adaptive = nil
+		if got != want {
+			t.Errorf("%s: %+v\nwant %+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestTimingForAdaptive(t *testing.T) {
+	cfg := load(t, `
+[aliases]
+studio = "C0:FF:EE:00:00:01"
+
+[timing]
+adaptive = true
+
+[cameras."ILCE-7M3"]
+adaptive = false
+
+[cameras.studio]
+adaptive = true
+`)
+	tests := []struct {
+		camera, address string
+		want            bool
+	}{
+		{"ILCE-7M4", "C0:FF:EE:00:00:09", true},
+		// false is a setting of its own rather than an unset value
+		{"ILCE-7M3", "C0:FF:EE:00:00:09", false},
+		{"ILCE-7M3", "C0:FF:EE:00:00:01", true},
+	}
+	for _, tt := range tests {
+		timing := cfg.TimingFor(tt.camera, tt.address)
+		if timing.Adaptive == nil || *timing.Adaptive != tt.want || timing.Profile().Adaptive != tt.want {
+			t.Errorf("%s at %s: adaptive %v, want %t", tt.camera, tt.address, timing.Adaptive, tt.want)
+		}
+	}
+}
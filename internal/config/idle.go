@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// IdleDisconnect configures closing the link to a camera that received no
+// commands for a while. The next command reconnects.
+type IdleDisconnect struct {
+	// After is how long the link may go without a command before it is closed; zero keeps it open
+	After time.Duration `toml:"after"`
+	// ReconnectTimeout bounds how long a command waits for the link to reopen
+	ReconnectTimeout time.Duration `toml:"reconnect_timeout"`
+}
+
+// Options returns the idle disconnect settings for the client.
+func (i IdleDisconnect) Options() sony_remote_ble.IdleDisconnect {
+	return sony_remote_ble.IdleDisconnect{
+		After:            i.After,
+		ReconnectTimeout: i.ReconnectTimeout,
+	}
+}
+
+func (i IdleDisconnect) validate() []string {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if i.After < 0 {
+		addf("idle_disconnect.after: must not be negative")
+	}
+	if i.ReconnectTimeout <= 0 {
+		addf("idle_disconnect.reconnect_timeout: must be greater than zero")
+	}
+	return problems
+}
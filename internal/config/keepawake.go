@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// keepAwakeSignals maps the signal names accepted in keep_awake.signal to signals.
+var keepAwakeSignals = map[string]sony_remote_ble.KeepAwakeSignal{
+	sony_remote_ble.KeepAwakeHalfPress.String(): sony_remote_ble.KeepAwakeHalfPress,
+	sony_remote_ble.KeepAwakeRelease.String():   sony_remote_ble.KeepAwakeRelease,
+}
+
+// KeepAwake configures the signals that stop an idle camera from powering
+// off and dropping the connection.
+type KeepAwake struct {
+	// Enabled sends the signals from the TUI and the long-running subcommands
+	Enabled bool `toml:"enabled"`
+	// Interval is how long the camera may go without a command before it is signalled
+	Interval time.Duration `toml:"interval"`
+	// Signal is half_press or release
+	Signal string `toml:"signal"`
+}
+
+// Options returns the keep-awake options for the client.
+func (k KeepAwake) Options() sony_remote_ble.KeepAwakeOptions {
+	return sony_remote_ble.KeepAwakeOptions{
+		Interval: k.Interval,
+		Signal:   keepAwakeSignals[k.Signal],
+	}
+}
+
+func (k KeepAwake) validate() []string {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if k.Interval <= 0 {
+		addf("keep_awake.interval: must be greater than zero")
+	}
+	if _, ok := keepAwakeSignals[k.Signal]; !ok {
+		names := make([]string, 0, len(keepAwakeSignals))
+		for name := range keepAwakeSignals {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		addf("keep_awake.signal: %q must be one of %s", k.Signal, strings.Join(names, ", "))
+	}
+	return problems
+}
@@ -0,0 +1,76 @@
+package config
+
+// TUI actions that can be bound to keys in the [keys] section.
+const (
+	ActionQuit      = "quit"
+	ActionScan      = "scan"
+	ActionUp        = "up"
+	ActionDown      = "down"
+	ActionConnect   = "connect"
+	ActionStopScan  = "stop_scan"
+	ActionBack      = "back"
+	ActionFocus     = "focus"
+	ActionShutter   = "shutter"
+	ActionZoomIn    = "zoom_in"
+	ActionZoomOut   = "zoom_out"
+	ActionAutofocus = "autofocus"
+	ActionRecord    = "record"
+	ActionCustom    = "custom"
+	ActionPhoto     = "photo"
+	ActionDashboard = "dashboard"
+	ActionSelect    = "select"
+	ActionSelectAll = "select_all"
+)
+
+// DeviceListActions are the actions available on the device list screen.
+var DeviceListActions = []string{
+	ActionQuit, ActionScan, ActionUp, ActionDown, ActionConnect, ActionStopScan, ActionDashboard,
+}
+
+// ControlActions are the actions available on the camera control screen.
+var ControlActions = []string{
+	ActionQuit, ActionBack, ActionFocus, ActionShutter, ActionZoomIn, ActionZoomOut,
+	ActionAutofocus, ActionRecord, ActionCustom, ActionPhoto,
+}
+
+// DashboardActions are the actions available on the multi-camera dashboard.
+// Connect toggles the connection of the camera under the cursor; shutter and
+// record fire on the selected cameras.
+var DashboardActions = []string{
+	ActionQuit, ActionBack, ActionScan, ActionUp, ActionDown, ActionConnect,
+	ActionSelect, ActionSelectAll, ActionShutter, ActionRecord,
+}
+
+// DefaultKeys returns the built-in key bindings. Key names follow Bubble Tea's
+// key strings, e.g. "ctrl+c", "enter", "esc" or a single character; "space"
+// may be used for the space bar.
+func DefaultKeys() map[string][]string {
+	return map[string][]string{
+		ActionQuit:      {"q", "ctrl+c"},
+		ActionScan:      {"tab"},
+		ActionUp:        {"up", "k"},
+		ActionDown:      {"down", "j"},
+		ActionConnect:   {"enter"},
+		ActionStopScan:  {"esc"},
+		ActionBack:      {"esc", "backspace"},
+		ActionFocus:     {"f", "F"},
+		ActionShutter:   {"s", "S"},
+		ActionZoomIn:    {"Z"},
+		ActionZoomOut:   {"z"},
+		ActionAutofocus: {"a", "A"},
+		ActionRecord:    {"r", "R"},
+		ActionCustom:    {"c", "C"},
+		ActionPhoto:     {"space"},
+		ActionDashboard: {"d", "D"},
+		ActionSelect:    {"x", "X"},
+		ActionSelectAll: {"a", "A"},
+	}
+}
+
+// KeyName converts a configured key name into the string Bubble Tea reports for it.
+func KeyName(key string) string {
+	if key == "space" {
+		return " "
+	}
+	return key
+}
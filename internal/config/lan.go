@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// maxLead bounds how far ahead of the fire time a trigger may be sent.
+const maxLead = time.Minute
+
+// LAN configures synchronized triggering across hosts with the leader and
+// follow subcommands.
+type LAN struct {
+	// Listen is the TCP address the leader accepts followers on
+	Listen string `toml:"listen"`
+	// Leader is the host:port of the leader a follower registers with
+	Leader string `toml:"leader"`
+	// Name identifies a follower to the leader; empty uses the host name
+	Name string `toml:"name"`
+	// Lead is how far ahead of the fire time the leader sends a trigger. It
+	// must cover the network latency and the time cameras need to focus.
+	Lead time.Duration `toml:"lead"`
+}
+
+func (l LAN) validate() []string {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(l.Listen); err != nil {
+		addf("lan.listen: %q must be host:port, e.g. :47800", l.Listen)
+	}
+	if l.Leader != "" {
+		if host, _, err := net.SplitHostPort(l.Leader); err != nil || host == "" {
+			addf("lan.leader: %q must be host:port", l.Leader)
+		}
+	}
+	if l.Lead <= 0 || l.Lead > maxLead {
+		addf("lan.lead: must be greater than zero and at most %s", maxLead)
+	}
+	return problems
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// OSC actions that addresses in the [osc.addresses] section can map to.
+const (
+	OSCShoot      = "shoot"
+	OSCRecord     = "record"
+	OSCZoomIn     = "zoom_in"
+	OSCZoomOut    = "zoom_out"
+	OSCButton     = "button"
+	OSCConnect    = "connect"
+	OSCDisconnect = "disconnect"
+	OSCState      = "state"
+)
+
+// OSCActions lists every action an OSC address can map to.
+var OSCActions = []string{
+	OSCShoot, OSCRecord, OSCZoomIn, OSCZoomOut, OSCButton, OSCConnect, OSCDisconnect, OSCState,
+}
+
+// OSC configures the OSC server started by the osc subcommand.
+type OSC struct {
+	// Listen is the UDP address to receive OSC messages on
+	Listen string `toml:"listen"`
+	// ReplyTo is the host:port that replies and status broadcasts are sent to;
+	// empty disables them
+	ReplyTo string `toml:"reply_to"`
+	// ReplyAddress is the OSC address of command replies
+	ReplyAddress string `toml:"reply_address"`
+	// StatusAddress is the prefix of status broadcasts, e.g. /camera/status/state
+	StatusAddress string `toml:"status_address"`
+	// Addresses maps incoming OSC addresses to actions. An address ending in /*
+	// matches any last segment; with the button action that segment names the
+	// button. A section in the file replaces the defaults entirely.
+	Addresses map[string]string `toml:"addresses"`
+}
+
+// DefaultOSCAddresses returns the built-in OSC address mapping.
+func DefaultOSCAddresses() map[string]string {
+	return map[string]string{
+		"/camera/shoot":      OSCShoot,
+		"/camera/record":     OSCRecord,
+		"/camera/zoom/tele":  OSCZoomIn,
+		"/camera/zoom/wide":  OSCZoomOut,
+		"/camera/focus":      OSCButton + " focus",
+		"/camera/button/*":   OSCButton,
+		"/camera/connect":    OSCConnect,
+		"/camera/disconnect": OSCDisconnect,
+		"/camera/state":      OSCState,
+	}
+}
+
+// ParseOSCAction splits a mapping value such as "button c1" into the action and
+// its optional argument.
+func ParseOSCAction(value string) (action, arg string) {
+	action, arg, _ = strings.Cut(strings.TrimSpace(value), " ")
+	return action, strings.TrimSpace(arg)
+}
+
+func (o OSC) validate() []string {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(o.Listen); err != nil {
+		addf("osc.listen: %q must be host:port, e.g. :53000", o.Listen)
+	}
+	if o.ReplyTo != "" {
+		if host, _, err := net.SplitHostPort(o.ReplyTo); err != nil || host == "" {
+			addf("osc.reply_to: %q must be host:port", o.ReplyTo)
+		}
+	}
+	if !strings.HasPrefix(o.ReplyAddress, "/") {
+		addf("osc.reply_address: %q must start with /", o.ReplyAddress)
+	}
+	if !strings.HasPrefix(o.StatusAddress, "/") {
+		addf("osc.status_address: %q must start with /", o.StatusAddress)
+	}
+
+	known := make(map[string]bool)
+	for _, action := range OSCActions {
+		known[action] = true
+	}
+	for _, address := range sortedKeys(o.Addresses) {
+		if !strings.HasPrefix(address, "/") {
+			addf("osc.addresses: %q must start with /", address)
+		}
+		wildcard := strings.HasSuffix(address, "/*")
+		if strings.Contains(strings.TrimSuffix(address, "/*"), "*") {
+			addf("osc.addresses: %q may only use * as its last segment", address)
+		}
+
+		action, arg := ParseOSCAction(o.Addresses[address])
+		switch {
+		case !known[action]:
+			addf("osc.addresses.%q: unknown action %q (expected one of %s)", address, action, strings.Join(OSCActions, ", "))
+		case action == OSCButton && arg == "" && !wildcard:
+			addf("osc.addresses.%q: button needs a name, e.g. \"button c1\", or an address ending in /*", address)
+		case action != OSCButton && arg != "":
+			addf("osc.addresses.%q: %s does not take an argument", address, action)
+		}
+	}
+	return problems
+}
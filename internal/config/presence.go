@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// presenceEvents maps the names accepted in presence.rules.on to event types.
+var presenceEvents = map[string]sony_remote_ble.PresenceEventType{
+	sony_remote_ble.PresenceAppeared.String(): sony_remote_ble.PresenceAppeared,
+	sony_remote_ble.PresenceNear.String():     sony_remote_ble.PresenceNear,
+	sony_remote_ble.PresenceFar.String():      sony_remote_ble.PresenceFar,
+	sony_remote_ble.PresenceGone.String():     sony_remote_ble.PresenceGone,
+}
+
+// PresenceActions lists the actions a presence rule may take.
+var PresenceActions = []string{"connect", "disconnect", "stop_recording", "webhook", "exec"}
+
+// Presence configures the presence subcommand, which watches cameras come into
+// range, go out of range, appear and disappear, and runs rules when they do.
+type Presence struct {
+	// Near is the smoothed RSSI in dBm at or above which a camera is near
+	Near int16 `toml:"near"`
+	// Far is the smoothed RSSI in dBm below which a near camera is far again
+	Far int16 `toml:"far"`
+	// Smoothing is the weight of the newest RSSI reading in the moving average
+	Smoothing float64 `toml:"smoothing"`
+	// Timeout is how long a camera may go without advertising before it is gone
+	Timeout time.Duration `toml:"timeout"`
+	// Rules run in order for every event they match
+	Rules []PresenceRule `toml:"rules"`
+}
+
+// PresenceRule takes an action when a camera's presence changes.
+type PresenceRule struct {
+	// Camera is an alias, name or address; empty matches every camera
+	Camera string `toml:"camera"`
+	// On is the event the rule fires on: appeared, near, far or gone
+	On string `toml:"on"`
+	// Action is connect, disconnect, stop_recording, webhook or exec
+	Action string `toml:"action"`
+	// URL receives the event as a JSON POST for webhook rules
+	URL string `toml:"url"`
+	// Command is run by the shell for exec rules
+	Command string `toml:"command"`
+}
+
+// Event returns the event type the rule fires on.
+func (r PresenceRule) Event() sony_remote_ble.PresenceEventType {
+	return presenceEvents[r.On]
+}
+
+// Options returns the presence watcher options.
+func (p Presence) Options() sony_remote_ble.PresenceOptions {
+	return sony_remote_ble.PresenceOptions{
+		Near:      p.Near,
+		Far:       p.Far,
+		Smoothing: p.Smoothing,
+		Timeout:   p.Timeout,
+	}
+}
+
+func (p Presence) validate() []string {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if p.Near < -127 || p.Near >= 0 {
+		addf("presence.near: %d must be a signal strength between -127 and -1 dBm", p.Near)
+	}
+	if p.Far < -127 || p.Far > p.Near {
+		addf("presence.far: %d must be between -127 dBm and presence.near", p.Far)
+	}
+	if p.Smoothing <= 0 || p.Smoothing > 1 {
+		addf("presence.smoothing: must be greater than zero and at most 1")
+	}
+	if p.Timeout <= 0 {
+		addf("presence.timeout: must be greater than zero")
+	}
+
+	events := make([]string, 0, len(presenceEvents))
+	for name := range presenceEvents {
+		events = append(events, name)
+	}
+	slices.Sort(events)
+	for i, rule := range p.Rules {
+		key := fmt.Sprintf("presence.rules[%d]", i)
+		if _, ok := presenceEvents[rule.On]; !ok {
+			addf("%s.on: %q must be one of %s", key, rule.On, strings.Join(events, ", "))
+		}
+		// A camera is only gone once its link is lost, when there is nothing
+		// left to stop or disconnect
+		if rule.On == "gone" && (rule.Action == "stop_recording" || rule.Action == "disconnect") {
+			addf("%s.action: %s cannot act on a gone camera, whose link is already lost; use far", key, rule.Action)
+		}
+		switch rule.Action {
+		case "webhook":
+			if u, err := url.Parse(rule.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				addf("%s.url: %q must be an http or https URL", key, rule.URL)
+			}
+		case "exec":
+			if strings.TrimSpace(rule.Command) == "" {
+				addf("%s.command: must not be empty", key)
+			}
+		default:
+			if !slices.Contains(PresenceActions, rule.Action) {
+				addf("%s.action: %q must be one of %s", key, rule.Action, strings.Join(PresenceActions, ", "))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	// Path is the configuration file, empty when only defaults and environment were used
+	Path string
+	// Problems describes each invalid setting, prefixed with its key
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	source := "config"
+	if e.Path != "" {
+		source = "config " + e.Path
+	}
+	if len(e.Problems) == 1 {
+		return source + ": " + e.Problems[0]
+	}
+	return source + ":\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validate checks the configuration and returns a *ValidationError describing
+// every invalid setting, or nil if the configuration is usable.
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	for _, name := range sortedKeys(c.Aliases) {
+		if name == "" {
+			addf("aliases: alias names must not be empty")
+		}
+		if !validAddress(c.Aliases[name]) {
+			addf("aliases.%s: %q is not a Bluetooth address (expected AA:BB:CC:DD:EE:FF or a UUID)", name, c.Aliases[name])
+		}
+	}
+
+	if c.Scan.Timeout <= 0 {
+		addf("scan.timeout: must be greater than zero")
+	}
+
+	problems = append(problems, c.Timing.validate("timing", true)...)
+	for _, name := range sortedKeys(c.Cameras) {
+		problems = append(problems, c.Cameras[name].validate("cameras."+name, false)...)
+	}
+
+	problems = append(problems, validateKeys(c.Keys)...)
+
+	problems = append(problems, c.Log.validate()...)
+	problems = append(problems, c.MQTT.validate()...)
+	problems = append(problems, c.OSC.validate()...)
+	problems = append(problems, c.LAN.validate()...)
+	problems = append(problems, c.KeepAwake.validate()...)
+	problems = append(problems, c.IdleDisconnect.validate()...)
+	problems = append(problems, c.Presence.validate()...)
+	if c.KeepAwake.Enabled && c.IdleDisconnect.After > 0 && c.KeepAwake.Interval < c.IdleDisconnect.After {
+		addf("idle_disconnect.after: %s never passes while keep_awake signals the camera every %s", c.IdleDisconnect.After, c.KeepAwake.Interval)
+	}
+	// Most cameras stop advertising while connected, so far only fires for the
+	// connected camera while its link is closed for being idle
+	for i, rule := range c.Presence.Rules {
+		if rule.On == "far" && c.IdleDisconnect.After <= 0 && (rule.Action == "stop_recording" || rule.Action == "disconnect") {
+			addf("presence.rules[%d].on: far never fires for the connected camera, which stops advertising, unless idle_disconnect.after is set", i)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Path: c.path, Problems: problems}
+	}
+	return nil
+}
+
+func (t Timing) validate(section string, required bool) []string {
+	var problems []string
+	checkDuration := func(key string, d time.Duration) {
+		if d < 0 {
+			problems = append(problems, fmt.Sprintf("%s.%s: must not be negative", section, key))
+		}
+	}
+	checkDuration("command_delay", t.CommandDelay)
+	checkDuration("zoom_hold", t.ZoomHold)
+	checkDuration("focus_hold", t.FocusHold)
+	checkDuration("half_press_settle", t.HalfPressSettle)
+	checkDuration("full_press_hold", t.FullPressHold)
+	checkDuration("release_gap", t.ReleaseGap)
+	checkDuration("zoom_stop", t.ZoomStop)
+
+	if t.ZoomSpeed != 0 || required {
+		if t.ZoomSpeed < 1 || t.ZoomSpeed > 127 {
+			problems = append(problems, fmt.Sprintf("%s.zoom_speed: %d must be between 1 and 127", section, t.ZoomSpeed))
+		}
+	}
+	return problems
+}
+
+// Validate checks log settings that bypass Load, such as those given as
+// command-line flags, and returns an error describing every invalid one.
+func (l Log) Validate() error {
+	if problems := l.validate(); len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func (l Log) validate() []string {
+	var problems []string
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		problems = append(problems, fmt.Sprintf("log.level: %q must be one of debug, info, warn or error", l.Level))
+	}
+	switch l.Format {
+	case "text", "json":
+	case "journal":
+		if l.File != "" {
+			problems = append(problems, "log.file: cannot be combined with the journal format")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("log.format: %q must be text, json or journal", l.Format))
+	}
+	return problems
+}
+
+func (m MQTT) validate() []string {
+	var problems []string
+	if u, err := url.Parse(m.Broker); err != nil || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("mqtt.broker: %q is not a broker URL (expected e.g. tcp://localhost:1883)", m.Broker))
+	} else {
+		switch u.Scheme {
+		case "tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss":
+		default:
+			problems = append(problems, fmt.Sprintf("mqtt.broker: unsupported scheme %q", u.Scheme))
+		}
+	}
+	checkPrefix := func(key, prefix string) {
+		if prefix == "" || strings.ContainsAny(prefix, "+#") {
+			problems = append(problems, fmt.Sprintf("mqtt.%s: %q must be non-empty and must not contain + or #", key, prefix))
+		}
+	}
+	checkPrefix("topic_prefix", m.TopicPrefix)
+	checkPrefix("discovery_prefix", m.DiscoveryPrefix)
+	return problems
+}
+
+func validateKeys(keys map[string][]string) []string {
+	var problems []string
+
+	known := make(map[string]bool)
+	for _, action := range append(append(append([]string{}, DeviceListActions...), ControlActions...), DashboardActions...) {
+		known[action] = true
+	}
+	for _, action := range sortedKeys(keys) {
+		if !known[action] {
+			problems = append(problems, fmt.Sprintf("keys.%s: unknown action", action))
+			continue
+		}
+		if len(keys[action]) == 0 {
+			problems = append(problems, fmt.Sprintf("keys.%s: at least one key is required", action))
+		}
+		for _, key := range keys[action] {
+			if key == "" {
+				problems = append(problems, fmt.Sprintf("keys.%s: key names must not be empty", action))
+			}
+		}
+	}
+
+	// A key may be reused across screens but not by two actions on the same screen
+	for _, screen := range []struct {
+		name    string
+		actions []string
+	}{
+		{"device list", DeviceListActions},
+		{"control", ControlActions},
+		{"dashboard", DashboardActions},
+	} {
+		owner := make(map[string]string)
+		for _, action := range screen.actions {
+			for _, key := range keys[action] {
+				name := KeyName(key)
+				if other, ok := owner[name]; ok && other != action {
+					problems = append(problems, fmt.Sprintf("keys.%s: %q is already bound to %s on the %s screen", action, key, other, screen.name))
+					continue
+				}
+				owner[name] = action
+			}
+		}
+	}
+	return problems
+}
+
+// validAddress accepts MAC addresses (Linux, Windows) and UUIDs (macOS).
+func validAddress(address string) bool {
+	if _, err := net.ParseMAC(address); err == nil && len(address) == 17 {
+		return true
+	}
+	_, err := bluetooth.ParseUUID(address)
+	return err == nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
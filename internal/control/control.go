@@ -0,0 +1,263 @@
+// Package control implements the JSON command set shared by the front ends that
+// let other programs drive a camera session, such as the WebSocket event stream
+// and the daemon socket. Requests name an action and carry its parameters;
+// replies echo the request ID so clients can pipeline requests.
+package control
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// maxScanTimeout caps the scan duration a client can request.
+const maxScanTimeout = 60 * time.Second
+
+// Actions lists the actions Execute understands.
+var Actions = []string{"state", "scan", "connect", "disconnect", "shoot", "record_start", "record_stop", "record_toggle", "press", "sequence"}
+
+// Device describes a camera in replies and events.
+type Device struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Model   string `json:"model,omitempty"`
+	RSSI    int16  `json:"rssi"`
+	Known   bool   `json:"known"`
+}
+
+// State describes the session: its connection state, whether an operation
+// holds the link, whether the link was closed for being idle, whether the
+// camera is recording if it reports that, the connected camera and the
+// keep-awake, if running.
+type State struct {
+	State     string          `json:"state"`
+	Busy      bool            `json:"busy"`
+	Idle      bool            `json:"idle,omitempty"`
+	Recording *bool           `json:"recording,omitempty"`
+	Device    *Device         `json:"device,omitempty"`
+	KeepAwake *KeepAwakeState `json:"keep_awake,omitempty"`
+}
+
+// KeepAwakeState describes the keep-awake of a session.
+type KeepAwakeState struct {
+	// Paused tells why no signal is due, empty when one is
+	Paused     string     `json:"paused,omitempty"`
+	Next       time.Time  `json:"next"`
+	LastSignal *time.Time `json:"last_signal,omitempty"`
+	Signals    int        `json:"signals"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// DeviceOf describes a camera, marking it as known if it is in the session's store.
+func DeviceOf(sess *session.Session, device sony_remote_ble.DeviceInfo) Device {
+	known := false
+	if store := sess.Known(); store != nil {
+		_, known = store.Lookup(device.AddressStr)
+	}
+	return Device{
+		Name:    device.Name,
+		Address: device.AddressStr,
+		Model:   sony_remote_ble.ModelFromName(device.Name),
+		RSSI:    device.RSSI,
+		Known:   known,
+	}
+}
+
+// StateOf describes the current state of the session.
+func StateOf(sess *session.Session) State {
+	state := State{
+		State: sess.State().String(),
+		Busy:  sess.Busy(),
+		Idle:  sess.Idle(),
+	}
+	if device, ok := sess.Device(); ok {
+		d := DeviceOf(sess, device)
+		state.Device = &d
+	}
+	if recording, known := sess.Recording(); known {
+		state.Recording = &recording
+	}
+	if status, ok := sess.KeepAwakeStatus(); ok && status.Running {
+		state.KeepAwake = &KeepAwakeState{
+			Paused:  status.Paused,
+			Next:    status.Next,
+			Signals: status.Signals,
+		}
+		if !status.LastSignal.IsZero() {
+			state.KeepAwake.LastSignal = &status.LastSignal
+		}
+		if status.Err != nil {
+			state.KeepAwake.Error = status.Err.Error()
+		}
+	}
+	return state
+}
+
+// Request is a command from a client. Fields other than ID and Action are only
+// used by the actions that need them.
+type Request struct {
+	// ID is echoed in the reply
+	ID string `json:"id"`
+	// Action is one of Actions
+	Action string `json:"action"`
+	// Device is the alias, name or address to connect to (connect)
+	Device string `json:"device,omitempty"`
+	// Timeout bounds scanning (scan, connect)
+	Timeout string `json:"timeout,omitempty"`
+	// Button and Hold select the button to press and for how long (press)
+	Button string `json:"button,omitempty"`
+	Hold   string `json:"hold,omitempty"`
+	// Commands and Delay describe a command sequence by name or hex frame (sequence)
+	Commands []string `json:"commands,omitempty"`
+	Delay    string   `json:"delay,omitempty"`
+}
+
+// Reply answers a Request. On success it carries the session state after the
+// action and, for scans, the cameras found.
+type Reply struct {
+	Type    string   `json:"type"`
+	ID      string   `json:"id,omitempty"`
+	OK      bool     `json:"ok"`
+	Error   string   `json:"error,omitempty"`
+	State   *State   `json:"state,omitempty"`
+	Devices []Device `json:"devices,omitempty"`
+}
+
+// ErrorReply builds the reply for a request that could not be executed.
+func ErrorReply(id string, err error) Reply {
+	return Reply{Type: "reply", ID: id, Error: err.Error()}
+}
+
+// Execute performs a request on the session and builds its reply.
+func Execute(ctx context.Context, sess *session.Session, req Request) Reply {
+	devices, err := execute(ctx, sess, req)
+	if err != nil {
+		return ErrorReply(req.ID, err)
+	}
+	state := StateOf(sess)
+	return Reply{Type: "reply", ID: req.ID, OK: true, State: &state, Devices: devices}
+}
+
+func execute(ctx context.Context, sess *session.Session, req Request) ([]Device, error) {
+	switch req.Action {
+	case "state":
+		return nil, nil
+
+	case "scan":
+		timeout, err := parseDuration("timeout", req.Timeout, 5*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		found, err := sess.Scan(ctx, min(timeout, maxScanTimeout))
+		if err != nil {
+			return nil, err
+		}
+		devices := make([]Device, 0, len(found))
+		for _, device := range found {
+			devices = append(devices, DeviceOf(sess, device))
+		}
+		return devices, nil
+
+	case "connect":
+		cfg := sess.Config()
+		timeout, err := parseDuration("timeout", req.Timeout, cfg.Scan.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		if req.Device == "" {
+			req.Device = cfg.DefaultCamera
+		}
+		_, err = sess.Connect(ctx, req.Device, timeout)
+		return nil, err
+
+	case "disconnect":
+		return nil, sess.Disconnect(ctx)
+
+	case "shoot":
+		return nil, sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+			return client.TakePhoto()
+		})
+
+	case "record_start", "record_stop":
+		// The camera only exposes a toggle, so these follow its notifications
+		return nil, sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+			return client.SetRecording(ctx, req.Action == "record_start")
+		})
+
+	case "record_toggle":
+		return nil, sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+			return client.Press("record", sony_remote_ble.DefaultCommandDelay)
+		})
+
+	case "press":
+		if req.Button == "" {
+			return nil, errors.New("button is required")
+		}
+		hold, err := parseDuration("hold", req.Hold, sony_remote_ble.DefaultCommandDelay)
+		if err != nil {
+			return nil, err
+		}
+		return nil, sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+			return client.Press(req.Button, hold)
+		})
+
+	case "sequence":
+		if len(req.Commands) == 0 {
+			return nil, errors.New("commands must not be empty")
+		}
+		delay, err := parseDuration("delay", req.Delay, sess.Timing().CommandDelay)
+		if err != nil {
+			return nil, err
+		}
+		sequence := make([]sony_remote_ble.SonyCommand, 0, len(req.Commands))
+		for i, entry := range req.Commands {
+			cmd, err := ParseCommand(entry)
+			if err != nil {
+				return nil, fmt.Errorf("commands[%d]: %w", i, err)
+			}
+			sequence = append(sequence, cmd)
+		}
+		return nil, sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+			return client.SendCommandSequence(sequence, delay)
+		})
+
+	default:
+		return nil, fmt.Errorf("unknown action %q (expected one of %s)", req.Action, strings.Join(Actions, ", "))
+	}
+}
+
+// ParseCommand resolves a command name or a hex frame such as "0x0109".
+func ParseCommand(entry string) (sony_remote_ble.SonyCommand, error) {
+	if cmd, ok := sony_remote_ble.Commands[entry]; ok {
+		return cmd, nil
+	}
+	if !strings.HasPrefix(entry, "0x") {
+		return sony_remote_ble.SonyCommand{}, fmt.Errorf("unknown command %q", entry)
+	}
+	code, err := hex.DecodeString(strings.TrimPrefix(entry, "0x"))
+	if err != nil || len(code) == 0 {
+		return sony_remote_ble.SonyCommand{}, fmt.Errorf("invalid hex frame %q", entry)
+	}
+	return sony_remote_ble.SonyCommand{Name: "Raw " + entry, Code: code}, nil
+}
+
+// parseDuration parses an optional duration field, returning fallback when empty.
+func parseDuration(field, value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", field, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s: must not be negative", field)
+	}
+	return d, nil
+}
@@ -0,0 +1,193 @@
+package control_test
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/control"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/simulator"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// connected returns a session connected to cam through the connect action.
+func connected(t *testing.T, cam *simulator.Camera) *session.Session {
+	t.Helper()
+	sess := session.New(sony_remote_ble.NewClientWithTransport(cam), config.Default(), nil, logger)
+	t.Cleanup(func() { sess.Disconnect(context.Background()) })
+
+	reply := control.Execute(context.Background(), sess, control.Request{ID: "c", Action: "connect", Device: cam.Address().String(), Timeout: "2s"})
+	if !reply.OK || reply.State.State != sony_remote_ble.Connected.String() {
+		t.Fatalf("connect: %+v", reply)
+	}
+	if reply.State.Device == nil || reply.State.Device.Address != cam.Address().String() {
+		t.Fatalf("connected to %+v, want the simulator", reply.State.Device)
+	}
+	return sess
+}
+
+// newCamera creates a simulated camera that answers quickly.
+func newCamera(opts simulator.Options) *simulator.Camera {
+	opts.AdvertiseInterval = 10 * time.Millisecond
+	opts.RecordLatency = 10 * time.Millisecond
+	return simulator.New(opts)
+}
+
+func TestExecute(t *testing.T) {
+	cam := newCamera(simulator.Options{})
+	sess := connected(t, cam)
+	ctx := context.Background()
+
+	tests := []struct {
+		req   control.Request
+		error string
+	}{
+		{control.Request{Action: "state"}, ""},
+		{control.Request{Action: "shoot"}, ""},
+		{control.Request{Action: "record_start"}, ""},
+		{control.Request{Action: "record_stop"}, ""},
+		{control.Request{Action: "press", Button: "c1", Hold: "10ms"}, ""},
+		{control.Request{Action: "sequence", Commands: []string{"focus_down", "0x0106"}, Delay: "1ms"}, ""},
+		{control.Request{Action: "press"}, "button is required"},
+		{control.Request{Action: "press", Button: "c1", Hold: "-1s"}, "hold: must not be negative"},
+		{control.Request{Action: "press", Button: "c9"}, "c9"},
+		{control.Request{Action: "sequence"}, "commands must not be empty"},
+		{control.Request{Action: "sequence", Commands: []string{"focus_down", "0xzz"}}, `commands[1]: invalid hex frame "0xzz"`},
+		{control.Request{Action: "sequence", Commands: []string{"wave"}}, `commands[0]: unknown command "wave"`},
+		{control.Request{Action: "scan", Timeout: "soon"}, "timeout: "},
+		{control.Request{Action: "launch"}, `unknown action "launch"`},
+	}
+	for i, tt := range tests {
+		tt.req.ID = strings.Repeat("x", i+1)
+		reply := control.Execute(ctx, sess, tt.req)
+		if reply.Type != "reply" || reply.ID != tt.req.ID {
+			t.Errorf("%s: reply %+v does not answer request %q", tt.req.Action, reply, tt.req.ID)
+		}
+		if tt.error == "" {
+			if !reply.OK || reply.State == nil {
+				t.Errorf("%s: %+v, want success with the state", tt.req.Action, reply)
+			}
+			continue
+		}
+		if reply.OK || !strings.Contains(reply.Error, tt.error) || reply.State != nil {
+			t.Errorf("%+v: %+v, want an error mentioning %q", tt.req, reply, tt.error)
+		}
+	}
+
+	if cam.Shots() != 1 || cam.Recording() {
+		t.Errorf("camera took %d shots and records %t, want one shot and stopped", cam.Shots(), cam.Recording())
+	}
+	if frames := hexFrames(cam); !strings.Contains(strings.Join(frames, " "), "0107 0106") {
+		t.Errorf("frames %v, want the sequence's focus_down then 0x0106", frames)
+	}
+}
+
+func TestExecuteRecordingState(t *testing.T) {
+	cam := newCamera(simulator.Options{})
+	sess := connected(t, cam)
+	ctx := context.Background()
+
+	reply := control.Execute(ctx, sess, control.Request{Action: "record_start"})
+	if !reply.OK || reply.State.Recording == nil || !*reply.State.Recording {
+		t.Fatalf("record_start: %+v, want the state to report recording", reply)
+	}
+	// Starting a recording the camera reported is a no-op rather than a toggle
+	reply = control.Execute(ctx, sess, control.Request{Action: "record_start"})
+	if !reply.OK || !cam.Recording() {
+		t.Errorf("second record_start: %+v with camera recording %t", reply, cam.Recording())
+	}
+	// A toggle does not wait for the camera to report the change
+	if reply = control.Execute(ctx, sess, control.Request{Action: "record_toggle"}); !reply.OK {
+		t.Errorf("record_toggle: %s", reply.Error)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for cam.Recording() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if cam.Recording() {
+		t.Error("camera still records after record_toggle")
+	}
+
+	silent := connected(t, newCamera(simulator.Options{NoNotifications: true, Address: "C0:FF:EE:00:00:02"}))
+	reply = control.Execute(ctx, silent, control.Request{Action: "record_start"})
+	if reply.OK || reply.Error != sony_remote_ble.ErrRecordingUnknown.Error() {
+		t.Errorf("record_start without notifications: %+v, want %v", reply, sony_remote_ble.ErrRecordingUnknown)
+	}
+	if state := control.StateOf(silent); state.Recording != nil {
+		t.Errorf("state reports recording %t for a camera without notifications", *state.Recording)
+	}
+}
+
+// hexFrames returns the frames cam received in hex.
+func hexFrames(cam *simulator.Camera) []string {
+	var frames []string
+	for _, f := range cam.Frames() {
+		frames = append(frames, hex.EncodeToString(f))
+	}
+	return frames
+}
+
+func TestBroadcasterNumbersEvents(t *testing.T) {
+	cam := newCamera(simulator.Options{NoNotifications: true})
+	sess := connected(t, cam)
+	events := control.NewBroadcaster(sess)
+	defer events.Close()
+	ctx := context.Background()
+
+	shoot := func() {
+		t.Helper()
+		if reply := control.Execute(ctx, sess, control.Request{Action: "shoot"}); !reply.OK {
+			t.Fatalf("shoot: %s", reply.Error)
+		}
+	}
+	// A photo is four frames, each reported as a sent command
+	receive := func(ch chan control.Event) []control.Event {
+		t.Helper()
+		var got []control.Event
+		for len(got) < 4 {
+			select {
+			case ev := <-ch:
+				got = append(got, ev)
+			case <-time.After(2 * time.Second):
+				t.Fatalf("received %d events, want 4", len(got))
+			}
+		}
+		return got
+	}
+
+	ch := events.Subscribe()
+	shoot()
+	first := receive(ch)
+	for i, ev := range first {
+		if ev.Type != sony_remote_ble.EventCommandSent.String() || ev.Command == "" || ev.Bytes == "" {
+			t.Errorf("event %d: %+v, want a sent command", i, ev)
+		}
+		if i > 0 && ev.Seq != first[i-1].Seq+1 {
+			t.Errorf("event %d has seq %d after %d", i, ev.Seq, first[i-1].Seq)
+		}
+	}
+
+	// Events are still numbered while nobody listens
+	events.Unsubscribe(ch)
+	shoot()
+	select {
+	case ev := <-ch:
+		t.Errorf("unsubscribed channel received %+v", ev)
+	default:
+	}
+
+	ch = events.Subscribe()
+	defer events.Unsubscribe(ch)
+	shoot()
+	if got, want := receive(ch)[0].Seq, first[3].Seq+5; got != want {
+		t.Errorf("seq %d after a photo nobody watched, want %d", got, want)
+	}
+}
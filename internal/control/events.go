@@ -0,0 +1,138 @@
+package control
+
+import (
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// SubscriberBuffer is how many events are queued for a slow subscriber before
+// newer events are dropped. Subscribers notice the gap in Seq.
+const SubscriberBuffer = 64
+
+// Event is the JSON form of a client event. Seq increases by one for every
+// event, so a jump tells a subscriber that it missed events.
+type Event struct {
+	Seq          uint64        `json:"seq"`
+	Time         time.Time     `json:"time"`
+	Type         string        `json:"type"`
+	State        string        `json:"state,omitempty"`
+	Previous     string        `json:"previous,omitempty"`
+	Device       *Device       `json:"device,omitempty"`
+	Command      string        `json:"command,omitempty"`
+	Bytes        string        `json:"bytes,omitempty"`
+	LatencyMS    *float64      `json:"latency_ms,omitempty"`
+	ReconnectMS  *float64      `json:"reconnect_ms,omitempty"`
+	Notification *Notification `json:"notification,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// Notification is the JSON form of a camera status notification.
+type Notification struct {
+	Kind    string `json:"kind"`
+	Active  bool   `json:"active"`
+	Message string `json:"message"`
+	Raw     string `json:"raw"`
+}
+
+// Broadcaster numbers the events of a session and fans them out to subscribers.
+type Broadcaster struct {
+	sess   *session.Session
+	remove func()
+
+	mu          sync.Mutex
+	seq         uint64
+	subscribers map[chan Event]struct{}
+}
+
+// NewBroadcaster starts listening to the session's events. Call Close to stop.
+func NewBroadcaster(sess *session.Session) *Broadcaster {
+	b := &Broadcaster{
+		sess:        sess,
+		subscribers: make(map[chan Event]struct{}),
+	}
+	b.remove = sess.OnEvent(b.handle)
+	return b
+}
+
+// Close stops listening to the session.
+func (b *Broadcaster) Close() {
+	b.remove()
+}
+
+// Subscribe returns a channel that receives every subsequent event.
+func (b *Broadcaster) Subscribe() chan Event {
+	ch := make(chan Event, SubscriberBuffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe stops delivering events to ch.
+func (b *Broadcaster) Unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	delete(b.subscribers, ch)
+	b.mu.Unlock()
+}
+
+// handle converts a client event and publishes it. It runs on the goroutine
+// that emitted the event, so it must not touch the session link.
+func (b *Broadcaster) handle(ev sony_remote_ble.Event) {
+	msg := Event{
+		Time: ev.Time,
+		Type: ev.Type.String(),
+	}
+	if ev.Err != nil {
+		msg.Error = ev.Err.Error()
+	}
+
+	switch ev.Type {
+	case sony_remote_ble.EventStateChanged:
+		msg.State = ev.State.String()
+		msg.Previous = ev.Previous.String()
+	case sony_remote_ble.EventDeviceFound:
+		d := DeviceOf(b.sess, ev.Device)
+		msg.Device = &d
+	case sony_remote_ble.EventCommandSent, sony_remote_ble.EventCommandFailed:
+		msg.Command = ev.Command.Name
+		msg.Bytes = hex.EncodeToString(ev.Command.Code)
+		if ev.Latency > 0 {
+			ms := float64(ev.Latency) / float64(time.Millisecond)
+			msg.LatencyMS = &ms
+		}
+		if ev.Reconnect > 0 {
+			ms := float64(ev.Reconnect) / float64(time.Millisecond)
+			msg.ReconnectMS = &ms
+		}
+	case sony_remote_ble.EventNotification:
+		msg.Notification = &Notification{
+			Kind:    ev.Notification.Kind.String(),
+			Active:  ev.Notification.Active,
+			Message: ev.Notification.String(),
+			Raw:     hex.EncodeToString(ev.Notification.Raw),
+		}
+	}
+	b.publish(msg)
+}
+
+// publish assigns the next sequence number and queues msg for every subscriber.
+// Events are numbered even when nobody listens, so Seq also reveals events a
+// client missed while disconnected.
+func (b *Broadcaster) publish(msg Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	msg.Seq = b.seq
+	for ch := range b.subscribers {
+		select {
+		case ch <- msg:
+		default:
+			// Slow subscriber; it will see a gap in Seq
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/smazurov/sony_remote_ble/internal/control"
+)
+
+// Client talks to a running daemon.
+type Client struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	nextID  int
+}
+
+// Dial connects to the daemon listening on path.
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("daemon not reachable at %s: %w", path, err)
+	}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	return &Client{conn: conn, scanner: scanner}, nil
+}
+
+// Close closes the connection to the daemon.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Call sends a request and waits for its reply. The request ID is assigned by
+// the client. Events received while waiting are passed to onEvent, which may be nil.
+func (c *Client) Call(ctx context.Context, req control.Request, onEvent func(control.Event)) (control.Reply, error) {
+	c.nextID++
+	req.ID = strconv.Itoa(c.nextID)
+
+	stop := context.AfterFunc(ctx, func() { c.conn.Close() })
+	defer stop()
+
+	if err := json.NewEncoder(c.conn).Encode(req); err != nil {
+		return control.Reply{}, c.wrap(ctx, err)
+	}
+
+	for {
+		reply, event, err := c.read()
+		if err != nil {
+			return control.Reply{}, c.wrap(ctx, err)
+		}
+		if event != nil {
+			if onEvent != nil {
+				onEvent(*event)
+			}
+			continue
+		}
+		if reply.ID == req.ID {
+			return *reply, nil
+		}
+	}
+}
+
+// Subscribe asks the daemon for events and passes each to fn until ctx is
+// cancelled or the daemon goes away.
+func (c *Client) Subscribe(ctx context.Context, fn func(control.Event)) error {
+	reply, err := c.Call(ctx, control.Request{Action: "subscribe"}, fn)
+	if err != nil {
+		return err
+	}
+	if !reply.OK {
+		return fmt.Errorf("subscribe: %s", reply.Error)
+	}
+
+	stop := context.AfterFunc(ctx, func() { c.conn.Close() })
+	defer stop()
+	for {
+		_, event, err := c.read()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return c.wrap(ctx, err)
+		}
+		if event != nil {
+			fn(*event)
+		}
+	}
+}
+
+// read decodes the next line, which is either a reply or an event.
+func (c *Client) read() (*control.Reply, *control.Event, error) {
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("daemon closed the connection")
+	}
+	line := c.scanner.Bytes()
+
+	var kind struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &kind); err != nil {
+		return nil, nil, fmt.Errorf("invalid message from daemon: %w", err)
+	}
+	if kind.Type == "reply" {
+		var reply control.Reply
+		if err := json.Unmarshal(line, &reply); err != nil {
+			return nil, nil, fmt.Errorf("invalid reply from daemon: %w", err)
+		}
+		return &reply, nil, nil
+	}
+	var event control.Event
+	if err := json.Unmarshal(line, &event); err != nil {
+		return nil, nil, fmt.Errorf("invalid event from daemon: %w", err)
+	}
+	return nil, &event, nil
+}
+
+func (c *Client) wrap(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
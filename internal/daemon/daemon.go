@@ -0,0 +1,316 @@
+// Package daemon lets several local programs share one camera. The daemon owns
+// the Bluetooth client and keeps the camera connected, and clients talk to it
+// over a Unix domain socket using line-delimited JSON.
+//
+// Each line a client sends is a control.Request. Besides the control actions a
+// client may send {"action": "subscribe"} to receive every camera event as a
+// control.Event line, and {"action": "unsubscribe"} to stop. Every request is
+// answered with a control.Reply carrying the request's ID. A client's requests
+// are executed in order; requests from different clients share the camera
+// through the session queue.
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/control"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+const (
+	// maxLineSize limits a single request line
+	maxLineSize = 1 << 20
+	// pendingRequests is how many requests a client can pipeline before reading stalls
+	pendingRequests = 16
+	// minReconnectDelay and maxReconnectDelay bound the backoff between
+	// attempts to reconnect the camera
+	minReconnectDelay = 2 * time.Second
+	maxReconnectDelay = time.Minute
+	// connectionCheckInterval is how often the daemon checks that the camera is still connected
+	connectionCheckInterval = 5 * time.Second
+)
+
+// DefaultSocketPath returns $XDG_RUNTIME_DIR/sony-remote.sock, falling back to
+// a per-user path in the temporary directory.
+func DefaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "sony-remote.sock")
+	}
+	return filepath.Join(os.TempDir(), "sony-remote-"+strconv.Itoa(os.Getuid())+".sock")
+}
+
+// Listen creates the daemon socket at path, readable and writable only by the
+// current user. A stale socket left by a daemon that exited uncleanly is
+// replaced; a socket with a live daemon behind it is an error.
+func Listen(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("remove stale socket: %w", err)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("restrict socket permissions: %w", err)
+	}
+	return ln, nil
+}
+
+// Options configures a Server.
+type Options struct {
+	// Device is the alias, name or address of the camera to keep connected;
+	// empty leaves connecting to clients
+	Device string
+	// ScanTimeout bounds each attempt to find the camera
+	ScanTimeout time.Duration
+	// LinkGrace is how long the configured camera may stay disconnected before
+	// the systemd watchdog is no longer pinged; zero disables the check
+	LinkGrace time.Duration
+}
+
+// Server serves the daemon protocol for a camera session.
+type Server struct {
+	sess   *session.Session
+	opts   Options
+	logger *slog.Logger
+	events *control.Broadcaster
+	sv     *supervisor
+}
+
+// New creates a daemon server for sess.
+func New(sess *session.Session, opts Options, logger *slog.Logger) *Server {
+	return &Server{
+		sess:   sess,
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// Serve accepts clients on ln until ctx is cancelled. If a device is
+// configured the camera is connected and reconnected whenever it drops.
+//
+// Under systemd the daemon reports READY=1 once it is listening and, if a
+// device is configured, the camera is connected. It keeps STATUS= in line with
+// the connection state and, when WatchdogSec= is set, pings the watchdog only
+// while the link is healthy.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	s.events = control.NewBroadcaster(s.sess)
+	defer s.events.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	s.sv = newSupervisor(s)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.sv.run(ctx)
+	}()
+
+	if s.opts.Device != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.keepConnected(ctx)
+		}()
+	} else {
+		s.sv.ready()
+	}
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	s.logger.Info("daemon listening", "socket", ln.Addr().String())
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accept: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// keepConnected connects the configured camera and reconnects it with
+// exponential backoff whenever the connection is lost.
+func (s *Server) keepConnected(ctx context.Context) {
+	var ready sync.Once
+	delay := minReconnectDelay
+	for {
+		wait := connectionCheckInterval
+		if s.sess.State() == sony_remote_ble.Connected {
+			ready.Do(s.sv.ready)
+		} else {
+			device, connected, err := s.sess.EnsureConnected(ctx, s.opts.Device, s.opts.ScanTimeout)
+			switch {
+			case err != nil && ctx.Err() == nil:
+				s.logger.Warn("camera connection failed", "device", s.opts.Device, "error", err, "retry_in", delay)
+				wait = delay
+				delay = min(delay*2, maxReconnectDelay)
+			case connected:
+				s.logger.Info("connected", "name", device.Name, "address", device.AddressStr)
+				delay = minReconnectDelay
+				ready.Do(s.sv.ready)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// serveConn handles one client until it disconnects or ctx is cancelled. A
+// client that closes its write side still receives the replies to the requests
+// it sent.
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	s.logger.Debug("daemon client connected")
+	defer s.logger.Debug("daemon client disconnected")
+
+	// One writer goroutine owns the connection; replies wait for room while
+	// events are dropped when the client falls behind
+	out := make(chan any, control.SubscriberBuffer)
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		enc := json.NewEncoder(conn)
+		for msg := range out {
+			if ctx.Err() != nil {
+				continue
+			}
+			if err := enc.Encode(msg); err != nil {
+				cancel()
+			}
+		}
+	}()
+
+	reply := func(r control.Reply) {
+		select {
+		case out <- r:
+		case <-ctx.Done():
+		}
+	}
+
+	// Camera requests run in order on a per-client worker so a pipelined
+	// "shoot" then "record_start" happens in that order
+	var producers sync.WaitGroup
+	requests := make(chan control.Request, pendingRequests)
+	producers.Add(1)
+	go func() {
+		defer producers.Done()
+		for req := range requests {
+			reply(control.Execute(ctx, s.sess, req))
+		}
+	}()
+
+	var events chan control.Event
+	unsubscribe := func() {
+		if events != nil {
+			s.events.Unsubscribe(events)
+			close(events)
+			events = nil
+		}
+	}
+
+	s.readRequests(ctx, conn, func(req control.Request) bool {
+		switch req.Action {
+		case "subscribe":
+			if events == nil {
+				events = s.events.Subscribe()
+				producers.Add(1)
+				go func(events chan control.Event) {
+					defer producers.Done()
+					for ev := range events {
+						select {
+						case out <- ev:
+						default:
+							// Slow client; it will see a gap in seq
+						}
+					}
+				}(events)
+			}
+			reply(control.Execute(ctx, s.sess, control.Request{ID: req.ID, Action: "state"}))
+		case "unsubscribe":
+			unsubscribe()
+			reply(control.Execute(ctx, s.sess, control.Request{ID: req.ID, Action: "state"}))
+		default:
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}, reply)
+
+	// Finish the requests already queued, then flush their replies
+	unsubscribe()
+	close(requests)
+	producers.Wait()
+	close(out)
+	<-writerDone
+}
+
+// readRequests decodes request lines and passes them to handle until the
+// client stops sending or handle returns false. Malformed lines are answered
+// with an error reply.
+func (s *Server) readRequests(ctx context.Context, conn net.Conn, handle func(control.Request) bool, reply func(control.Reply)) {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req control.Request
+		if err := json.Unmarshal(line, &req); err != nil {
+			reply(control.ErrorReply("", fmt.Errorf("invalid request: %w", err)))
+			continue
+		}
+		if !handle(req) {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		s.logger.Debug("daemon client read failed", "error", err)
+	}
+}
@@ -0,0 +1,231 @@
+package daemon_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/control"
+	"github.com/smazurov/sony_remote_ble/internal/daemon"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/simulator"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// startDaemon serves a session on cam with opts on a socket in a temporary
+// directory until the test ends and returns the socket path.
+func startDaemon(t *testing.T, cam *simulator.Camera, opts daemon.Options) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "d.sock")
+	ln, err := daemon.Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := session.New(sony_remote_ble.NewClientWithTransport(cam), config.Default(), nil, logger)
+	srv := daemon.New(sess, opts, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+		sess.Disconnect(context.Background())
+	})
+	return path
+}
+
+// newCamera creates a simulated camera that answers quickly.
+func newCamera(opts simulator.Options) *simulator.Camera {
+	opts.AdvertiseInterval = 10 * time.Millisecond
+	opts.RecordLatency = 10 * time.Millisecond
+	return simulator.New(opts)
+}
+
+// dial opens a raw connection to the daemon at path.
+func dial(t *testing.T, path string) (*net.UnixConn, *bufio.Scanner) {
+	t.Helper()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return conn.(*net.UnixConn), bufio.NewScanner(conn)
+}
+
+// readReply reads the next line, which must be a reply.
+func readReply(t *testing.T, lines *bufio.Scanner) control.Reply {
+	t.Helper()
+	if !lines.Scan() {
+		t.Fatalf("connection ended before a reply: %v", lines.Err())
+	}
+	var reply control.Reply
+	if err := json.Unmarshal(lines.Bytes(), &reply); err != nil || reply.Type != "reply" {
+		t.Fatalf("line %q is not a reply: %v", lines.Text(), err)
+	}
+	return reply
+}
+
+// connectLine is a request connecting the simulator.
+func connectLine(id string, cam *simulator.Camera) string {
+	return `{"id": "` + id + `", "action": "connect", "device": "` + cam.Address().String() + `", "timeout": "2s"}`
+}
+
+func TestPipelinedRequestsRunInOrder(t *testing.T) {
+	cam := newCamera(simulator.Options{})
+	conn, lines := dial(t, startDaemon(t, cam, daemon.Options{}))
+
+	// Every request is written before the first reply is read
+	requests := []string{
+		`not json`,
+		connectLine("1", cam),
+		`{"id": "2", "action": "shoot"}`,
+		`{"id": "3", "action": "record_start"}`,
+		`{"id": "4", "action": "launch"}`,
+		`{"id": "5", "action": "press", "button": "c1", "hold": "10ms"}`,
+		`{"id": "6", "action": "record_stop"}`,
+		`{"id": "7", "action": "state"}`,
+	}
+	if _, err := io.WriteString(conn, strings.Join(requests, "\n")+"\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply := readReply(t, lines); reply.OK || reply.ID != "" || !strings.Contains(reply.Error, "invalid request") {
+		t.Errorf("malformed line: %+v, want an invalid request error", reply)
+	}
+	for _, id := range []string{"1", "2", "3", "4", "5", "6", "7"} {
+		reply := readReply(t, lines)
+		if reply.ID != id {
+			t.Fatalf("reply %+v, want the reply to request %s", reply, id)
+		}
+		if ok := id != "4"; reply.OK != ok {
+			t.Errorf("request %s: %+v, want ok %t", id, reply, ok)
+		}
+	}
+
+	// The camera saw the frames in the order the requests were sent
+	var frames []string
+	for _, f := range cam.Frames() {
+		frames = append(frames, hex.EncodeToString(f))
+	}
+	shutter := slices.Index(frames, hex.EncodeToString(sony_remote_ble.Commands["shutter_full_down"].Code))
+	record := slices.Index(frames, hex.EncodeToString(sony_remote_ble.Commands["record_down"].Code))
+	c1 := slices.Index(frames, hex.EncodeToString(sony_remote_ble.Commands["c1_down"].Code))
+	if shutter < 0 || record < shutter || c1 < record {
+		t.Errorf("frames %v, want the shutter, then record, then c1", frames)
+	}
+	if cam.Shots() != 1 || cam.Recording() {
+		t.Errorf("camera took %d shots and records %t, want one shot and stopped", cam.Shots(), cam.Recording())
+	}
+}
+
+func TestReplyAfterCloseWrite(t *testing.T) {
+	cam := newCamera(simulator.Options{})
+	conn, lines := dial(t, startDaemon(t, cam, daemon.Options{}))
+
+	// The client stops sending while the press is still held
+	requests := connectLine("1", cam) + "\n" + `{"id": "2", "action": "press", "button": "c1", "hold": "200ms"}` + "\n"
+	if _, err := io.WriteString(conn, requests); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		if reply := readReply(t, lines); reply.ID != id || !reply.OK {
+			t.Fatalf("reply %+v, want success for request %s", reply, id)
+		}
+	}
+	if lines.Scan() {
+		t.Errorf("unexpected line %q after the last reply", lines.Text())
+	}
+	if cam.Pressed("c1") {
+		t.Error("c1 still held after the press")
+	}
+}
+
+func TestEventsUntilUnsubscribe(t *testing.T) {
+	cam := newCamera(simulator.Options{NoNotifications: true})
+	path := startDaemon(t, cam, daemon.Options{})
+	client, err := daemon.Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var events []control.Event
+	collect := func(ev control.Event) {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	}
+	sent := func() []control.Event {
+		mu.Lock()
+		defer mu.Unlock()
+		var commands []control.Event
+		for _, ev := range events {
+			if ev.Type == sony_remote_ble.EventCommandSent.String() {
+				commands = append(commands, ev)
+			}
+		}
+		return commands
+	}
+	call := func(req control.Request) {
+		t.Helper()
+		reply, err := client.Call(ctx, req, collect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reply.OK {
+			t.Fatalf("%s: %s", req.Action, reply.Error)
+		}
+	}
+
+	call(control.Request{Action: "connect", Device: cam.Address().String(), Timeout: "2s"})
+	call(control.Request{Action: "subscribe"})
+	call(control.Request{Action: "shoot"})
+
+	// Events may trail the reply to the request that caused them
+	deadline := time.Now().Add(2 * time.Second)
+	for len(sent()) < 4 && time.Now().Before(deadline) {
+		call(control.Request{Action: "state"})
+	}
+	photo := sent()
+	if len(photo) != 4 {
+		t.Fatalf("received %d sent commands for a photo, want 4", len(photo))
+	}
+	for i, ev := range photo {
+		if want := hex.EncodeToString(cam.Frames()[i]); ev.Bytes != want {
+			t.Errorf("event %d carries %s, want frame %s", i, ev.Bytes, want)
+		}
+		if i > 0 && ev.Seq != photo[i-1].Seq+1 {
+			t.Errorf("event %d has seq %d after %d", i, ev.Seq, photo[i-1].Seq)
+		}
+	}
+
+	call(control.Request{Action: "unsubscribe"})
+	call(control.Request{Action: "shoot"})
+	call(control.Request{Action: "state"})
+	if n := len(sent()); n != 4 || cam.Shots() != 2 {
+		t.Errorf("received %d sent commands for %d photos, want only the first photo's 4", n, cam.Shots())
+	}
+}
@@ -0,0 +1,274 @@
+package daemon
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/control"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"tinygo.org/x/bluetooth"
+)
+
+// remoteScanTimeout is how long each scan request of a Remote runs; scans
+// are repeated until stopped. The daemon finishes a request even after the
+// client hangs up, so this also bounds how long a stopped scan holds the camera.
+const remoteScanTimeout = 2 * time.Second
+
+// Remote drives the camera of a running daemon with the same methods as a
+// sony_remote_ble.Client, so that the TUI can share a camera the daemon owns.
+//
+// Each request uses its own connection, so requests may come from several
+// goroutines. A separate subscription keeps State and DeviceName current
+// without a round trip, and streams the cameras found while scanning.
+type Remote struct {
+	path   string
+	events *Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu         sync.Mutex
+	state      sony_remote_ble.ConnectionState
+	deviceName string
+	// found receives the cameras advertised while a scan of this Remote runs
+	found    chan<- sony_remote_ble.DeviceInfo
+	scanStop chan struct{}
+}
+
+// DialRemote connects to the daemon listening on path and subscribes to its
+// events. Call Close to disconnect.
+func DialRemote(path string) (*Remote, error) {
+	events, err := Dial(path)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Remote{path: path, events: events, ctx: ctx, cancel: cancel, done: make(chan struct{})}
+
+	reply, err := r.call(ctx, control.Request{Action: "state"})
+	if err != nil {
+		cancel()
+		events.Close()
+		return nil, err
+	}
+	r.update(reply.State)
+
+	go func() {
+		defer close(r.done)
+		events.Subscribe(ctx, r.handle)
+		// The daemon went away; nothing is connected through it any more
+		r.mu.Lock()
+		r.state = sony_remote_ble.Disconnected
+		r.deviceName = ""
+		r.mu.Unlock()
+	}()
+	return r, nil
+}
+
+// Close ends the subscription. It does not disconnect the daemon's camera.
+func (r *Remote) Close() error {
+	r.StopScan()
+	r.cancel()
+	<-r.done
+	return r.events.Close()
+}
+
+// handle follows the daemon's events.
+func (r *Remote) handle(ev control.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch ev.Type {
+	case sony_remote_ble.EventStateChanged.String():
+		r.state = parseState(ev.State)
+		if r.state != sony_remote_ble.Connected {
+			r.deviceName = ""
+			return
+		}
+		// State events do not name the camera, so ask for it
+		go r.call(r.ctx, control.Request{Action: "state"})
+	case sony_remote_ble.EventDeviceFound.String():
+		if r.found == nil || ev.Device == nil {
+			return
+		}
+		device := sony_remote_ble.DeviceInfo{
+			Name:       ev.Device.Name,
+			Address:    sony_remote_ble.ParseAddress(ev.Device.Address),
+			AddressStr: ev.Device.Address,
+			RSSI:       ev.Device.RSSI,
+		}
+		// Never block the subscription on a slow reader
+		select {
+		case r.found <- device:
+		default:
+		}
+	}
+}
+
+// update copies the state carried by a reply.
+func (r *Remote) update(state *control.State) {
+	if state == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state = parseState(state.State)
+	r.deviceName = ""
+	if state.Device != nil {
+		r.deviceName = state.Device.Address
+	}
+}
+
+// call performs a request on a connection of its own and returns an error
+// for replies that are not OK.
+func (r *Remote) call(ctx context.Context, req control.Request) (control.Reply, error) {
+	client, err := Dial(r.path)
+	if err != nil {
+		return control.Reply{}, err
+	}
+	defer client.Close()
+
+	reply, err := client.Call(ctx, req, nil)
+	if err != nil {
+		return reply, err
+	}
+	if !reply.OK {
+		return reply, errors.New(reply.Error)
+	}
+	r.update(reply.State)
+	return reply, nil
+}
+
+// do performs a request that has no deadline of its own.
+func (r *Remote) do(req control.Request) error {
+	_, err := r.call(context.Background(), req)
+	return err
+}
+
+// State returns the daemon's connection state as of its last event.
+func (r *Remote) State() sony_remote_ble.ConnectionState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+// DeviceName returns the address of the daemon's connected camera, or "".
+func (r *Remote) DeviceName() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.deviceName
+}
+
+// SetCommandDelay does nothing; the daemon applies its own timing.
+func (r *Remote) SetCommandDelay(time.Duration) {}
+
+// SetTimingProfile does nothing; the daemon applies its own timing.
+func (r *Remote) SetTimingProfile(sony_remote_ble.TimingProfile) {}
+
+// ScanForDevices asks the daemon to scan until StopScan is called or ctx
+// ends, and sends every camera it finds to deviceChan.
+func (r *Remote) ScanForDevices(ctx context.Context, deviceChan chan<- sony_remote_ble.DeviceInfo) error {
+	r.StopScan()
+	stop := make(chan struct{})
+	r.mu.Lock()
+	r.found = deviceChan
+	r.scanStop = stop
+	r.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		for ctx.Err() == nil {
+			_, err := r.call(ctx, control.Request{Action: "scan", Timeout: remoteScanTimeout.String()})
+			if err != nil && ctx.Err() == nil {
+				// The daemon is busy with the camera; try again shortly
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Second):
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// StopScan stops a scan started with ScanForDevices.
+func (r *Remote) StopScan() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.scanStop != nil {
+		close(r.scanStop)
+		r.scanStop = nil
+	}
+	r.found = nil
+}
+
+// Connect asks the daemon to connect to the camera at address.
+func (r *Remote) Connect(address bluetooth.Address) error {
+	return r.do(control.Request{Action: "connect", Device: address.String()})
+}
+
+// Disconnect asks the daemon to disconnect its camera.
+func (r *Remote) Disconnect() error {
+	return r.do(control.Request{Action: "disconnect"})
+}
+
+// Shared reports that the camera belongs to the daemon, so that the TUI
+// leaves it connected for the daemon's other clients when it quits.
+func (r *Remote) Shared() bool { return true }
+
+// SendCommand sends a single command through the daemon.
+func (r *Remote) SendCommand(cmd sony_remote_ble.SonyCommand) error {
+	return r.do(control.Request{Action: "sequence", Commands: []string{"0x" + hex.EncodeToString(cmd.Code)}})
+}
+
+// Press presses a button through the daemon.
+func (r *Remote) Press(button string, hold time.Duration) error {
+	return r.do(control.Request{Action: "press", Button: button, Hold: hold.String()})
+}
+
+// Zoom zooms through the daemon, sending the release after hold.
+func (r *Remote) Zoom(in bool, speed byte, hold time.Duration) error {
+	release := sony_remote_ble.Commands["zoom_out_up"]
+	if in {
+		release = sony_remote_ble.Commands["zoom_in_up"]
+	}
+
+	if err := r.SendCommand(sony_remote_ble.ZoomCommand(in, speed)); err != nil {
+		return err
+	}
+	if hold > 0 {
+		time.Sleep(hold)
+	}
+	return r.SendCommand(release)
+}
+
+// TakePhoto takes a photo through the daemon.
+func (r *Remote) TakePhoto() error {
+	return r.do(control.Request{Action: "shoot"})
+}
+
+// parseState converts the name of a connection state back into the state.
+func parseState(name string) sony_remote_ble.ConnectionState {
+	for _, state := range []sony_remote_ble.ConnectionState{
+		sony_remote_ble.Disconnected,
+		sony_remote_ble.Scanning,
+		sony_remote_ble.Connecting,
+		sony_remote_ble.Connected,
+		sony_remote_ble.Error,
+	} {
+		if state.String() == name {
+			return state
+		}
+	}
+	return sony_remote_ble.Disconnected
+}
@@ -0,0 +1,138 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/systemd"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// maxOperation is how long an operation may hold the link beyond the scan
+// timeout before the link is considered wedged.
+const maxOperation = time.Minute
+
+// supervisor reports readiness, status and link health to systemd. Outside
+// systemd every notification is a no-op.
+type supervisor struct {
+	s *Server
+	// status carries the latest status line to the notifier goroutine
+	status chan string
+
+	// lostSince is when the configured camera was last seen disconnecting, zero while connected
+	lostSince time.Time
+}
+
+func newSupervisor(s *Server) *supervisor {
+	return &supervisor{
+		s:         s,
+		status:    make(chan string, 1),
+		lostSince: time.Now(),
+	}
+}
+
+// run sends status updates and watchdog pings until ctx is cancelled, then
+// tells systemd the service is stopping.
+func (sv *supervisor) run(ctx context.Context) {
+	remove := sv.s.sess.OnEvent(func(ev sony_remote_ble.Event) {
+		if ev.Type == sony_remote_ble.EventStateChanged {
+			sv.setStatus(sv.describe(ev.State))
+		}
+	})
+	defer remove()
+	sv.setStatus(sv.describe(sv.s.sess.State()))
+
+	var watchdog <-chan time.Time
+	interval, watchdogEnabled := systemd.WatchdogInterval()
+	if watchdogEnabled {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		watchdog = ticker.C
+		sv.s.logger.Debug("systemd watchdog enabled", "interval", interval)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			sv.notify(systemd.Stopping)
+			return
+		case status := <-sv.status:
+			sv.notify(systemd.Status(status))
+		case <-watchdog:
+			if err := sv.health(); err != nil {
+				// Withholding the ping lets systemd restart the service,
+				// which resets BlueZ state that a reconnect cannot
+				sv.s.logger.Warn("link unhealthy, skipping watchdog ping", "error", err)
+				continue
+			}
+			sv.notify(systemd.Watchdog)
+		}
+	}
+}
+
+// ready tells systemd that the daemon is serving.
+func (sv *supervisor) ready() {
+	sv.notify(systemd.Ready)
+}
+
+// setStatus queues a status line, replacing one that has not been sent yet.
+func (sv *supervisor) setStatus(status string) {
+	for {
+		select {
+		case sv.status <- status:
+			return
+		default:
+			select {
+			case <-sv.status:
+			default:
+			}
+		}
+	}
+}
+
+func (sv *supervisor) describe(state sony_remote_ble.ConnectionState) string {
+	if state == sony_remote_ble.Connected {
+		if device, ok := sv.s.sess.Device(); ok {
+			return fmt.Sprintf("Connected to %s (%s)", device.Name, device.AddressStr)
+		}
+	}
+	if state == sony_remote_ble.Error {
+		if err := sv.s.sess.LastError(); err != nil {
+			return "Error: " + err.Error()
+		}
+	}
+	return state.String()
+}
+
+// health reports why the link is unhealthy, or nil if it is fine. The link is
+// unhealthy when an operation has held it for far longer than any command
+// takes, or when the configured camera has been disconnected for longer than
+// the grace period.
+func (sv *supervisor) health() error {
+	opts := sv.s.opts
+	if held := sv.s.sess.HeldFor(); held > opts.ScanTimeout+maxOperation {
+		return fmt.Errorf("operation has held the link for %s", held.Round(time.Second))
+	}
+	if opts.Device == "" || opts.LinkGrace <= 0 {
+		return nil
+	}
+
+	if sv.s.sess.State() == sony_remote_ble.Connected {
+		sv.lostSince = time.Time{}
+		return nil
+	}
+	if sv.lostSince.IsZero() {
+		sv.lostSince = time.Now()
+	}
+	if lost := time.Since(sv.lostSince); lost > opts.LinkGrace {
+		return fmt.Errorf("camera %s disconnected for %s", opts.Device, lost.Round(time.Second))
+	}
+	return nil
+}
+
+func (sv *supervisor) notify(state string) {
+	if _, err := systemd.Notify(state); err != nil {
+		sv.s.logger.Debug("systemd notify failed", "state", state, "error", err)
+	}
+}
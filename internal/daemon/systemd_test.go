@@ -0,0 +1,168 @@
+package daemon_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/control"
+	"github.com/smazurov/sony_remote_ble/internal/daemon"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/simulator"
+)
+
+// serviceManager receives the notifications a service sends to systemd.
+type serviceManager struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+// listenNotify points NOTIFY_SOCKET at a datagram socket in a temporary
+// directory and collects what arrives on it until the test ends.
+func listenNotify(t *testing.T) *serviceManager {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	m := &serviceManager{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			m.mu.Lock()
+			m.messages = append(m.messages, string(buf[:n]))
+			m.mu.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		conn.Close()
+		<-done
+	})
+	return m
+}
+
+// received returns the messages received so far.
+func (m *serviceManager) received() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.messages)
+}
+
+// count returns how many messages equal msg.
+func (m *serviceManager) count(msg string) int {
+	n := 0
+	for _, got := range m.received() {
+		if got == msg {
+			n++
+		}
+	}
+	return n
+}
+
+// waitFor waits until a message with prefix arrives after the first skip
+// messages and returns its index.
+func (m *serviceManager) waitFor(t *testing.T, skip int, prefix string) int {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		messages := m.received()
+		for i := skip; i < len(messages); i++ {
+			if strings.HasPrefix(messages[i], prefix) {
+				return i
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no %s after %q", prefix, messages[min(skip, len(messages)):])
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// assertNoPings fails if the watchdog is pinged during d.
+func (m *serviceManager) assertNoPings(t *testing.T, d time.Duration, why string) {
+	t.Helper()
+	before := m.count("WATCHDOG=1")
+	time.Sleep(d)
+	if n := m.count("WATCHDOG=1") - before; n > 0 {
+		t.Errorf("watchdog pinged %d times %s", n, why)
+	}
+}
+
+func TestSystemdFollowsLink(t *testing.T) {
+	const grace = 150 * time.Millisecond
+	manager := listenNotify(t)
+	t.Setenv("WATCHDOG_USEC", "60000")
+	t.Setenv("WATCHDOG_PID", "")
+	// Registered first, so it runs once the daemon has stopped
+	t.Cleanup(func() {
+		stopping := manager.waitFor(t, 0, "STOPPING=1")
+		if messages := manager.received(); stopping != len(messages)-1 {
+			t.Errorf("notifications %q after STOPPING=1", messages[stopping+1:])
+		}
+	})
+
+	cam := newCamera(simulator.Options{})
+	cam.SetAdvertising(false)
+	path := startDaemon(t, cam, daemon.Options{
+		Device:      cam.Address().String(),
+		ScanTimeout: 100 * time.Millisecond,
+		LinkGrace:   grace,
+	})
+
+	// Starting up counts against the grace too
+	manager.waitFor(t, 0, "STATUS=Scanning")
+	time.Sleep(2 * grace)
+	manager.assertNoPings(t, 3*grace, "before the camera ever connected")
+	if manager.count("READY=1") != 0 {
+		t.Fatalf("ready before the camera connected: %q", manager.received())
+	}
+
+	// The daemon retries after its backoff and then reports ready
+	cam.SetAdvertising(true)
+	ready := manager.waitFor(t, 0, "READY=1")
+	manager.waitFor(t, 0, "STATUS=Connected to "+cam.Device().Name+" ("+cam.Address().String()+")")
+	manager.waitFor(t, ready, "WATCHDOG=1")
+	if manager.count("READY=1") != 1 {
+		t.Errorf("ready sent %d times, want once", manager.count("READY=1"))
+	}
+
+	// Losing the camera for longer than the grace withholds the pings
+	client, err := daemon.Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	cam.SetAdvertising(false)
+	lost := len(manager.received())
+	if reply, err := client.Call(context.Background(), control.Request{Action: "disconnect"}, nil); err != nil || !reply.OK {
+		t.Fatalf("disconnect: %+v %v", reply, err)
+	}
+	manager.waitFor(t, lost, "STATUS=Disconnected")
+	time.Sleep(2 * grace)
+	manager.assertNoPings(t, 3*grace, "while the camera was lost")
+
+	// Reconnecting restores them, without a second READY=1
+	cam.SetAdvertising(true)
+	back := len(manager.received())
+	reply, err := client.Call(context.Background(), control.Request{Action: "connect", Device: cam.Address().String(), Timeout: "1s"}, nil)
+	if err != nil || !reply.OK {
+		t.Fatalf("connect: %+v %v", reply, err)
+	}
+	manager.waitFor(t, back, "WATCHDOG=1")
+	if manager.count("READY=1") != 1 {
+		t.Errorf("ready sent %d times, want once", manager.count("READY=1"))
+	}
+}
@@ -0,0 +1,248 @@
+// Package known persists the cameras the application has successfully connected to,
+// so they can be listed and reconnected without waiting for a manual scan.
+//
+// Cameras are stored as JSON in $XDG_STATE_HOME/sony-remote/cameras.json
+// (falling back to ~/.local/state/sony-remote/cameras.json).
+package known
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// Camera is a camera the application has connected to before.
+type Camera struct {
+	// Address is the Bluetooth address string used to reconnect
+	Address string `json:"address"`
+	// Name is the name the camera advertised when last connected
+	Name string `json:"name"`
+	// Model is the Sony model code derived from the name, if any
+	Model string `json:"model,omitempty"`
+	// LastConnected is when the last successful connection was made
+	LastConnected time.Time `json:"last_connected"`
+	// Calibration is the camera's measured shutter latency, if it was calibrated
+	Calibration *Calibration `json:"calibration,omitempty"`
+}
+
+// Calibration is the stored result of calibrating a camera's shutter latency.
+type Calibration struct {
+	// Offset is the time from sending a full press to the exposure
+	Offset time.Duration `json:"offset_ns"`
+	// Jitter is the standard deviation of the measured latencies
+	Jitter time.Duration `json:"jitter_ns"`
+	// Trials is how many photos the calibration took
+	Trials int `json:"trials"`
+	// At is when the camera was calibrated
+	At time.Time `json:"calibrated_at"`
+}
+
+// DeviceInfo converts the camera into a DeviceInfo that can be passed to Client.Connect.
+// The RSSI is zero because the camera has not necessarily been seen in this session.
+func (c Camera) DeviceInfo() sony_remote_ble.DeviceInfo {
+	return sony_remote_ble.DeviceInfo{
+		Name:       c.Name,
+		Address:    sony_remote_ble.ParseAddress(c.Address),
+		AddressStr: c.Address,
+	}
+}
+
+// Store is the set of known cameras backed by a JSON file.
+// It is safe for concurrent use.
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	cameras []Camera
+}
+
+// DefaultPath returns the location of the known cameras file, honouring XDG_STATE_HOME.
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot locate state directory: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "sony-remote", "cameras.json"), nil
+}
+
+// Open loads the known cameras from path. A missing file yields an empty store.
+func Open(path string) (*Store, error) {
+	cameras, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{path: path, cameras: cameras}
+	s.sort()
+	return s, nil
+}
+
+// Cameras returns the known cameras, most recently connected first.
+func (s *Store) Cameras() []Camera {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Camera(nil), s.cameras...)
+}
+
+// MostRecent returns the camera that was connected most recently.
+func (s *Store) MostRecent() (Camera, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.cameras) == 0 {
+		return Camera{}, false
+	}
+	return s.cameras[0], true
+}
+
+// Lookup finds a known camera by address or name, ignoring case.
+func (s *Store) Lookup(query string) (Camera, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, camera := range s.cameras {
+		if strings.EqualFold(camera.Address, query) || strings.EqualFold(camera.Name, query) {
+			return camera, true
+		}
+	}
+	return Camera{}, false
+}
+
+// Remember records a successful connection to a device and saves the store.
+// A stored calibration is kept.
+func (s *Store) Remember(device sony_remote_ble.DeviceInfo, at time.Time) error {
+	camera := Camera{
+		Address:       device.AddressStr,
+		Name:          device.Name,
+		Model:         sony_remote_ble.ModelFromName(device.Name),
+		LastConnected: at,
+	}
+	return s.update(func(cameras []Camera) ([]Camera, error) {
+		for i := range cameras {
+			if strings.EqualFold(cameras[i].Address, camera.Address) {
+				camera.Calibration = cameras[i].Calibration
+				cameras[i] = camera
+				return cameras, nil
+			}
+		}
+		return append(cameras, camera), nil
+	})
+}
+
+// SetCalibration stores the calibration of a known camera and saves the store.
+func (s *Store) SetCalibration(address string, calibration Calibration) error {
+	return s.update(func(cameras []Camera) ([]Camera, error) {
+		for i := range cameras {
+			if strings.EqualFold(cameras[i].Address, address) {
+				cameras[i].Calibration = &calibration
+				return cameras, nil
+			}
+		}
+		return nil, fmt.Errorf("camera %s is not known", address)
+	})
+}
+
+// Forget removes a camera from the store and saves it.
+func (s *Store) Forget(address string) error {
+	return s.update(func(cameras []Camera) ([]Camera, error) {
+		return slices.DeleteFunc(cameras, func(camera Camera) bool {
+			return strings.EqualFold(camera.Address, address)
+		}), nil
+	})
+}
+
+func (s *Store) sort() {
+	sort.SliceStable(s.cameras, func(i, j int) bool {
+		return s.cameras[i].LastConnected.After(s.cameras[j].LastConnected)
+	})
+}
+
+// update applies change to the cameras in the file and saves the result.
+// Other processes, such as the daemon and a CLI command, share the file, so
+// update holds a lock on it and re-reads it first: the change then lands on
+// top of theirs instead of overwriting them with this store's older copy.
+func (s *Store) update(change func(cameras []Camera) ([]Camera, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("save known cameras: %w", err)
+	}
+	lock, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("lock known cameras: %w", err)
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("lock known cameras: %w", err)
+	}
+
+	cameras, err := load(s.path)
+	if err != nil {
+		return err
+	}
+	if cameras, err = change(cameras); err != nil {
+		return err
+	}
+	if err := save(s.path, cameras); err != nil {
+		return err
+	}
+	s.cameras = cameras
+	s.sort()
+	return nil
+}
+
+// load reads the cameras from path. A missing file holds no cameras.
+func load(path string) ([]Camera, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read known cameras: %w", err)
+	}
+	var cameras []Camera
+	if err := json.Unmarshal(data, &cameras); err != nil {
+		return nil, fmt.Errorf("parse known cameras %s: %w", path, err)
+	}
+	return cameras, nil
+}
+
+// save writes cameras to path atomically so a crash never leaves a truncated file.
+func save(path string, cameras []Camera) error {
+	data, err := json.MarshalIndent(cameras, "", "  ")
+	if err != nil {
+		return fmt.Errorf("save known cameras: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cameras-*.json")
+	if err != nil {
+		return fmt.Errorf("save known cameras: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save known cameras: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save known cameras: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("save known cameras: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package known_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/known"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// at is the fixed time of the connections the tests remember.
+var at = time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+
+// device returns a camera advertising as name at address.
+func device(name, address string) sony_remote_ble.DeviceInfo {
+	return sony_remote_ble.DeviceInfo{Name: name, Address: sony_remote_ble.ParseAddress(address), AddressStr: address}
+}
+
+// open opens the store at path, failing the test on error.
+func open(t *testing.T, path string) *known.Store {
+	t.Helper()
+	store, err := known.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestStoresSharingFileMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cameras.json")
+	// The daemon and a CLI command each open the file before the other saves
+	daemon, cli := open(t, path), open(t, path)
+
+	if err := daemon.Remember(device("ILCE-7M4", "C0:FF:EE:00:00:01"), at); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Remember(device("ZV-E10", "C0:FF:EE:00:00:02"), at.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	calibration := known.Calibration{Offset: 42 * time.Millisecond, Jitter: time.Millisecond, Trials: 10, At: at}
+	if err := cli.SetCalibration("c0:ff:ee:00:00:01", calibration); err != nil {
+		t.Fatalf("calibrating the camera the other store remembered: %v", err)
+	}
+	if err := daemon.Remember(device("ILCE-7M4", "C0:FF:EE:00:00:01"), at.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	cameras := open(t, path).Cameras()
+	if len(cameras) != 2 {
+		t.Fatalf("file holds %+v, want both cameras", cameras)
+	}
+	if cameras[0].Name != "ILCE-7M4" || cameras[0].Calibration == nil || cameras[0].Calibration.Offset != calibration.Offset {
+		t.Errorf("first camera %+v, want the ILCE-7M4 with the calibration kept", cameras[0])
+	}
+	if got := daemon.Cameras(); len(got) != 2 {
+		t.Errorf("saving store holds %d cameras, want the other's too", len(got))
+	}
+
+	if err := daemon.Forget("C0:FF:EE:00:00:02"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.SetCalibration("C0:FF:EE:00:00:02", calibration); err == nil {
+		t.Error("calibrated a camera another store forgot")
+	}
+}
+
+func TestConcurrentRemember(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cameras.json")
+	var wg sync.WaitGroup
+	for i := range 4 {
+		store := open(t, path)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 16 {
+				address := fmt.Sprintf("C0:FF:EE:00:%02X:%02X", i, j)
+				if err := store.Remember(device("ILCE-7M4", address), at); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(open(t, path).Cameras()); n != 64 {
+		t.Errorf("file holds %d cameras, want all 64", n)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "sony-remote", "cameras.json")
+	store := open(t, path)
+	if len(store.Cameras()) != 0 {
+		t.Fatalf("missing file holds %+v", store.Cameras())
+	}
+	if _, ok := store.MostRecent(); ok {
+		t.Fatal("empty store has a most recent camera")
+	}
+
+	calibration := known.Calibration{Offset: 42 * time.Millisecond, Jitter: 3 * time.Millisecond, Trials: 10, At: at}
+	if err := store.Remember(device("ILCE-7M4", "C0:FF:EE:00:00:01"), at); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetCalibration("C0:FF:EE:00:00:01", calibration); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Remember(device("Studio DSC-RX100M7", "C0:FF:EE:00:00:02"), at.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// Reconnecting keeps the calibration
+	if err := store.Remember(device("ILCE-7M4", "c0:ff:ee:00:00:01"), at.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := open(t, path)
+	want := []known.Camera{
+		{Address: "C0:FF:EE:00:00:02", Name: "Studio DSC-RX100M7", Model: "DSC-RX100M7", LastConnected: at.Add(time.Hour)},
+		{Address: "c0:ff:ee:00:00:01", Name: "ILCE-7M4", Model: "ILCE-7M4", LastConnected: at.Add(time.Minute), Calibration: &calibration},
+	}
+	got := reopened.Cameras()
+	if len(got) != len(want) {
+		t.Fatalf("reopened store holds %+v, want %+v", got, want)
+	}
+	for i := range want {
+		gotCalibration, wantCalibration := got[i].Calibration, want[i].Calibration
+		got[i].Calibration, want[i].Calibration = nil, nil
+		if !got[i].LastConnected.Equal(want[i].LastConnected) {
+			t.Errorf("camera %d connected at %s, want %s", i, got[i].LastConnected, want[i].LastConnected)
+		}
+		got[i].LastConnected, want[i].LastConnected = time.Time{}, time.Time{}
+		if got[i] != want[i] {
+			t.Errorf("camera %d: %+v, want %+v", i, got[i], want[i])
+		}
+		if (gotCalibration == nil) != (wantCalibration == nil) || gotCalibration != nil && (gotCalibration.Offset != wantCalibration.Offset || gotCalibration.Jitter != wantCalibration.Jitter || gotCalibration.Trials != wantCalibration.Trials || !gotCalibration.At.Equal(wantCalibration.At)) {
+			t.Errorf("camera %d calibration %+v, want %+v", i, gotCalibration, wantCalibration)
+		}
+	}
+
+	if recent, ok := reopened.MostRecent(); !ok || recent.Address != "C0:FF:EE:00:00:02" {
+		t.Errorf("most recent %+v, want the RX100 VII", recent)
+	}
+	if camera, ok := reopened.Lookup("ilce-7m4"); !ok || !strings.EqualFold(camera.DeviceInfo().AddressStr, "C0:FF:EE:00:00:01") {
+		t.Errorf("lookup by name found %+v", camera)
+	}
+	if _, ok := reopened.Lookup("C0:FF:EE:00:00:09"); ok {
+		t.Error("lookup found a camera that was never connected")
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); name != "cameras.json" && name != "cameras.json.lock" {
+			t.Errorf("saving left %s behind", name)
+		}
+	}
+}
+
+func TestCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cameras.json")
+	corrupt := []byte(`[{"address": "C0:FF:EE:00:00:01", "name": `)
+	if err := os.WriteFile(path, corrupt, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := known.Open(path); err == nil || !strings.Contains(err.Error(), "parse known cameras "+path) {
+		t.Errorf("opening a corrupt file: %v, want a parse error naming it", err)
+	}
+
+	// A store opened before the file was damaged does not overwrite it
+	if err := os.WriteFile(path, []byte("[]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := open(t, path)
+	if err := os.WriteFile(path, corrupt, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Remember(device("ILCE-7M4", "C0:FF:EE:00:00:01"), at); err == nil {
+		t.Error("remembered a camera on top of a corrupt file")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != string(corrupt) {
+		t.Errorf("file now holds %q (%v), want it left for inspection", data, err)
+	}
+}
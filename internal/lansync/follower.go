@@ -0,0 +1,286 @@
+package lansync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+const (
+	// syncSamples is how many pings each clock measurement takes; the one with
+	// the shortest round trip is the least disturbed by queueing and wins
+	syncSamples = 8
+	// syncInterval is how often followers measure their offset again, which
+	// follows the drift between the clocks
+	syncInterval = 30 * time.Second
+	// pongTimeout bounds how long a follower waits for each pong
+	pongTimeout = 2 * time.Second
+	// reconnectDelay is the pause before a follower reconnects to its leader
+	reconnectDelay = 2 * time.Second
+)
+
+// errNotSynced is reported for fires that arrive before the first clock measurement.
+var errNotSynced = errors.New("clock offset not measured yet")
+
+// FollowerOptions configures a Follower.
+type FollowerOptions struct {
+	// Leader is the host:port of the leader
+	Leader string
+	// Name identifies the follower to the leader
+	Name string
+	// Device is the alias, name or address of the camera to fire
+	Device string
+	// ScanTimeout bounds each attempt to find the camera
+	ScanTimeout time.Duration
+}
+
+// Follower fires its session's camera when its leader says so.
+type Follower struct {
+	sess   *session.Session
+	opts   FollowerOptions
+	logger *slog.Logger
+
+	mu sync.Mutex
+	// clock is the latest measurement; synced is false until the first one
+	clock  clockSample
+	synced bool
+}
+
+// NewFollower creates a follower for sess.
+func NewFollower(sess *session.Session, opts FollowerOptions, logger *slog.Logger) *Follower {
+	return &Follower{
+		sess:   sess,
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// Run registers with the leader and follows it until ctx is cancelled,
+// reconnecting whenever the connection to the leader is lost.
+func (f *Follower) Run(ctx context.Context) error {
+	for {
+		err := f.follow(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		f.logger.Warn("leader connection lost", "leader", f.opts.Leader, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// pong is a pong with the time it arrived.
+type pong struct {
+	msg      message
+	received time.Time
+}
+
+// follow serves one connection to the leader.
+func (f *Follower) follow(ctx context.Context) error {
+	var dialer net.Dialer
+	nc, err := dialer.DialContext(ctx, "tcp", f.opts.Leader)
+	if err != nil {
+		return err
+	}
+	c := newConn(nc)
+	defer c.Close()
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	defer stop()
+
+	device := f.opts.Device
+	if connected, ok := f.sess.Device(); ok {
+		device = connected.Name
+	}
+	if err := c.send(message{Type: "hello", Name: f.opts.Name, Device: device}); err != nil {
+		return err
+	}
+	f.logger.Info("registered with leader", "leader", f.opts.Leader, "name", f.opts.Name)
+
+	pongs := make(chan pong, syncSamples)
+	lost := make(chan error, 1)
+	go func() {
+		for {
+			msg, received, err := c.receive()
+			if err != nil {
+				lost <- err
+				return
+			}
+			switch msg.Type {
+			case "pong":
+				select {
+				case pongs <- pong{msg: msg, received: received}:
+				default:
+				}
+			case "fire":
+				go f.fire(ctx, c, msg)
+			default:
+				f.logger.Debug("unexpected message from leader", "type", msg.Type)
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	for {
+		if err := f.sync(c, pongs); err != nil {
+			c.Close()
+			<-lost
+			return err
+		}
+		select {
+		case err := <-lost:
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync measures the offset to the leader's clock and reports it to the leader.
+func (f *Follower) sync(c *conn, pongs <-chan pong) error {
+	var best clockSample
+	measured := false
+	for range syncSamples {
+		t0 := time.Now().UnixNano()
+		if err := c.send(message{Type: "ping", T0: t0}); err != nil {
+			return err
+		}
+
+		timeout := time.NewTimer(pongTimeout)
+	wait:
+		for {
+			select {
+			case p := <-pongs:
+				// A pong of an earlier ping that timed out is ignored
+				if p.msg.T0 != t0 {
+					continue
+				}
+				sample := newClockSample(t0, p.msg.T1, p.msg.T2, p.received.UnixNano())
+				if !measured || sample.rtt < best.rtt {
+					best = sample
+				}
+				measured = true
+				break wait
+			case <-timeout.C:
+				break wait
+			}
+		}
+		timeout.Stop()
+	}
+	if !measured {
+		return fmt.Errorf("leader did not answer %d pings", syncSamples)
+	}
+
+	f.mu.Lock()
+	f.clock = best
+	f.synced = true
+	f.mu.Unlock()
+	f.logger.Debug("clock synced", "offset", best.offset, "rtt", best.rtt)
+	return c.send(message{Type: "sync", Offset: int64(best.offset), RTT: int64(best.rtt)})
+}
+
+// fire performs a fire from the leader and reports the outcome.
+func (f *Follower) fire(ctx context.Context, c *conn, msg message) {
+	f.mu.Lock()
+	clock, synced := f.clock, f.synced
+	f.mu.Unlock()
+
+	reply := message{Type: "result", ID: msg.ID}
+	if !synced {
+		reply.Error = errNotSynced.Error()
+	} else {
+		target := time.Unix(0, msg.At).Add(-clock.offset)
+		fired, err := f.execute(ctx, msg.Action, target)
+		if err != nil {
+			reply.Error = err.Error()
+			f.logger.Warn("fire failed", "id", msg.ID, "action", msg.Action, "error", err)
+		} else {
+			reply.At = fired.Add(clock.offset).UnixNano()
+			f.logger.Info("fired", "id", msg.ID, "action", msg.Action, "late", fired.Sub(target))
+		}
+	}
+	if err := c.send(reply); err != nil {
+		f.logger.Warn("could not report fire", "id", msg.ID, "error", err)
+	}
+}
+
+// execute arms the camera, waits for target on the local clock and fires.
+// It returns when the firing command reached the camera. For cameras with a
+// stored calibration the command is sent the calibrated latency early, so
+// that the exposure falls on target, and the returned time is the expected
+// exposure.
+func (f *Follower) execute(ctx context.Context, action string, target time.Time) (time.Time, error) {
+	var arm, release []sony_remote_ble.SonyCommand
+	var trigger sony_remote_ble.SonyCommand
+	switch action {
+	case ActionShoot:
+		arm = []sony_remote_ble.SonyCommand{sony_remote_ble.Commands["shutter_half_down"]}
+		trigger = sony_remote_ble.Commands["shutter_full_down"]
+		release = []sony_remote_ble.SonyCommand{sony_remote_ble.Commands["shutter_full_up"], sony_remote_ble.Commands["shutter_half_up"]}
+	case ActionRecord:
+		trigger = sony_remote_ble.Commands["record_down"]
+		release = []sony_remote_ble.SonyCommand{sony_remote_ble.Commands["record_up"]}
+	default:
+		return time.Time{}, fmt.Errorf("unknown action %q", action)
+	}
+
+	device, _, err := f.sess.EnsureConnected(ctx, f.opts.Device, f.opts.ScanTimeout)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var latency time.Duration
+	if store := f.sess.Known(); store != nil && action == ActionShoot {
+		if camera, ok := store.Lookup(device.AddressStr); ok && camera.Calibration != nil {
+			latency = camera.Calibration.Offset
+		}
+	}
+
+	var fired time.Time
+	err = f.sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+		for _, cmd := range arm {
+			if err := client.SendCommand(cmd); err != nil {
+				return err
+			}
+		}
+
+		timer := time.NewTimer(time.Until(target.Add(-latency)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			// Do not leave the camera armed
+			timer.Stop()
+			for _, cmd := range release {
+				client.SendCommand(cmd)
+			}
+			return ctx.Err()
+		}
+
+		sent := time.Now()
+		err := client.SendCommand(trigger)
+		fired = time.Now()
+		if latency > 0 {
+			fired = sent.Add(latency)
+		}
+		if err != nil {
+			return err
+		}
+		for _, cmd := range release {
+			time.Sleep(client.CommandDelay())
+			if err := client.SendCommand(cmd); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return fired, err
+}
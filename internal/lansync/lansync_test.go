@@ -0,0 +1,215 @@
+package lansync_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/lansync"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/simulator"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// startLeader serves a leader on a loopback port until the test ends.
+func startLeader(t *testing.T, opts lansync.Options) (*lansync.Leader, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader := lansync.NewLeader(opts, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- leader.Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+	return leader, ln.Addr().String()
+}
+
+// startFollower runs a follower driving cam until the test ends.
+func startFollower(t *testing.T, leader, name string, cam *simulator.Camera) {
+	t.Helper()
+	sess := session.New(sony_remote_ble.NewClientWithTransport(cam), config.Default(), nil, logger)
+	follower := lansync.NewFollower(sess, lansync.FollowerOptions{
+		Leader:      leader,
+		Name:        name,
+		Device:      cam.Address().String(),
+		ScanTimeout: time.Second,
+	}, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- follower.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// startFake registers a hand-written follower that reports a clock offset
+// and calls onFire with its connection for every fire instead of reporting.
+func startFake(t *testing.T, leader, name string, onFire func(net.Conn)) {
+	t.Helper()
+	c, err := net.Dial("tcp", leader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	fmt.Fprintf(c, "{\"type\":\"hello\",\"name\":%q}\n{\"type\":\"sync\",\"offset\":0,\"rtt\":1000}\n", name)
+	go func() {
+		scanner := bufio.NewScanner(c)
+		for scanner.Scan() {
+			if strings.Contains(scanner.Text(), `"type":"fire"`) {
+				onFire(c)
+			}
+		}
+	}()
+}
+
+// newCamera creates a simulated camera at a distinct address.
+func newCamera(address string) *simulator.Camera {
+	return simulator.New(simulator.Options{Address: address, AdvertiseInterval: 10 * time.Millisecond})
+}
+
+// waitFollowers waits for n synced followers, failing the test after a few seconds.
+func waitFollowers(t *testing.T, leader *lansync.Leader, n int) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := leader.WaitFollowers(ctx, n); err != nil {
+		t.Fatalf("waiting for %d followers: %v", n, err)
+	}
+}
+
+func TestFireOverLoopback(t *testing.T) {
+	lead := 300 * time.Millisecond
+	leader, addr := startLeader(t, lansync.Options{Lead: lead, ReportTimeout: 2 * time.Second})
+	ctx := context.Background()
+
+	if _, err := leader.Fire(ctx, lansync.ActionShoot); !errors.Is(err, lansync.ErrNoFollowers) {
+		t.Fatalf("fire without followers: got %v, want %v", err, lansync.ErrNoFollowers)
+	}
+
+	left, right := newCamera("C0:FF:EE:00:00:0A"), newCamera("C0:FF:EE:00:00:0B")
+	startFollower(t, addr, "left", left)
+	waitFollowers(t, leader, 1)
+	startFollower(t, addr, "right", right)
+	waitFollowers(t, leader, 2)
+
+	followers := leader.Followers()
+	if len(followers) != 2 || followers[0].Name != "left" || followers[1].Name != "right" {
+		t.Fatalf("followers %+v, want left and right in that order", followers)
+	}
+	if followers[0].Device != left.Address().String() || followers[0].Synced.IsZero() {
+		t.Errorf("left follower %+v, want its camera and a clock measurement", followers[0])
+	}
+	// Both ends share a clock, so the offset is within the round trip
+	for _, f := range followers {
+		if f.Offset > f.RTT || -f.Offset > f.RTT {
+			t.Errorf("%s: offset %v larger than the round trip %v on one host", f.Name, f.Offset, f.RTT)
+		}
+	}
+
+	if _, err := leader.Fire(ctx, "wave"); err == nil {
+		t.Error("fire of an unknown action succeeded")
+	}
+
+	result, err := leader.Fire(ctx, lansync.ActionShoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Action != lansync.ActionShoot || len(result.Reports) != 2 {
+		t.Fatalf("result %+v, want a shoot reported by both followers", result)
+	}
+	for i, name := range []string{"left", "right"} {
+		report := result.Reports[i]
+		if report.Name != name || report.Err != nil {
+			t.Errorf("report %d: %+v, want %s without error", i, report, name)
+		}
+		// Clock measurements on one host are off by microseconds at most
+		if report.Late < -time.Millisecond || report.Late > lead {
+			t.Errorf("%s fired %v late", name, report.Late)
+		}
+	}
+	if skew := result.Skew(); skew > lead {
+		t.Errorf("skew %v", skew)
+	}
+	if left.Shots() != 1 || right.Shots() != 1 {
+		t.Errorf("shots %d and %d, want one each", left.Shots(), right.Shots())
+	}
+
+	// A camera that refuses the shot fails only its own report
+	right.RejectWrites(1)
+	result, err = leader.Fire(ctx, lansync.ActionShoot)
+	if err == nil || !strings.HasPrefix(err.Error(), "right: ") {
+		t.Fatalf("got %v, want the error of the right follower", err)
+	}
+	rejected := result.Reports[1].Err
+	if result.Reports[0].Err != nil || rejected == nil || !strings.Contains(rejected.Error(), simulator.ErrWriteRejected.Error()) {
+		t.Errorf("reports %+v, want only right failing with a rejected write", result.Reports)
+	}
+	if left.Shots() != 2 {
+		t.Errorf("left took %d shots, want 2", left.Shots())
+	}
+}
+
+func TestFireReportsSilentFollowers(t *testing.T) {
+	leader, addr := startLeader(t, lansync.Options{Lead: 50 * time.Millisecond, ReportTimeout: 200 * time.Millisecond})
+
+	cam := newCamera("C0:FF:EE:00:00:0C")
+	startFollower(t, addr, "camera", cam)
+	waitFollowers(t, leader, 1)
+	var once sync.Once
+	startFake(t, addr, "leaving", func(c net.Conn) { once.Do(func() { c.Close() }) })
+	waitFollowers(t, leader, 2)
+	startFake(t, addr, "silent", func(net.Conn) {})
+	waitFollowers(t, leader, 3)
+
+	start := time.Now()
+	result, err := leader.Fire(context.Background(), lansync.ActionShoot)
+	if err == nil {
+		t.Fatal("fire succeeded with followers that did not report")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("fire took %v, want it to end at the report timeout", elapsed)
+	}
+
+	want := []struct{ name, err string }{
+		{"camera", ""},
+		{"leaving", "follower disconnected"},
+		{"silent", "no report before the timeout"},
+	}
+	for i, w := range want {
+		report := result.Reports[i]
+		got := ""
+		if report.Err != nil {
+			got = report.Err.Error()
+		}
+		if report.Name != w.name || got != w.err {
+			t.Errorf("report %d: %s with error %q, want %s with %q", i, report.Name, got, w.name, w.err)
+		}
+	}
+	if cam.Shots() != 1 {
+		t.Errorf("camera took %d shots, want 1", cam.Shots())
+	}
+	if n := len(leader.Followers()); n != 2 {
+		t.Errorf("%d followers registered after one left, want 2", n)
+	}
+}
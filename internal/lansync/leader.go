@@ -0,0 +1,344 @@
+package lansync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// helloTimeout bounds how long a new connection may take to introduce itself.
+const helloTimeout = 10 * time.Second
+
+// ErrNoFollowers is returned by Fire when no follower is registered.
+var ErrNoFollowers = errors.New("no followers registered")
+
+// errFollowerGone is reported for followers that disconnect before reporting.
+var errFollowerGone = errors.New("follower disconnected")
+
+// Options configures a Leader.
+type Options struct {
+	// Lead is how far ahead of the fire time triggers are sent
+	Lead time.Duration
+	// ReportTimeout is how long Fire waits for reports after the fire time
+	ReportTimeout time.Duration
+}
+
+// FollowerInfo describes a registered follower.
+type FollowerInfo struct {
+	// Name identifies the follower
+	Name string
+	// Addr is the follower's network address
+	Addr string
+	// Device is the camera the follower drives, as it reported it
+	Device string
+	// Offset is the leader's clock minus the follower's, as last measured
+	Offset time.Duration
+	// RTT is the network round trip of that measurement
+	RTT time.Duration
+	// Synced is when the follower last measured its offset; zero before the first time
+	Synced time.Time
+}
+
+// Report tells how a fire went on one follower.
+type Report struct {
+	// Name identifies the follower
+	Name string
+	// At is when the command reached the follower's camera, or the expected
+	// exposure for calibrated cameras, on the leader's clock
+	At time.Time
+	// Late is how much later than the fire time the command reached the camera;
+	// negative if it was early
+	Late time.Duration
+	// Err is why the follower did not fire, or nil
+	Err error
+}
+
+// FireResult reports how a fire went on every follower.
+type FireResult struct {
+	// ID numbers the fires of a leader
+	ID uint64
+	// Action is the action that was fired
+	Action string
+	// At is the requested fire time
+	At time.Time
+	// Reports holds one report per follower in the order they registered
+	Reports []Report
+}
+
+// Skew returns the spread between the earliest and the latest camera that fired.
+func (r FireResult) Skew() time.Duration {
+	var first, last time.Time
+	for _, report := range r.Reports {
+		if report.Err != nil {
+			continue
+		}
+		if first.IsZero() || report.At.Before(first) {
+			first = report.At
+		}
+		if report.At.After(last) {
+			last = report.At
+		}
+	}
+	return last.Sub(first)
+}
+
+// Err joins the errors of the followers that did not fire, each prefixed with
+// the follower's name, or returns nil if every follower fired.
+func (r FireResult) Err() error {
+	var errs []error
+	for _, report := range r.Reports {
+		if report.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", report.Name, report.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Leader accepts followers and fires their cameras together.
+type Leader struct {
+	opts   Options
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	followers []*peer
+	nextID    uint64
+	// changed is closed and replaced whenever a follower registers, syncs or leaves
+	changed chan struct{}
+}
+
+// peer is a registered follower.
+type peer struct {
+	conn *conn
+	info FollowerInfo
+	// pending receives the result of each fire awaiting this follower's report
+	pending map[uint64]chan message
+}
+
+// NewLeader creates a leader.
+func NewLeader(opts Options, logger *slog.Logger) *Leader {
+	return &Leader{
+		opts:    opts,
+		logger:  logger,
+		changed: make(chan struct{}),
+	}
+}
+
+// Serve accepts followers on ln until ctx is cancelled.
+func (l *Leader) Serve(ctx context.Context, ln net.Listener) error {
+	l.logger.Info("leader listening", "addr", ln.Addr().String(), "lead", l.opts.Lead)
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accept follower: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.handle(ctx, newConn(c))
+		}()
+	}
+}
+
+// handle registers a follower and answers its messages until it disconnects.
+func (l *Leader) handle(ctx context.Context, c *conn) {
+	defer c.Close()
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	defer stop()
+
+	c.SetReadDeadline(time.Now().Add(helloTimeout))
+	hello, _, err := c.receive()
+	if err != nil || hello.Type != "hello" || hello.Name == "" {
+		l.logger.Warn("rejected follower", "addr", c.RemoteAddr().String(), "error", err)
+		return
+	}
+	c.SetReadDeadline(time.Time{})
+
+	p := &peer{
+		conn:    c,
+		info:    FollowerInfo{Name: hello.Name, Addr: c.RemoteAddr().String(), Device: hello.Device},
+		pending: make(map[uint64]chan message),
+	}
+	l.register(p)
+	defer l.unregister(p)
+
+	for {
+		msg, received, err := c.receive()
+		if err != nil {
+			if ctx.Err() == nil {
+				l.logger.Info("follower left", "name", p.info.Name, "error", err)
+			}
+			return
+		}
+		switch msg.Type {
+		case "ping":
+			err = c.send(message{Type: "pong", T0: msg.T0, T1: received.UnixNano(), T2: time.Now().UnixNano()})
+		case "sync":
+			l.mu.Lock()
+			p.info.Offset = time.Duration(msg.Offset)
+			p.info.RTT = time.Duration(msg.RTT)
+			p.info.Synced = received
+			l.notify()
+			l.mu.Unlock()
+			l.logger.Debug("follower synced", "name", p.info.Name, "offset", p.info.Offset, "rtt", p.info.RTT)
+		case "result":
+			l.mu.Lock()
+			if ch, ok := p.pending[msg.ID]; ok {
+				ch <- msg
+				delete(p.pending, msg.ID)
+			}
+			l.mu.Unlock()
+		default:
+			l.logger.Debug("unexpected message from follower", "name", p.info.Name, "type", msg.Type)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// register adds a follower, replacing an earlier connection under the same
+// name, which is usually a follower that reconnected before the leader
+// noticed it was gone.
+func (l *Leader) register(p *peer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, old := range l.followers {
+		if old.info.Name == p.info.Name {
+			l.logger.Info("follower replaced", "name", p.info.Name, "old", old.info.Addr)
+			old.conn.Close()
+		}
+	}
+	l.followers = append(l.followers, p)
+	l.logger.Info("follower registered", "name", p.info.Name, "addr", p.info.Addr, "device", p.info.Device)
+	l.notify()
+}
+
+// unregister removes a follower and fails the fires it has not reported.
+func (l *Leader) unregister(p *peer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.followers = slices.DeleteFunc(l.followers, func(f *peer) bool { return f == p })
+	for id, ch := range p.pending {
+		ch <- message{Type: "result", ID: id, Error: errFollowerGone.Error()}
+		delete(p.pending, id)
+	}
+	l.notify()
+}
+
+// notify wakes WaitFollowers. Callers hold l.mu.
+func (l *Leader) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Followers returns the registered followers in the order they registered.
+func (l *Leader) Followers() []FollowerInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	infos := make([]FollowerInfo, len(l.followers))
+	for i, p := range l.followers {
+		infos[i] = p.info
+	}
+	return infos
+}
+
+// WaitFollowers waits until at least n followers are registered and have
+// measured their clock offset.
+func (l *Leader) WaitFollowers(ctx context.Context, n int) error {
+	for {
+		l.mu.Lock()
+		synced := 0
+		for _, p := range l.followers {
+			if !p.info.Synced.IsZero() {
+				synced++
+			}
+		}
+		changed := l.changed
+		l.mu.Unlock()
+		if synced >= n {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Fire makes every follower perform action at the same instant, the lead time
+// from now, and waits for their reports. The error joins the failures of the
+// followers that did not fire; the result holds a report for every follower.
+func (l *Leader) Fire(ctx context.Context, action string) (FireResult, error) {
+	if !slices.Contains(Actions, action) {
+		return FireResult{}, fmt.Errorf("unknown action %q", action)
+	}
+
+	l.mu.Lock()
+	l.nextID++
+	result := FireResult{ID: l.nextID, Action: action, At: time.Now().Add(l.opts.Lead)}
+	peers := slices.Clone(l.followers)
+	reports := make([]chan message, len(peers))
+	for i, p := range peers {
+		reports[i] = make(chan message, 1)
+		p.pending[result.ID] = reports[i]
+	}
+	l.mu.Unlock()
+	if len(peers) == 0 {
+		return result, ErrNoFollowers
+	}
+
+	fire := message{Type: "fire", ID: result.ID, Action: action, At: result.At.UnixNano()}
+	for i, p := range peers {
+		if err := p.conn.send(fire); err != nil {
+			l.mu.Lock()
+			delete(p.pending, result.ID)
+			l.mu.Unlock()
+			reports[i] <- message{Error: err.Error()}
+		}
+	}
+
+	wait, cancel := context.WithDeadline(ctx, result.At.Add(l.opts.ReportTimeout))
+	defer cancel()
+	result.Reports = make([]Report, len(peers))
+	for i, p := range peers {
+		report := Report{Name: p.info.Name}
+		select {
+		case msg := <-reports[i]:
+			if msg.Error != "" {
+				report.Err = errors.New(msg.Error)
+			} else {
+				report.At = time.Unix(0, msg.At)
+				report.Late = report.At.Sub(result.At)
+			}
+		case <-wait.Done():
+			report.Err = ctx.Err()
+			if report.Err == nil {
+				report.Err = errors.New("no report before the timeout")
+			}
+		}
+		result.Reports[i] = report
+	}
+
+	l.mu.Lock()
+	for _, p := range peers {
+		delete(p.pending, result.ID)
+	}
+	l.mu.Unlock()
+	l.logger.Info("fired", "id", result.ID, "action", action, "followers", len(peers), "skew", result.Skew())
+	return result, result.Err()
+}
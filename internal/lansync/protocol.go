@@ -0,0 +1,124 @@
+// Package lansync fires cameras attached to different hosts at the same
+// instant. Each camera sits next to a host running a Follower, which owns the
+// camera's session and registers with a single Leader over TCP.
+//
+// Followers measure the offset between their clock and the leader's with an
+// NTP-style exchange when they register and periodically afterwards. To fire,
+// the leader sends every follower the action and a time on its own clock a
+// short lead ahead; each follower converts the time to its local clock, arms
+// the camera, waits and fires, and reports when the command actually reached
+// its camera, again on the leader's clock. Followers whose camera has a stored
+// calibration send the shutter that much early and report the expected
+// exposure instead.
+//
+// Messages are line-delimited JSON objects with a type field:
+//
+//	{"type":"hello","name":"left","device":"ILCE-7M4"}                  follower -> leader
+//	{"type":"ping","t0":1760777523000000000}                             follower -> leader
+//	{"type":"pong","t0":...,"t1":...,"t2":...}                           leader -> follower
+//	{"type":"sync","offset":-1200000,"rtt":310000}                       follower -> leader
+//	{"type":"fire","id":3,"action":"shoot","at":1760777523500000000}     leader -> follower
+//	{"type":"result","id":3,"at":1760777523500420000}                    follower -> leader
+//
+// Times are Unix nanoseconds; durations are nanoseconds.
+package lansync
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// maxLineSize limits a single message
+	maxLineSize = 64 << 10
+	// ActionShoot takes a photo; the fire time is the full press of the shutter
+	ActionShoot = "shoot"
+	// ActionRecord toggles video recording; the fire time is the press of the
+	// record button
+	ActionRecord = "record"
+)
+
+// Actions lists the actions the leader can fire.
+var Actions = []string{ActionShoot, ActionRecord}
+
+// message is a single line of the protocol. Only the fields used by its type are set.
+type message struct {
+	Type   string `json:"type"`
+	ID     uint64 `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Device string `json:"device,omitempty"`
+	Action string `json:"action,omitempty"`
+	// At is the fire time (fire) or the achieved time (result) on the leader's clock
+	At int64 `json:"at,omitempty"`
+	// T0 to T2 are the timestamps of a ping and its pong
+	T0 int64 `json:"t0,omitempty"`
+	T1 int64 `json:"t1,omitempty"`
+	T2 int64 `json:"t2,omitempty"`
+	// Offset is the leader's clock minus the follower's, RTT the round trip
+	// of the measurement it was taken from
+	Offset int64  `json:"offset,omitempty"`
+	RTT    int64  `json:"rtt,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// conn sends and receives messages on a connection. Sends may come from
+// several goroutines; receives must come from one.
+type conn struct {
+	net.Conn
+	scanner *bufio.Scanner
+
+	mu sync.Mutex
+}
+
+func newConn(c net.Conn) *conn {
+	scanner := bufio.NewScanner(c)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	return &conn{Conn: c, scanner: scanner}
+}
+
+// send writes msg as one line.
+func (c *conn) send(msg message) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.Write(append(line, '\n'))
+	return err
+}
+
+// receive reads the next message and returns it with the time it arrived.
+func (c *conn) receive() (message, time.Time, error) {
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return message{}, time.Time{}, err
+		}
+		return message{}, time.Time{}, fmt.Errorf("connection closed")
+	}
+	received := time.Now()
+	var msg message
+	if err := json.Unmarshal(c.scanner.Bytes(), &msg); err != nil {
+		return message{}, received, fmt.Errorf("invalid message: %w", err)
+	}
+	return msg, received, nil
+}
+
+// clockSample is one NTP-style measurement taken by a follower: it sent a
+// ping at t0, the leader received it at t1 and answered at t2, and the pong
+// arrived at t3. t0 and t3 are on the follower's clock, t1 and t2 on the leader's.
+type clockSample struct {
+	offset time.Duration
+	rtt    time.Duration
+}
+
+func newClockSample(t0, t1, t2, t3 int64) clockSample {
+	return clockSample{
+		offset: time.Duration(((t1 - t0) + (t2 - t3)) / 2),
+		rtt:    time.Duration((t3 - t0) - (t2 - t1)),
+	}
+}
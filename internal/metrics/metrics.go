@@ -0,0 +1,317 @@
+// Package metrics exposes the activity of a camera session in the Prometheus
+// text format, so that unattended rigs can be monitored and alerted on.
+//
+// All values are derived from the events of the session's client:
+//
+//	sony_remote_connection_state{state="Connected"}      1 for the current state, 0 otherwise
+//	sony_remote_connect_attempts_total                   connection attempts
+//	sony_remote_connect_failures_total                   attempts that did not end connected
+//	sony_remote_reconnects_total                         connections after the first one
+//	sony_remote_commands_sent_total{command="..."}       commands written to the camera
+//	sony_remote_commands_failed_total{command="..."}     commands that could not be written
+//	sony_remote_command_write_seconds                    write latency histogram
+//	sony_remote_rssi_dbm{address="...",name="..."}       signal strength of the last advertisement
+//	sony_remote_shots_total                              full shutter presses
+//	sony_remote_recording                                1 while the camera reports recording
+//	sony_remote_recording_seconds_total                  time spent recording
+//	sony_remote_advertisements_total                     camera advertisements seen while scanning
+//
+// Recording is only known for cameras that send notifications.
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+const namespace = "sony_remote"
+
+// rawCommand labels commands that are not in sony_remote_ble.Commands, such as
+// raw hex frames, so that they cannot blow up the number of series.
+const rawCommand = "raw"
+
+// states lists every connection state so that each has a series from the start.
+var states = []sony_remote_ble.ConnectionState{
+	sony_remote_ble.Disconnected,
+	sony_remote_ble.Scanning,
+	sony_remote_ble.Connecting,
+	sony_remote_ble.Connected,
+	sony_remote_ble.Error,
+}
+
+// Metrics collects the metrics of one session.
+type Metrics struct {
+	registry *prometheus.Registry
+	remove   func()
+
+	state          *prometheus.GaugeVec
+	connects       prometheus.Counter
+	connectFails   prometheus.Counter
+	reconnects     prometheus.Counter
+	commandsSent   *prometheus.CounterVec
+	commandsFailed *prometheus.CounterVec
+	writeLatency   prometheus.Histogram
+	idleReconnect  prometheus.Histogram
+	rssi           *prometheus.GaugeVec
+	shots          prometheus.Counter
+	advertisements prometheus.Counter
+
+	// commandLabels maps command names back to their Commands keys
+	commandLabels map[string]string
+
+	mu sync.Mutex
+	// connected is set once the first connection succeeded
+	connected bool
+	// recordingSince is when the current recording started, zero when not recording
+	recordingSince time.Time
+	// recorded is the total length of finished recordings
+	recorded time.Duration
+}
+
+// New registers the metrics and starts following the session's events. Call
+// Close to stop.
+func New(sess *session.Session) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		state: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "connection_state",
+			Help:      "Current connection state of the camera link; 1 for the current state.",
+		}, []string{"state"}),
+		connects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "connect_attempts_total",
+			Help:      "Number of attempts to connect to a camera.",
+		}),
+		connectFails: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "connect_failures_total",
+			Help:      "Number of connection attempts that failed.",
+		}),
+		reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reconnects_total",
+			Help:      "Number of successful connections after the first one.",
+		}),
+		commandsSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "commands_sent_total",
+			Help:      "Number of commands written to the camera.",
+		}, []string{"command"}),
+		commandsFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "commands_failed_total",
+			Help:      "Number of commands that could not be written to the camera.",
+		}, []string{"command"}),
+		writeLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "command_write_seconds",
+			Help:      "Time taken by WriteWithoutResponse for each command.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 12),
+		}),
+		idleReconnect: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "idle_reconnect_seconds",
+			Help:      "Time taken to reopen a link closed for being idle before a command.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 8),
+		}),
+		rssi: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rssi_dbm",
+			Help:      "Signal strength of the last advertisement seen from each camera.",
+		}, []string{"address", "name"}),
+		shots: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "shots_total",
+			Help:      "Number of full shutter presses sent to the camera.",
+		}),
+		advertisements: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "advertisements_total",
+			Help:      "Number of camera advertisements seen while scanning.",
+		}),
+		commandLabels: make(map[string]string, len(sony_remote_ble.Commands)),
+	}
+	// Walk the keys in order so that a name shared by several keys always
+	// gets the same label
+	for _, key := range slices.Sorted(maps.Keys(sony_remote_ble.Commands)) {
+		name := sony_remote_ble.Commands[key].Name
+		if _, ok := m.commandLabels[name]; !ok {
+			m.commandLabels[name] = key
+		}
+	}
+
+	recording := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "recording",
+		Help:      "1 while the camera reports that it is recording video.",
+	}, func() float64 {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.recordingSince.IsZero() {
+			return 0
+		}
+		return 1
+	})
+	recordingSeconds := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "recording_seconds_total",
+		Help:      "Total time the camera has reported recording video, including the current recording.",
+	}, func() float64 {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		total := m.recorded
+		if !m.recordingSince.IsZero() {
+			total += time.Since(m.recordingSince)
+		}
+		return total.Seconds()
+	})
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.state, m.connects, m.connectFails, m.reconnects,
+		m.commandsSent, m.commandsFailed, m.writeLatency, m.idleReconnect,
+		m.rssi, m.shots, recording, recordingSeconds, m.advertisements,
+	)
+
+	m.setState(sess.State())
+	m.remove = sess.OnEvent(m.handle)
+	return m
+}
+
+// Close stops following the session.
+func (m *Metrics) Close() {
+	m.remove()
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ListenAndServe serves GET /metrics on addr until ctx is cancelled. It is for
+// commands that have no HTTP server of their own.
+func (m *Metrics) ListenAndServe(ctx context.Context, addr string, logger *slog.Logger) error {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m.Handler())
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	logger.Info("metrics listening", "addr", addr)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+// handle updates the metrics for one event. It runs on the goroutine that
+// emitted the event and only touches counters, so it returns quickly.
+func (m *Metrics) handle(ev sony_remote_ble.Event) {
+	switch ev.Type {
+	case sony_remote_ble.EventStateChanged:
+		m.setState(ev.State)
+		switch {
+		case ev.State == sony_remote_ble.Connecting:
+			m.connects.Inc()
+		case ev.Previous == sony_remote_ble.Connecting && ev.State == sony_remote_ble.Connected:
+			m.mu.Lock()
+			if m.connected {
+				m.reconnects.Inc()
+			}
+			m.connected = true
+			m.mu.Unlock()
+		case ev.Previous == sony_remote_ble.Connecting:
+			m.connectFails.Inc()
+		}
+		if ev.State != sony_remote_ble.Connected {
+			// Recording cannot be observed without a link
+			m.setRecording(false, ev.Time)
+		}
+
+	case sony_remote_ble.EventDeviceFound:
+		m.advertisements.Inc()
+		m.rssi.WithLabelValues(ev.Device.AddressStr, ev.Device.Name).Set(float64(ev.Device.RSSI))
+
+	case sony_remote_ble.EventCommandSent:
+		if ev.Reconnect > 0 {
+			m.idleReconnect.Observe(ev.Reconnect.Seconds())
+		}
+		label := m.commandLabel(ev.Command)
+		m.commandsSent.WithLabelValues(label).Inc()
+		m.writeLatency.Observe(ev.Latency.Seconds())
+		if label == "shutter_full_down" {
+			m.shots.Inc()
+		}
+
+	case sony_remote_ble.EventCommandFailed:
+		m.commandsFailed.WithLabelValues(m.commandLabel(ev.Command)).Inc()
+		if ev.Reconnect > 0 {
+			m.idleReconnect.Observe(ev.Reconnect.Seconds())
+		}
+		if ev.Latency > 0 {
+			m.writeLatency.Observe(ev.Latency.Seconds())
+		}
+
+	case sony_remote_ble.EventNotification:
+		if ev.Notification.Kind == sony_remote_ble.NotifyRecording {
+			m.setRecording(ev.Notification.Active, ev.Time)
+		}
+	}
+}
+
+func (m *Metrics) setState(state sony_remote_ble.ConnectionState) {
+	for _, s := range states {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		m.state.WithLabelValues(s.String()).Set(value)
+	}
+}
+
+// setRecording starts or stops timing a recording.
+func (m *Metrics) setRecording(recording bool, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case recording && m.recordingSince.IsZero():
+		m.recordingSince = at
+	case !recording && !m.recordingSince.IsZero():
+		m.recorded += at.Sub(m.recordingSince)
+		m.recordingSince = time.Time{}
+	}
+}
+
+// commandLabel returns the Commands key of a command, or rawCommand for
+// commands that are not in the map.
+func (m *Metrics) commandLabel(cmd sony_remote_ble.SonyCommand) string {
+	if label, ok := m.commandLabels[cmd.Name]; ok {
+		return label
+	}
+	return rawCommand
+}
@@ -0,0 +1,162 @@
+package metrics_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/metrics"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/simulator"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// scrape fetches the metrics from m and returns the value of every series,
+// keyed by its name and labels as exposed.
+func scrape(t *testing.T, m *metrics.Metrics) map[string]float64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("content type %q, want the text format", ct)
+	}
+
+	series := make(map[string]float64)
+	lines := bufio.NewScanner(w.Body)
+	for lines.Scan() {
+		line := lines.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		series[line[:i]] = value
+	}
+	return series
+}
+
+// expect checks the value of each series.
+func expect(t *testing.T, series map[string]float64, want map[string]float64) {
+	t.Helper()
+	for name, value := range want {
+		got, ok := series[name]
+		if !ok {
+			t.Errorf("%s missing", name)
+			continue
+		}
+		if got != value {
+			t.Errorf("%s = %g, want %g", name, got, value)
+		}
+	}
+}
+
+func TestMetricsFollowSession(t *testing.T) {
+	cam := simulator.New(simulator.Options{AdvertiseInterval: 10 * time.Millisecond, RecordLatency: 10 * time.Millisecond})
+	sess := session.New(sony_remote_ble.NewClientWithTransport(cam), config.Default(), nil, logger)
+	defer sess.Disconnect(context.Background())
+	m := metrics.New(sess)
+	defer m.Close()
+	ctx := context.Background()
+
+	expect(t, scrape(t, m), map[string]float64{
+		`sony_remote_connection_state{state="Disconnected"}`: 1,
+		`sony_remote_connection_state{state="Connected"}`:    0,
+		`sony_remote_connect_attempts_total`:                 0,
+		`sony_remote_recording`:                              0,
+	})
+
+	if _, err := sess.Connect(ctx, cam.Address().String(), 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	err := sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+		if err := client.TakePhoto(); err != nil {
+			return err
+		}
+		if err := client.Press("c1", 10*time.Millisecond); err != nil {
+			return err
+		}
+		if err := client.SendCommand(sony_remote_ble.SonyCommand{Name: "Raw 0x0199", Code: []byte{0x01, 0x99}}); err != nil {
+			return err
+		}
+		cam.RejectWrites(1)
+		if err := client.SendCommand(sony_remote_ble.Commands["focus_down"]); err == nil {
+			t.Error("rejected write succeeded")
+		}
+		return client.SetRecording(ctx, true)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device := cam.Device()
+	series := scrape(t, m)
+	expect(t, series, map[string]float64{
+		`sony_remote_connection_state{state="Disconnected"}`:                                   0,
+		`sony_remote_connection_state{state="Connected"}`:                                      1,
+		`sony_remote_connect_attempts_total`:                                                   1,
+		`sony_remote_connect_failures_total`:                                                   0,
+		`sony_remote_reconnects_total`:                                                         0,
+		`sony_remote_commands_sent_total{command="focus_down"}`:                                1,
+		`sony_remote_commands_sent_total{command="shutter_full_down"}`:                         1,
+		`sony_remote_commands_sent_total{command="shutter_full_up"}`:                           1,
+		`sony_remote_commands_sent_total{command="focus_up"}`:                                  1,
+		`sony_remote_commands_sent_total{command="c1_down"}`:                                   1,
+		`sony_remote_commands_sent_total{command="c1_up"}`:                                     1,
+		`sony_remote_commands_sent_total{command="record_down"}`:                               1,
+		`sony_remote_commands_sent_total{command="raw"}`:                                       1,
+		`sony_remote_commands_failed_total{command="focus_down"}`:                              1,
+		`sony_remote_shots_total`:                                                              1,
+		`sony_remote_recording`:                                                                1,
+		`sony_remote_rssi_dbm{address="` + device.AddressStr + `",name="` + device.Name + `"}`: float64(device.RSSI),
+	})
+	if series[`sony_remote_advertisements_total`] < 1 {
+		t.Errorf("%g advertisements counted, want the simulator's", series[`sony_remote_advertisements_total`])
+	}
+	if _, ok := series[`sony_remote_commands_sent_total{command="Raw 0x0199"}`]; ok {
+		t.Error("a raw frame got a series of its own")
+	}
+	sent := 0.0
+	for name, value := range series {
+		if strings.HasPrefix(name, "sony_remote_commands_sent_total{") {
+			sent += value
+		}
+	}
+	if writes := series[`sony_remote_command_write_seconds_count`]; writes < sent {
+		t.Errorf("%g write latencies observed for %g commands", writes, sent)
+	}
+
+	// Reconnecting ends the recording, which cannot be observed without a link
+	if err := sess.Disconnect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cam.FailConnects(1)
+	if _, err := sess.Connect(ctx, cam.Address().String(), 2*time.Second); err == nil {
+		t.Fatal("refused connection succeeded")
+	}
+	if _, err := sess.Connect(ctx, cam.Address().String(), 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	series = scrape(t, m)
+	expect(t, series, map[string]float64{
+		`sony_remote_connection_state{state="Connected"}`: 1,
+		`sony_remote_connect_attempts_total`:              3,
+		`sony_remote_connect_failures_total`:              1,
+		`sony_remote_reconnects_total`:                    1,
+		`sony_remote_recording`:                           0,
+	})
+	if series[`sony_remote_recording_seconds_total`] <= 0 {
+		t.Error("the recording was not timed")
+	}
+}
@@ -0,0 +1,371 @@
+// Package mqttbridge connects a camera session to an MQTT broker so that home
+// and building automation systems can trigger the camera.
+//
+// For a camera named "studio" and the default topic prefix the bridge subscribes to
+//
+//	sony/studio/shoot       any payload takes a photo
+//	sony/studio/record/set  ON or OFF starts or stops recording
+//	sony/studio/zoom        "in", "out" or {"direction": "in", "speed": 32, "hold": "500ms"}
+//
+// and publishes retained state to
+//
+//	sony/studio/availability  online or offline (the broker publishes offline if the bridge dies)
+//	sony/studio/state         connection state, e.g. Connected
+//	sony/studio/recording     ON or OFF, for cameras that send notifications
+//	sony/studio/rssi          signal strength in dBm when the camera was found
+//	sony/studio/last_shot     RFC 3339 time of the last photo
+//
+// With discovery enabled the camera also appears in Home Assistant as a device
+// with buttons, a recording switch and sensors.
+package mqttbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+const (
+	// publishTimeout bounds waiting for the broker to accept a message
+	publishTimeout = 5 * time.Second
+	// disconnectQuiesce is how long the client waits for in-flight work when disconnecting, in milliseconds
+	disconnectQuiesce = 250
+)
+
+// Options configures a Bridge.
+type Options struct {
+	// MQTT holds the broker connection and topic settings
+	MQTT config.MQTT
+	// Camera is the topic name of the camera, e.g. "studio"
+	Camera string
+	// Device is the alias, name or address used to connect to the camera
+	Device string
+	// ScanTimeout bounds each attempt to find the camera
+	ScanTimeout time.Duration
+}
+
+// Bridge relays MQTT commands to a camera session and publishes its state.
+type Bridge struct {
+	sess    *session.Session
+	opts    Options
+	logger  *slog.Logger
+	version string
+	// base is the topic prefix for this camera, e.g. "sony/studio"
+	base   string
+	client mqtt.Client
+
+	mu       sync.Mutex
+	rssi     int16
+	hasRSSI  bool
+	lastShot time.Time
+}
+
+// New creates a bridge for sess. The version is reported in discovery payloads.
+func New(sess *session.Session, opts Options, logger *slog.Logger, version string) *Bridge {
+	opts.Camera = TopicName(opts.Camera)
+	return &Bridge{
+		sess:    sess,
+		opts:    opts,
+		logger:  logger,
+		version: version,
+		base:    opts.MQTT.TopicPrefix + "/" + opts.Camera,
+	}
+}
+
+// TopicName turns a camera name or alias into a single topic level, keeping
+// letters, digits, dashes and underscores and lower-casing the result.
+func TopicName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "camera"
+	}
+	return b.String()
+}
+
+// Run connects to the broker and relays commands until ctx is cancelled. The
+// camera is connected in the background and again on demand when a command
+// arrives while it is disconnected.
+func (b *Bridge) Run(ctx context.Context) error {
+	clientID := b.opts.MQTT.ClientID
+	if clientID == "" {
+		clientID = "sony-remote-" + b.opts.Camera
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(b.opts.MQTT.Broker).
+		SetClientID(clientID).
+		SetUsername(b.opts.MQTT.Username).
+		SetPassword(b.opts.MQTT.Password).
+		SetWill(b.topic("availability"), "offline", 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		// Commands can take seconds; handle them concurrently so state updates
+		// and other topics are not held up. The session serializes the camera.
+		SetOrderMatters(false).
+		SetOnConnectHandler(b.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			b.logger.Warn("mqtt connection lost", "error", err)
+		})
+	b.client = mqtt.NewClient(opts)
+
+	remove := b.sess.OnEvent(b.handleEvent)
+	defer remove()
+
+	token := b.client.Connect()
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("mqtt connect %s: %w", b.opts.MQTT.Broker, err)
+		}
+	case <-ctx.Done():
+		return nil
+	}
+
+	if b.opts.Device != "" {
+		go b.ensureConnected(ctx)
+	}
+
+	<-ctx.Done()
+	b.publish("availability", "offline")
+	b.client.Disconnect(disconnectQuiesce)
+	return nil
+}
+
+// onConnect runs after every (re)connection to the broker. Subscriptions and
+// retained state are restored because the broker may have lost the session.
+func (b *Bridge) onConnect(client mqtt.Client) {
+	b.logger.Info("mqtt connected", "broker", b.opts.MQTT.Broker, "topic", b.base)
+
+	for suffix, handler := range map[string]mqtt.MessageHandler{
+		"shoot":      b.handleShoot,
+		"record/set": b.handleRecord,
+		"zoom":       b.handleZoom,
+	} {
+		topic := b.topic(suffix)
+		if token := client.Subscribe(topic, 1, handler); token.WaitTimeout(publishTimeout) && token.Error() != nil {
+			b.logger.Error("mqtt subscribe failed", "topic", topic, "error", token.Error())
+		}
+	}
+
+	if b.opts.MQTT.Discovery {
+		b.publishDiscovery()
+	}
+	b.publish("availability", "online")
+	b.publishState()
+}
+
+// handleEvent keeps the state topics in sync with the camera. It runs on the
+// goroutine that emitted the event and only publishes, which does not block.
+func (b *Bridge) handleEvent(ev sony_remote_ble.Event) {
+	switch ev.Type {
+	case sony_remote_ble.EventStateChanged:
+		b.publish("state", ev.State.String())
+	case sony_remote_ble.EventNotification:
+		if ev.Notification.Kind == sony_remote_ble.NotifyRecording {
+			b.publish("recording", onOff(ev.Notification.Active))
+		}
+	}
+}
+
+func (b *Bridge) handleShoot(_ mqtt.Client, msg mqtt.Message) {
+	b.run(msg, func(ctx context.Context) error {
+		err := b.sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+			return client.TakePhoto()
+		})
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		b.mu.Lock()
+		b.lastShot = now
+		b.mu.Unlock()
+		b.publish("last_shot", now.Format(time.RFC3339))
+		return nil
+	})
+}
+
+func (b *Bridge) handleRecord(_ mqtt.Client, msg mqtt.Message) {
+	b.run(msg, func(ctx context.Context) error {
+		want, err := parseSwitch(string(msg.Payload()))
+		if err != nil {
+			return err
+		}
+		// The camera only has a toggle, so the client presses it only when the
+		// camera reports the other state
+		err = b.sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+			return client.SetRecording(ctx, want)
+		})
+		if err != nil {
+			return err
+		}
+		b.publishRecording()
+		return nil
+	})
+}
+
+// zoomRequest is the JSON form of a zoom command. A bare "in" or "out" payload
+// uses the configured speed and hold.
+type zoomRequest struct {
+	Direction string `json:"direction"`
+	Speed     int    `json:"speed"`
+	Hold      string `json:"hold"`
+}
+
+func (b *Bridge) handleZoom(_ mqtt.Client, msg mqtt.Message) {
+	b.run(msg, func(ctx context.Context) error {
+		var req zoomRequest
+		payload := strings.TrimSpace(string(msg.Payload()))
+		if strings.HasPrefix(payload, "{") {
+			if err := json.Unmarshal([]byte(payload), &req); err != nil {
+				return fmt.Errorf("invalid zoom payload: %w", err)
+			}
+		} else {
+			req.Direction = payload
+		}
+
+		timing := b.sess.Timing()
+		speed, hold := timing.ZoomSpeed, timing.ZoomHold
+		if req.Speed != 0 {
+			speed = req.Speed
+		}
+		if req.Hold != "" {
+			d, err := time.ParseDuration(req.Hold)
+			if err != nil {
+				return fmt.Errorf("invalid zoom hold: %w", err)
+			}
+			hold = d
+		}
+		if speed < 1 || speed > 127 {
+			return fmt.Errorf("zoom speed %d must be between 1 and 127", speed)
+		}
+
+		var in bool
+		switch strings.ToLower(req.Direction) {
+		case "in", "tele":
+			in = true
+		case "out", "wide":
+		default:
+			return fmt.Errorf("zoom direction %q must be in or out", req.Direction)
+		}
+
+		return b.sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+			return client.Zoom(in, byte(speed), hold)
+		})
+	})
+}
+
+// run executes a command for msg, connecting to the camera first if needed,
+// and logs the outcome. Failed commands are published to the error topic.
+func (b *Bridge) run(msg mqtt.Message, fn func(ctx context.Context) error) {
+	ctx := context.Background()
+	b.logger.Debug("mqtt command", "topic", msg.Topic(), "payload", string(msg.Payload()))
+
+	err := b.ensureConnected(ctx)
+	if err == nil {
+		err = fn(ctx)
+	}
+	if err != nil {
+		b.logger.Warn("mqtt command failed", "topic", msg.Topic(), "error", err)
+		b.client.Publish(b.topic("error"), 0, false, err.Error())
+	}
+}
+
+// ensureConnected connects to the camera unless it already is.
+func (b *Bridge) ensureConnected(ctx context.Context) error {
+	device, connected, err := b.sess.EnsureConnected(ctx, b.opts.Device, b.opts.ScanTimeout)
+	if err != nil {
+		b.logger.Warn("camera connection failed", "device", b.opts.Device, "error", err)
+		return err
+	}
+	if !connected {
+		return nil
+	}
+	b.logger.Info("connected", "name", device.Name, "address", device.AddressStr)
+
+	b.mu.Lock()
+	b.rssi, b.hasRSSI = device.RSSI, true
+	b.mu.Unlock()
+	b.publish("rssi", strconv.Itoa(int(device.RSSI)))
+
+	// The model is only known once connected, so refresh the device description
+	if b.opts.MQTT.Discovery {
+		b.publishDiscovery()
+	}
+	return nil
+}
+
+// publishRecording publishes the recording state the camera reported, if it
+// sends notifications; the state of other cameras is unknown.
+func (b *Bridge) publishRecording() {
+	if recording, known := b.sess.Recording(); known {
+		b.publish("recording", onOff(recording))
+	}
+}
+
+// publishState publishes every retained state topic with its current value.
+func (b *Bridge) publishState() {
+	b.mu.Lock()
+	rssi, hasRSSI, lastShot := b.rssi, b.hasRSSI, b.lastShot
+	b.mu.Unlock()
+
+	b.publish("state", b.sess.State().String())
+	b.publishRecording()
+	if hasRSSI {
+		b.publish("rssi", strconv.Itoa(int(rssi)))
+	}
+	if !lastShot.IsZero() {
+		b.publish("last_shot", lastShot.Format(time.RFC3339))
+	}
+}
+
+// publish sends a retained message below the camera topic without waiting for
+// the broker, so it is safe to call from event listeners.
+func (b *Bridge) publish(suffix string, payload any) {
+	if b.client == nil || !b.client.IsConnectionOpen() {
+		return
+	}
+	b.client.Publish(b.topic(suffix), 1, true, payload)
+}
+
+func (b *Bridge) topic(suffix string) string {
+	return b.base + "/" + suffix
+}
+
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// parseSwitch accepts the payloads automation systems commonly send for a switch.
+func parseSwitch(payload string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(payload)) {
+	case "on", "1", "true", "start":
+		return true, nil
+	case "off", "0", "false", "stop":
+		return false, nil
+	default:
+		return false, fmt.Errorf("record payload %q must be ON or OFF", payload)
+	}
+}
@@ -0,0 +1,187 @@
+package mqttbridge_test
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/mqttbridge"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/simulator"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// broker is an in-process MQTT broker that remembers the last payload of
+// every topic below the camera.
+type broker struct {
+	server *mochi.Server
+	url    string
+
+	mu       sync.Mutex
+	payloads map[string][]string
+}
+
+// startBroker serves a broker on a loopback port until the test ends.
+func startBroker(t *testing.T) *broker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := mochi.New(&mochi.Options{InlineClient: true, Logger: logger})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddListener(listeners.NewNet("test", ln)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	b := &broker{server: server, url: "tcp://" + ln.Addr().String(), payloads: make(map[string][]string)}
+	err = server.Subscribe("sony/studio/#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		b.mu.Lock()
+		b.payloads[pk.TopicName] = append(b.payloads[pk.TopicName], string(pk.Payload))
+		b.mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// published returns the payloads published to topic so far.
+func (b *broker) published(topic string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.payloads["sony/studio/"+topic]...)
+}
+
+// command publishes a command as an automation system would.
+func (b *broker) command(t *testing.T, topic, payload string) {
+	t.Helper()
+	if err := b.server.Publish("sony/studio/"+topic, []byte(payload), false, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitFor waits until the last payload of topic satisfies match.
+func (b *broker) waitFor(t *testing.T, topic string, match func(string) bool) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		payloads := b.published(topic)
+		if len(payloads) > 0 && match(payloads[len(payloads)-1]) {
+			return payloads[len(payloads)-1]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: got %q, want a matching payload", topic, payloads)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func equals(want string) func(string) bool {
+	return func(got string) bool { return got == want }
+}
+
+// startBridge runs a bridge for cam against b until the test ends and waits
+// until the camera is connected.
+func startBridge(t *testing.T, b *broker, cam *simulator.Camera) {
+	t.Helper()
+	sess := session.New(sony_remote_ble.NewClientWithTransport(cam), config.Default(), nil, logger)
+	mqtt := config.Default().MQTT
+	mqtt.Broker = b.url
+	bridge := mqttbridge.New(sess, mqttbridge.Options{
+		MQTT:        mqtt,
+		Camera:      "studio",
+		Device:      cam.Address().String(),
+		ScanTimeout: time.Second,
+	}, logger, "v1.0.0")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- bridge.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+	b.waitFor(t, "availability", equals("online"))
+	b.waitFor(t, "state", equals(sony_remote_ble.Connected.String()))
+}
+
+// recordPresses counts the record button presses the camera received.
+func recordPresses(cam *simulator.Camera) int {
+	down := hex.EncodeToString(sony_remote_ble.Commands["record_down"].Code)
+	var n int
+	for _, frame := range cam.Frames() {
+		if hex.EncodeToString(frame) == down {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBridgeRecordFollowsCamera(t *testing.T) {
+	b := startBroker(t)
+	cam := simulator.New(simulator.Options{AdvertiseInterval: 10 * time.Millisecond, RecordLatency: 20 * time.Millisecond})
+	startBridge(t, b, cam)
+
+	b.command(t, "record/set", "ON")
+	b.waitFor(t, "recording", equals("ON"))
+	if !cam.Recording() || recordPresses(cam) != 1 {
+		t.Fatalf("camera recording %t after %d presses, want recording after one", cam.Recording(), recordPresses(cam))
+	}
+
+	// Asking again for the state the camera reported does not toggle it back
+	b.command(t, "record/set", "on")
+	b.command(t, "shoot", "")
+	b.waitFor(t, "last_shot", func(string) bool { return true })
+	if !cam.Recording() || recordPresses(cam) != 1 {
+		t.Errorf("camera recording %t after %d presses, want still recording after one", cam.Recording(), recordPresses(cam))
+	}
+
+	b.command(t, "record/set", "OFF")
+	b.waitFor(t, "recording", equals("OFF"))
+	if cam.Recording() || recordPresses(cam) != 2 {
+		t.Errorf("camera recording %t after %d presses, want stopped after two", cam.Recording(), recordPresses(cam))
+	}
+
+	b.command(t, "record/set", "maybe")
+	b.waitFor(t, "error", equals(`record payload "maybe" must be ON or OFF`))
+}
+
+func TestBridgeRecordNeedsNotifications(t *testing.T) {
+	b := startBroker(t)
+	cam := simulator.New(simulator.Options{AdvertiseInterval: 10 * time.Millisecond, NoNotifications: true})
+	startBridge(t, b, cam)
+
+	b.command(t, "record/set", "ON")
+	b.waitFor(t, "error", func(msg string) bool {
+		return strings.Contains(msg, sony_remote_ble.ErrRecordingUnknown.Error())
+	})
+	if got := b.published("recording"); len(got) != 0 {
+		t.Errorf("published recording %q for a camera whose state is unknown", got)
+	}
+	if cam.Recording() || recordPresses(cam) != 0 {
+		t.Errorf("camera recording %t after %d presses, want untouched", cam.Recording(), recordPresses(cam))
+	}
+}
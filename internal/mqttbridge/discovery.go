@@ -0,0 +1,85 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// discoveryDevice groups the entities of one camera in Home Assistant.
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model,omitempty"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+}
+
+// discoveryEntity is a Home Assistant MQTT discovery payload. Only the fields
+// relevant to the component are set.
+type discoveryEntity struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	ObjectID          string          `json:"object_id"`
+	Device            discoveryDevice `json:"device"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	CommandTopic      string          `json:"command_topic,omitempty"`
+	StateTopic        string          `json:"state_topic,omitempty"`
+	PayloadPress      string          `json:"payload_press,omitempty"`
+	PayloadOn         string          `json:"payload_on,omitempty"`
+	PayloadOff        string          `json:"payload_off,omitempty"`
+	DeviceClass       string          `json:"device_class,omitempty"`
+	StateClass        string          `json:"state_class,omitempty"`
+	Unit              string          `json:"unit_of_measurement,omitempty"`
+	EntityCategory    string          `json:"entity_category,omitempty"`
+	Icon              string          `json:"icon,omitempty"`
+}
+
+// publishDiscovery announces the camera's entities to Home Assistant. The
+// payloads are retained, so Home Assistant picks them up after a restart.
+func (b *Bridge) publishDiscovery() {
+	if !b.client.IsConnectionOpen() {
+		return
+	}
+	nodeID := "sony_remote_" + b.opts.Camera
+	device := discoveryDevice{
+		Identifiers:  []string{nodeID},
+		Name:         b.opts.Camera,
+		Manufacturer: "Sony",
+		SWVersion:    b.version,
+	}
+	if info, ok := b.sess.Device(); ok {
+		device.Model = sony_remote_ble.ModelFromName(info.Name)
+	}
+
+	entities := []struct {
+		component string
+		entity    discoveryEntity
+	}{
+		{"button", discoveryEntity{Name: "Shoot", CommandTopic: b.topic("shoot"), PayloadPress: "PRESS", Icon: "mdi:camera"}},
+		{"button", discoveryEntity{Name: "Zoom in", CommandTopic: b.topic("zoom"), PayloadPress: "in", Icon: "mdi:magnify-plus"}},
+		{"button", discoveryEntity{Name: "Zoom out", CommandTopic: b.topic("zoom"), PayloadPress: "out", Icon: "mdi:magnify-minus"}},
+		{"switch", discoveryEntity{Name: "Recording", CommandTopic: b.topic("record/set"), StateTopic: b.topic("recording"),
+			PayloadOn: "ON", PayloadOff: "OFF", Icon: "mdi:record-rec"}},
+		{"sensor", discoveryEntity{Name: "Connection", StateTopic: b.topic("state"), EntityCategory: "diagnostic", Icon: "mdi:bluetooth"}},
+		{"sensor", discoveryEntity{Name: "Signal strength", StateTopic: b.topic("rssi"), DeviceClass: "signal_strength",
+			StateClass: "measurement", Unit: "dBm", EntityCategory: "diagnostic"}},
+		{"sensor", discoveryEntity{Name: "Last shot", StateTopic: b.topic("last_shot"), DeviceClass: "timestamp"}},
+	}
+
+	for _, e := range entities {
+		objectID := TopicName(e.entity.Name)
+		e.entity.UniqueID = nodeID + "_" + objectID
+		e.entity.ObjectID = nodeID + "_" + objectID
+		e.entity.Device = device
+		e.entity.AvailabilityTopic = b.topic("availability")
+
+		payload, err := json.Marshal(e.entity)
+		if err != nil {
+			b.logger.Error("encode discovery payload", "entity", e.entity.Name, "error", err)
+			continue
+		}
+		topic := b.opts.MQTT.DiscoveryPrefix + "/" + e.component + "/" + nodeID + "/" + objectID + "/config"
+		b.client.Publish(topic, 1, true, payload)
+	}
+}
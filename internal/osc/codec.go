@@ -0,0 +1,214 @@
+// Package osc implements an Open Sound Control (OSC 1.0) server that lets show
+// control software such as QLab or lighting consoles trigger the camera over UDP.
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// bundleTag starts every OSC bundle.
+const bundleTag = "#bundle"
+
+// Message is a single OSC message. Args hold int32, float32, string, []byte,
+// bool or nil values.
+type Message struct {
+	Address string
+	Args    []any
+}
+
+// String formats the message the way OSC tools usually print it, e.g. "/camera/zoom/tele 64".
+func (m Message) String() string {
+	var b bytes.Buffer
+	b.WriteString(m.Address)
+	for _, arg := range m.Args {
+		fmt.Fprintf(&b, " %v", arg)
+	}
+	return b.String()
+}
+
+// ParsePacket decodes a UDP packet holding either a message or a bundle.
+// Messages inside bundles, including nested ones, are returned in order;
+// time tags are ignored and bundled messages are handled immediately.
+func ParsePacket(data []byte) ([]Message, error) {
+	if len(data) == 0 || len(data)%4 != 0 {
+		return nil, fmt.Errorf("osc: packet size %d is not a positive multiple of 4", len(data))
+	}
+
+	if data[0] != '#' {
+		msg, err := parseMessage(data)
+		if err != nil {
+			return nil, err
+		}
+		return []Message{msg}, nil
+	}
+
+	tag, rest, err := readString(data)
+	if err != nil {
+		return nil, err
+	}
+	if tag != bundleTag {
+		return nil, fmt.Errorf("osc: unknown packet type %q", tag)
+	}
+	if len(rest) < 8 {
+		return nil, errors.New("osc: bundle without time tag")
+	}
+	rest = rest[8:]
+
+	var messages []Message
+	for len(rest) > 0 {
+		if len(rest) < 4 {
+			return nil, errors.New("osc: truncated bundle element")
+		}
+		size := int(binary.BigEndian.Uint32(rest))
+		rest = rest[4:]
+		if size > len(rest) {
+			return nil, errors.New("osc: bundle element exceeds packet")
+		}
+		inner, err := ParsePacket(rest[:size])
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, inner...)
+		rest = rest[size:]
+	}
+	return messages, nil
+}
+
+func parseMessage(data []byte) (Message, error) {
+	address, rest, err := readString(data)
+	if err != nil {
+		return Message{}, err
+	}
+	if len(address) == 0 || address[0] != '/' {
+		return Message{}, fmt.Errorf("osc: invalid address %q", address)
+	}
+	msg := Message{Address: address}
+
+	// Very old implementations omit the type tag string for messages without arguments
+	if len(rest) == 0 {
+		return msg, nil
+	}
+	tags, rest, err := readString(rest)
+	if err != nil {
+		return Message{}, err
+	}
+	if len(tags) == 0 || tags[0] != ',' {
+		return Message{}, fmt.Errorf("osc: invalid type tags %q", tags)
+	}
+
+	for _, tag := range tags[1:] {
+		switch tag {
+		case 'i', 'f':
+			if len(rest) < 4 {
+				return Message{}, fmt.Errorf("osc: %s: missing argument", address)
+			}
+			bits := binary.BigEndian.Uint32(rest)
+			rest = rest[4:]
+			if tag == 'i' {
+				msg.Args = append(msg.Args, int32(bits))
+			} else {
+				msg.Args = append(msg.Args, math.Float32frombits(bits))
+			}
+		case 's', 'S':
+			var s string
+			if s, rest, err = readString(rest); err != nil {
+				return Message{}, err
+			}
+			msg.Args = append(msg.Args, s)
+		case 'b':
+			if len(rest) < 4 {
+				return Message{}, fmt.Errorf("osc: %s: missing blob size", address)
+			}
+			size := int(binary.BigEndian.Uint32(rest))
+			rest = rest[4:]
+			if size > len(rest) {
+				return Message{}, fmt.Errorf("osc: %s: blob exceeds packet", address)
+			}
+			msg.Args = append(msg.Args, append([]byte(nil), rest[:size]...))
+			rest = rest[pad(size):]
+		case 'T':
+			msg.Args = append(msg.Args, true)
+		case 'F':
+			msg.Args = append(msg.Args, false)
+		case 'N', 'I':
+			msg.Args = append(msg.Args, nil)
+		default:
+			return Message{}, fmt.Errorf("osc: %s: unsupported argument type %q", address, tag)
+		}
+	}
+	return msg, nil
+}
+
+// MarshalBinary encodes the message. Go int and float64 arguments are sent as
+// int32 and float32.
+func (m Message) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	writeString(&b, m.Address)
+
+	tags := []byte{','}
+	var args bytes.Buffer
+	for _, arg := range m.Args {
+		switch v := arg.(type) {
+		case int32:
+			tags = append(tags, 'i')
+			binary.Write(&args, binary.BigEndian, v)
+		case int:
+			tags = append(tags, 'i')
+			binary.Write(&args, binary.BigEndian, int32(v))
+		case float32:
+			tags = append(tags, 'f')
+			binary.Write(&args, binary.BigEndian, v)
+		case float64:
+			tags = append(tags, 'f')
+			binary.Write(&args, binary.BigEndian, float32(v))
+		case string:
+			tags = append(tags, 's')
+			writeString(&args, v)
+		case []byte:
+			tags = append(tags, 'b')
+			binary.Write(&args, binary.BigEndian, int32(len(v)))
+			args.Write(v)
+			args.Write(make([]byte, pad(len(v))-len(v)))
+		case bool:
+			if v {
+				tags = append(tags, 'T')
+			} else {
+				tags = append(tags, 'F')
+			}
+		case nil:
+			tags = append(tags, 'N')
+		default:
+			return nil, fmt.Errorf("osc: unsupported argument type %T", arg)
+		}
+	}
+	writeString(&b, string(tags))
+	b.Write(args.Bytes())
+	return b.Bytes(), nil
+}
+
+// readString reads a NUL-terminated string padded to a multiple of four bytes.
+func readString(data []byte) (string, []byte, error) {
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return "", nil, errors.New("osc: unterminated string")
+	}
+	next := pad(end + 1)
+	if next > len(data) {
+		return "", nil, errors.New("osc: string padding exceeds packet")
+	}
+	return string(data[:end]), data[next:], nil
+}
+
+func writeString(b *bytes.Buffer, s string) {
+	b.WriteString(s)
+	b.Write(make([]byte, pad(len(s)+1)-len(s)))
+}
+
+// pad rounds n up to the next multiple of four.
+func pad(n int) int {
+	return (n + 3) &^ 3
+}
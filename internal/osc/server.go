@@ -0,0 +1,323 @@
+package osc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+const (
+	// maxPacketSize is the largest UDP datagram the server reads
+	maxPacketSize = 65507
+	// queueSize is how many messages wait for the camera before new ones are dropped
+	queueSize = 64
+)
+
+// Options configures a Server.
+type Options struct {
+	// OSC holds the listen address, reply target and address mapping
+	OSC config.OSC
+	// Device is the alias, name or address used to connect to the camera
+	Device string
+	// ScanTimeout bounds each attempt to find the camera
+	ScanTimeout time.Duration
+}
+
+// Server receives OSC messages over UDP and maps them onto camera operations.
+//
+// Messages are handled one at a time in the order they arrive. With a reply
+// target configured, every message is answered on the reply address with the
+// original address, "ok" or "error" and an error message, and changes in
+// connection state, recording and camera notifications are broadcast below the
+// status address:
+//
+//	/camera/reply /camera/shoot ok
+//	/camera/status/state Connected
+//	/camera/status/recording 1
+//	/camera/status/notification "focus acquired"
+type Server struct {
+	sess   *session.Session
+	opts   Options
+	logger *slog.Logger
+
+	conn    *net.UDPConn
+	replyTo *net.UDPAddr
+}
+
+// New creates an OSC server for sess.
+func New(sess *session.Session, opts Options, logger *slog.Logger) *Server {
+	return &Server{
+		sess:   sess,
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// ListenAndServe receives OSC messages until ctx is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	addr, err := net.ResolveUDPAddr("udp", s.opts.OSC.Listen)
+	if err != nil {
+		return fmt.Errorf("osc listen address: %w", err)
+	}
+	if s.opts.OSC.ReplyTo != "" {
+		if s.replyTo, err = net.ResolveUDPAddr("udp", s.opts.OSC.ReplyTo); err != nil {
+			return fmt.Errorf("osc reply address: %w", err)
+		}
+	}
+	if s.conn, err = net.ListenUDP("udp", addr); err != nil {
+		return fmt.Errorf("osc listen: %w", err)
+	}
+	defer s.conn.Close()
+	s.logger.Info("osc server listening", "addr", s.conn.LocalAddr().String(), "reply_to", s.opts.OSC.ReplyTo)
+
+	remove := s.sess.OnEvent(s.handleEvent)
+	defer remove()
+
+	queue := make(chan Message, queueSize)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for msg := range queue {
+			s.handle(ctx, msg)
+		}
+	}()
+	defer wg.Wait()
+	defer close(queue)
+
+	go func() {
+		<-ctx.Done()
+		s.conn.Close()
+	}()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("osc read: %w", err)
+		}
+
+		messages, err := ParsePacket(buf[:n])
+		if err != nil {
+			s.logger.Warn("invalid osc packet", "from", from.String(), "error", err)
+			continue
+		}
+		for _, msg := range messages {
+			select {
+			case queue <- msg:
+			default:
+				s.logger.Warn("osc queue full, dropping message", "address", msg.Address)
+			}
+		}
+	}
+}
+
+// handle performs the action mapped to a message and sends the reply.
+func (s *Server) handle(ctx context.Context, msg Message) {
+	action, arg, ok := s.lookup(msg.Address)
+	if !ok {
+		s.logger.Debug("unmapped osc address", "message", msg.String())
+		return
+	}
+	s.logger.Debug("osc message", "message", msg.String(), "action", action)
+
+	err := s.perform(ctx, action, arg, msg.Args)
+	if err != nil {
+		s.logger.Warn("osc command failed", "address", msg.Address, "error", err)
+		s.send(s.opts.OSC.ReplyAddress, msg.Address, "error", err.Error())
+		return
+	}
+	s.send(s.opts.OSC.ReplyAddress, msg.Address, "ok")
+}
+
+// lookup finds the action for an address, trying exact matches before
+// wildcards. For a wildcard match the last segment of the address becomes the
+// argument unless the mapping names one.
+func (s *Server) lookup(address string) (action, arg string, ok bool) {
+	if value, found := s.opts.OSC.Addresses[address]; found {
+		action, arg = config.ParseOSCAction(value)
+		return action, arg, true
+	}
+
+	i := strings.LastIndexByte(address, '/')
+	if i <= 0 {
+		return "", "", false
+	}
+	value, found := s.opts.OSC.Addresses[address[:i]+"/*"]
+	if !found {
+		return "", "", false
+	}
+	action, arg = config.ParseOSCAction(value)
+	if arg == "" {
+		arg = address[i+1:]
+	}
+	return action, arg, true
+}
+
+func (s *Server) perform(ctx context.Context, action, arg string, args []any) error {
+	value, hasValue := number(args)
+
+	switch action {
+	case config.OSCState:
+		s.broadcastStatus()
+		return nil
+	case config.OSCConnect:
+		_, _, err := s.sess.EnsureConnected(ctx, s.opts.Device, s.opts.ScanTimeout)
+		return err
+	case config.OSCDisconnect:
+		return s.sess.Disconnect(ctx)
+	}
+
+	// Consoles send 1 on press and 0 on release; only the press triggers one-shot actions
+	if hasValue && value == 0 && (action == config.OSCShoot || action == config.OSCZoomIn || action == config.OSCZoomOut) {
+		return nil
+	}
+
+	if _, _, err := s.sess.EnsureConnected(ctx, s.opts.Device, s.opts.ScanTimeout); err != nil {
+		return err
+	}
+
+	switch action {
+	case config.OSCShoot:
+		return s.sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+			return client.TakePhoto()
+		})
+
+	case config.OSCRecord:
+		return s.sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+			if !hasValue {
+				return client.Press("record", sony_remote_ble.DefaultCommandDelay)
+			}
+			// The camera only has a toggle, so the client presses it only when
+			// the camera reports the other state
+			return client.SetRecording(ctx, value != 0)
+		})
+
+	case config.OSCZoomIn, config.OSCZoomOut:
+		timing := s.sess.Timing()
+		speed := timing.ZoomSpeed
+		if hasValue {
+			var err error
+			if speed, err = zoomSpeed(value, args[0]); err != nil {
+				return err
+			}
+		}
+		return s.sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+			return client.Zoom(action == config.OSCZoomIn, byte(speed), timing.ZoomHold)
+		})
+
+	case config.OSCButton:
+		return s.sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+			if !hasValue {
+				return client.Press(arg, sony_remote_ble.DefaultCommandDelay)
+			}
+			suffix := "_up"
+			if value != 0 {
+				suffix = "_down"
+			}
+			cmd, ok := sony_remote_ble.Commands[arg+suffix]
+			if !ok {
+				return fmt.Errorf("%w: %s", sony_remote_ble.ErrUnknownButton, arg)
+			}
+			return client.SendCommand(cmd)
+		})
+
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+}
+
+// handleEvent broadcasts status changes. It runs on the goroutine that emitted
+// the event; sending a UDP datagram does not block.
+func (s *Server) handleEvent(ev sony_remote_ble.Event) {
+	switch ev.Type {
+	case sony_remote_ble.EventStateChanged:
+		s.send(s.opts.OSC.StatusAddress+"/state", ev.State.String())
+	case sony_remote_ble.EventNotification:
+		if ev.Notification.Kind == sony_remote_ble.NotifyRecording {
+			s.send(s.opts.OSC.StatusAddress+"/recording", boolInt(ev.Notification.Active))
+		}
+		s.send(s.opts.OSC.StatusAddress+"/notification", ev.Notification.String())
+	}
+}
+
+// broadcastStatus sends every status value.
+func (s *Server) broadcastStatus() {
+	s.send(s.opts.OSC.StatusAddress+"/state", s.sess.State().String())
+	// Only cameras that send notifications report whether they record
+	if recording, known := s.sess.Recording(); known {
+		s.send(s.opts.OSC.StatusAddress+"/recording", boolInt(recording))
+	}
+	if device, ok := s.sess.Device(); ok {
+		s.send(s.opts.OSC.StatusAddress+"/device", device.Name, device.AddressStr)
+	}
+}
+
+// send delivers a message to the reply target, if one is configured.
+func (s *Server) send(address string, args ...any) {
+	if s.replyTo == nil || s.conn == nil {
+		return
+	}
+	data, err := Message{Address: address, Args: args}.MarshalBinary()
+	if err != nil {
+		s.logger.Error("encode osc message", "address", address, "error", err)
+		return
+	}
+	if _, err := s.conn.WriteToUDP(data, s.replyTo); err != nil && !errors.Is(err, net.ErrClosed) {
+		s.logger.Warn("osc send failed", "to", s.replyTo.String(), "error", err)
+	}
+}
+
+// number returns the first argument as a number. Booleans count as 1 and 0 so
+// that T and F work like 1 and 0.
+func number(args []any) (float64, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	switch v := args[0].(type) {
+	case int32:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// zoomSpeed converts a speed argument into the camera's 1-127 range. Floats
+// between 0 and 1, as sent by faders, are scaled; other values are used as is.
+func zoomSpeed(value float64, raw any) (int, error) {
+	if _, isFloat := raw.(float32); isFloat && value > 0 && value <= 1 {
+		return max(1, int(math.Round(value*127))), nil
+	}
+	speed := int(math.Round(value))
+	if speed < 1 || speed > 127 {
+		return 0, fmt.Errorf("zoom speed %v must be between 1 and 127, or 0-1 as a float", value)
+	}
+	return speed, nil
+}
+
+func boolInt(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package presence
+
+import (
+	"context"
+	"os/exec"
+)
+
+// shell returns a command that runs line with the system shell.
+func shell(ctx context.Context, line string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", line)
+}
@@ -0,0 +1,247 @@
+// Package presence watches cameras come into range, go out of range, appear
+// and disappear, and runs the configured rules when they do: connecting,
+// stopping a recording, disconnecting, calling a webhook or running a command.
+package presence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+const (
+	// queueSize is how many events wait for their rules before new ones are dropped
+	queueSize = 64
+	// actionTimeout bounds each webhook call and command
+	actionTimeout = 30 * time.Second
+)
+
+// Event is the JSON form of a presence event, as posted to webhooks.
+type Event struct {
+	Event   string    `json:"event"`
+	Camera  string    `json:"camera"`
+	Address string    `json:"address"`
+	RSSI    float64   `json:"rssi"`
+	Time    time.Time `json:"time"`
+}
+
+// EventOf converts a presence event to its JSON form.
+func EventOf(ev sony_remote_ble.PresenceEvent) Event {
+	return Event{
+		Event:   ev.Type.String(),
+		Camera:  ev.Device.Name,
+		Address: ev.Device.AddressStr,
+		RSSI:    ev.RSSI,
+		Time:    ev.Time,
+	}
+}
+
+// Watcher scans for cameras with a client of its own and runs the presence
+// rules against a session. Rules run one event at a time in the order the
+// events were seen, so that a slow webhook never delays the scan.
+//
+// Most cameras stop advertising while connected, so the camera of the session
+// is kept present while it is connected and only goes gone once the link is
+// lost and the camera is not seen again. Its RSSI is only seen while the link
+// is closed for being idle, so far only fires for it with idle disconnect.
+type Watcher struct {
+	sess    *session.Session
+	scanner sony_remote_ble.Camera
+	opts    config.Presence
+	logger  *slog.Logger
+	watcher *sony_remote_ble.PresenceWatcher
+	http    *http.Client
+
+	mu sync.Mutex
+	// held is the address of the connected camera kept present, empty if none
+	held string
+}
+
+// New creates a watcher that scans with scanner, which must not be the
+// session's client, and acts on sess.
+func New(sess *session.Session, scanner sony_remote_ble.Camera, opts config.Presence, logger *slog.Logger) *Watcher {
+	return &Watcher{
+		sess:    sess,
+		scanner: scanner,
+		opts:    opts,
+		logger:  logger,
+		watcher: sony_remote_ble.NewPresenceWatcher(opts.Options()),
+		http:    &http.Client{Timeout: actionTimeout},
+	}
+}
+
+// Run watches until ctx ends and calls fn for every event before its rules
+// are queued. It returns nil when ctx ends, or the error that stopped the scan.
+func (w *Watcher) Run(ctx context.Context, fn func(sony_remote_ble.PresenceEvent)) error {
+	remove := w.sess.OnEvent(w.handleEvent)
+	defer remove()
+	if device, ok := w.sess.Device(); ok {
+		w.hold(device.AddressStr)
+	}
+
+	queue := make(chan sony_remote_ble.PresenceEvent, queueSize)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ev := range queue {
+			w.apply(ctx, ev)
+		}
+	}()
+	defer wg.Wait()
+	defer close(queue)
+
+	return w.watcher.Watch(ctx, w.scanner, func(ev sony_remote_ble.PresenceEvent) {
+		// The connected camera may still be advertising from before the connection
+		if device, ok := w.sess.Device(); ok && device.AddressStr == ev.Device.AddressStr {
+			w.hold(device.AddressStr)
+		}
+		fn(ev)
+		select {
+		case queue <- ev:
+		default:
+			w.logger.Warn("presence rules fell behind, event dropped", "event", ev.Type.String(), "camera", ev.Device.Name)
+		}
+	})
+}
+
+// Cameras returns the cameras currently present.
+func (w *Watcher) Cameras() []sony_remote_ble.Presence {
+	return w.watcher.Cameras()
+}
+
+// handleEvent releases the held camera when its connection ends. It runs on
+// the goroutine that emitted the event, so it must not touch the session link.
+func (w *Watcher) handleEvent(ev sony_remote_ble.Event) {
+	if ev.Type != sony_remote_ble.EventStateChanged || ev.State == sony_remote_ble.Connected {
+		return
+	}
+	w.mu.Lock()
+	held := w.held
+	w.held = ""
+	w.mu.Unlock()
+	if held != "" {
+		w.watcher.Hold(held, false)
+	}
+}
+
+// hold keeps the connected camera at address present.
+func (w *Watcher) hold(address string) {
+	w.mu.Lock()
+	w.held = address
+	w.mu.Unlock()
+	w.watcher.Hold(address, true)
+}
+
+// apply runs every rule matching ev in order.
+func (w *Watcher) apply(ctx context.Context, ev sony_remote_ble.PresenceEvent) {
+	for i, rule := range w.opts.Rules {
+		if rule.Event() != ev.Type || !session.MatchesDevice(w.sess.Config().ResolveAlias(rule.Camera), ev.Device) {
+			continue
+		}
+		logger := w.logger.With("rule", i, "event", ev.Type.String(), "camera", ev.Device.Name, "action", rule.Action)
+		if err := w.run(ctx, rule, ev); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Warn("presence rule failed", "error", err)
+			continue
+		}
+		logger.Info("presence rule ran")
+	}
+}
+
+// run takes the action of rule for ev.
+func (w *Watcher) run(ctx context.Context, rule config.PresenceRule, ev sony_remote_ble.PresenceEvent) error {
+	switch rule.Action {
+	case "connect":
+		connected, err := w.sess.EnsureConnectedTo(ctx, ev.Device)
+		if connected {
+			w.hold(ev.Device.AddressStr)
+		}
+		return err
+
+	case "disconnect":
+		if !w.connectedTo(ev.Device) {
+			return nil
+		}
+		return w.sess.Disconnect(ctx)
+
+	case "stop_recording":
+		if !w.connectedTo(ev.Device) {
+			return nil
+		}
+		// Waiting for the camera to confirm keeps a disconnect rule next from
+		// cutting the recording short
+		return w.sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+			return client.SetRecording(ctx, false)
+		})
+
+	case "webhook":
+		return w.post(ctx, rule.URL, ev)
+
+	case "exec":
+		return w.exec(ctx, rule.Command, ev)
+	}
+	return fmt.Errorf("unknown action %q", rule.Action)
+}
+
+// connectedTo reports whether the session is connected to device.
+func (w *Watcher) connectedTo(device sony_remote_ble.DeviceInfo) bool {
+	connected, ok := w.sess.Device()
+	return ok && connected.AddressStr == device.AddressStr
+}
+
+// post sends ev to url as JSON.
+func (w *Watcher) post(ctx context.Context, url string, ev sony_remote_ble.PresenceEvent) error {
+	body, err := json.Marshal(EventOf(ev))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// exec runs line with the shell, passing the event in the environment.
+func (w *Watcher) exec(ctx context.Context, line string, ev sony_remote_ble.PresenceEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, actionTimeout)
+	defer cancel()
+
+	cmd := shell(ctx, line)
+	cmd.Env = append(os.Environ(),
+		"SONY_REMOTE_EVENT="+ev.Type.String(),
+		"SONY_REMOTE_CAMERA="+ev.Device.Name,
+		"SONY_REMOTE_ADDRESS="+ev.Device.AddressStr,
+		fmt.Sprintf("SONY_REMOTE_RSSI=%.0f", ev.RSSI),
+	)
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		w.logger.Debug("presence command output", "command", line, "output", string(bytes.TrimSpace(output)))
+	}
+	if err != nil {
+		return fmt.Errorf("command %q: %w", line, err)
+	}
+	return nil
+}
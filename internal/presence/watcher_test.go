@@ -0,0 +1,105 @@
+package presence_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/presence"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/simulator"
+)
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestStopRecordingWhenCameraWalksAway runs the rules of the README: connect
+// when the camera comes near, and stop recording and disconnect when it goes
+// far while connected, which the watcher only sees once the link went idle.
+func TestStopRecordingWhenCameraWalksAway(t *testing.T) {
+	cam := simulator.New(simulator.Options{RSSI: -90, AdvertiseInterval: 10 * time.Millisecond})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	cfg := config.Default()
+	cfg.IdleDisconnect.After = 100 * time.Millisecond
+	cfg.Presence.Smoothing = 1
+	cfg.Presence.Rules = []config.PresenceRule{
+		{On: "near", Action: "connect"},
+		{On: "far", Action: "stop_recording"},
+		{On: "far", Action: "disconnect"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	sess := session.New(sony_remote_ble.NewClientWithTransport(cam), cfg, nil, logger)
+	watcher := presence.New(sess, sony_remote_ble.NewClientWithTransport(cam), cfg.Presence, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- watcher.Run(ctx, func(sony_remote_ble.PresenceEvent) {})
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	cam.SetRSSI(-50)
+	waitFor(t, "the near rule to connect", func() bool {
+		_, ok := sess.Device()
+		return ok
+	})
+	err := sess.Do(ctx, func(client *sony_remote_ble.Client) error {
+		return client.SetRecording(ctx, true)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the link to go idle", sess.Idle)
+
+	cam.SetRSSI(-95)
+	waitFor(t, "the far rules to disconnect", func() bool {
+		return sess.State() == sony_remote_ble.Disconnected
+	})
+	if cam.Recording() {
+		t.Error("camera still recording after the far rules ran")
+	}
+}
+
+func TestRejectsRulesThatCannotFire(t *testing.T) {
+	for _, tc := range []struct {
+		on, action string
+		idle       time.Duration
+		valid      bool
+	}{
+		{"far", "stop_recording", 0, false},
+		{"far", "disconnect", 0, false},
+		{"far", "stop_recording", time.Minute, true},
+		{"gone", "stop_recording", time.Minute, false},
+		{"gone", "disconnect", time.Minute, false},
+		{"gone", "webhook", 0, true},
+	} {
+		cfg := config.Default()
+		cfg.IdleDisconnect.After = tc.idle
+		cfg.Presence.Rules = []config.PresenceRule{{On: tc.on, Action: tc.action, URL: "http://localhost/hook"}}
+		if err := cfg.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%s %s with idle disconnect %s: got %v, want valid %t", tc.on, tc.action, tc.idle, err, tc.valid)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/smazurov/sony_remote_ble/internal/control"
+)
+
+const (
+	// writeTimeout bounds a single WebSocket write
+	writeTimeout = 10 * time.Second
+	// pingInterval keeps idle connections alive through proxies
+	pingInterval = 30 * time.Second
+)
+
+// checkOrigin accepts requests from clients that are not browsers, which send
+// no Origin header, from pages served by the same host and from the allowed
+// origins. The API has no authentication, so accepting any origin
+// would let every web page a user on the network opens drive the camera.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range s.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(strings.TrimSpace(allowed), "/"), origin) {
+			return true
+		}
+	}
+	s.logger.Warn("origin not allowed", "origin", origin, "path", r.URL.Path, "remote", r.RemoteAddr)
+	return false
+}
+
+// GET /events upgrades to a WebSocket that streams events and accepts control messages.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     s.checkOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an error response
+		s.logger.Debug("websocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxBodySize)
+	s.logger.Info("websocket client connected", "remote", r.RemoteAddr)
+	defer s.logger.Info("websocket client disconnected", "remote", r.RemoteAddr)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	events := s.events.Subscribe()
+	defer s.events.Unsubscribe(events)
+
+	// Control replies are produced by the reader and written by the writer, so
+	// only one goroutine ever writes to the connection
+	replies := make(chan control.Reply, 8)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer cancel()
+		s.readControl(ctx, conn, replies)
+	}()
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-s.shutdown:
+			cancel()
+			s.closeStream(conn, websocket.CloseGoingAway, done)
+			return
+		case <-ctx.Done():
+			s.closeStream(conn, websocket.CloseNormalClosure, done)
+			return
+		case msg := <-events:
+			err = s.writeMessage(conn, msg)
+		case reply := <-replies:
+			err = s.writeMessage(conn, reply)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+		}
+		if err != nil {
+			s.logger.Debug("websocket write failed", "error", err)
+			cancel()
+		}
+	}
+}
+
+// closeStream sends a close frame, closes the connection and waits for the reader to exit.
+func (s *Server) closeStream(conn *websocket.Conn, code int, readerDone <-chan struct{}) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(time.Second))
+	conn.Close()
+	<-readerDone
+}
+
+func (s *Server) writeMessage(conn *websocket.Conn, v any) error {
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return conn.WriteJSON(v)
+}
+
+// readControl reads control messages until the connection closes and queues a
+// reply for each. Commands run one at a time per connection.
+func (s *Server) readControl(ctx context.Context, conn *websocket.Conn, replies chan<- control.Reply) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var reply control.Reply
+		var req control.Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			reply = control.ErrorReply("", fmt.Errorf("invalid message: %w", err))
+		} else {
+			reply = control.Execute(ctx, s.sess, req)
+		}
+
+		select {
+		case replies <- reply:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/control"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// maxScanTimeout caps the scan duration a client can request from GET /devices.
+const maxScanTimeout = 60 * time.Second
+
+// GET /devices?timeout=5s scans for cameras and lists them.
+func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	timeout, err := parseDuration("timeout", r.URL.Query().Get("timeout"), 5*time.Second)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	timeout = min(timeout, maxScanTimeout)
+
+	devices, err := s.sess.Scan(r.Context(), timeout)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := struct {
+		Devices []control.Device `json:"devices"`
+	}{Devices: make([]control.Device, 0, len(devices))}
+	for _, device := range devices {
+		resp.Devices = append(resp.Devices, control.DeviceOf(s.sess, device))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// POST /connect {"device": "studio", "timeout": "15s"}
+func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Device  string `json:"device"`
+		Timeout string `json:"timeout"`
+	}
+	if err := decode(r, &req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	cfg := s.sess.Config()
+	timeout, err := parseDuration("timeout", req.Timeout, cfg.Scan.Timeout)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if req.Device == "" {
+		req.Device = cfg.DefaultCamera
+	}
+
+	if _, err := s.sess.Connect(r.Context(), req.Device, timeout); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, control.StateOf(s.sess))
+}
+
+// POST /disconnect
+func (s *Server) handleDisconnect(w http.ResponseWriter, r *http.Request) {
+	if err := s.sess.Disconnect(r.Context()); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, control.StateOf(s.sess))
+}
+
+// POST /shoot
+func (s *Server) handleShoot(w http.ResponseWriter, r *http.Request) {
+	err := s.sess.Do(r.Context(), func(client *sony_remote_ble.Client) error {
+		return client.TakePhoto()
+	})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, control.StateOf(s.sess))
+}
+
+// POST /record/start and POST /record/stop. The camera only exposes a toggle,
+// so the record button is pressed only when the camera reports the other
+// state, and cameras that send no notifications fail with 409.
+func (s *Server) handleRecord(w http.ResponseWriter, r *http.Request) {
+	on := r.URL.Path == "/record/start"
+	err := s.sess.Do(r.Context(), func(client *sony_remote_ble.Client) error {
+		return client.SetRecording(r.Context(), on)
+	})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, control.StateOf(s.sess))
+}
+
+// POST /buttons/{name}/press {"hold": "200ms"}
+func (s *Server) handlePress(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Hold string `json:"hold"`
+	}
+	if err := decode(r, &req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	hold, err := parseDuration("hold", req.Hold, sony_remote_ble.DefaultCommandDelay)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	button := r.PathValue("name")
+	err = s.sess.Do(r.Context(), func(client *sony_remote_ble.Client) error {
+		return client.Press(button, hold)
+	})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, control.StateOf(s.sess))
+}
+
+// POST /sequences {"commands": ["focus_down", "0x0109", ...], "delay": "100ms"}
+//
+// Each entry is either a name from the Commands map or a raw frame in hex.
+func (s *Server) handleSequence(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Commands []string `json:"commands"`
+		Delay    string   `json:"delay"`
+	}
+	if err := decode(r, &req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if len(req.Commands) == 0 {
+		s.writeError(w, &badRequest{fmt.Errorf("commands must not be empty")})
+		return
+	}
+	delay, err := parseDuration("delay", req.Delay, s.sess.Timing().CommandDelay)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	sequence := make([]sony_remote_ble.SonyCommand, 0, len(req.Commands))
+	for i, entry := range req.Commands {
+		cmd, err := control.ParseCommand(entry)
+		if err != nil {
+			s.writeError(w, &badRequest{fmt.Errorf("commands[%d]: %w", i, err)})
+			return
+		}
+		sequence = append(sequence, cmd)
+	}
+
+	err = s.sess.Do(r.Context(), func(client *sony_remote_ble.Client) error {
+		return client.SendCommandSequence(sequence, delay)
+	})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, control.StateOf(s.sess))
+}
+
+// GET /state
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, control.StateOf(s.sess))
+}
+
+// GET /info
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	timing := s.sess.Timing()
+	resp := struct {
+		Version        string        `json:"version"`
+		Service        string        `json:"service_uuid"`
+		Characteristic string        `json:"characteristic_uuid"`
+		CommandDelay   string        `json:"command_delay"`
+		Buttons        []string      `json:"buttons"`
+		Commands       []string      `json:"commands"`
+		State          control.State `json:"state"`
+	}{
+		Version:        s.version,
+		Service:        sony_remote_ble.SonyServiceUUID,
+		Characteristic: sony_remote_ble.CommandCharUUID,
+		CommandDelay:   timing.CommandDelay.String(),
+		Buttons:        sony_remote_ble.Buttons(),
+		Commands:       sony_remote_ble.CommandNames(),
+		State:          control.StateOf(s.sess),
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
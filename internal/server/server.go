@@ -0,0 +1,214 @@
+// Package server exposes a camera session over a JSON REST API so that other
+// software on the network can trigger the camera.
+//
+// All requests that touch the camera are serialized through the session; a
+// request that cannot get the link within the queue timeout fails with
+// 503 Service Unavailable instead of piling up behind a long operation.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/control"
+	"github.com/smazurov/sony_remote_ble/internal/metrics"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// maxBodySize limits request bodies; every request body is a small JSON object.
+const maxBodySize = 64 << 10
+
+// Server serves the REST API for a single camera session.
+type Server struct {
+	sess    *session.Session
+	logger  *slog.Logger
+	version string
+	mux     *http.ServeMux
+
+	// events numbers client events and fans them out to GET /events subscribers
+	events *control.Broadcaster
+	// metrics backs GET /metrics
+	metrics *metrics.Metrics
+	// shutdown is closed when the HTTP server shuts down, ending WebSocket streams
+	// that Shutdown itself does not wait for
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	// allowedOrigins are the browser origins besides the server's own that
+	// may open GET /events
+	allowedOrigins []string
+}
+
+// New creates a server for sess. The version is reported by GET /info.
+func New(sess *session.Session, logger *slog.Logger, version string) *Server {
+	s := &Server{
+		sess:     sess,
+		logger:   logger,
+		version:  version,
+		mux:      http.NewServeMux(),
+		events:   control.NewBroadcaster(sess),
+		metrics:  metrics.New(sess),
+		shutdown: make(chan struct{}),
+	}
+	s.routes()
+	return s
+}
+
+// SetAllowedOrigins lets web pages from the given origins, such as
+// "http://dashboard.local:3000", open the GET /events WebSocket. Pages served
+// by the same host are always allowed; "*" allows every origin.
+func (s *Server) SetAllowedOrigins(origins []string) {
+	s.allowedOrigins = origins
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /devices", s.handleDevices)
+	s.mux.HandleFunc("POST /connect", s.sameOrigin(s.handleConnect))
+	s.mux.HandleFunc("POST /disconnect", s.sameOrigin(s.handleDisconnect))
+	s.mux.HandleFunc("POST /shoot", s.sameOrigin(s.handleShoot))
+	s.mux.HandleFunc("POST /record/start", s.sameOrigin(s.handleRecord))
+	s.mux.HandleFunc("POST /record/stop", s.sameOrigin(s.handleRecord))
+	s.mux.HandleFunc("POST /buttons/{name}/press", s.sameOrigin(s.handlePress))
+	s.mux.HandleFunc("POST /sequences", s.sameOrigin(s.handleSequence))
+	s.mux.HandleFunc("GET /state", s.handleState)
+	s.mux.HandleFunc("GET /info", s.handleInfo)
+	s.mux.HandleFunc("GET /events", s.handleEvents)
+	s.mux.Handle("GET /metrics", s.metrics.Handler())
+}
+
+// sameOrigin rejects requests that web pages from other origins send, as
+// checkOrigin does for GET /events. Browsers let any page POST to the API
+// without asking, so this keeps a page a user opens from driving the camera.
+func (s *Server) sameOrigin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.checkOrigin(r) {
+			s.writeJSON(w, http.StatusForbidden, errorResponse{Error: "origin not allowed: " + r.Header.Get("Origin")})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	s.mux.ServeHTTP(w, r)
+	s.logger.Debug("http request", "method", r.Method, "path", r.URL.Path, "duration", time.Since(start))
+}
+
+// ListenAndServe serves the API on addr until ctx is cancelled, then shuts down gracefully.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	srv.RegisterOnShutdown(func() {
+		s.shutdownOnce.Do(func() { close(s.shutdown) })
+	})
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	s.logger.Info("http server listening", "addr", addr)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+// errorResponse is the body of every non-2xx response.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// badRequest marks errors caused by the request itself.
+type badRequest struct {
+	err error
+}
+
+func (e *badRequest) Error() string {
+	return e.err.Error()
+}
+
+func (e *badRequest) Unwrap() error {
+	return e.err
+}
+
+// statusFor maps errors onto HTTP status codes.
+func statusFor(err error) int {
+	var bad *badRequest
+	switch {
+	case errors.As(err, &bad):
+		return http.StatusBadRequest
+	case errors.Is(err, sony_remote_ble.ErrNotConnected), errors.Is(err, sony_remote_ble.ErrRecordingUnknown):
+		return http.StatusConflict
+	case errors.Is(err, session.ErrBusy):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, session.ErrNotFound), errors.Is(err, sony_remote_ble.ErrUnknownButton):
+		return http.StatusNotFound
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		// The camera or the Bluetooth stack rejected the operation
+		return http.StatusBadGateway
+	}
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Warn("failed to write response", "error", err)
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	status := statusFor(err)
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	s.logger.Debug("request failed", "status", status, "error", err)
+	s.writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// decode reads an optional JSON body into v. An empty body leaves v untouched.
+func decode(r *http.Request, v any) error {
+	if r.ContentLength == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return &badRequest{fmt.Errorf("invalid request body: %w", err)}
+	}
+	return nil
+}
+
+// parseDuration parses an optional duration field, returning fallback when empty.
+func parseDuration(field, value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, &badRequest{fmt.Errorf("%s: %w", field, err)}
+	}
+	if d < 0 {
+		return 0, &badRequest{fmt.Errorf("%s: must not be negative", field)}
+	}
+	return d, nil
+}
@@ -0,0 +1,259 @@
+package server_test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/control"
+	"github.com/smazurov/sony_remote_ble/internal/server"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/simulator"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// newServer serves the API for a session on cam.
+func newServer(t *testing.T, cam *simulator.Camera) (*server.Server, *session.Session) {
+	t.Helper()
+	sess := session.New(sony_remote_ble.NewClientWithTransport(cam), config.Default(), nil, logger)
+	t.Cleanup(func() { sess.Disconnect(context.Background()) })
+	return server.New(sess, logger, "v1.0.0"), sess
+}
+
+// newCamera creates a simulated camera that answers quickly.
+func newCamera(opts simulator.Options) *simulator.Camera {
+	opts.AdvertiseInterval = 10 * time.Millisecond
+	opts.RecordLatency = 10 * time.Millisecond
+	return simulator.New(opts)
+}
+
+// request sends a request with an optional JSON body and headers to srv and
+// returns the status and the decoded response.
+func request(t *testing.T, srv http.Handler, method, path, body string, header ...string) (int, map[string]any) {
+	t.Helper()
+	var r *http.Request
+	if body == "" {
+		r = httptest.NewRequest(method, path, nil)
+	} else {
+		r = httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s %s: response %q is not JSON: %v", method, path, w.Body.String(), err)
+	}
+	return w.Code, resp
+}
+
+// connect connects the server's session to cam through POST /connect.
+func connect(t *testing.T, srv http.Handler, cam *simulator.Camera) {
+	t.Helper()
+	body := `{"device": "` + cam.Address().String() + `", "timeout": "2s"}`
+	if status, resp := request(t, srv, "POST", "/connect", body); status != http.StatusOK || resp["state"] != "Connected" {
+		t.Fatalf("connect: %d %v", status, resp)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	cam := newCamera(simulator.Options{})
+	srv, _ := newServer(t, cam)
+
+	if status, resp := request(t, srv, "POST", "/shoot", ""); status != http.StatusConflict {
+		t.Fatalf("shoot while disconnected: %d %v, want 409", status, resp)
+	}
+	if status, _ := request(t, srv, "POST", "/connect", `{"device": "C0:FF:EE:00:00:99", "timeout": "50ms"}`); status != http.StatusNotFound {
+		t.Errorf("connect to a missing camera: %d, want 404", status)
+	}
+	connect(t, srv, cam)
+
+	if status, resp := request(t, srv, "POST", "/shoot", ""); status != http.StatusOK || cam.Shots() != 1 {
+		t.Fatalf("shoot: %d %v with %d shots", status, resp, cam.Shots())
+	}
+
+	status, resp := request(t, srv, "POST", "/record/start", "")
+	if status != http.StatusOK || resp["recording"] != true || !cam.Recording() {
+		t.Fatalf("record start: %d %v, camera recording %t", status, resp, cam.Recording())
+	}
+	status, resp = request(t, srv, "POST", "/record/stop", "")
+	if status != http.StatusOK || resp["recording"] != false || cam.Recording() {
+		t.Fatalf("record stop: %d %v, camera recording %t", status, resp, cam.Recording())
+	}
+
+	if status, resp := request(t, srv, "POST", "/buttons/c1/press", `{"hold": "10ms"}`); status != http.StatusOK || !pressedOnce(cam, "0108") {
+		t.Errorf("press c1: %d %v", status, resp)
+	}
+	if status, resp := request(t, srv, "POST", "/sequences", `{"commands": ["focus_down", "0x0106"], "delay": "1ms"}`); status != http.StatusOK {
+		t.Errorf("sequence: %d %v", status, resp)
+	}
+
+	if status, resp := request(t, srv, "GET", "/state", ""); status != http.StatusOK || resp["state"] != "Connected" {
+		t.Errorf("state: %d %v", status, resp)
+	}
+	if status, resp := request(t, srv, "GET", "/info", ""); status != http.StatusOK || resp["version"] != "v1.0.0" {
+		t.Errorf("info: %d %v", status, resp)
+	}
+
+	if status, resp := request(t, srv, "POST", "/disconnect", ""); status != http.StatusOK || resp["state"] != "Disconnected" {
+		t.Errorf("disconnect: %d %v", status, resp)
+	}
+}
+
+// pressedOnce reports whether cam received the frame in hex exactly once.
+func pressedOnce(cam *simulator.Camera, frame string) bool {
+	n := 0
+	for _, f := range cam.Frames() {
+		if hex.EncodeToString(f) == frame {
+			n++
+		}
+	}
+	return n == 1
+}
+
+func TestBodyValidation(t *testing.T) {
+	cam := newCamera(simulator.Options{})
+	srv, _ := newServer(t, cam)
+	connect(t, srv, cam)
+
+	tests := []struct {
+		path, body string
+		status     int
+		error      string
+	}{
+		{"/buttons/c1/press", `{"hold": "soon"}`, http.StatusBadRequest, "hold: "},
+		{"/buttons/c1/press", `{"hold": "-1s"}`, http.StatusBadRequest, "hold: must not be negative"},
+		{"/buttons/c1/press", `{"hold": "1s", "speed": 3}`, http.StatusBadRequest, `unknown field "speed"`},
+		{"/buttons/c1/press", `{"hold":`, http.StatusBadRequest, "invalid request body"},
+		{"/buttons/nope/press", "", http.StatusNotFound, "nope"},
+		{"/sequences", `{"delay": "1ms"}`, http.StatusBadRequest, "commands must not be empty"},
+		{"/sequences", `{"commands": ["focus_down", "wave"]}`, http.StatusBadRequest, "commands[1]: "},
+		{"/sequences", `{"commands": ["focus_down"], "delay": "fast"}`, http.StatusBadRequest, "delay: "},
+		{"/connect", `{"timeout": "later"}`, http.StatusBadRequest, "timeout: "},
+	}
+	for _, tt := range tests {
+		status, resp := request(t, srv, "POST", tt.path, tt.body)
+		msg, _ := resp["error"].(string)
+		if status != tt.status || !strings.Contains(msg, tt.error) {
+			t.Errorf("POST %s %s: %d %q, want %d mentioning %q", tt.path, tt.body, status, msg, tt.status, tt.error)
+		}
+	}
+	if len(cam.Frames()) != 0 {
+		t.Errorf("invalid requests sent %d frames", len(cam.Frames()))
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	cam := newCamera(simulator.Options{NoNotifications: true})
+	srv, sess := newServer(t, cam)
+	connect(t, srv, cam)
+
+	// Recording cannot be started on a camera that does not report it
+	if status, resp := request(t, srv, "POST", "/record/start", ""); status != http.StatusConflict {
+		t.Errorf("record start without notifications: %d %v, want 409", status, resp)
+	}
+
+	// The camera refusing a write is a gateway failure
+	cam.RejectWrites(1)
+	if status, resp := request(t, srv, "POST", "/shoot", ""); status != http.StatusBadGateway {
+		t.Errorf("rejected write: %d %v, want 502", status, resp)
+	}
+
+	// A request that cannot get the link in time is turned away
+	sess.SetQueueTimeout(20 * time.Millisecond)
+	release := make(chan struct{})
+	held := make(chan struct{})
+	go sess.Do(context.Background(), func(*sony_remote_ble.Client) error {
+		close(held)
+		<-release
+		return nil
+	})
+	<-held
+	r := httptest.NewRequest("POST", "/shoot", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+	close(release)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("busy link: %d with Retry-After %q, want 503 with the header", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestPostsCheckOrigin(t *testing.T) {
+	cam := newCamera(simulator.Options{})
+	srv, _ := newServer(t, cam)
+	srv.SetAllowedOrigins([]string{"http://dashboard.local:3000/"})
+	connect(t, srv, cam)
+
+	tests := []struct {
+		origin string
+		status int
+	}{
+		{"", http.StatusOK},
+		{"http://example.com", http.StatusOK}, // the host httptest requests are sent to
+		{"http://dashboard.local:3000", http.StatusOK},
+		{"http://evil.example", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	shots := 0
+	for _, tt := range tests {
+		var header []string
+		if tt.origin != "" {
+			header = []string{"Origin", tt.origin}
+		}
+		status, resp := request(t, srv, "POST", "/shoot", "", header...)
+		if status != tt.status {
+			t.Errorf("origin %q: %d %v, want %d", tt.origin, status, resp, tt.status)
+		}
+		if status == http.StatusOK {
+			shots++
+		}
+	}
+	if cam.Shots() != shots {
+		t.Errorf("camera took %d shots, want %d from the allowed origins", cam.Shots(), shots)
+	}
+
+	// Every state-changing route checks, before reading the body
+	for _, path := range []string{"/connect", "/disconnect", "/record/start", "/record/stop", "/buttons/c1/press", "/sequences"} {
+		if status, _ := request(t, srv, "POST", path, `{"bogus": true}`, "Origin", "http://evil.example"); status != http.StatusForbidden {
+			t.Errorf("POST %s from another origin: %d, want 403", path, status)
+		}
+	}
+	if status, _ := request(t, srv, "GET", "/state", "", "Origin", "http://evil.example"); status != http.StatusOK {
+		t.Errorf("GET /state from another origin: %d, want 200", status)
+	}
+}
+
+// TestStateReportsRecording checks the recording field against the simulator.
+func TestStateReportsRecording(t *testing.T) {
+	cam := newCamera(simulator.Options{})
+	srv, _ := newServer(t, cam)
+	connect(t, srv, cam)
+
+	var state control.State
+	r := httptest.NewRequest("GET", "/state", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+	if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Recording == nil || *state.Recording {
+		t.Errorf("recording %v after connecting, want known and stopped", state.Recording)
+	}
+	if state.Device == nil || state.Device.Address != cam.Address().String() {
+		t.Errorf("device %+v, want the simulator", state.Device)
+	}
+}
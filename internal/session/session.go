@@ -0,0 +1,408 @@
+// Package session owns a camera client on behalf of long-running front ends such
+// as the HTTP server. Bluetooth only carries one operation at a time, so every
+// scan, connection and command goes through Do, which serializes access to the link.
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/known"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// ErrBusy is returned when the link stays occupied by another operation for
+// longer than the queue timeout.
+var ErrBusy = errors.New("camera is busy")
+
+// ErrNotFound is returned when the requested camera does not advertise before
+// the scan times out.
+var ErrNotFound = errors.New("camera not found")
+
+// ErrConnect wraps errors from connecting to a camera that was found.
+var ErrConnect = errors.New("connection failed")
+
+// Session serializes access to a single camera client and tracks the connected camera.
+type Session struct {
+	client *sony_remote_ble.Client
+	cfg    *config.Config
+	known  *known.Store
+	logger *slog.Logger
+
+	// link is a one-slot semaphore held for the duration of every BLE operation
+	link chan struct{}
+	// queueTimeout bounds how long Do waits for the link before returning ErrBusy
+	queueTimeout time.Duration
+
+	mu     sync.Mutex
+	device sony_remote_ble.DeviceInfo
+	timing config.Timing
+	// heldSince is when the current operation took the link, zero when it is free
+	heldSince time.Time
+	// keepAwake is the running keep-awake, nil if none was started
+	keepAwake *sony_remote_ble.KeepAwake
+}
+
+// New creates a session around client and applies the idle disconnect of
+// cfg to it. The known store may be nil.
+func New(client *sony_remote_ble.Client, cfg *config.Config, store *known.Store, logger *slog.Logger) *Session {
+	client.SetIdleDisconnect(cfg.IdleDisconnect.Options())
+	return &Session{
+		client:       client,
+		cfg:          cfg,
+		known:        store,
+		logger:       logger,
+		link:         make(chan struct{}, 1),
+		queueTimeout: 5 * time.Second,
+		timing:       cfg.Timing,
+	}
+}
+
+// SetQueueTimeout changes how long operations wait for the link before failing with ErrBusy.
+func (s *Session) SetQueueTimeout(timeout time.Duration) {
+	s.queueTimeout = timeout
+}
+
+// Do runs fn with exclusive use of the client. It waits for any operation in
+// progress to finish, up to the queue timeout, and returns ErrBusy if the link
+// does not become free in time.
+func (s *Session) Do(ctx context.Context, fn func(client *sony_remote_ble.Client) error) error {
+	timer := time.NewTimer(s.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case s.link <- struct{}{}:
+	case <-timer.C:
+		return ErrBusy
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return s.run(fn)
+}
+
+// TryDo runs fn with exclusive use of the client if no other operation holds
+// the link, and returns ErrBusy without waiting otherwise.
+func (s *Session) TryDo(fn func(client *sony_remote_ble.Client) error) error {
+	select {
+	case s.link <- struct{}{}:
+	default:
+		return ErrBusy
+	}
+	return s.run(fn)
+}
+
+// run runs fn on the link the caller took.
+func (s *Session) run(fn func(client *sony_remote_ble.Client) error) error {
+	s.mu.Lock()
+	s.heldSince = time.Now()
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.heldSince = time.Time{}
+		s.mu.Unlock()
+		<-s.link
+	}()
+
+	return fn(s.client)
+}
+
+// KeepAwake signals the camera whenever it was idle for the interval of opts,
+// until ctx ends, so that it does not power off. Signals are only sent while
+// no other operation holds the link.
+func (s *Session) KeepAwake(ctx context.Context, opts sony_remote_ble.KeepAwakeOptions) error {
+	opts.Exclusive = func(send func() error) error {
+		return s.TryDo(func(*sony_remote_ble.Client) error { return send() })
+	}
+	keepAwake := sony_remote_ble.NewKeepAwake(s.client, opts)
+	s.mu.Lock()
+	s.keepAwake = keepAwake
+	s.mu.Unlock()
+	return keepAwake.Run(ctx)
+}
+
+// KeepAwakeStatus returns the status of the keep-awake, or false if KeepAwake
+// was never started.
+func (s *Session) KeepAwakeStatus() (sony_remote_ble.KeepAwakeStatus, bool) {
+	s.mu.Lock()
+	keepAwake := s.keepAwake
+	s.mu.Unlock()
+	if keepAwake == nil {
+		return sony_remote_ble.KeepAwakeStatus{}, false
+	}
+	return keepAwake.Status(), true
+}
+
+// Busy reports whether an operation currently holds the link.
+func (s *Session) Busy() bool {
+	return len(s.link) > 0
+}
+
+// HeldFor returns how long the current operation has held the link, or zero if
+// the link is free. A value far beyond any command's duration indicates a
+// wedged Bluetooth stack.
+func (s *Session) HeldFor() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.heldSince.IsZero() {
+		return 0
+	}
+	return time.Since(s.heldSince)
+}
+
+// State returns the connection state of the client without waiting for the link.
+func (s *Session) State() sony_remote_ble.ConnectionState {
+	return s.client.State()
+}
+
+// Idle reports whether the client closed the link to the connected camera for
+// being idle; the next command reopens it.
+func (s *Session) Idle() bool {
+	return s.client.Idle()
+}
+
+// Recording reports whether the connected camera is recording, and whether
+// that is known at all; see sony_remote_ble.Client.Recording.
+func (s *Session) Recording() (recording, known bool) {
+	return s.client.Recording()
+}
+
+// LastError returns the most recent error recorded by the client.
+func (s *Session) LastError() error {
+	return s.client.LastError()
+}
+
+// Device returns the connected camera, or false if none is connected.
+func (s *Session) Device() (sony_remote_ble.DeviceInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client.State() != sony_remote_ble.Connected {
+		return sony_remote_ble.DeviceInfo{}, false
+	}
+	return s.device, true
+}
+
+// Timing returns the command timings for the connected camera.
+func (s *Session) Timing() config.Timing {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.timing
+}
+
+// Config returns the configuration the session was created with.
+func (s *Session) Config() *config.Config {
+	return s.cfg
+}
+
+// Known returns the known cameras store, which may be nil.
+func (s *Session) Known() *known.Store {
+	return s.known
+}
+
+// OnEvent registers fn for events emitted by the client. Registering does not
+// take the link, so listeners keep receiving events while operations run.
+func (s *Session) OnEvent(fn func(sony_remote_ble.Event)) (remove func()) {
+	return s.client.OnEvent(fn)
+}
+
+// Scan collects advertising cameras for the given duration, keeping the latest
+// reading for each address.
+func (s *Session) Scan(ctx context.Context, timeout time.Duration) ([]sony_remote_ble.DeviceInfo, error) {
+	var found []sony_remote_ble.DeviceInfo
+	err := s.Do(ctx, func(client *sony_remote_ble.Client) error {
+		scanCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		deviceChan := make(chan sony_remote_ble.DeviceInfo, 10)
+		if err := client.ScanForDevices(scanCtx, deviceChan); err != nil {
+			return fmt.Errorf("failed to start scan: %w", err)
+		}
+		defer client.StopScan()
+
+		index := make(map[string]int)
+		for {
+			select {
+			case device := <-deviceChan:
+				if i, ok := index[device.AddressStr]; ok {
+					found[i] = device
+					continue
+				}
+				index[device.AddressStr] = len(found)
+				found = append(found, device)
+			case <-scanCtx.Done():
+				if err := client.LastError(); err != nil {
+					return fmt.Errorf("scan failed: %w", err)
+				}
+				return nil
+			}
+		}
+	})
+	return found, err
+}
+
+// Find scans until a camera matching the query advertises. The query may be an
+// alias, name or address; an empty query matches the first camera found.
+// Returns ErrNotFound if no camera matches before the timeout.
+func (s *Session) Find(ctx context.Context, query string, timeout time.Duration) (sony_remote_ble.DeviceInfo, error) {
+	var device sony_remote_ble.DeviceInfo
+	err := s.Do(ctx, func(client *sony_remote_ble.Client) error {
+		var err error
+		device, err = s.find(ctx, client, query, timeout)
+		return err
+	})
+	return device, err
+}
+
+func (s *Session) find(ctx context.Context, client *sony_remote_ble.Client, query string, timeout time.Duration) (sony_remote_ble.DeviceInfo, error) {
+	query = s.cfg.ResolveAlias(query)
+
+	scanCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	deviceChan := make(chan sony_remote_ble.DeviceInfo, 10)
+	if err := client.ScanForDevices(scanCtx, deviceChan); err != nil {
+		return sony_remote_ble.DeviceInfo{}, fmt.Errorf("failed to start scan: %w", err)
+	}
+	defer client.StopScan()
+	s.logger.Debug("scanning for camera", "query", query, "timeout", timeout)
+
+	for {
+		select {
+		case device := <-deviceChan:
+			if MatchesDevice(query, device) {
+				s.logger.Debug("camera found", "name", device.Name, "address", device.AddressStr, "rssi", device.RSSI)
+				return device, nil
+			}
+		case <-scanCtx.Done():
+			if err := client.LastError(); err != nil {
+				return sony_remote_ble.DeviceInfo{}, fmt.Errorf("scan failed: %w", err)
+			}
+			if ctx.Err() != nil {
+				return sony_remote_ble.DeviceInfo{}, ctx.Err()
+			}
+			if query == "" {
+				return sony_remote_ble.DeviceInfo{}, fmt.Errorf("%w: no Sony camera advertised within %s", ErrNotFound, timeout)
+			}
+			return sony_remote_ble.DeviceInfo{}, fmt.Errorf("%w: %q did not advertise within %s", ErrNotFound, query, timeout)
+		}
+	}
+}
+
+// Connect finds the camera matching the query and connects to it, replacing
+// any existing connection. Per-camera timings from the configuration are applied
+// and the camera is recorded in the known cameras store.
+func (s *Session) Connect(ctx context.Context, query string, timeout time.Duration) (sony_remote_ble.DeviceInfo, error) {
+	var device sony_remote_ble.DeviceInfo
+	err := s.Do(ctx, func(client *sony_remote_ble.Client) error {
+		if client.State() == sony_remote_ble.Connected {
+			if err := client.Disconnect(); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		device, err = s.connect(ctx, client, query, timeout)
+		return err
+	})
+	return device, err
+}
+
+// EnsureConnected connects to the camera matching the query unless a camera is
+// already connected, and reports whether a new connection was made. Front ends
+// that connect on demand use it so that concurrent commands connect only once.
+func (s *Session) EnsureConnected(ctx context.Context, query string, timeout time.Duration) (sony_remote_ble.DeviceInfo, bool, error) {
+	var device sony_remote_ble.DeviceInfo
+	var connected bool
+	err := s.Do(ctx, func(client *sony_remote_ble.Client) error {
+		if client.State() == sony_remote_ble.Connected {
+			s.mu.Lock()
+			device = s.device
+			s.mu.Unlock()
+			return nil
+		}
+
+		var err error
+		device, err = s.connect(ctx, client, query, timeout)
+		connected = err == nil
+		return err
+	})
+	return device, connected, err
+}
+
+// EnsureConnectedTo connects to a camera that was found by another scan, such
+// as a presence watcher's, without scanning for it again, unless a camera is
+// already connected. It reports whether a new connection was made.
+func (s *Session) EnsureConnectedTo(ctx context.Context, device sony_remote_ble.DeviceInfo) (bool, error) {
+	var connected bool
+	err := s.Do(ctx, func(client *sony_remote_ble.Client) error {
+		if client.State() == sony_remote_ble.Connected {
+			return nil
+		}
+		err := s.connectDevice(client, device)
+		connected = err == nil
+		return err
+	})
+	return connected, err
+}
+
+func (s *Session) connect(ctx context.Context, client *sony_remote_ble.Client, query string, timeout time.Duration) (sony_remote_ble.DeviceInfo, error) {
+	device, err := s.find(ctx, client, query, timeout)
+	if err != nil {
+		return sony_remote_ble.DeviceInfo{}, err
+	}
+	if err := s.connectDevice(client, device); err != nil {
+		return sony_remote_ble.DeviceInfo{}, err
+	}
+	return device, nil
+}
+
+// connectDevice connects to a camera that was found, applies its timings and
+// remembers it.
+func (s *Session) connectDevice(client *sony_remote_ble.Client, device sony_remote_ble.DeviceInfo) error {
+	// Device only reports the camera while the client is connected, so it is
+	// recorded first for listeners to the Connected event
+	s.mu.Lock()
+	s.device = device
+	s.mu.Unlock()
+	if err := client.Connect(device.Address); err != nil {
+		return fmt.Errorf("%w: %w", ErrConnect, err)
+	}
+	s.logger.Debug("connected", "name", device.Name, "address", device.AddressStr)
+
+	timing := s.cfg.TimingFor(device.Name, device.AddressStr)
+	client.SetCommandDelay(timing.CommandDelay)
+	client.SetTimingProfile(timing.Profile())
+
+	s.mu.Lock()
+	s.timing = timing
+	s.mu.Unlock()
+
+	if s.known != nil {
+		if err := s.known.Remember(device, time.Now()); err != nil {
+			s.logger.Warn("could not save known camera", "error", err)
+		}
+	}
+	return nil
+}
+
+// Disconnect closes the connection to the current camera, if any.
+func (s *Session) Disconnect(ctx context.Context) error {
+	return s.Do(ctx, func(client *sony_remote_ble.Client) error {
+		return client.Disconnect()
+	})
+}
+
+// MatchesDevice reports whether a scanned device matches a name or address query,
+// ignoring case. An empty query matches any device.
+func MatchesDevice(query string, device sony_remote_ble.DeviceInfo) bool {
+	if query == "" {
+		return true
+	}
+	return strings.EqualFold(device.AddressStr, query) || strings.EqualFold(device.Name, query)
+}
@@ -0,0 +1,177 @@
+package session_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/known"
+	"github.com/smazurov/sony_remote_ble/internal/session"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/simulator"
+	"tinygo.org/x/bluetooth"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// studio is a room of simulated cameras advertising at the same time.
+type studio []*simulator.Camera
+
+// Scan implements sony_remote_ble.Transport.
+func (s studio) Scan(found func(sony_remote_ble.DeviceInfo)) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(s))
+	for i, cam := range s {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = cam.Scan(found)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// StopScan implements sony_remote_ble.Transport.
+func (s studio) StopScan() error {
+	for _, cam := range s {
+		cam.StopScan()
+	}
+	return nil
+}
+
+// Connect implements sony_remote_ble.Transport.
+func (s studio) Connect(address bluetooth.Address) (sony_remote_ble.Link, error) {
+	for _, cam := range s {
+		if cam.Address().String() == address.String() {
+			return cam.Connect(address)
+		}
+	}
+	return nil, fmt.Errorf("no simulated camera at %s", address.String())
+}
+
+// newStudio creates the cameras of a studio: an A7 IV, an RX100 VII and an A7 III.
+func newStudio() studio {
+	var s studio
+	for i, name := range []string{"ILCE-7M4", "DSC-RX100M7", "ILCE-7M3"} {
+		s = append(s, simulator.New(simulator.Options{
+			Name:              name,
+			Address:           fmt.Sprintf("C0:FF:EE:00:00:%02X", i+1),
+			AdvertiseInterval: 5 * time.Millisecond,
+		}))
+	}
+	return s
+}
+
+// newSession creates a session on the cameras of s with cfg and store.
+func newSession(t *testing.T, s studio, cfg *config.Config, store *known.Store) *session.Session {
+	t.Helper()
+	sess := session.New(sony_remote_ble.NewClientWithTransport(s), cfg, store, logger)
+	t.Cleanup(func() { sess.Disconnect(context.Background()) })
+	return sess
+}
+
+func TestMatchesDevice(t *testing.T) {
+	device := sony_remote_ble.DeviceInfo{Name: "ILCE-7M4", AddressStr: "C0:FF:EE:00:00:01"}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"ILCE-7M4", true},
+		{"ilce-7m4", true},
+		{"C0:FF:EE:00:00:01", true},
+		{"c0:ff:ee:00:00:01", true},
+		{"ILCE-7", false},
+		{"C0:FF:EE:00:00:02", false},
+		{"studio", false}, // aliases are resolved before matching
+	}
+	for _, tt := range tests {
+		if got := session.MatchesDevice(tt.query, device); got != tt.want {
+			t.Errorf("MatchesDevice(%q) = %t, want %t", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestEnsureConnectedChoosesCamera(t *testing.T) {
+	cfg := config.Default()
+	cfg.Aliases["studio"] = "C0:FF:EE:00:00:03"
+	ctx := context.Background()
+
+	tests := []struct {
+		query, address string
+	}{
+		{"studio", "C0:FF:EE:00:00:03"},
+		{"STUDIO", "C0:FF:EE:00:00:03"},
+		{"dsc-rx100m7", "C0:FF:EE:00:00:02"},
+		{"c0:ff:ee:00:00:01", "C0:FF:EE:00:00:01"},
+	}
+	for _, tt := range tests {
+		s := newStudio()
+		sess := newSession(t, s, cfg, nil)
+		device, connected, err := sess.EnsureConnected(ctx, tt.query, time.Second)
+		if err != nil || !connected || device.AddressStr != tt.address {
+			t.Errorf("%q: connected %t to %s (%v), want %s", tt.query, connected, device.AddressStr, err, tt.address)
+			continue
+		}
+		if current, ok := sess.Device(); !ok || current.AddressStr != tt.address {
+			t.Errorf("%q: session device %+v, want %s", tt.query, current, tt.address)
+		}
+
+		// A connected session stays on its camera whatever is asked for
+		device, connected, err = sess.EnsureConnected(ctx, "ILCE-7M4", time.Second)
+		if err != nil || connected || device.AddressStr != tt.address {
+			t.Errorf("%q then ILCE-7M4: connected %t to %s (%v), want to stay on %s", tt.query, connected, device.AddressStr, err, tt.address)
+		}
+	}
+
+	sess := newSession(t, newStudio(), cfg, nil)
+	if _, _, err := sess.EnsureConnected(ctx, "ILCE-7RM5", 50*time.Millisecond); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("camera nobody has: %v, want ErrNotFound", err)
+	}
+}
+
+func TestEnsureConnectedTo(t *testing.T) {
+	s := newStudio()
+	cfg := config.Default()
+	cfg.Aliases["studio"] = "C0:FF:EE:00:00:03"
+	cfg.Cameras["studio"] = config.Timing{CommandDelay: 120 * time.Millisecond}
+	store, err := known.Open(filepath.Join(t.TempDir(), "cameras.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := newSession(t, s, cfg, store)
+	ctx := context.Background()
+
+	// The device comes from another scan and is connected without a new one
+	s[2].FailConnects(1)
+	if connected, err := sess.EnsureConnectedTo(ctx, s[2].Device()); connected || !errors.Is(err, session.ErrConnect) {
+		t.Fatalf("refused connection: connected %t (%v), want ErrConnect", connected, err)
+	}
+	if connected, err := sess.EnsureConnectedTo(ctx, s[2].Device()); !connected || err != nil {
+		t.Fatalf("connected %t (%v), want a new connection", connected, err)
+	}
+	if device, _ := sess.Device(); device.AddressStr != "C0:FF:EE:00:00:03" {
+		t.Errorf("connected to %s, want the A7 III", device.AddressStr)
+	}
+	if got := sess.Timing().CommandDelay; got != 120*time.Millisecond {
+		t.Errorf("command delay %s, want the alias section's 120ms", got)
+	}
+	if camera, ok := store.Lookup("ILCE-7M3"); !ok || camera.Address != "C0:FF:EE:00:00:03" {
+		t.Errorf("known cameras %+v, want the A7 III remembered", store.Cameras())
+	}
+
+	if connected, err := sess.EnsureConnectedTo(ctx, s[0].Device()); connected || err != nil {
+		t.Errorf("while connected: connected %t (%v), want to keep the link", connected, err)
+	}
+	if device, _ := sess.Device(); device.AddressStr != "C0:FF:EE:00:00:03" {
+		t.Errorf("switched to %s while connected", device.AddressStr)
+	}
+}
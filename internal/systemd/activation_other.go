@@ -0,0 +1,10 @@
+//go:build !unix
+
+package systemd
+
+import "net"
+
+// Listeners returns nil; socket activation is only available on Unix.
+func Listeners() ([]net.Listener, error) {
+	return nil, nil
+}
@@ -0,0 +1,161 @@
+package systemd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// journalSocket is where journald receives native protocol datagrams.
+const journalSocket = "/run/systemd/journal/socket"
+
+// JournalHandler is a slog.Handler that sends records to journald using its
+// native protocol, so attributes become searchable journal fields:
+//
+//	journalctl -u sony-remote DEVICE=studio
+//
+// Attribute keys are upper-cased and anything other than letters, digits and
+// underscores is replaced, so "retry_in" becomes RETRY_IN and an attribute
+// "name" in group "camera" becomes CAMERA_NAME.
+type JournalHandler struct {
+	conn       *net.UnixConn
+	level      slog.Leveler
+	identifier string
+	// fields holds the encoded attributes added with WithAttrs
+	fields []byte
+	// prefix is the field name prefix of the current group
+	prefix string
+}
+
+// NewJournalHandler connects to the journal. It fails when journald is not
+// running, in which case callers should fall back to another handler.
+func NewJournalHandler(identifier string, level slog.Leveler) (*JournalHandler, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &JournalHandler{conn: conn, level: level, identifier: identifier}, nil
+}
+
+// Enabled implements slog.Handler.
+func (h *JournalHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle implements slog.Handler.
+func (h *JournalHandler) Handle(_ context.Context, r slog.Record) error {
+	var b bytes.Buffer
+	writeField(&b, "MESSAGE", r.Message)
+	writeField(&b, "PRIORITY", strconv.Itoa(priority(r.Level)))
+	writeField(&b, "SYSLOG_IDENTIFIER", h.identifier)
+	b.Write(h.fields)
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&b, h.prefix, a)
+		return true
+	})
+
+	_, err := h.conn.Write(b.Bytes())
+	return err
+}
+
+// WithAttrs implements slog.Handler.
+func (h *JournalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	var b bytes.Buffer
+	b.Write(h.fields)
+	for _, a := range attrs {
+		writeAttr(&b, h.prefix, a)
+	}
+	h2.fields = b.Bytes()
+	return &h2
+}
+
+// WithGroup implements slog.Handler.
+func (h *JournalHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + fieldName(name) + "_"
+	return &h2
+}
+
+func writeAttr(b *bytes.Buffer, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += fieldName(a.Key) + "_"
+		}
+		for _, ga := range a.Value.Group() {
+			writeAttr(b, prefix, ga)
+		}
+		return
+	}
+
+	var value string
+	switch a.Value.Kind() {
+	case slog.KindTime:
+		value = a.Value.Time().Format(time.RFC3339Nano)
+	default:
+		value = a.Value.String()
+	}
+	writeField(b, prefix+fieldName(a.Key), value)
+}
+
+// writeField encodes one field. Values containing newlines use the binary form
+// with an explicit length.
+func writeField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteString(name)
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// fieldName converts an attribute key into a valid journal field name.
+func fieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	// Fields starting with an underscore are reserved for journald
+	s := strings.TrimLeft(string(name), "_")
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "X_" + s
+	}
+	return s
+}
+
+// priority maps slog levels onto syslog priorities.
+func priority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"context"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"tinygo.org/x/bluetooth"
+)
+
+// Controller is what the TUI drives: a Bluetooth client, a simulated or
+// replayed one, the camera of a running daemon or a fake in tests. Every
+// sony_remote_ble.Camera is a Controller.
+type Controller interface {
+	State() sony_remote_ble.ConnectionState
+	DeviceName() string
+	SetCommandDelay(delay time.Duration)
+	SetTimingProfile(profile sony_remote_ble.TimingProfile)
+
+	ScanForDevices(ctx context.Context, deviceChan chan<- sony_remote_ble.DeviceInfo) error
+	StopScan()
+	Connect(address bluetooth.Address) error
+	Disconnect() error
+
+	SendCommand(cmd sony_remote_ble.SonyCommand) error
+	Press(button string, hold time.Duration) error
+	Zoom(in bool, speed byte, hold time.Duration) error
+	TakePhoto() error
+}
+
+// sharedController is implemented by controllers that drive a camera owned
+// by someone else, such as the camera of a running daemon. The TUI leaves such
+// a camera connected when it quits or leaves a view, so that it does not drop
+// the camera for every other client.
+type sharedController interface {
+	Shared() bool
+}
+
+// owned reports whether the TUI owns the camera of ctrl and should disconnect
+// it when done with it.
+func owned(ctrl Controller) bool {
+	shared, ok := ctrl.(sharedController)
+	return !ok || !shared.Shared()
+}
+
+// Unavailable returns a controller whose scans, connections and commands all
+// fail with err, so that the TUI can still start and show why, for example
+// when the host has no Bluetooth adapter.
+func Unavailable(err error) Controller {
+	return unavailable{err: err}
+}
+
+type unavailable struct {
+	err error
+}
+
+func (u unavailable) State() sony_remote_ble.ConnectionState { return sony_remote_ble.Disconnected }
+func (u unavailable) DeviceName() string                     { return "" }
+func (u unavailable) SetCommandDelay(time.Duration)          {}
+func (u unavailable) StopScan()                              {}
+func (u unavailable) Disconnect() error                      { return nil }
+func (u unavailable) TakePhoto() error                       { return u.err }
+
+func (u unavailable) SetTimingProfile(sony_remote_ble.TimingProfile) {}
+
+func (u unavailable) ScanForDevices(context.Context, chan<- sony_remote_ble.DeviceInfo) error {
+	return u.err
+}
+
+func (u unavailable) Connect(bluetooth.Address) error               { return u.err }
+func (u unavailable) SendCommand(sony_remote_ble.SonyCommand) error { return u.err }
+func (u unavailable) Press(string, time.Duration) error             { return u.err }
+func (u unavailable) Zoom(bool, byte, time.Duration) error          { return u.err }
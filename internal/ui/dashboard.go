@@ -0,0 +1,484 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+)
+
+// errNoController is reported when the dashboard has no controller left for
+// another camera, e.g. when driving a daemon that owns a single camera.
+var errNoController = errors.New("no controller available for another camera")
+
+// dashCamera is a camera connected from the dashboard.
+type dashCamera struct {
+	ctrl Controller
+	// primary is set when ctrl is the model's own controller
+	primary bool
+	state   sony_remote_ble.ConnectionState
+	// busy is set while a connect or disconnect is in flight
+	busy      bool
+	recording bool
+	// last describes the last command fired at the camera
+	last   string
+	remove func()
+}
+
+// eventSource is implemented by controllers that report their events, such as
+// sony_remote_ble.Client. The dashboard uses them for state and recording.
+type eventSource interface {
+	OnEvent(fn func(sony_remote_ble.Event)) (remove func())
+}
+
+type dashConnectMsg struct {
+	address string
+	err     error
+}
+type dashDisconnectMsg struct {
+	address string
+	err     error
+}
+type dashEventMsg struct {
+	address string
+	event   sony_remote_ble.Event
+}
+type dashFireMsg struct {
+	action string
+	result sony_remote_ble.GroupResult
+	err    error
+}
+
+func (m *Model) handleDashboardKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch m.keys.dashboard[msg.String()] {
+	case config.ActionQuit:
+		m.disconnectDashboard()
+		m.cancel()
+		return m, tea.Quit
+
+	case config.ActionBack:
+		m.disconnectDashboard()
+		if m.scanning {
+			m.ctrl.StopScan()
+			m.scanning = false
+		}
+		m.mode = ModeDeviceList
+		m.addLog("Left dashboard")
+
+	case config.ActionScan:
+		if !m.scanning {
+			return m, func() tea.Msg { return scanStartMsg{} }
+		}
+		m.addLog("Stopping scan...")
+		m.ctrl.StopScan()
+		return m, func() tea.Msg { return scanCompleteMsg{} }
+
+	case config.ActionUp:
+		if m.selected > 0 {
+			m.selected--
+		}
+
+	case config.ActionDown:
+		if m.selected < len(m.devices)-1 {
+			m.selected++
+		}
+
+	case config.ActionSelect:
+		if device, ok := m.cursorDevice(); ok {
+			m.dashSelected[device.AddressStr] = !m.dashSelected[device.AddressStr]
+		}
+
+	case config.ActionSelectAll:
+		// Select every camera, or clear the selection if all are selected
+		all := len(m.devices) > 0
+		for _, device := range m.devices {
+			all = all && m.dashSelected[device.AddressStr]
+		}
+		for _, device := range m.devices {
+			m.dashSelected[device.AddressStr] = !all
+		}
+
+	case config.ActionConnect:
+		device, ok := m.cursorDevice()
+		if !ok {
+			break
+		}
+		if cam, ok := m.dash[device.AddressStr]; ok {
+			if cam.busy {
+				break
+			}
+			m.addLog(fmt.Sprintf("Disconnecting %s...", device.Name))
+			return m, m.dashDisconnect(device.AddressStr, cam)
+		}
+		return m, m.dashConnect(device)
+
+	case config.ActionShutter:
+		return m, m.dashFire("Photo")
+
+	case config.ActionRecord:
+		return m, m.dashFire("Record")
+	}
+	return m, nil
+}
+
+// updateDashboard handles the messages of dashboard commands.
+func (m *Model) updateDashboard(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case dashConnectMsg:
+		cam, ok := m.dash[msg.address]
+		if !ok {
+			return m, nil
+		}
+		cam.busy = false
+		cam.state = cam.ctrl.State()
+		if msg.err != nil {
+			m.addLog(fmt.Sprintf("Connection to %s failed: %v", m.deviceLabel(msg.address), msg.err))
+			m.dropDashCamera(msg.address)
+			return m, nil
+		}
+		device := m.deviceAt(msg.address)
+		timing := m.cfg.TimingFor(device.Name, device.AddressStr)
+		cam.ctrl.SetCommandDelay(timing.CommandDelay)
+		cam.ctrl.SetTimingProfile(timing.Profile())
+		m.addLog("Connected to " + m.deviceLabel(msg.address))
+		if m.known != nil {
+			if err := m.known.Remember(device, m.now()); err != nil {
+				m.addLog(fmt.Sprintf("Could not save known camera: %v", err))
+			}
+		}
+
+	case dashDisconnectMsg:
+		if msg.err != nil {
+			m.addLog(fmt.Sprintf("Disconnecting %s failed: %v", m.deviceLabel(msg.address), msg.err))
+		} else {
+			m.addLog("Disconnected " + m.deviceLabel(msg.address))
+		}
+		m.dropDashCamera(msg.address)
+
+	case dashEventMsg:
+		cam, ok := m.dash[msg.address]
+		if !ok {
+			return m, m.waitDashEvent()
+		}
+		switch msg.event.Type {
+		case sony_remote_ble.EventStateChanged:
+			if !cam.busy {
+				cam.state = msg.event.State
+				m.dropLostDashCameras()
+			}
+		case sony_remote_ble.EventNotification:
+			if msg.event.Notification.Kind == sony_remote_ble.NotifyRecording {
+				cam.recording = msg.event.Notification.Active
+			}
+		}
+		return m, m.waitDashEvent()
+
+	case dashFireMsg:
+		for _, member := range msg.result.Members {
+			cam, ok := m.dash[member.Name]
+			if !ok {
+				continue
+			}
+			if member.Err != nil {
+				cam.last = fmt.Sprintf("%s failed: %v", msg.action, member.Err)
+			} else {
+				cam.last = fmt.Sprintf("%s +%s", msg.action, member.Skew.Round(10*time.Microsecond))
+			}
+		}
+		if msg.err != nil {
+			m.addLog(fmt.Sprintf("%s failed: %v", msg.action, msg.err))
+		} else {
+			m.addLog(fmt.Sprintf("%s on %d camera(s), skew %s", msg.action, len(msg.result.Members), msg.result.Skew().Round(10*time.Microsecond)))
+		}
+	}
+	return m, nil
+}
+
+// cursorDevice returns the device under the cursor.
+func (m *Model) cursorDevice() (sony_remote_ble.DeviceInfo, bool) {
+	if m.selected < 0 || m.selected >= len(m.devices) {
+		return sony_remote_ble.DeviceInfo{}, false
+	}
+	return m.devices[m.selected], true
+}
+
+// deviceAt returns the listed device with the given address.
+func (m *Model) deviceAt(address string) sony_remote_ble.DeviceInfo {
+	for _, device := range m.devices {
+		if device.AddressStr == address {
+			return device
+		}
+	}
+	return sony_remote_ble.DeviceInfo{AddressStr: address, Address: sony_remote_ble.ParseAddress(address)}
+}
+
+// deviceLabel names a camera in log lines.
+func (m *Model) deviceLabel(address string) string {
+	if device := m.deviceAt(address); device.Name != "" {
+		return device.Name
+	}
+	return address
+}
+
+// dashConnect connects the camera with a controller of its own. The model's
+// controller is only used when no other controller can be created, because
+// it also runs the scans.
+func (m *Model) dashConnect(device sony_remote_ble.DeviceInfo) tea.Cmd {
+	var ctrl Controller
+	primary := false
+	if m.newController != nil {
+		var err error
+		if ctrl, err = m.newController(); err != nil {
+			m.addLog(fmt.Sprintf("Connection to %s failed: %v", device.Name, err))
+			return nil
+		}
+	} else {
+		for _, cam := range m.dash {
+			if cam.primary {
+				m.addLog(fmt.Sprintf("Connection to %s failed: %v", device.Name, errNoController))
+				return nil
+			}
+		}
+		ctrl, primary = m.ctrl, true
+	}
+
+	if m.scanning {
+		m.ctrl.StopScan()
+		m.scanning = false
+	}
+	cam := &dashCamera{ctrl: ctrl, primary: primary, busy: true, state: sony_remote_ble.Connecting}
+	if source, ok := ctrl.(eventSource); ok {
+		events := m.dashEvents
+		address := device.AddressStr
+		cam.remove = source.OnEvent(func(ev sony_remote_ble.Event) {
+			// Listeners must not block; the dashboard catches up on the next tick
+			select {
+			case events <- dashEventMsg{address: address, event: ev}:
+			default:
+			}
+		})
+	}
+	m.dash[device.AddressStr] = cam
+	m.addLog(fmt.Sprintf("Connecting to %s...", device.Name))
+
+	return func() tea.Msg {
+		return dashConnectMsg{address: device.AddressStr, err: ctrl.Connect(device.Address)}
+	}
+}
+
+func (m *Model) dashDisconnect(address string, cam *dashCamera) tea.Cmd {
+	cam.busy = true
+	return func() tea.Msg {
+		return dashDisconnectMsg{address: address, err: cam.ctrl.Disconnect()}
+	}
+}
+
+// dropDashCamera forgets a camera that is no longer connected.
+func (m *Model) dropDashCamera(address string) {
+	cam, ok := m.dash[address]
+	if !ok {
+		return
+	}
+	if cam.remove != nil {
+		cam.remove()
+	}
+	delete(m.dash, address)
+}
+
+// disconnectDashboard disconnects every camera connected from the dashboard,
+// except a camera the model's controller shares with its owner.
+func (m *Model) disconnectDashboard() {
+	for address, cam := range m.dash {
+		if !cam.primary || owned(cam.ctrl) {
+			cam.ctrl.Disconnect()
+		}
+		m.dropDashCamera(address)
+	}
+}
+
+// dropLostDashCameras forgets the cameras whose connection ended without
+// a disconnect from the dashboard, so that connecting them again reconnects.
+func (m *Model) dropLostDashCameras() {
+	for address, cam := range m.dash {
+		if cam.busy || cam.state == sony_remote_ble.Connected {
+			continue
+		}
+		m.addLog(fmt.Sprintf("Lost connection to %s", m.deviceLabel(address)))
+		m.dropDashCamera(address)
+	}
+}
+
+// dashTargets returns the connected cameras an action applies to: the
+// selected ones, or the one under the cursor when nothing is selected.
+func (m *Model) dashTargets() []string {
+	var targets []string
+	for _, device := range m.devices {
+		if m.dashSelected[device.AddressStr] {
+			targets = append(targets, device.AddressStr)
+		}
+	}
+	if len(targets) == 0 {
+		if device, ok := m.cursorDevice(); ok {
+			targets = append(targets, device.AddressStr)
+		}
+	}
+
+	connected := targets[:0]
+	for _, address := range targets {
+		if cam, ok := m.dash[address]; ok && !cam.busy && cam.state == sony_remote_ble.Connected {
+			connected = append(connected, address)
+		}
+	}
+	return connected
+}
+
+// dashFire takes a photo or toggles recording on the target cameras together.
+func (m *Model) dashFire(action string) tea.Cmd {
+	targets := m.dashTargets()
+	if len(targets) == 0 {
+		m.addLog(action + ": no connected camera selected")
+		return nil
+	}
+
+	group := sony_remote_ble.NewCameraGroup()
+	group.SetCommandDelay(m.timing.CommandDelay)
+	for _, address := range targets {
+		group.Add(address, m.dash[address].ctrl, sony_remote_ble.ParseAddress(address))
+		// Calibrated cameras are fired so that the exposures line up
+		if m.known != nil && action != "Record" {
+			if camera, ok := m.known.Lookup(address); ok && camera.Calibration != nil {
+				group.SetLatency(address, camera.Calibration.Offset)
+			}
+		}
+	}
+	m.addLog(fmt.Sprintf("%s on %d camera(s)...", action, len(targets)))
+	return func() tea.Msg {
+		var result sony_remote_ble.GroupResult
+		var err error
+		if action == "Record" {
+			result, err = group.ToggleRecord()
+		} else {
+			result, err = group.TakePhoto()
+		}
+		return dashFireMsg{action: action, result: result, err: err}
+	}
+}
+
+// waitDashEvent delivers the next event of a dashboard camera.
+func (m *Model) waitDashEvent() tea.Cmd {
+	events := m.dashEvents
+	done := m.ctx.Done()
+	return func() tea.Msg {
+		select {
+		case msg := <-events:
+			return msg
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// refreshDashboard copies the connection state of every dashboard camera.
+func (m *Model) refreshDashboard() {
+	for _, cam := range m.dash {
+		if !cam.busy {
+			cam.state = cam.ctrl.State()
+		}
+	}
+	m.dropLostDashCameras()
+}
+
+func (m *Model) dashboardView() string {
+	var sections []string
+
+	title := titleStyle.Render(fmt.Sprintf("Sony Camera Remote %s", m.version))
+	sections = append(sections, title+" | Dashboard")
+
+	connected, selected := 0, 0
+	for _, cam := range m.dash {
+		if cam.state == sony_remote_ble.Connected {
+			connected++
+		}
+	}
+	for _, device := range m.devices {
+		if m.dashSelected[device.AddressStr] {
+			selected++
+		}
+	}
+	status := fmt.Sprintf("Status: %d connected, %d selected", connected, selected)
+	if m.scanning {
+		spinners := []string{"|", "/", "-", "\\"}
+		status += " | " + spinners[m.spinnerIndex%len(spinners)] + " scanning"
+	}
+	sections = append(sections, status)
+
+	if len(m.devices) == 0 {
+		sections = append(sections, fmt.Sprintf("\nNo cameras yet. Press %s to scan.", m.keys.help(config.ActionScan)))
+	} else {
+		sections = append(sections, "\nCameras:")
+		for i, device := range m.devices {
+			sections = append(sections, m.renderDashRow(i, device))
+		}
+	}
+
+	help := []string{
+		"Controls:",
+		fmt.Sprintf("%s and %s - Move | %s - Select | %s - Select all | %s - Connect/disconnect",
+			m.keys.help(config.ActionUp), m.keys.help(config.ActionDown),
+			m.keys.help(config.ActionSelect), m.keys.help(config.ActionSelectAll),
+			m.keys.help(config.ActionConnect)),
+		fmt.Sprintf("%s - Fire selected | %s - Record selected | %s - Scan | %s - Back | %s - Quit",
+			m.keys.help(config.ActionShutter), m.keys.help(config.ActionRecord),
+			m.keys.help(config.ActionScan), m.keys.help(config.ActionBack),
+			m.keys.help(config.ActionQuit)),
+	}
+	sections = append(sections, "\n"+helpStyle.Render(strings.Join(help, "\n")))
+
+	if len(m.logs) > 0 {
+		logWidth := max(m.width-12, 40)
+		sections = append(sections, "\n"+logStyle.Width(logWidth).Render(strings.Join(m.getLastLogs(3), "\n")))
+	}
+
+	containerWidth := max(m.width-2, 60)
+	return containerStyle.Width(containerWidth).Render(strings.Join(sections, "\n"))
+}
+
+// renderDashRow renders one camera of the dashboard grid.
+func (m *Model) renderDashRow(i int, device sony_remote_ble.DeviceInfo) string {
+	cursor := "  "
+	style := deviceStyle
+	if i == m.selected {
+		cursor = "▶ "
+		style = selectedDeviceStyle
+	}
+	check := "[ ]"
+	if m.dashSelected[device.AddressStr] {
+		check = "[x]"
+	}
+
+	state := sony_remote_ble.Disconnected.String()
+	rec, last := "   ", ""
+	cam, ok := m.dash[device.AddressStr]
+	if ok {
+		state = cam.state.String()
+		if cam.recording {
+			rec = "REC"
+		}
+		last = cam.last
+	}
+	rssi := "--"
+	if m.seen[device.AddressStr] {
+		rssi = fmt.Sprintf("%d", device.RSSI)
+	}
+
+	row := fmt.Sprintf("%s%s %-20.20s %-17s %-12s RSSI %4s %s  %s",
+		cursor, check, device.Name, device.AddressStr, state, rssi, rec, last)
+	if ok && cam.state == sony_remote_ble.Connected {
+		return connectedStyle.Render(row)
+	}
+	return style.Render(row)
+}
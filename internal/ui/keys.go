@@ -0,0 +1,67 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/smazurov/sony_remote_ble/internal/config"
+)
+
+// keyMap resolves pressed keys to actions for each screen.
+type keyMap struct {
+	bindings   map[string][]string
+	deviceList map[string]string
+	control    map[string]string
+	dashboard  map[string]string
+}
+
+func newKeyMap(bindings map[string][]string) keyMap {
+	return keyMap{
+		bindings:   bindings,
+		deviceList: screenKeys(bindings, config.DeviceListActions),
+		control:    screenKeys(bindings, config.ControlActions),
+		dashboard:  screenKeys(bindings, config.DashboardActions),
+	}
+}
+
+// screenKeys builds the key to action lookup for the actions of one screen.
+func screenKeys(bindings map[string][]string, actions []string) map[string]string {
+	keys := make(map[string]string)
+	for _, action := range actions {
+		for _, key := range bindings[action] {
+			keys[config.KeyName(key)] = action
+		}
+	}
+	return keys
+}
+
+// help returns the keys bound to an action formatted for the help text.
+func (k keyMap) help(action string) string {
+	keys := k.bindings[action]
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = displayKey(key)
+	}
+	return strings.Join(names, "/")
+}
+
+// displayKey converts a key name into a friendlier label.
+func displayKey(key string) string {
+	switch key {
+	case " ", "space":
+		return "Space"
+	case "up":
+		return "↑"
+	case "down":
+		return "↓"
+	case "left":
+		return "←"
+	case "right":
+		return "→"
+	case "esc", "enter", "tab", "backspace":
+		return strings.ToUpper(key[:1]) + key[1:]
+	}
+	if strings.HasPrefix(key, "ctrl+") {
+		return "Ctrl+" + strings.ToUpper(strings.TrimPrefix(key, "ctrl+"))
+	}
+	return key
+}
@@ -3,9 +3,15 @@ package ui
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/known"
+	"github.com/smazurov/sony_remote_ble/internal/session"
 	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
 )
 
@@ -14,12 +20,33 @@ type AppMode int
 const (
 	ModeDeviceList AppMode = iota
 	ModeControl
+	ModeDashboard
 )
 
+// Options configures the TUI.
+type Options struct {
+	// Config supplies keybindings, timings and auto-connect settings
+	Config *config.Config
+	// Logger receives a copy of every log line
+	Logger *slog.Logger
+	// Known records cameras after successful connections; nil disables persistence
+	Known *known.Store
+	// Controller is the camera the TUI drives
+	Controller Controller
+	// NewController creates a controller for each camera connected from the
+	// dashboard; nil limits the dashboard to one camera on Controller
+	NewController func() (Controller, error)
+	// Now stamps log lines; nil uses time.Now. Tests fix it so that views
+	// render the same every time.
+	Now func() time.Time
+}
+
 type Model struct {
-	client     *sony_remote_ble.Client
+	ctrl       Controller
 	mode       AppMode
 	devices    []sony_remote_ble.DeviceInfo
+	seen       map[string]bool // addresses seen advertising since the last scan started
+	known      *known.Store
 	selected   int
 	scanning   bool
 	logs       []string
@@ -29,19 +56,51 @@ type Model struct {
 	width      int
 	height     int
 	version    string
+	cfg        *config.Config
+	logger     *slog.Logger
+	keys       keyMap
+	now        func() time.Time
+
+	// state and deviceName mirror the controller as of the last update, so
+	// rendering never calls into the controller
+	state      sony_remote_ble.ConnectionState
+	deviceName string
+
+	// timing holds the command timings for the connected camera
+	timing config.Timing
+
+	// autoConnect is the camera to connect to as soon as it advertises, empty when disabled
+	autoConnect string
 
 	// Animation state
 	spinnerIndex int
 
 	// Button states for visual feedback
 	buttonStates map[string]bool
+
+	// newController, dash and dashSelected back the dashboard: the cameras
+	// connected from it and the selected ones, both by address
+	newController func() (Controller, error)
+	dash          map[string]*dashCamera
+	dashSelected  map[string]bool
+	// dashEvents carries events from the dashboard cameras into Update
+	dashEvents chan tea.Msg
+
+	// keepAwake signals an idle camera, nil when disabled or when the
+	// controller is not a local camera. sendMu keeps its signals out of the
+	// middle of the commands sent from the keyboard.
+	keepAwake *sony_remote_ble.KeepAwake
+	sendMu    sync.Mutex
 }
 
 type tickMsg time.Time
 type scanStartMsg struct{}
-type scanCompleteMsg struct{}
+type scanCompleteMsg struct {
+	err error
+}
 type deviceFoundMsg sony_remote_ble.DeviceInfo
 type connectionMsg struct {
+	device    sony_remote_ble.DeviceInfo
 	connected bool
 	err       error
 }
@@ -50,18 +109,24 @@ type commandSentMsg struct {
 	err     error
 }
 
-func NewModel(version string) (*Model, error) {
-	client, err := sony_remote_ble.NewClient()
-	if err != nil {
-		return nil, err
-	}
-
+// NewModel creates the TUI for the camera behind opts.Controller.
+func NewModel(version string, opts Options) *Model {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	now := opts.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	cfg := opts.Config
 	m := &Model{
-		client:       client,
+		ctrl:         opts.Controller,
+		state:        opts.Controller.State(),
+		deviceName:   opts.Controller.DeviceName(),
+		now:          now,
 		mode:         ModeDeviceList,
-		devices:      make([]sony_remote_ble.DeviceInfo, 0),
+		seen:         make(map[string]bool),
+		known:        opts.Known,
 		logs:         make([]string, 0),
 		ctx:          ctx,
 		cancel:       cancel,
@@ -69,18 +134,64 @@ func NewModel(version string) (*Model, error) {
 		width:        80, // Default width
 		height:       24, // Default height
 		version:      version,
+		cfg:          cfg,
+		logger:       opts.Logger,
+		keys:         newKeyMap(cfg.Keys),
+		timing:       cfg.Timing,
 		buttonStates: make(map[string]bool),
+
+		newController: opts.NewController,
+		dash:          make(map[string]*dashCamera),
+		dashSelected:  make(map[string]bool),
+		dashEvents:    make(chan tea.Msg, 64),
 	}
 
-	m.addLog("Sony Camera Remote started. Press Tab to scan for devices.")
-	return m, nil
+	m.devices = m.knownDevices()
+	// A daemon may already hold the camera
+	if m.state == sony_remote_ble.Connected {
+		m.mode = ModeControl
+	}
+
+	m.addLog(fmt.Sprintf("Sony Camera Remote started. Press %s to scan for devices.", m.keys.help(config.ActionScan)))
+	if cfg.AutoConnect.Enabled {
+		m.autoConnect = m.autoConnectTarget()
+		if m.autoConnect != "" {
+			m.addLog(fmt.Sprintf("Waiting for %s to auto-connect...", m.autoConnect))
+		} else {
+			m.addLog("Auto-connect enabled but no known camera yet")
+		}
+	}
+	// A daemon keeps its own camera awake
+	if cam, ok := opts.Controller.(sony_remote_ble.Camera); ok && cfg.KeepAwake.Enabled {
+		keepAwakeOpts := cfg.KeepAwake.Options()
+		keepAwakeOpts.Exclusive = func(send func() error) error {
+			if !m.sendMu.TryLock() {
+				return session.ErrBusy
+			}
+			defer m.sendMu.Unlock()
+			return send()
+		}
+		m.keepAwake = sony_remote_ble.NewKeepAwake(cam, keepAwakeOpts)
+	}
+	return m
 }
 
 func (m *Model) Init() tea.Cmd {
-	return tea.Batch(
+	cmds := []tea.Cmd{
 		tickCmd(),
 		m.checkForDevicesCmd(),
-	)
+		m.waitDashEvent(),
+	}
+	if m.autoConnect != "" {
+		cmds = append(cmds, func() tea.Msg { return scanStartMsg{} })
+	}
+	if m.keepAwake != nil {
+		cmds = append(cmds, func() tea.Msg {
+			m.keepAwake.Run(m.ctx)
+			return nil
+		})
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -94,6 +205,8 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleKeyPress(msg)
 
 	case tickMsg:
+		m.refreshState()
+		m.refreshDashboard()
 		if m.scanning {
 			m.spinnerIndex = (m.spinnerIndex + 1) % 4
 			return m, tea.Batch(tickCmd(), m.checkForDevicesCmd())
@@ -102,13 +215,22 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case scanStartMsg:
 		m.scanning = true
-		m.devices = make([]sony_remote_ble.DeviceInfo, 0)
+		m.devices = m.listedDevices()
+		m.seen = make(map[string]bool)
 		m.selected = 0
 		m.addLog("Starting scan for Sony cameras...")
 		return m, m.performScan()
 
 	case deviceFoundMsg:
-		m.addDevice(sony_remote_ble.DeviceInfo(msg))
+		device := sony_remote_ble.DeviceInfo(msg)
+		m.addDevice(device)
+		if m.autoConnect != "" && session.MatchesDevice(m.autoConnect, device) {
+			m.autoConnect = ""
+			m.addLog(fmt.Sprintf("Auto-connecting to %s...", device.Name))
+			m.ctrl.StopScan()
+			m.scanning = false
+			return m, m.connect(device)
+		}
 		if m.scanning {
 			return m, m.checkForDevicesCmd()
 		}
@@ -117,9 +239,11 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case scanCompleteMsg:
 		wasScanning := m.scanning
 		m.scanning = false
-		if wasScanning {
-			if len(m.devices) > 0 {
-				m.addLog(fmt.Sprintf("Scan stopped - found %d device(s)", len(m.devices)))
+		if msg.err != nil {
+			m.addLog(fmt.Sprintf("Scan failed: %v", msg.err))
+		} else if wasScanning {
+			if len(m.seen) > 0 {
+				m.addLog(fmt.Sprintf("Scan stopped - found %d device(s)", len(m.seen)))
 			} else {
 				m.addLog("Scan stopped - no devices found")
 			}
@@ -127,11 +251,20 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case connectionMsg:
+		m.refreshState()
 		if msg.err != nil {
 			m.addLog(fmt.Sprintf("Connection failed: %v", msg.err))
 		} else if msg.connected {
-			m.addLog("Connected to " + m.client.DeviceName())
+			m.timing = m.cfg.TimingFor(msg.device.Name, msg.device.AddressStr)
+			m.ctrl.SetCommandDelay(m.timing.CommandDelay)
+			m.ctrl.SetTimingProfile(m.timing.Profile())
+			m.addLog("Connected to " + m.deviceName)
 			m.mode = ModeControl
+			if m.known != nil {
+				if err := m.known.Remember(msg.device, m.now()); err != nil {
+					m.addLog(fmt.Sprintf("Could not save known camera: %v", err))
+				}
+			}
 		} else {
 			m.addLog("Disconnected")
 			m.mode = ModeDeviceList
@@ -146,6 +279,9 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.addLog(fmt.Sprintf("Sent: %s", msg.command))
 		}
 		return m, nil
+
+	case dashConnectMsg, dashDisconnectMsg, dashEventMsg, dashFireMsg:
+		return m.updateDashboard(msg)
 	}
 
 	return m, nil
@@ -157,6 +293,8 @@ func (m *Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleDeviceListKeys(msg)
 	case ModeControl:
 		return m.handleControlKeys(msg)
+	case ModeDashboard:
+		return m.handleDashboardKeys(msg)
 	}
 	return m, nil
 }
@@ -165,51 +303,46 @@ func (m *Model) handleDeviceListKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	key := msg.String()
 	m.addLog(fmt.Sprintf("Key pressed: '%s' Type: %d (scanning: %t)", key, msg.Type, m.scanning))
 
-	// Check for ESC specifically
-	if msg.Type == tea.KeyEsc {
-		if m.scanning {
-			m.addLog("ESC key detected - stopping scan...")
-			m.client.StopScan()
-			return m, func() tea.Msg { return scanCompleteMsg{} }
-		}
-	}
-
-	switch key {
-	case "q", "ctrl+c":
+	switch m.keys.deviceList[key] {
+	case config.ActionQuit:
 		m.cancel()
 		return m, tea.Quit
 
-	case "tab":
+	case config.ActionScan:
 		if !m.scanning {
 			return m, func() tea.Msg { return scanStartMsg{} }
 		}
 
-	case "up", "k":
+	case config.ActionUp:
 		if m.selected > 0 {
 			m.selected--
 		}
 
-	case "down", "j":
+	case config.ActionDown:
 		if m.selected < len(m.devices)-1 {
 			m.selected++
 		}
 
-	case "enter":
+	case config.ActionConnect:
 		if len(m.devices) > 0 && m.selected < len(m.devices) {
 			device := m.devices[m.selected]
 			m.addLog(fmt.Sprintf("Connecting to %s...", device.Name))
 			if m.scanning {
-				m.client.StopScan()
+				m.ctrl.StopScan()
 			}
 			return m, m.connect(device)
 		}
 
-	case "esc", "escape":
+	case config.ActionStopScan:
 		if m.scanning {
-			m.addLog("ESC pressed - stopping scan...")
-			m.client.StopScan()
+			m.addLog("Stopping scan...")
+			m.ctrl.StopScan()
 			return m, func() tea.Msg { return scanCompleteMsg{} }
 		}
+
+	case config.ActionDashboard:
+		m.mode = ModeDashboard
+		m.addLog("Dashboard: connect several cameras and fire them together")
 	}
 	return m, nil
 }
@@ -217,55 +350,64 @@ func (m *Model) handleDeviceListKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m *Model) handleControlKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
-	switch msg.String() {
-	case "q", "ctrl+c":
+	switch m.keys.control[msg.String()] {
+	case config.ActionQuit:
 		m.cancel()
-		m.client.Disconnect()
+		if owned(m.ctrl) {
+			m.ctrl.Disconnect()
+		}
 		return m, tea.Quit
 
-	case "esc", "backspace":
-		m.client.Disconnect()
+	case config.ActionBack:
+		if owned(m.ctrl) {
+			m.ctrl.Disconnect()
+			m.addLog("Disconnected")
+		} else {
+			m.addLog("Left the camera connected to its owner")
+		}
+		m.refreshState()
 		m.mode = ModeDeviceList
-		m.devices = make([]sony_remote_ble.DeviceInfo, 0)
-		m.addLog("Disconnected")
+		m.devices = m.knownDevices()
+		m.seen = make(map[string]bool)
+		m.selected = 0
 		return m, nil
 
 	// Focus controls
-	case "f", "F":
+	case config.ActionFocus:
 		m.buttonStates["focus"] = true
-		cmds = append(cmds, m.sendCommand("focus_down"))
+		cmds = append(cmds, m.focus())
 
 	// Shutter controls
-	case "s", "S":
+	case config.ActionShutter:
 		m.buttonStates["shutter"] = true
 		cmds = append(cmds, m.sendCommand("shutter_full_down"))
 
 	// Zoom controls
-	case "z":
+	case config.ActionZoomOut:
 		m.buttonStates["zoom_out"] = true
-		cmds = append(cmds, m.sendCommand("zoom_out_down"))
+		cmds = append(cmds, m.zoom(false))
 
-	case "Z":
+	case config.ActionZoomIn:
 		m.buttonStates["zoom_in"] = true
-		cmds = append(cmds, m.sendCommand("zoom_in_down"))
+		cmds = append(cmds, m.zoom(true))
 
 	// AutoFocus
-	case "a", "A":
+	case config.ActionAutofocus:
 		m.buttonStates["autofocus"] = true
 		cmds = append(cmds, m.sendCommand("autofocus_down"))
 
 	// Record
-	case "r", "R":
+	case config.ActionRecord:
 		m.buttonStates["record"] = true
 		cmds = append(cmds, m.sendCommand("record_toggle"))
 
 	// Custom button
-	case "c", "C":
+	case config.ActionCustom:
 		m.buttonStates["custom"] = true
 		cmds = append(cmds, m.sendCommand("c1_down"))
 
 	// Quick photo
-	case " ":
+	case config.ActionPhoto:
 		m.buttonStates["shutter"] = true
 		m.addLog("Taking photo...")
 		cmds = append(cmds, m.takePhoto())
@@ -280,12 +422,15 @@ func (m *Model) View() string {
 		return m.deviceListView()
 	case ModeControl:
 		return m.controlView()
+	case ModeDashboard:
+		return m.dashboardView()
 	}
 	return ""
 }
 
 func (m *Model) addLog(message string) {
-	timestamp := time.Now().Format("15:04:05")
+	m.logger.Info(message)
+	timestamp := m.now().Format("15:04:05")
 	m.logs = append(m.logs, fmt.Sprintf("[%s] %s", timestamp, message))
 	if len(m.logs) > 10 {
 		m.logs = m.logs[1:]
@@ -293,14 +438,81 @@ func (m *Model) addLog(message string) {
 }
 
 func (m *Model) addDevice(device sony_remote_ble.DeviceInfo) {
-	// Check if device already exists
-	for _, d := range m.devices {
-		if d.AddressStr == device.AddressStr {
+	firstSeen := !m.seen[device.AddressStr]
+	m.seen[device.AddressStr] = true
+	if firstSeen {
+		m.addLog(fmt.Sprintf("Found: %s (%s)", device.Name, device.AddressStr))
+	}
+
+	// Known cameras are already listed; refresh them with the advertised details
+	for i, d := range m.devices {
+		if strings.EqualFold(d.AddressStr, device.AddressStr) {
+			m.devices[i] = device
 			return
 		}
 	}
 	m.devices = append(m.devices, device)
-	m.addLog(fmt.Sprintf("Found: %s (%s)", device.Name, device.AddressStr))
+}
+
+// refreshState copies the connection state from the controller.
+func (m *Model) refreshState() {
+	m.state = m.ctrl.State()
+	m.deviceName = m.ctrl.DeviceName()
+}
+
+// knownDevices returns the remembered cameras as list entries, most recent first.
+func (m *Model) knownDevices() []sony_remote_ble.DeviceInfo {
+	devices := make([]sony_remote_ble.DeviceInfo, 0)
+	if m.known == nil {
+		return devices
+	}
+	for _, camera := range m.known.Cameras() {
+		devices = append(devices, camera.DeviceInfo())
+	}
+	return devices
+}
+
+// listedDevices returns the devices listed before a scan: the known cameras
+// and the dashboard cameras, which stay listed while connected.
+func (m *Model) listedDevices() []sony_remote_ble.DeviceInfo {
+	devices := m.knownDevices()
+	for _, device := range m.devices {
+		if _, ok := m.dash[device.AddressStr]; !ok {
+			continue
+		}
+		listed := false
+		for _, d := range devices {
+			listed = listed || strings.EqualFold(d.AddressStr, device.AddressStr)
+		}
+		if !listed {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// isKnown reports whether a device has been connected to before.
+func (m *Model) isKnown(device sony_remote_ble.DeviceInfo) bool {
+	if m.known == nil {
+		return false
+	}
+	_, ok := m.known.Lookup(device.AddressStr)
+	return ok
+}
+
+// autoConnectTarget returns the camera that auto-connect waits for: the configured
+// camera (resolving aliases) or else the most recently connected known camera.
+func (m *Model) autoConnectTarget() string {
+	if m.cfg.AutoConnect.Camera != "" {
+		return m.cfg.ResolveAlias(m.cfg.AutoConnect.Camera)
+	}
+	if m.known == nil {
+		return ""
+	}
+	if camera, ok := m.known.MostRecent(); ok {
+		return camera.Address
+	}
+	return ""
 }
 
 // Command functions
@@ -323,9 +535,9 @@ func (m *Model) checkForDevicesCmd() tea.Cmd {
 
 func (m *Model) performScan() tea.Cmd {
 	return func() tea.Msg {
-		err := m.client.ScanForDevices(m.ctx, m.deviceChan)
+		err := m.ctrl.ScanForDevices(m.ctx, m.deviceChan)
 		if err != nil {
-			return scanCompleteMsg{} // End scan on error
+			return scanCompleteMsg{err: err} // End scan on error
 		}
 		return nil // Continue scanning
 	}
@@ -333,8 +545,9 @@ func (m *Model) performScan() tea.Cmd {
 
 func (m *Model) connect(device sony_remote_ble.DeviceInfo) tea.Cmd {
 	return func() tea.Msg {
-		err := m.client.Connect(device.Address)
+		err := m.ctrl.Connect(device.Address)
 		return connectionMsg{
+			device:    device,
 			connected: err == nil,
 			err:       err,
 		}
@@ -351,7 +564,9 @@ func (m *Model) sendCommand(command string) tea.Cmd {
 			}
 		}
 
-		err := m.client.SendCommand(cmd)
+		m.sendMu.Lock()
+		err := m.ctrl.SendCommand(cmd)
+		m.sendMu.Unlock()
 		return commandSentMsg{
 			command: cmd.Name,
 			err:     err,
@@ -359,9 +574,41 @@ func (m *Model) sendCommand(command string) tea.Cmd {
 	}
 }
 
+func (m *Model) zoom(in bool) tea.Cmd {
+	speed, hold := byte(m.timing.ZoomSpeed), m.timing.ZoomHold
+	name := "Zoom Out"
+	if in {
+		name = "Zoom In"
+	}
+	return func() tea.Msg {
+		m.sendMu.Lock()
+		err := m.ctrl.Zoom(in, speed, hold)
+		m.sendMu.Unlock()
+		return commandSentMsg{
+			command: name,
+			err:     err,
+		}
+	}
+}
+
+func (m *Model) focus() tea.Cmd {
+	hold := m.timing.FocusHold
+	return func() tea.Msg {
+		m.sendMu.Lock()
+		err := m.ctrl.Press("focus", hold)
+		m.sendMu.Unlock()
+		return commandSentMsg{
+			command: "Focus",
+			err:     err,
+		}
+	}
+}
+
 func (m *Model) takePhoto() tea.Cmd {
 	return func() tea.Msg {
-		err := m.client.TakePhoto()
+		m.sendMu.Lock()
+		err := m.ctrl.TakePhoto()
+		m.sendMu.Unlock()
 		return commandSentMsg{
 			command: "Take Photo",
 			err:     err,
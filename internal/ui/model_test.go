@@ -0,0 +1,291 @@
+package ui
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/known"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/sonyremotetest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// now is the fixed clock of the tests, which stamps the log lines.
+var now = time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+
+// Cameras advertised by the fakes; the studio camera is known from an
+// earlier session.
+var (
+	studio = sony_remote_ble.DeviceInfo{Name: "ILCE-7M4", Address: sonyremotetest.Address, AddressStr: sonyremotetest.Address.String(), RSSI: -48}
+	field  = sony_remote_ble.DeviceInfo{
+		Name:       "ZV-E10",
+		Address:    sony_remote_ble.ParseAddress("C0:FF:EE:00:00:03"),
+		AddressStr: "C0:FF:EE:00:00:03",
+		RSSI:       -71,
+	}
+)
+
+// sharedCamera is a camera owned by someone else, like the camera of a daemon.
+type sharedCamera struct {
+	*sonyremotetest.Camera
+}
+
+func (sharedCamera) Shared() bool { return true }
+
+// newCamera creates a fake that advertises both cameras and never sleeps.
+func newCamera() *sonyremotetest.Camera {
+	cam := sonyremotetest.New()
+	cam.SkipSleeps()
+	cam.SetDevices(studio, field)
+	return cam
+}
+
+// newTestModel creates a model on ctrl with the default keys, the studio
+// camera known and a fixed clock and window size.
+func newTestModel(t *testing.T, ctrl Controller, newController func() (Controller, error)) *Model {
+	t.Helper()
+	store, err := known.Open(filepath.Join(t.TempDir(), "known.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Remember(studio, now.Add(-24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	m := NewModel("v1.0.0", Options{
+		Config:        config.Default(),
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Known:         store,
+		Controller:    ctrl,
+		NewController: newController,
+		Now:           func() time.Time { return now },
+	})
+	t.Cleanup(m.cancel)
+	m.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+	return m
+}
+
+// keyMsg returns the message Bubble Tea sends for a key name.
+func keyMsg(key string) tea.KeyMsg {
+	special := map[string]tea.KeyType{
+		"enter": tea.KeyEnter,
+		"esc":   tea.KeyEscape,
+		"tab":   tea.KeyTab,
+		"up":    tea.KeyUp,
+		"down":  tea.KeyDown,
+		" ":     tea.KeySpace,
+	}
+	if typ, ok := special[key]; ok {
+		return tea.KeyMsg{Type: typ}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
+
+// press sends each key to the model and runs the commands it returns. It
+// reports whether the model quit.
+func press(t *testing.T, m *Model, keys ...string) (quit bool) {
+	t.Helper()
+	for _, key := range keys {
+		if got := keyMsg(key).String(); got != key {
+			t.Fatalf("key %q is reported as %q", key, got)
+		}
+		_, cmd := m.Update(keyMsg(key))
+		quit = run(m, cmd) || quit
+	}
+	return quit
+}
+
+// tick sends a tick to the model, as Bubble Tea does every second, and runs
+// the device checks it starts.
+func tick(m *Model) {
+	_, cmd := m.Update(tickMsg(now))
+	run(m, cmd)
+}
+
+// run executes cmd and feeds its messages back into the model until no
+// command is left, and reports whether one of them quit. Commands that do not
+// finish promptly are dropped: the ticks, whose timing the tests control.
+func run(m *Model, cmd tea.Cmd) (quit bool) {
+	if cmd == nil {
+		return false
+	}
+	done := make(chan tea.Msg, 1)
+	go func() { done <- cmd() }()
+	var msg tea.Msg
+	select {
+	case msg = <-done:
+	case <-time.After(500 * time.Millisecond):
+		return false
+	}
+
+	switch msg := msg.(type) {
+	case nil:
+		return false
+	case tea.QuitMsg:
+		return true
+	case tea.BatchMsg:
+		for _, cmd := range msg {
+			quit = run(m, cmd) || quit
+		}
+		return quit
+	case tickMsg:
+		return false
+	}
+	_, next := m.Update(msg)
+	return run(m, next)
+}
+
+// scan starts a scan and lets the model pick up what the fake advertises.
+func scan(t *testing.T, m *Model) {
+	t.Helper()
+	press(t, m, "tab")
+	tick(m)
+	if len(m.seen) != 2 {
+		t.Fatalf("scan found %d cameras, want 2", len(m.seen))
+	}
+}
+
+// ansi matches the escape sequences of styled text.
+var ansi = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// assertGolden compares a view with testdata/name.golden, or rewrites the
+// file with -update.
+func assertGolden(t *testing.T, name, view string) {
+	t.Helper()
+	view = ansi.ReplaceAllString(view, "")
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(view), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view != string(want) {
+		t.Errorf("%s view differs from %s (rerun with -update after checking):\n%s", name, path, view)
+	}
+}
+
+func TestDeviceListView(t *testing.T) {
+	m := newTestModel(t, newCamera(), nil)
+	scan(t, m)
+	press(t, m, "esc", "down")
+	assertGolden(t, "device_list", m.View())
+}
+
+func TestControlKeys(t *testing.T) {
+	cam := newCamera()
+	m := newTestModel(t, cam, nil)
+	press(t, m, "enter")
+	if m.mode != ModeControl || cam.State() != sony_remote_ble.Connected {
+		t.Fatalf("mode %d, camera %s after connecting", m.mode, cam.State())
+	}
+
+	press(t, m, "f", "s", "z", "Z", "a", "r", "c", " ")
+	want := []string{
+		"focus_down", "focus_up",
+		"shutter_full_down",
+		"zoom_out_down", "zoom_out_up", "zoom_in_down", "zoom_in_up",
+		"autofocus_down",
+		"record_toggle",
+		"c1_down",
+		"focus_down", "shutter_full_down", "shutter_full_up", "focus_up",
+	}
+	if written := cam.Written(); !slices.Equal(written, want) {
+		t.Errorf("wrote %v, want %v", written, want)
+	}
+	assertGolden(t, "control", m.View())
+
+	press(t, m, "esc")
+	if m.mode != ModeDeviceList || cam.State() != sony_remote_ble.Disconnected {
+		t.Errorf("mode %d, camera %s after going back, want the device list and disconnected", m.mode, cam.State())
+	}
+}
+
+func TestControlLeavesSharedCameraConnected(t *testing.T) {
+	cam := newCamera()
+	if err := cam.Connect(sonyremotetest.Address); err != nil {
+		t.Fatal(err)
+	}
+	m := newTestModel(t, sharedCamera{cam}, nil)
+	if m.mode != ModeControl {
+		t.Fatalf("mode %d with a connected camera, want control", m.mode)
+	}
+
+	press(t, m, "esc")
+	if m.mode != ModeDeviceList || cam.State() != sony_remote_ble.Connected {
+		t.Errorf("mode %d, camera %s after going back, want the device list and still connected", m.mode, cam.State())
+	}
+	press(t, m, "enter")
+	if !press(t, m, "q") {
+		t.Fatal("q did not quit")
+	}
+	if cam.State() != sony_remote_ble.Connected {
+		t.Errorf("camera %s after quitting, want it left to its owner", cam.State())
+	}
+
+	// A camera of its own is disconnected on quit
+	own := newCamera()
+	m = newTestModel(t, own, nil)
+	press(t, m, "enter")
+	if !press(t, m, "q") || own.State() != sony_remote_ble.Disconnected {
+		t.Errorf("camera %s after quitting, want disconnected", own.State())
+	}
+}
+
+func TestDashboardKeys(t *testing.T) {
+	var cameras []*sonyremotetest.Camera
+	newController := func() (Controller, error) {
+		cam := newCamera()
+		cameras = append(cameras, cam)
+		return cam, nil
+	}
+	m := newTestModel(t, newCamera(), newController)
+
+	press(t, m, "d")
+	scan(t, m)
+	press(t, m, "enter", "down", "enter", "a")
+	if len(cameras) != 2 || cameras[0].State() != sony_remote_ble.Connected || cameras[1].State() != sony_remote_ble.Connected {
+		t.Fatalf("dashboard connected %d cameras, want both", len(cameras))
+	}
+	assertGolden(t, "dashboard", m.View())
+
+	press(t, m, "s")
+	for i, cam := range cameras {
+		cam.AssertSentCount(t, "shutter_full_down", 1)
+		if last := m.dash[cam.DeviceName()].last; !strings.HasPrefix(last, "Photo +") {
+			t.Errorf("camera %d shows %q after the photo", i, last)
+		}
+	}
+
+	// A camera that goes away is dropped on the next tick
+	cameras[1].Drop(errors.New("link lost"))
+	tick(m)
+	if _, ok := m.dash[field.AddressStr]; ok {
+		t.Error("lost camera still on the dashboard")
+	}
+	if log := m.logs[len(m.logs)-1]; !strings.HasSuffix(log, "Lost connection to ZV-E10") {
+		t.Errorf("last log %q, want the lost camera", log)
+	}
+
+	if !press(t, m, "q") {
+		t.Fatal("q did not quit")
+	}
+	if cameras[0].State() != sony_remote_ble.Disconnected {
+		t.Errorf("camera %s after quitting the dashboard, want disconnected", cameras[0].State())
+	}
+}
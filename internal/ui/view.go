@@ -3,8 +3,10 @@ package ui
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/smazurov/sony_remote_ble/internal/config"
 	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
 )
 
@@ -23,16 +25,19 @@ func (m *Model) deviceListView() string {
 	}
 
 	statusText := "Status: Ready to scan"
+	scanKey := m.keys.help(config.ActionScan)
 	if m.scanning {
-		if len(m.devices) == 0 {
+		if len(m.seen) == 0 {
 			statusText = fmt.Sprintf("Status: %sScanning for Sony cameras... (none found yet)", spinner)
 		} else {
-			statusText = fmt.Sprintf("Status: %sScanning... found %d device(s) so far", spinner, len(m.devices))
+			statusText = fmt.Sprintf("Status: %sScanning... found %d device(s) so far", spinner, len(m.seen))
 		}
 	} else if len(m.devices) == 0 {
-		statusText = "Status: No devices found. Press Tab to scan."
+		statusText = fmt.Sprintf("Status: No devices found. Press %s to scan.", scanKey)
+	} else if len(m.seen) == 0 {
+		statusText = fmt.Sprintf("Status: %d known camera(s). Press %s to scan.", len(m.devices), scanKey)
 	} else {
-		statusText = fmt.Sprintf("Status: Scan complete - found %d device(s)", len(m.devices))
+		statusText = fmt.Sprintf("Status: Scan complete - found %d device(s)", len(m.seen))
 	}
 	sections = append(sections, statusText)
 
@@ -54,8 +59,18 @@ func (m *Model) deviceListView() string {
 					scanIndicator = " [scanning...]"
 				}
 
-				deviceLine := fmt.Sprintf("%s%s (%s) RSSI: %d%s",
-					prefix, device.Name, device.AddressStr, device.RSSI, scanIndicator)
+				// Known cameras that have not advertised yet have no signal reading
+				rssi := "--"
+				if m.seen[device.AddressStr] {
+					rssi = fmt.Sprintf("%d", device.RSSI)
+				}
+				knownIndicator := ""
+				if m.isKnown(device) {
+					knownIndicator = " [known]"
+				}
+
+				deviceLine := fmt.Sprintf("%s%s (%s) RSSI: %s%s%s",
+					prefix, device.Name, device.AddressStr, rssi, knownIndicator, scanIndicator)
 				sections = append(sections, style.Render(deviceLine))
 			}
 		} else if m.scanning {
@@ -67,11 +82,12 @@ func (m *Model) deviceListView() string {
 	// Controls help
 	help := []string{
 		"Controls:",
-		"↑/↓ or k/j - Navigate devices",
-		"Tab - Scan for devices",
-		"Enter - Connect to selected device",
-		"Esc - Stop scanning",
-		"q - Quit",
+		fmt.Sprintf("%s and %s - Navigate devices", m.keys.help(config.ActionUp), m.keys.help(config.ActionDown)),
+		m.keys.help(config.ActionScan) + " - Scan for devices",
+		m.keys.help(config.ActionConnect) + " - Connect to selected device",
+		m.keys.help(config.ActionStopScan) + " - Stop scanning",
+		m.keys.help(config.ActionDashboard) + " - Multi-camera dashboard",
+		m.keys.help(config.ActionQuit) + " - Quit",
 	}
 	sections = append(sections, "\n"+helpStyle.Render(strings.Join(help, "\n")))
 
@@ -103,17 +119,20 @@ func (m *Model) controlView() string {
 	var sections []string
 
 	// Title with connection status
-	connected := m.client.State() == sony_remote_ble.Connected
+	connected := m.state == sony_remote_ble.Connected
 	connectionStatus := "Disconnected"
 	statusStyle := disconnectedStyle
 	if connected {
-		connectionStatus = "Connected to " + m.client.DeviceName()
+		connectionStatus = "Connected to " + m.deviceName
 		statusStyle = connectedStyle
 	}
 
 	title := titleStyle.Render("Sony Camera Remote")
 	status := statusStyle.Render(connectionStatus)
 	sections = append(sections, title+" | "+status)
+	if keepAwake := m.renderKeepAwake(); keepAwake != "" {
+		sections = append(sections, keepAwake)
+	}
 
 	// Main control interface using the compact design
 	controlInterface := m.renderControlInterface()
@@ -126,9 +145,14 @@ func (m *Model) controlView() string {
 	// Controls help
 	help := []string{
 		"Controls:",
-		"F/f - Focus | S/s - Shutter | Z/z - Zoom | A - AutoFocus",
-		"Space - Quick Shot | R - Record | C - Custom | Esc - Back",
-		"Q - Quit",
+		fmt.Sprintf("%s - Focus | %s - Shutter | %s/%s - Zoom | %s - AutoFocus",
+			m.keys.help(config.ActionFocus), m.keys.help(config.ActionShutter),
+			m.keys.help(config.ActionZoomIn), m.keys.help(config.ActionZoomOut),
+			m.keys.help(config.ActionAutofocus)),
+		fmt.Sprintf("%s - Quick Shot | %s - Record | %s - Custom | %s - Back",
+			m.keys.help(config.ActionPhoto), m.keys.help(config.ActionRecord),
+			m.keys.help(config.ActionCustom), m.keys.help(config.ActionBack)),
+		m.keys.help(config.ActionQuit) + " - Quit",
 	}
 	sections = append(sections, helpStyle.Render(strings.Join(help, "\n")))
 
@@ -159,8 +183,29 @@ func (m *Model) controlView() string {
 	return containerStyle.Width(containerWidth).Render(strings.Join(sections, "\n"))
 }
 
+// renderKeepAwake describes the keep-awake, or returns "" when it is off.
+func (m *Model) renderKeepAwake() string {
+	if m.keepAwake == nil {
+		return ""
+	}
+	status := m.keepAwake.Status()
+	text := "Keep-awake: "
+	switch {
+	case status.Paused != "":
+		text += "paused (" + status.Paused + ")"
+	case status.Err != nil:
+		text += fmt.Sprintf("signal failed: %v", status.Err)
+	default:
+		text += "next in " + max(status.Next.Sub(m.now()), 0).Round(time.Second).String()
+	}
+	if status.Signals > 0 {
+		text += fmt.Sprintf(" | %d sent", status.Signals)
+	}
+	return helpStyle.Render(text)
+}
+
 func (m *Model) renderControlInterface() string {
-	disabled := m.client.State() != sony_remote_ble.Connected
+	disabled := m.state != sony_remote_ble.Connected
 
 	// Zoom controls row
 	zoomOut := GetButtonStyle(m.buttonStates["zoom_out"], disabled).Render("Z-")
@@ -195,7 +240,7 @@ func (m *Model) renderControlInterface() string {
 }
 
 func (m *Model) renderQuickActions() string {
-	disabled := m.client.State() != sony_remote_ble.Connected
+	disabled := m.state != sony_remote_ble.Connected
 
 	custom := GetButtonStyle(m.buttonStates["custom"], disabled).Render("C1")
 	quickShot := GetButtonStyle(m.buttonStates["shutter"], disabled).Render("Quick Shot")
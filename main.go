@@ -1,23 +1,130 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/smazurov/sony_remote_ble/internal/cli"
+	"github.com/smazurov/sony_remote_ble/internal/config"
+	"github.com/smazurov/sony_remote_ble/internal/daemon"
+	"github.com/smazurov/sony_remote_ble/internal/known"
 	"github.com/smazurov/sony_remote_ble/internal/ui"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
 )
 
 // version is set via ldflags during build
 var version = "dev"
 
 func main() {
-	// Initialize the model
-	model, err := ui.NewModel(version)
+	// Global flags come before the subcommand and override the config file
+	configPath := flag.String("config", "", "configuration file")
+	logLevel := flag.String("log-level", "", "log level: debug, info, warn or error")
+	logFile := flag.String("log-file", "", "append logs to this file")
+	logFormat := flag.String("log-format", "", "log format: text, json or journal")
+	capturePath := flag.String("capture", "", "record Bluetooth traffic to this JSONL file")
+	replayPath := flag.String("replay", "", "replay a capture instead of using Bluetooth")
+	simulate := flag.Bool("simulate", false, "use a simulated camera instead of Bluetooth")
+	remote := flag.Bool("remote", false, "drive the camera of a running daemon in the TUI")
+	adapters := flag.String("adapter", "", "comma-separated Bluetooth adapters to use (see the adapters command)")
+	flag.Usage = func() { cli.Usage(os.Stderr, version) }
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(cli.ExitUsage)
+	}
+	if *logLevel != "" {
+		cfg.Log.Level = *logLevel
+	}
+	if *logFile != "" {
+		cfg.Log.File = *logFile
+	}
+	if *logFormat != "" {
+		cfg.Log.Format = *logFormat
+	}
+	// The flags override a configuration Load has already validated
+	if err := cfg.Log.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(cli.ExitUsage)
+	}
+
+	clients, closeClients, err := clientOptions(*capturePath, *replayPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(cli.ExitUsage)
+	}
+	clients.Simulate = *simulate
+	if *adapters != "" {
+		clients.Adapters = strings.Split(*adapters, ",")
+	}
+	defer closeClients()
+
+	// Any remaining arguments select a headless subcommand instead of the TUI
+	if flag.NArg() > 0 {
+		logger, closeLog, err := cfg.Log.NewLogger(os.Stderr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(cli.ExitUsage)
+		}
+		code := cli.Run(flag.Args(), version, cfg, logger, clients)
+		closeLog()
+		closeClients()
+		os.Exit(code)
+	}
+
+	// The TUI owns the terminal, so logs only go to a file if one is configured
+	logger, closeLog, err := cfg.Log.NewLogger(io.Discard)
+	if err != nil {
+		log.Fatalf("Failed to initialize logging: %v", err)
+	}
+	defer closeLog()
+
+	// Known cameras are listed immediately; failing to load them is not fatal
+	var knownCameras *known.Store
+	if path, err := known.DefaultPath(); err == nil && !clients.Virtual() {
+		if knownCameras, err = known.Open(path); err != nil {
+			logger.Warn("known cameras unavailable", "error", err)
+		}
+	}
+
+	ctrl, closeCtrl, err := controller(cfg, clients, *remote, logger)
 	if err != nil {
 		log.Fatalf("Failed to initialize application: %v", err)
 	}
+	defer closeCtrl()
+
+	// Every camera on the dashboard gets a client of its own, spread across the
+	// adapters; a daemon owns only one
+	var newController func() (ui.Controller, error)
+	if !*remote {
+		next := 0
+		newController = func() (ui.Controller, error) {
+			client, err := clients.NewClientOn(next)
+			next++
+			if err != nil {
+				return nil, err
+			}
+			client.SetLogger(logger.With("component", "ble"))
+			client.SetIdleDisconnect(cfg.IdleDisconnect.Options())
+			return client, nil
+		}
+	}
+
+	// Initialize the model
+	model := ui.NewModel(version, ui.Options{
+		Config:        cfg,
+		Logger:        logger,
+		Known:         knownCameras,
+		Controller:    ctrl,
+		NewController: newController,
+	})
 
 	// Create the Bubble Tea program
 	p := tea.NewProgram(
@@ -32,3 +139,58 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// controller returns what the TUI drives: the camera of a running daemon, or
+// a client of its own. Without a Bluetooth adapter the TUI still starts and
+// reports the error when asked to scan or connect.
+func controller(cfg *config.Config, clients cli.ClientOptions, remote bool, logger *slog.Logger) (ui.Controller, func(), error) {
+	if remote {
+		if clients.Virtual() || len(clients.Adapters) > 0 {
+			return nil, nil, fmt.Errorf("--remote cannot be combined with --replay, --simulate or --adapter")
+		}
+		path := cfg.Daemon.Socket
+		if path == "" {
+			path = daemon.DefaultSocketPath()
+		}
+		r, err := daemon.DialRemote(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, func() { r.Close() }, nil
+	}
+
+	client, err := clients.NewClient()
+	if err != nil {
+		logger.Warn("bluetooth unavailable", "error", err)
+		return ui.Unavailable(err), func() {}, nil
+	}
+	client.SetLogger(logger.With("component", "ble"))
+	client.SetIdleDisconnect(cfg.IdleDisconnect.Options())
+	return client, func() {}, nil
+}
+
+// clientOptions opens the capture to replay and the file to record traffic
+// to. The returned function closes the recording.
+func clientOptions(capturePath, replayPath string) (cli.ClientOptions, func(), error) {
+	var opts cli.ClientOptions
+	if replayPath != "" {
+		f, err := os.Open(replayPath)
+		if err != nil {
+			return opts, nil, err
+		}
+		opts.Replay, err = sony_remote_ble.ReadCapture(f)
+		f.Close()
+		if err != nil {
+			return opts, nil, fmt.Errorf("%s: %w", replayPath, err)
+		}
+	}
+	if capturePath == "" {
+		return opts, func() {}, nil
+	}
+	f, err := os.Create(capturePath)
+	if err != nil {
+		return opts, nil, err
+	}
+	opts.Capture = f
+	return opts, func() { f.Close() }, nil
+}
@@ -0,0 +1,110 @@
+package sony_remote_ble
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// ErrAdapterNotFound is returned by NewClientWithAdapter when no adapter of
+// the host matches the requested one.
+var ErrAdapterNotFound = errors.New("bluetooth adapter not found")
+
+// AdapterInfo describes a Bluetooth adapter of the host, as listed by ListAdapters.
+type AdapterInfo struct {
+	// ID identifies the adapter, e.g. "hci0" on Linux and "default" elsewhere
+	ID string
+	// Address is the adapter's own Bluetooth address, if the platform reports it
+	Address string
+	// Name is the adapter's human-readable name, if the platform reports it
+	Name string
+	// Powered reports whether the adapter is switched on
+	Powered bool
+	// Default is set for the adapter that NewClient uses
+	Default bool
+}
+
+// ListAdapters returns the Bluetooth adapters of the host, sorted by ID.
+//
+// On Linux every adapter known to BlueZ is listed. On macOS and Windows the
+// operating system picks the radio, so a single default adapter is listed.
+//
+// Example:
+//
+//	adapters, err := sony_remote_ble.ListAdapters()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for _, a := range adapters {
+//		fmt.Println(a.ID, a.Address, a.Name)
+//	}
+func ListAdapters() ([]AdapterInfo, error) {
+	return listAdapters()
+}
+
+// enabled holds the adapters enabled so far by ID. Clients of the same
+// adapter share it, as every client of NewClient shares the default adapter.
+var enabled = struct {
+	sync.Mutex
+	adapters map[string]*bluetooth.Adapter
+}{adapters: make(map[string]*bluetooth.Adapter)}
+
+// NewClientWithAdapter creates a client that reaches cameras through the given
+// adapter instead of the default one. The adapter is matched against the ID,
+// address and name reported by ListAdapters; the empty string selects the
+// default adapter, like NewClient.
+//
+// Clients may use different adapters at the same time, which spreads several
+// cameras over several radios, for example an onboard radio and a USB dongle
+// with a long-range antenna.
+//
+// Example:
+//
+//	client, err := sony_remote_ble.NewClientWithAdapter("hci1")
+//	if err != nil {
+//		log.Fatal("Failed to create client:", err)
+//	}
+//	defer client.Disconnect()
+func NewClientWithAdapter(adapter string) (*Client, error) {
+	id := defaultAdapterID
+	if adapter != "" {
+		var err error
+		if id, err = resolveAdapter(adapter); err != nil {
+			return nil, err
+		}
+	}
+
+	enabled.Lock()
+	defer enabled.Unlock()
+	a, ok := enabled.adapters[id]
+	if !ok {
+		a = newAdapter(id)
+		if err := a.Enable(); err != nil {
+			return nil, fmt.Errorf("failed to enable adapter %s: %w", id, err)
+		}
+		enabled.adapters[id] = a
+	}
+	return NewClientWithTransport(&adapterTransport{adapter: a}), nil
+}
+
+// resolveAdapter returns the ID of the adapter matching an ID, address or name.
+func resolveAdapter(adapter string) (string, error) {
+	adapters, err := listAdapters()
+	if err != nil {
+		return "", err
+	}
+	for _, a := range adapters {
+		if a.ID == adapter || strings.EqualFold(a.Address, adapter) {
+			return a.ID, nil
+		}
+	}
+	for _, a := range adapters {
+		if a.Name != "" && strings.EqualFold(a.Name, adapter) {
+			return a.ID, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrAdapterNotFound, adapter)
+}
@@ -0,0 +1,52 @@
+package sony_remote_ble
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/godbus/dbus/v5"
+	"tinygo.org/x/bluetooth"
+)
+
+// defaultAdapterID is the BlueZ adapter behind bluetooth.DefaultAdapter.
+const defaultAdapterID = "hci0"
+
+// listAdapters asks BlueZ for its adapters.
+func listAdapters() ([]AdapterInfo, error) {
+	bus, err := dbus.SystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach BlueZ: %w", err)
+	}
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	err = bus.Object("org.bluez", "/").
+		Call("org.freedesktop.DBus.ObjectManager.GetManagedObjects", 0).
+		Store(&objects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list adapters: %w", err)
+	}
+
+	var adapters []AdapterInfo
+	for objectPath, interfaces := range objects {
+		props, ok := interfaces["org.bluez.Adapter1"]
+		if !ok {
+			continue
+		}
+		id := path.Base(string(objectPath))
+		info := AdapterInfo{ID: id, Default: id == defaultAdapterID}
+		info.Address, _ = props["Address"].Value().(string)
+		info.Name, _ = props["Alias"].Value().(string)
+		info.Powered, _ = props["Powered"].Value().(bool)
+		adapters = append(adapters, info)
+	}
+	sort.Slice(adapters, func(i, j int) bool { return adapters[i].ID < adapters[j].ID })
+	return adapters, nil
+}
+
+// newAdapter returns the adapter for a BlueZ adapter ID.
+func newAdapter(id string) *bluetooth.Adapter {
+	if id == defaultAdapterID {
+		return bluetooth.DefaultAdapter
+	}
+	return bluetooth.NewAdapter(id)
+}
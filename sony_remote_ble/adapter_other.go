@@ -0,0 +1,19 @@
+//go:build !linux
+
+package sony_remote_ble
+
+import "tinygo.org/x/bluetooth"
+
+// defaultAdapterID names the only adapter; the operating system picks the radio.
+const defaultAdapterID = "default"
+
+// listAdapters lists the default adapter. Its power state is not reported,
+// so it is listed as powered.
+func listAdapters() ([]AdapterInfo, error) {
+	return []AdapterInfo{{ID: defaultAdapterID, Powered: true, Default: true}}, nil
+}
+
+// newAdapter returns the default adapter, the only one there is.
+func newAdapter(string) *bluetooth.Adapter {
+	return bluetooth.DefaultAdapter
+}
@@ -0,0 +1,180 @@
+package sony_remote_ble
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+// btsnoop file format constants, see RFC 1761 and the Android btsnoop variant
+// that Wireshark reads.
+const (
+	btsnoopVersion = 1
+	// btsnoopH4 is the datalink type for HCI packets prefixed with the UART packet type
+	btsnoopH4 = 1002
+	// btsnoopEpochDelta is the number of microseconds between 0000-01-01 and the Unix epoch
+	btsnoopEpochDelta = 0x00dcddb30f2f8000
+
+	btsnoopReceived = 1 << 0
+	btsnoopEvent    = 1 << 1
+)
+
+// HCI and ATT values used to frame capture records as Bluetooth packets.
+const (
+	hciACLData = 0x02
+	hciEvent   = 0x04
+
+	hciDisconnectionComplete = 0x05
+	hciLEMeta                = 0x3e
+	hciLEConnectionComplete  = 0x01
+	hciLEAdvertisingReport   = 0x02
+
+	// hciConnectionHandle is the handle used for the single camera connection
+	hciConnectionHandle = 0x0040
+	// l2capATT is the L2CAP channel of the attribute protocol
+	l2capATT = 0x0004
+
+	attWriteCommand = 0x52
+	attNotification = 0x1b
+
+	// Captures do not record attribute handles, so fixed placeholders stand
+	// in for the command and notification characteristics
+	attCommandHandle = 0x0010
+	attNotifyHandle  = 0x0012
+)
+
+// WriteBtsnoop writes the capture in btsnoop format so that it can be opened
+// in Wireshark. Command writes become ATT Write Commands, notifications ATT
+// Handle Value Notifications, advertisements LE Advertising Reports, and
+// connections and disconnections the matching HCI events.
+//
+// The capture does not record attribute handles, so the command characteristic
+// appears as handle 0x0010 and the notification characteristic as 0x0012.
+// Writes that failed in the host never reached the air and are left out.
+//
+// Example:
+//
+//	capture, err := sony_remote_ble.ReadCapture(in)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	err = capture.WriteBtsnoop(out)
+func (c *Capture) WriteBtsnoop(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("btsnoop\x00")
+	binary.Write(bw, binary.BigEndian, uint32(btsnoopVersion))
+	binary.Write(bw, binary.BigEndian, uint32(btsnoopH4))
+
+	for _, rec := range c.Records {
+		packet, flags := btsnoopPacket(rec)
+		if packet == nil {
+			continue
+		}
+		at := c.Started.Add(seconds(rec.T))
+		binary.Write(bw, binary.BigEndian, uint32(len(packet)))
+		binary.Write(bw, binary.BigEndian, uint32(len(packet)))
+		binary.Write(bw, binary.BigEndian, uint32(flags))
+		binary.Write(bw, binary.BigEndian, uint32(0))
+		binary.Write(bw, binary.BigEndian, at.UnixMicro()+btsnoopEpochDelta)
+		bw.Write(packet)
+	}
+	return bw.Flush()
+}
+
+// btsnoopPacket frames a record as an H4 packet, or returns nil for records
+// that have no packet.
+func btsnoopPacket(rec CaptureRecord) ([]byte, uint32) {
+	switch rec.Type {
+	case CaptureWrite:
+		frame, err := rec.Frame()
+		if err != nil || rec.Error != "" {
+			return nil, 0
+		}
+		return attPacket(attWriteCommand, attCommandHandle, frame), 0
+
+	case CaptureNotify:
+		frame, err := rec.Frame()
+		if err != nil {
+			return nil, 0
+		}
+		return attPacket(attNotification, attNotifyHandle, frame), btsnoopReceived
+
+	case CaptureAdvertisement:
+		name := []byte(rec.Name)
+		if len(name) > 29 {
+			name = name[:29]
+		}
+		report := []byte{hciLEAdvertisingReport, 1, 0x00, 0x00}
+		report = append(report, hciAddress(rec.Address)...)
+		report = append(report, byte(len(name)+2), byte(len(name)+1), 0x09)
+		report = append(report, name...)
+		report = append(report, byte(int8(rec.RSSI)))
+		return hciEventPacket(hciLEMeta, report), btsnoopReceived | btsnoopEvent
+
+	case CaptureState:
+		switch {
+		case rec.State == Connected.String():
+			return leConnectionComplete(0x00, rec.Address), btsnoopReceived | btsnoopEvent
+		case rec.Previous == Connecting.String():
+			// 0x3e: connection failed to be established
+			return leConnectionComplete(0x3e, rec.Address), btsnoopReceived | btsnoopEvent
+		case rec.Previous == Connected.String():
+			// 0x16: connection terminated by local host
+			params := binary.LittleEndian.AppendUint16([]byte{0x00}, hciConnectionHandle)
+			params = append(params, 0x16)
+			return hciEventPacket(hciDisconnectionComplete, params), btsnoopReceived | btsnoopEvent
+		}
+	}
+	return nil, 0
+}
+
+// attPacket wraps an ATT PDU in L2CAP and an HCI ACL data packet.
+func attPacket(opcode byte, handle uint16, value []byte) []byte {
+	pdu := binary.LittleEndian.AppendUint16([]byte{opcode}, handle)
+	pdu = append(pdu, value...)
+
+	packet := []byte{hciACLData}
+	// Packet boundary flag 0b10: first automatically flushable packet
+	packet = binary.LittleEndian.AppendUint16(packet, hciConnectionHandle|0x2000)
+	packet = binary.LittleEndian.AppendUint16(packet, uint16(len(pdu)+4))
+	packet = binary.LittleEndian.AppendUint16(packet, uint16(len(pdu)))
+	packet = binary.LittleEndian.AppendUint16(packet, l2capATT)
+	return append(packet, pdu...)
+}
+
+func hciEventPacket(code byte, params []byte) []byte {
+	return append([]byte{hciEvent, code, byte(len(params))}, params...)
+}
+
+func leConnectionComplete(status byte, address string) []byte {
+	params := []byte{hciLEConnectionComplete, status}
+	params = binary.LittleEndian.AppendUint16(params, hciConnectionHandle)
+	params = append(params, 0x00, 0x00) // central role, public address
+	params = append(params, hciAddress(address)...)
+	params = binary.LittleEndian.AppendUint16(params, 0x0018) // interval 30ms
+	params = binary.LittleEndian.AppendUint16(params, 0)      // latency
+	params = binary.LittleEndian.AppendUint16(params, 0x0048) // supervision timeout 720ms
+	params = append(params, 0x00)
+	return hciEventPacket(hciLEMeta, params)
+}
+
+// hciAddress converts a MAC address string into the little-endian byte order
+// of HCI packets. Addresses that are not MAC addresses, such as the UUIDs used
+// on macOS, become all zeros.
+func hciAddress(address string) []byte {
+	out := make([]byte, 6)
+	parts := strings.Split(address, ":")
+	if len(parts) != 6 {
+		return out
+	}
+	for i, part := range parts {
+		b, err := hex.DecodeString(part)
+		if err != nil || len(b) != 1 {
+			return make([]byte, 6)
+		}
+		out[5-i] = b[0]
+	}
+	return out
+}
@@ -0,0 +1,342 @@
+package sony_remote_ble
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Defaults used by Calibrate for options left at zero.
+const (
+	DefaultCalibrateTrials  = 10
+	DefaultCalibratePause   = time.Second
+	DefaultCalibrateTimeout = 2 * time.Second
+)
+
+// CalibrateOptions configures Calibrate. Zero values select the defaults.
+type CalibrateOptions struct {
+	// Trials is how many photos are taken (DefaultCalibrateTrials)
+	Trials int
+	// Hold is how long the half press is held before the full press; zero uses
+	// the camera's command delay, as TakePhoto does. Cameras with notifications
+	// are held until they report focus, up to Timeout.
+	Hold time.Duration
+	// Pause is the time between trials, which lets the camera store the photo
+	// (DefaultCalibratePause)
+	Pause time.Duration
+	// Timeout bounds the waits for the focus and shutter notifications of each
+	// trial (DefaultCalibrateTimeout)
+	Timeout time.Duration
+}
+
+// CommandTiming tells when the write of a command started and completed.
+type CommandTiming struct {
+	Command SonyCommand
+	Start   time.Time
+	End     time.Time
+}
+
+// Latency returns how long the write took.
+func (t CommandTiming) Latency() time.Duration {
+	return t.End.Sub(t.Start)
+}
+
+// Trial records one half press, full press and release of Calibrate. The
+// notification times are zero for notifications that did not arrive.
+type Trial struct {
+	HalfPress CommandTiming
+	FullPress CommandTiming
+	Release   CommandTiming
+	// Focused is when the camera reported focus after the half press
+	Focused time.Time
+	// Shutter is when the camera reported the shutter open after the full press
+	Shutter time.Time
+}
+
+// FocusLatency returns the time from starting the half press to focus, if the
+// camera reported it.
+func (t Trial) FocusLatency() (time.Duration, bool) {
+	if t.Focused.IsZero() {
+		return 0, false
+	}
+	return t.Focused.Sub(t.HalfPress.Start), true
+}
+
+// ShutterLatency returns the time from starting the full press to the shutter
+// opening, if the camera reported it.
+func (t Trial) ShutterLatency() (time.Duration, bool) {
+	if t.Shutter.IsZero() {
+		return 0, false
+	}
+	return t.Shutter.Sub(t.FullPress.Start), true
+}
+
+// LatencyStats summarizes a set of latency samples. All fields are zero
+// without samples.
+type LatencyStats struct {
+	Samples int
+	Min     time.Duration
+	Median  time.Duration
+	P95     time.Duration
+	Max     time.Duration
+	// Jitter is the standard deviation of the samples
+	Jitter time.Duration
+}
+
+// NewLatencyStats computes the statistics of samples.
+func NewLatencyStats(samples []time.Duration) LatencyStats {
+	if len(samples) == 0 {
+		return LatencyStats{}
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+
+	var sum float64
+	for _, s := range sorted {
+		sum += float64(s)
+	}
+	mean := sum / float64(len(sorted))
+	var variance float64
+	for _, s := range sorted {
+		variance += (float64(s) - mean) * (float64(s) - mean)
+	}
+	variance /= float64(len(sorted))
+
+	return LatencyStats{
+		Samples: len(sorted),
+		Min:     sorted[0],
+		Median:  percentile(sorted, 50),
+		P95:     percentile(sorted, 95),
+		Max:     sorted[len(sorted)-1],
+		Jitter:  time.Duration(math.Sqrt(variance)),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted samples.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// Calibration is the result of Calibrate.
+type Calibration struct {
+	Trials []Trial
+	// Write summarizes how long the full press writes took
+	Write LatencyStats
+	// Focus summarizes the time from the half press to focus
+	Focus LatencyStats
+	// Shutter summarizes the time from the full press to the shutter opening
+	Shutter LatencyStats
+}
+
+// Offset returns how long after a full press is sent the photo is taken: the
+// median shutter latency, or the median write latency for cameras that do not
+// report their shutter. Triggers that fire several cameras together hold the
+// faster ones back by the difference, see CameraGroup.SetLatency.
+func (c Calibration) Offset() time.Duration {
+	if c.Shutter.Samples > 0 {
+		return c.Shutter.Median
+	}
+	return c.Write.Median
+}
+
+// Jitter returns the jitter of the latency Offset is based on.
+func (c Calibration) Jitter() time.Duration {
+	if c.Shutter.Samples > 0 {
+		return c.Shutter.Jitter
+	}
+	return c.Write.Jitter
+}
+
+// Calibrate measures how long the connected camera takes from a command to
+// the exposure. Each trial half-presses the shutter, holds it, presses fully
+// and releases, timestamping every write and the focus and shutter
+// notifications of the camera. The camera takes a photo in every trial.
+//
+// Without notifications only the write latencies are measured. The
+// calibration so far is returned with the error of a failed trial.
+//
+// Example:
+//
+//	cal, err := sony_remote_ble.Calibrate(ctx, client, sony_remote_ble.CalibrateOptions{Trials: 20})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Printf("shutter: median %v, p95 %v, jitter %v\n", cal.Shutter.Median, cal.Shutter.P95, cal.Shutter.Jitter)
+func Calibrate(ctx context.Context, cam Camera, opts CalibrateOptions) (Calibration, error) {
+	if opts.Trials <= 0 {
+		opts.Trials = DefaultCalibrateTrials
+	}
+	if opts.Pause <= 0 {
+		opts.Pause = DefaultCalibratePause
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultCalibrateTimeout
+	}
+	if opts.Hold <= 0 {
+		opts.Hold = cam.CommandDelay()
+	}
+	if cam.State() != Connected {
+		return Calibration{}, ErrNotConnected
+	}
+
+	// Notifications are stamped when the client receives them and recorded
+	// into the running trial
+	var mu sync.Mutex
+	var current *Trial
+	n := notices{focus: make(chan struct{}, 1), shutter: make(chan struct{}, 1)}
+	remove := cam.OnEvent(func(ev Event) {
+		if ev.Type != EventNotification || !ev.Notification.Active {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if current == nil {
+			return
+		}
+		switch ev.Notification.Kind {
+		case NotifyFocus:
+			if current.Focused.IsZero() {
+				current.Focused = ev.Time
+				signal(n.focus)
+			}
+		case NotifyShutter:
+			if current.Shutter.IsZero() && !current.FullPress.Start.IsZero() {
+				current.Shutter = ev.Time
+				signal(n.shutter)
+			}
+		}
+	})
+	defer remove()
+
+	var cal Calibration
+	var err error
+	for i := range opts.Trials {
+		if i > 0 {
+			if err = sleepContext(ctx, opts.Pause); err != nil {
+				break
+			}
+		}
+
+		trial := &Trial{}
+		mu.Lock()
+		current = trial
+		mu.Unlock()
+		n.drain()
+
+		err = runTrial(ctx, cam, opts, trial, &mu, n)
+		mu.Lock()
+		current = nil
+		done := *trial
+		mu.Unlock()
+		if err != nil {
+			break
+		}
+		cal.Trials = append(cal.Trials, done)
+	}
+
+	var writes, focus, shutters []time.Duration
+	for _, t := range cal.Trials {
+		writes = append(writes, t.FullPress.Latency())
+		if d, ok := t.FocusLatency(); ok {
+			focus = append(focus, d)
+		}
+		if d, ok := t.ShutterLatency(); ok {
+			shutters = append(shutters, d)
+		}
+	}
+	cal.Write = NewLatencyStats(writes)
+	cal.Focus = NewLatencyStats(focus)
+	cal.Shutter = NewLatencyStats(shutters)
+	return cal, err
+}
+
+// waitNotice waits for ch until timeout. It only fails if ctx ends.
+func waitNotice(ctx context.Context, ch <-chan struct{}, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ch:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return ctx.Err()
+}
+
+// runTrial performs one trial. The full press is stamped under mu, which the
+// notification listener uses to tell the shutter of this trial from a late one.
+func runTrial(ctx context.Context, cam Camera, opts CalibrateOptions, trial *Trial, mu *sync.Mutex, n notices) error {
+	half, err := timedSend(cam, Commands["shutter_half_down"])
+	mu.Lock()
+	trial.HalfPress = half
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+	// Never leave the shutter pressed
+	defer cam.SendCommand(Commands["shutter_half_up"])
+
+	if err := sleepContext(ctx, opts.Hold); err != nil {
+		return err
+	}
+	mu.Lock()
+	focused := !trial.Focused.IsZero()
+	mu.Unlock()
+	if cam.SupportsNotifications() && !focused {
+		if err := waitNotice(ctx, n.focus, opts.Timeout); err != nil {
+			return err
+		}
+	}
+
+	mu.Lock()
+	trial.FullPress = CommandTiming{Command: Commands["shutter_full_down"], Start: time.Now()}
+	mu.Unlock()
+	err = cam.SendCommand(Commands["shutter_full_down"])
+	mu.Lock()
+	trial.FullPress.End = time.Now()
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if cam.SupportsNotifications() {
+		waitNotice(ctx, n.shutter, opts.Timeout)
+	} else if err := sleepContext(ctx, cam.CommandDelay()); err != nil {
+		cam.SendCommand(Commands["shutter_full_up"])
+		return err
+	}
+
+	release, err := timedSend(cam, Commands["shutter_full_up"])
+	mu.Lock()
+	trial.Release = release
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// timedSend sends cmd and stamps the write.
+func timedSend(cam Camera, cmd SonyCommand) (CommandTiming, error) {
+	t := CommandTiming{Command: cmd, Start: time.Now()}
+	err := cam.SendCommand(cmd)
+	t.End = time.Now()
+	return t, err
+}
+
+// sleepContext sleeps for d or until ctx ends.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
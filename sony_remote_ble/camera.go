@@ -0,0 +1,82 @@
+package sony_remote_ble
+
+import (
+	"context"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// Camera is the set of operations a Client offers for finding, connecting to
+// and controlling a camera. Code that accepts a Camera instead of a *Client
+// can be tested without Bluetooth by passing the fake from the sonyremotetest
+// package.
+//
+// Example:
+//
+//	// Timelapse takes count photos, one every interval.
+//	func Timelapse(cam sony_remote_ble.Camera, count int, interval time.Duration) error {
+//		for i := 0; i < count; i++ {
+//			if err := cam.TakePhoto(); err != nil {
+//				return err
+//			}
+//			time.Sleep(interval)
+//		}
+//		return nil
+//	}
+type Camera interface {
+	// State returns the current connection state
+	State() ConnectionState
+	// DeviceName returns the name of the connected camera, or "" when not connected
+	DeviceName() string
+	// LastError returns the last error that occurred, or nil
+	LastError() error
+	// CommandDelay returns the pause between commands in built-in sequences
+	CommandDelay() time.Duration
+	// SetCommandDelay changes the pause between commands in built-in sequences
+	SetCommandDelay(delay time.Duration)
+	// TimingProfile returns the pauses used by TakePhoto and Zoom
+	TimingProfile() TimingProfile
+	// SetTimingProfile changes the pauses used by TakePhoto and Zoom
+	SetTimingProfile(profile TimingProfile)
+
+	// ScanForDevices reports nearby cameras to deviceChan until ctx ends or StopScan is called
+	ScanForDevices(ctx context.Context, deviceChan chan<- DeviceInfo) error
+	// StopScan stops a running scan
+	StopScan()
+	// Connect connects to the camera at address
+	Connect(address bluetooth.Address) error
+	// Disconnect closes the connection
+	Disconnect() error
+	// SupportsNotifications reports whether the connected camera sends notifications
+	SupportsNotifications() bool
+	// SetIdleDisconnect makes the link close after a while without commands
+	SetIdleDisconnect(idle IdleDisconnect)
+	// Idle reports whether the link is closed for being idle
+	Idle() bool
+
+	// SendCommand writes a single command
+	SendCommand(cmd SonyCommand) error
+	// SendCommandSequence writes commands in order with delay between them
+	SendCommandSequence(commands []SonyCommand, delay time.Duration) error
+	// TakePhoto focuses and releases the shutter
+	TakePhoto() error
+	// TakePhotoWhenFocused takes a photo only once the camera reports focus
+	TakePhotoWhenFocused(ctx context.Context, timeout time.Duration, policy FocusPolicy) (FocusResult, error)
+	// Press presses a button and releases it after hold
+	Press(button string, hold time.Duration) error
+	// Bulb holds the shutter open for exposure
+	Bulb(exposure time.Duration) error
+	// Zoom zooms in or out at speed for hold
+	Zoom(in bool, speed byte, hold time.Duration) error
+	// Recording reports whether the camera records video, if that can be known
+	Recording() (recording, known bool)
+	// SetRecording starts or stops video recording
+	SetRecording(ctx context.Context, on bool) error
+
+	// OnEvent registers a listener for client events
+	OnEvent(fn func(Event)) (remove func())
+}
+
+// Client is the Bluetooth implementation of Camera.
+var _ Camera = (*Client)(nil)
@@ -0,0 +1,217 @@
+package sony_remote_ble
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// CaptureVersion is the format version written in the header of a capture.
+const CaptureVersion = 1
+
+// Capture record types.
+const (
+	// CaptureHeader is the first line of a capture and carries its start time
+	CaptureHeader = "header"
+	// CaptureState records a connection state change
+	CaptureState = "state"
+	// CaptureAdvertisement records a camera advertisement seen while scanning
+	CaptureAdvertisement = "advertisement"
+	// CaptureWrite records a command frame written to the camera
+	CaptureWrite = "write"
+	// CaptureNotify records a frame received on the notification characteristic
+	CaptureNotify = "notify"
+)
+
+// CaptureRecord is one line of a capture. Only the fields relevant to the
+// record type are set.
+type CaptureRecord struct {
+	// T is the number of seconds since the capture started, measured with the
+	// monotonic clock so that wall clock adjustments do not distort it
+	T float64 `json:"t"`
+	// Type is one of the Capture* record types
+	Type string `json:"type"`
+	// Version is the capture format version (header)
+	Version int `json:"version,omitempty"`
+	// Time is the wall clock time the capture started (header)
+	Time *time.Time `json:"time,omitempty"`
+	// State and Previous are the new and old connection state (state)
+	State    string `json:"state,omitempty"`
+	Previous string `json:"previous,omitempty"`
+	// Name is the command name (write) or the advertised camera name (advertisement)
+	Name string `json:"name,omitempty"`
+	// Address is the camera address (advertisement, and state when connected)
+	Address string `json:"address,omitempty"`
+	// RSSI is the signal strength in dBm (advertisement)
+	RSSI int16 `json:"rssi,omitempty"`
+	// Bytes is the frame in hex (write, notify)
+	Bytes string `json:"bytes,omitempty"`
+	// Latency is how many seconds the write took (write)
+	Latency float64 `json:"latency,omitempty"`
+	// Error describes a failed write or connection (write, state)
+	Error string `json:"error,omitempty"`
+}
+
+// Frame decodes the Bytes field.
+func (r CaptureRecord) Frame() ([]byte, error) {
+	return hex.DecodeString(r.Bytes)
+}
+
+// Record writes the client's protocol traffic to w as JSON lines until stop is
+// called: every command frame written, every notification received, every
+// camera advertisement and every connection state change. The first line is a
+// header with the wall clock start time; every line carries the seconds since
+// then.
+//
+// Attach a capture to a bug report, convert it for Wireshark with
+// Capture.WriteBtsnoop, or reproduce the session offline with a ReplayTransport.
+//
+// Writes to w happen on the goroutine that produced the traffic, so w should
+// not block for long. stop returns the first write error, if any.
+//
+// Example:
+//
+//	f, err := os.Create("camera.jsonl")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer f.Close()
+//	stop := client.Record(f)
+//	defer stop()
+func (c *Client) Record(w io.Writer) (stop func() error) {
+	rec := &recorder{enc: json.NewEncoder(w), start: time.Now()}
+	started := rec.start.Round(0)
+	rec.write(CaptureRecord{Type: CaptureHeader, Version: CaptureVersion, Time: &started})
+
+	// A capture that starts while connected still tells the replay which camera it is
+	if c.State() == Connected {
+		rec.write(CaptureRecord{
+			T:       rec.since(time.Now()),
+			Type:    CaptureState,
+			State:   Connected.String(),
+			Address: c.DeviceName(),
+		})
+	}
+
+	remove := c.OnEvent(func(ev Event) {
+		rec.handle(c, ev)
+	})
+	return func() error {
+		remove()
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return rec.err
+	}
+}
+
+// recorder encodes events as capture records.
+type recorder struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+	err   error
+}
+
+func (r *recorder) since(t time.Time) float64 {
+	return t.Sub(r.start).Seconds()
+}
+
+func (r *recorder) handle(c *Client, ev Event) {
+	rec := CaptureRecord{T: r.since(ev.Time)}
+	switch ev.Type {
+	case EventStateChanged:
+		rec.Type = CaptureState
+		rec.State = ev.State.String()
+		rec.Previous = ev.Previous.String()
+		if ev.State == Connected {
+			rec.Address = c.DeviceName()
+		}
+		if ev.Err != nil {
+			rec.Error = ev.Err.Error()
+		}
+	case EventDeviceFound:
+		rec.Type = CaptureAdvertisement
+		rec.Name = ev.Device.Name
+		rec.Address = ev.Device.AddressStr
+		rec.RSSI = ev.Device.RSSI
+	case EventCommandSent, EventCommandFailed:
+		// Commands refused before reaching the camera are not traffic
+		if errors.Is(ev.Err, ErrNotConnected) {
+			return
+		}
+		rec.Type = CaptureWrite
+		rec.Name = ev.Command.Name
+		rec.Bytes = hex.EncodeToString(ev.Command.Code)
+		rec.Latency = ev.Latency.Seconds()
+		if ev.Err != nil {
+			rec.Error = ev.Err.Error()
+		}
+	case EventNotification:
+		rec.Type = CaptureNotify
+		rec.Bytes = hex.EncodeToString(ev.Notification.Raw)
+	default:
+		return
+	}
+	r.write(rec)
+}
+
+func (r *recorder) write(rec CaptureRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(rec)
+}
+
+// Capture is a capture read back with ReadCapture.
+type Capture struct {
+	// Started is the wall clock time the capture started
+	Started time.Time
+	// Records holds every record after the header in order
+	Records []CaptureRecord
+}
+
+// ReadCapture parses a capture written by Client.Record.
+func ReadCapture(r io.Reader) (*Capture, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+
+	capture := &Capture{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("capture line %d: %w", line, err)
+		}
+
+		if rec.Type == CaptureHeader {
+			if rec.Version > CaptureVersion {
+				return nil, fmt.Errorf("capture line %d: unsupported version %d", line, rec.Version)
+			}
+			if rec.Time != nil {
+				capture.Started = *rec.Time
+			}
+			continue
+		}
+		if (rec.Type == CaptureWrite || rec.Type == CaptureNotify) && rec.Bytes != "" {
+			if _, err := rec.Frame(); err != nil {
+				return nil, fmt.Errorf("capture line %d: invalid bytes: %w", line, err)
+			}
+		}
+		capture.Records = append(capture.Records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return capture, nil
+}
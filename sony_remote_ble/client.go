@@ -2,8 +2,12 @@ package sony_remote_ble
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
 	"time"
 
 	"tinygo.org/x/bluetooth"
@@ -66,16 +70,54 @@ func (cs ConnectionState) String() string {
 //		log.Fatal(err)
 //	}
 type Client struct {
-	adapter     *bluetooth.Adapter
-	device      bluetooth.Device
-	service     bluetooth.DeviceService
-	char        bluetooth.DeviceCharacteristic
+	// mu guards state, deviceName and lastError, which are read by callers
+	// while scans and connections update them from other goroutines
+	mu          sync.Mutex
+	transport   Transport
+	link        Link
 	state       ConnectionState
 	deviceName  string
 	lastError   error
 	stopScan    chan bool
+	// commandDelay is the pause between commands in built-in sequences
+	commandDelay time.Duration
+	// profile refines the pauses of TakePhoto and Zoom
+	profile TimingProfile
+	// hasNotify is false for cameras without the notification characteristic
+	hasNotify bool
+	// recording is true while the camera reports that it is recording, and
+	// recordingReported once it has reported either state on this connection;
+	// guarded by mu
+	recording         bool
+	recordingReported bool
+	// events delivers client events to listeners registered with OnEvent
+	events eventHub
+	// logger receives structured records of the client's activity; it
+	// discards everything unless SetLogger was called
+	logger *slog.Logger
+
+	// linkMu serializes connecting, writes and closing the link, so that an
+	// idle disconnect never lands in the middle of a write. It guards the
+	// fields below; idle is read under mu as well.
+	linkMu sync.Mutex
+	// address is the connected camera, for reopening the link
+	address bluetooth.Address
+	// idleDisconnect, idleTimer and lastUse close the link once it is idle
+	idleDisconnect IdleDisconnect
+	idleTimer      *time.Timer
+	lastUse        time.Time
+	// idle is true while the link is closed for being idle
+	idle bool
+	// held holds the buttons pressed and not yet released, by down frame
+	held map[string]bool
 }
 
+// ErrNotConnected is returned when a command is sent while no camera is connected.
+var ErrNotConnected = errors.New("not connected to device")
+
+// ErrUnknownButton is returned by Press for button names without a "_down"/"_up" command pair.
+var ErrUnknownButton = errors.New("unknown button")
+
 // DeviceInfo contains information about a discovered Sony camera device.
 // This information is provided during device scanning and can be used to
 // identify and connect to specific cameras.
@@ -90,10 +132,24 @@ type DeviceInfo struct {
 	RSSI int16
 }
 
+// ParseAddress converts an address string, as found in DeviceInfo.AddressStr,
+// back into a platform-specific bluetooth address. On Linux this is a MAC address
+// and on macOS a UUID. Invalid strings yield the zero address.
+//
+// This allows connecting to a previously seen camera without scanning for it first:
+//
+//	err := client.Connect(sony_remote_ble.ParseAddress("AA:BB:CC:DD:EE:FF"))
+func ParseAddress(address string) bluetooth.Address {
+	var addr bluetooth.Address
+	addr.Set(address)
+	return addr
+}
+
 // NewClient creates a new Sony camera BLE client and initializes the Bluetooth adapter.
 // The client is ready to scan for devices and establish connections after creation.
 //
 // Returns an error if the Bluetooth adapter cannot be enabled or is not available.
+// Use NewClientWithAdapter to pick another adapter.
 //
 // Example:
 //
@@ -103,36 +159,145 @@ type DeviceInfo struct {
 //	}
 //	defer client.Disconnect()
 func NewClient() (*Client, error) {
-	adapter := bluetooth.DefaultAdapter
-	err := adapter.Enable()
-	if err != nil {
-		return nil, fmt.Errorf("failed to enable adapter: %w", err)
+	return NewClientWithAdapter("")
+}
+
+// NewClientWithTransport creates a client that reaches cameras through t
+// instead of the host's Bluetooth adapter, for example a ReplayTransport that
+// plays back a capture.
+//
+// Example:
+//
+//	capture, err := sony_remote_ble.ReadCapture(file)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	client := sony_remote_ble.NewClientWithTransport(sony_remote_ble.NewReplayTransport(capture))
+func NewClientWithTransport(t Transport) *Client {
+	c := &Client{
+		transport:    t,
+		state:        Disconnected,
+		stopScan:     make(chan bool, 1),
+		commandDelay: DefaultCommandDelay,
+		held:         make(map[string]bool),
 	}
+	c.SetLogger(nil)
+	return c
+}
 
-	return &Client{
-		adapter:  adapter,
-		state:    Disconnected,
-		stopScan: make(chan bool, 1),
-	}, nil
+// SetLogger directs the client's log records to logger. Scans, connection
+// phases and disconnects are logged at Info level, failures at Warn, and every
+// advertisement, command write and notification at Debug, so a debug logger
+// shows the protocol traffic. Passing nil discards the records again.
+//
+// Set the logger before using the client; it is not safe to change while
+// other goroutines use the client.
+//
+// Example:
+//
+//	client.SetLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+//		Level: slog.LevelDebug,
+//	})))
+func (c *Client) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	c.logger = logger
+	if t, ok := c.transport.(interface{ setLogger(*slog.Logger) }); ok {
+		t.setLogger(logger)
+	}
 }
 
 // State returns the current connection state of the client.
 func (c *Client) State() ConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.state
 }
 
+// Transport returns the transport the client reaches cameras through, so that
+// another client, for example one that keeps scanning, can share it.
+func (c *Client) Transport() Transport {
+	return c.transport
+}
+
 // DeviceName returns the name of the currently connected device.
 // Returns an empty string if not connected to any device.
 func (c *Client) DeviceName() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.deviceName
 }
 
+// CommandDelay returns the pause used between commands in built-in sequences
+// such as TakePhoto and Bulb.
+func (c *Client) CommandDelay() time.Duration {
+	return c.commandDelay
+}
+
+// SetCommandDelay changes the pause used between commands in built-in sequences.
+// Some camera bodies drop frames that arrive too quickly; increasing the delay
+// trades speed for reliability. The default is DefaultCommandDelay.
+func (c *Client) SetCommandDelay(delay time.Duration) {
+	c.commandDelay = delay
+}
+
+// TimingProfile returns the profile used by TakePhoto and Zoom.
+func (c *Client) TimingProfile() TimingProfile {
+	return c.profile
+}
+
+// SetTimingProfile changes the pauses TakePhoto and Zoom leave between
+// commands. Zero fields keep using the command delay. The default is the zero
+// profile; ProfileFor returns the built-in profile for a camera model.
+//
+// Example:
+//
+//	profile := sony_remote_ble.ProfileFor(client.DeviceName())
+//	profile.ReleaseGap = 150 * time.Millisecond
+//	profile.Adaptive = true
+//	client.SetTimingProfile(profile)
+func (c *Client) SetTimingProfile(profile TimingProfile) {
+	c.profile = profile
+}
+
 // LastError returns the last error that occurred during client operations.
 // Returns nil if no error has occurred or if the error has been cleared.
 func (c *Client) LastError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.lastError
 }
 
+// setState moves the client into a new state and emits EventStateChanged if it changed.
+func (c *Client) setState(state ConnectionState, err error) {
+	c.mu.Lock()
+	previous := c.state
+	c.state = state
+	c.mu.Unlock()
+
+	if previous != state {
+		c.emit(Event{Type: EventStateChanged, State: state, Previous: previous, Err: err})
+	}
+}
+
+// begin moves the client into a new state and clears the last error.
+func (c *Client) begin(state ConnectionState) {
+	c.mu.Lock()
+	c.lastError = nil
+	c.mu.Unlock()
+	c.setState(state, nil)
+}
+
+// fail records err as the last error, moves the client into the Error state and returns err.
+func (c *Client) fail(err error) error {
+	c.mu.Lock()
+	c.lastError = err
+	c.mu.Unlock()
+	c.setState(Error, err)
+	return err
+}
+
 // ScanForDevices starts scanning for nearby Sony cameras and sends discovered devices
 // to the provided channel. The scan runs asynchronously until stopped with StopScan()
 // or until the context is cancelled.
@@ -163,20 +328,39 @@ func (c *Client) LastError() error {
 //		fmt.Println("Scan timeout")
 //	}
 func (c *Client) ScanForDevices(ctx context.Context, deviceChan chan<- DeviceInfo) error {
-	c.state = Scanning
-	c.lastError = nil
+	// Discard a stop request left over from a previous scan that had already ended
+	select {
+	case <-c.stopScan:
+	default:
+	}
+	c.begin(Scanning)
+	c.logger.Info("scan started")
 
+	// The adapter scan only returns once stopped, so stop it when the context
+	// ends. Only the adapter is stopped: a StopScan that lost the race against
+	// the end of this scan would stop the next one.
+	scanDone := make(chan struct{})
 	go func() {
+		select {
+		case <-ctx.Done():
+			c.transport.StopScan()
+		case <-scanDone:
+		}
+	}()
+
+	go func() {
+		defer close(scanDone)
 		for {
 			select {
 			case <-c.stopScan:
 				return
 			case <-ctx.Done():
+				c.endScan()
 				return
 			default:
 			}
 
-			err := c.adapter.Scan(func(adapter *bluetooth.Adapter, result bluetooth.ScanResult) {
+			err := c.transport.Scan(func(device DeviceInfo) {
 				select {
 				case <-c.stopScan:
 					return
@@ -184,26 +368,26 @@ func (c *Client) ScanForDevices(ctx context.Context, deviceChan chan<- DeviceInf
 					return
 				default:
 					// Look for Sony cameras (they typically advertise with specific names)
-					name := result.LocalName()
-					if name == "" {
+					if device.Name == "" {
 						return
 					}
 
 					// Check if this might be a Sony camera
-					if containsSonyIdentifier(name) {
-						deviceChan <- DeviceInfo{
-							Name:       name,
-							Address:    result.Address,
-							AddressStr: result.Address.String(),
-							RSSI:       result.RSSI,
+					if containsSonyIdentifier(device.Name) {
+						c.logger.Debug("device found", "name", device.Name, "address", device.AddressStr, "rssi", device.RSSI)
+						c.emit(Event{Type: EventDeviceFound, Device: device})
+
+						select {
+						case deviceChan <- device:
+						case <-ctx.Done():
 						}
 					}
 				}
 			})
 
 			if err != nil {
-				c.lastError = err
-				c.state = Error
+				c.logger.Warn("scan failed", "error", err)
+				c.fail(err)
 				return
 			}
 			// If adapter.Scan() returns without error, restart it
@@ -221,10 +405,23 @@ func (c *Client) StopScan() {
 	case c.stopScan <- true:
 	default:
 	}
-	c.adapter.StopScan()
-	if c.state == Scanning {
+	c.transport.StopScan()
+	c.endScan()
+}
+
+// endScan returns the client from Scanning to Disconnected.
+func (c *Client) endScan() {
+	c.mu.Lock()
+	wasScanning := c.state == Scanning
+	if wasScanning {
 		c.state = Disconnected
 	}
+	c.mu.Unlock()
+
+	if wasScanning {
+		c.logger.Info("scan stopped")
+		c.emit(Event{Type: EventStateChanged, State: Disconnected, Previous: Scanning})
+	}
 }
 
 // Connect establishes a connection to a Sony camera using the provided Bluetooth address.
@@ -251,52 +448,43 @@ func (c *Client) StopScan() {
 //	}
 //	fmt.Println("Connected to camera successfully")
 func (c *Client) Connect(address bluetooth.Address) error {
-	c.state = Connecting
-	c.lastError = nil
-
-	// Connect to device
-	device, err := c.adapter.Connect(address, bluetooth.ConnectionParams{})
+	c.linkMu.Lock()
+	defer c.linkMu.Unlock()
+
+	c.mu.Lock()
+	c.idle = false
+	c.recording = false
+	c.recordingReported = false
+	c.mu.Unlock()
+	c.begin(Connecting)
+	logger := c.logger.With("address", address.String())
+
+	// The transport connects and discovers the remote control characteristics
+	logger.Info("connecting")
+	start := time.Now()
+	link, err := c.transport.Connect(address)
 	if err != nil {
-		c.lastError = fmt.Errorf("failed to connect: %w", err)
-		c.state = Error
-		return c.lastError
+		logger.Warn("connect failed", "error", err)
+		return c.fail(err)
 	}
-
-	c.device = device
-
-	// Discover services
-	services, err := device.DiscoverServices([]bluetooth.UUID{ServiceUUID()})
-	if err != nil {
-		c.lastError = fmt.Errorf("failed to discover services: %w", err)
-		c.state = Error
-		return c.lastError
-	}
-
-	if len(services) == 0 {
-		c.lastError = errors.New("Sony camera service not found")
-		c.state = Error
-		return c.lastError
-	}
-
-	c.service = services[0]
-
-	// Discover characteristics
-	chars, err := c.service.DiscoverCharacteristics([]bluetooth.UUID{CharacteristicUUID()})
-	if err != nil {
-		c.lastError = fmt.Errorf("failed to discover characteristics: %w", err)
-		c.state = Error
-		return c.lastError
-	}
-
-	if len(chars) == 0 {
-		c.lastError = errors.New("command characteristic not found")
-		c.state = Error
-		return c.lastError
+	c.link = link
+
+	// Notifications are optional; cameras without them can still be controlled
+	c.hasNotify = false
+	if err := link.EnableNotifications(c.handleNotification); err != nil {
+		logger.Debug("notifications unavailable", "error", err)
+	} else {
+		c.hasNotify = true
 	}
 
-	c.char = chars[0]
-	c.state = Connected
+	c.mu.Lock()
 	c.deviceName = address.String() // Could be enhanced to get actual device name
+	c.mu.Unlock()
+	c.address = address
+	clear(c.held)
+	logger.Info("connected", "notifications", c.hasNotify, "duration", time.Since(start))
+	c.setState(Connected, nil)
+	c.touch()
 
 	return nil
 }
@@ -314,19 +502,56 @@ func (c *Client) Connect(address bluetooth.Address) error {
 //		log.Printf("Disconnect error: %v", err)
 //	}
 func (c *Client) Disconnect() error {
-	if c.state == Connected {
-		err := c.device.Disconnect()
+	c.linkMu.Lock()
+	defer c.linkMu.Unlock()
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+	}
+
+	// A link closed for being idle is already gone
+	if c.State() == Connected && !c.Idle() {
+		if c.hasNotify {
+			c.link.EnableNotifications(nil)
+			c.hasNotify = false
+		}
+		err := c.link.Disconnect()
 		if err != nil {
-			c.lastError = err
-			c.state = Error
-			return err
+			c.logger.Warn("disconnect failed", "address", c.DeviceName(), "error", err)
+			return c.fail(err)
 		}
+		c.logger.Info("disconnected", "address", c.DeviceName())
 	}
-	c.state = Disconnected
+	c.mu.Lock()
 	c.deviceName = ""
+	c.idle = false
+	c.recording = false
+	c.recordingReported = false
+	c.mu.Unlock()
+	c.hasNotify = false
+	c.setState(Disconnected, nil)
 	return nil
 }
 
+// SupportsNotifications reports whether the connected camera exposes the
+// notification characteristic. Status notifications are delivered to
+// listeners registered with OnEvent as EventNotification events.
+func (c *Client) SupportsNotifications() bool {
+	return c.State() == Connected && c.hasNotify
+}
+
+// handleNotification decodes a frame from the notification characteristic and emits it.
+func (c *Client) handleNotification(data []byte) {
+	n := ParseNotification(data)
+	c.logger.Debug("notification", "kind", n.Kind.String(), "active", n.Active, "bytes", hex.EncodeToString(data))
+	if n.Kind == NotifyRecording {
+		c.mu.Lock()
+		c.recording = n.Active
+		c.recordingReported = true
+		c.mu.Unlock()
+	}
+	c.emit(Event{Type: EventNotification, Notification: n})
+}
+
 // SendCommand sends a low-level command to the connected Sony camera.
 // The client must be in Connected state for this method to succeed.
 //
@@ -336,7 +561,8 @@ func (c *Client) Disconnect() error {
 // Parameters:
 //   - cmd: The SonyCommand to send, containing both name and byte code
 //
-// Returns an error if not connected or if the command transmission fails.
+// Returns ErrNotConnected if not connected, or an error if the command transmission fails.
+// If the link was closed for being idle, it is reopened first; see SetIdleDisconnect.
 //
 // Example:
 //
@@ -354,16 +580,47 @@ func (c *Client) Disconnect() error {
 //	}
 //	err = client.SendCommand(customCmd)
 func (c *Client) SendCommand(cmd SonyCommand) error {
-	if c.state != Connected {
-		return errors.New("not connected to device")
+	c.linkMu.Lock()
+	defer c.linkMu.Unlock()
+
+	if c.State() != Connected {
+		c.logger.Warn("command failed", "command", cmd.Name, "bytes", hex.EncodeToString(cmd.Code), "error", ErrNotConnected)
+		c.emit(Event{Type: EventCommandFailed, Command: cmd, Err: ErrNotConnected})
+		return ErrNotConnected
 	}
 
-	_, err := c.char.WriteWithoutResponse(cmd.Code)
+	reconnect, err := c.wake()
 	if err != nil {
-		c.lastError = fmt.Errorf("failed to send command %s: %w", cmd.Name, err)
-		return c.lastError
+		err = fmt.Errorf("failed to send command %s: %w", cmd.Name, err)
+		c.logger.Warn("command failed", "command", cmd.Name, "bytes", hex.EncodeToString(cmd.Code), "reconnect", reconnect, "error", err)
+		c.emit(Event{Type: EventCommandFailed, Command: cmd, Reconnect: reconnect, Err: err})
+		return err
 	}
 
+	start := time.Now()
+	err = c.link.Write(cmd.Code)
+	latency := time.Since(start)
+	if err != nil {
+		err = fmt.Errorf("failed to send command %s: %w", cmd.Name, err)
+		c.mu.Lock()
+		c.lastError = err
+		c.mu.Unlock()
+		c.logger.Warn("command failed", "command", cmd.Name, "bytes", hex.EncodeToString(cmd.Code), "latency", latency, "error", err)
+		c.emit(Event{Type: EventCommandFailed, Command: cmd, Latency: latency, Reconnect: reconnect, Err: err})
+		return err
+	}
+
+	if down, pressed, ok := buttonFrame(cmd.Code); ok {
+		if pressed {
+			c.held[down] = true
+		} else {
+			delete(c.held, down)
+		}
+	}
+	c.touch()
+
+	c.logger.Debug("command sent", "command", cmd.Name, "bytes", hex.EncodeToString(cmd.Code), "latency", latency)
+	c.emit(Event{Type: EventCommandSent, Command: cmd, Latency: latency, Reconnect: reconnect})
 	return nil
 }
 
@@ -405,8 +662,11 @@ func (c *Client) SendCommandSequence(commands []SonyCommand, delay time.Duration
 // TakePhoto is a high-level convenience method that captures a photo using the camera.
 // This method sends the complete photo capture sequence: focus, capture, and release.
 //
-// It's equivalent to calling SendCommandSequence with TakePhotoSequence() and a 50ms delay.
-// The delay between commands ensures proper camera response timing.
+// The pauses between the commands come from the timing profile, and default
+// to the client's command delay (50ms unless changed with SetCommandDelay),
+// in which case it is equivalent to calling SendCommandSequence with
+// TakePhotoSequence() and that delay. With an adaptive profile the camera's
+// focus and shutter notifications end the pauses early.
 //
 // Returns an error if not connected or if any part of the photo sequence fails.
 //
@@ -419,7 +679,160 @@ func (c *Client) SendCommandSequence(commands []SonyCommand, delay time.Duration
 //		fmt.Println("Photo captured successfully!")
 //	}
 func (c *Client) TakePhoto() error {
-	return c.SendCommandSequence(TakePhotoSequence(), 50*time.Millisecond)
+	profile := c.profile.Resolve(c.commandDelay)
+
+	var n notices
+	if profile.Adaptive && c.SupportsNotifications() {
+		var remove func()
+		n, remove = c.watchNotices()
+		defer remove()
+	}
+
+	if err := c.SendCommand(Commands["focus_down"]); err != nil {
+		return err
+	}
+	pauseUntil(n.focus, profile.HalfPressSettle)
+	return c.fire(profile, n.shutter)
+}
+
+// fire completes a photo after the half press: it presses fully, holds for
+// the profile's hold or until shutter receives, and releases.
+func (c *Client) fire(profile TimingProfile, shutter <-chan struct{}) error {
+	steps := []struct {
+		cmd   SonyCommand
+		pause time.Duration
+		until <-chan struct{}
+	}{
+		{Commands["shutter_full_down"], profile.FullPressHold, shutter},
+		{Commands["shutter_full_up"], profile.ReleaseGap, nil},
+		{Commands["focus_up"], c.commandDelay, nil},
+	}
+	for _, step := range steps {
+		if err := c.SendCommand(step.cmd); err != nil {
+			return err
+		}
+		pauseUntil(step.until, step.pause)
+	}
+	return nil
+}
+
+// watchNotices signals n when the camera reports focus or the shutter, until
+// remove is called.
+func (c *Client) watchNotices() (n notices, remove func()) {
+	n = notices{focus: make(chan struct{}, 1), shutter: make(chan struct{}, 1)}
+	remove = c.OnEvent(func(ev Event) {
+		if ev.Type != EventNotification || !ev.Notification.Active {
+			return
+		}
+		switch ev.Notification.Kind {
+		case NotifyFocus:
+			signal(n.focus)
+		case NotifyShutter:
+			signal(n.shutter)
+		}
+	})
+	return n, remove
+}
+
+// Press presses and releases a camera button, holding it down for the given duration.
+// The button name is the command prefix shared by a "_down" and "_up" pair in the
+// Commands map, for example "c1", "zoom_in" or "shutter_full".
+//
+// Returns an error if the button is unknown, if not connected, or if either command fails.
+//
+// Example:
+//
+//	// Zoom in for half a second
+//	err := client.Press("zoom_in", 500*time.Millisecond)
+func (c *Client) Press(button string, hold time.Duration) error {
+	down, ok := Commands[button+"_down"]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownButton, button)
+	}
+	up, ok := Commands[button+"_up"]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownButton, button)
+	}
+
+	if err := c.SendCommand(down); err != nil {
+		return err
+	}
+	if hold > 0 {
+		time.Sleep(hold)
+	}
+	return c.SendCommand(up)
+}
+
+// Bulb takes a bulb exposure by holding the shutter open for the given duration.
+// The camera must be set to BULB mode for the exposure time to be honoured;
+// in other modes the camera takes a regular photo when the shutter is pressed.
+//
+// Example:
+//
+//	err := client.Bulb(30 * time.Second)
+//	if err != nil {
+//		log.Printf("Bulb exposure failed: %v", err)
+//	}
+func (c *Client) Bulb(exposure time.Duration) error {
+	if err := c.SendCommandSequence(BulbSequence(), c.commandDelay); err != nil {
+		return err
+	}
+	time.Sleep(exposure)
+	return c.SendCommandSequence(BulbReleaseSequence(), c.commandDelay)
+}
+
+// Zoom drives the lens zoom in or out at the given speed for the hold duration.
+// Speed is the raw speed byte sent with the zoom command; higher values zoom faster
+// and DefaultZoomSpeed matches the zoom commands in the Commands map. It returns
+// after the ZoomStop pause of the timing profile.
+//
+// Example:
+//
+//	// Zoom out slowly for two seconds
+//	err := client.Zoom(false, 0x10, 2*time.Second)
+func (c *Client) Zoom(in bool, speed byte, hold time.Duration) error {
+	release := Commands["zoom_out_up"]
+	if in {
+		release = Commands["zoom_in_up"]
+	}
+
+	if err := c.SendCommand(ZoomCommand(in, speed)); err != nil {
+		return err
+	}
+	if hold > 0 {
+		time.Sleep(hold)
+	}
+	if err := c.SendCommand(release); err != nil {
+		return err
+	}
+	if c.profile.ZoomStop > 0 {
+		time.Sleep(c.profile.ZoomStop)
+	}
+	return nil
+}
+
+// ModelFromName extracts the Sony model code, such as "ILCE-7M4" or "ZV-E10",
+// from an advertised device name. Cameras advertise their model code by default,
+// but users can rename them, so an empty string is returned when no code is found.
+func ModelFromName(name string) string {
+	modelPrefixes := []string{
+		"ILCE-", // Alpha mirrorless
+		"ILCA-", // Alpha A-mount
+		"ILME-", // Cinema Line
+		"DSC-",  // Cyber-shot
+		"ZV-",   // Vlog cameras
+		"NEX-",  // Older E-mount
+		"FX",    // Cinema Line short names (FX30, FX3)
+	}
+
+	for _, field := range strings.Fields(name) {
+		for _, prefix := range modelPrefixes {
+			if strings.HasPrefix(field, prefix) && len(field) > len(prefix) {
+				return field
+			}
+		}
+	}
+	return ""
 }
 
 // Helper function to identify Sony cameras
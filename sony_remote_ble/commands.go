@@ -35,7 +35,13 @@
 //	}
 package sony_remote_ble
 
-import "tinygo.org/x/bluetooth"
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+)
 
 const (
 	// SonyServiceUUID is the Bluetooth service UUID for Sony camera remote control.
@@ -45,6 +51,16 @@ const (
 	// CommandCharUUID is the characteristic UUID for sending commands to Sony cameras.
 	// Commands are written to this characteristic to trigger camera functions.
 	CommandCharUUID = "0000ff01-0000-1000-8000-00805f9b34fb"
+
+	// NotifyCharUUID is the characteristic UUID on which cameras send status notifications,
+	// such as focus acquired or recording started. Not every camera exposes it.
+	NotifyCharUUID = "0000ff02-0000-1000-8000-00805f9b34fb"
+
+	// DefaultCommandDelay is the pause between commands in built-in sequences such as TakePhoto.
+	DefaultCommandDelay = 50 * time.Millisecond
+
+	// DefaultZoomSpeed is the speed byte used by the zoom commands in the Commands map.
+	DefaultZoomSpeed byte = 0x20
 )
 
 // SonyCommand represents a camera command that can be sent to a Sony camera.
@@ -81,6 +97,7 @@ var Commands = map[string]SonyCommand{
 	// Record commands - Control video recording
 	"record_toggle": {"Toggle Record", []byte{0x01, 0x0e}}, // Start/stop video recording
 	"record_down":   {"Record Down", []byte{0x01, 0x0f}},   // Press record button
+	"record_up":     {"Record Up", []byte{0x01, 0x0e}},     // Release record button
 
 	// Zoom commands - Control lens zoom (if supported)
 	"zoom_in_down":  {"Zoom In Down", []byte{0x02, 0x6d, 0x20}},  // Start zooming in
@@ -123,6 +140,60 @@ func TakePhotoSequence() []SonyCommand {
 	}
 }
 
+// BulbSequence returns the commands that open the shutter for a bulb exposure.
+// The shutter stays open until the matching release commands are sent, so callers
+// must wait for the exposure time and then send the commands from BulbReleaseSequence.
+func BulbSequence() []SonyCommand {
+	return []SonyCommand{
+		Commands["shutter_half_down"],
+		Commands["shutter_full_down"],
+	}
+}
+
+// BulbReleaseSequence returns the commands that close the shutter after a bulb exposure.
+func BulbReleaseSequence() []SonyCommand {
+	return []SonyCommand{
+		Commands["shutter_full_up"],
+		Commands["shutter_half_up"],
+	}
+}
+
+// ZoomCommand returns the command that starts zooming in or out at the given speed.
+// The zoom continues until the matching "zoom_in_up" or "zoom_out_up" command is sent.
+func ZoomCommand(in bool, speed byte) SonyCommand {
+	if in {
+		return SonyCommand{"Zoom In Down", []byte{0x02, 0x6d, speed}}
+	}
+	return SonyCommand{"Zoom Out Down", []byte{0x02, 0x6b, speed}}
+}
+
+// CommandNames returns the identifiers of all entries in the Commands map in sorted order.
+func CommandNames() []string {
+	names := make([]string, 0, len(Commands))
+	for name := range Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Buttons returns the button names accepted by Client.Press in sorted order.
+// A button is any command prefix with both a "_down" and an "_up" entry in Commands.
+func Buttons() []string {
+	var buttons []string
+	for name := range Commands {
+		button, ok := strings.CutSuffix(name, "_down")
+		if !ok {
+			continue
+		}
+		if _, ok := Commands[button+"_up"]; ok {
+			buttons = append(buttons, button)
+		}
+	}
+	sort.Strings(buttons)
+	return buttons
+}
+
 // ServiceUUID returns the parsed Bluetooth service UUID for Sony camera remote control.
 // This UUID is used to identify and connect to the camera's remote control service.
 func ServiceUUID() bluetooth.UUID {
@@ -135,4 +206,10 @@ func ServiceUUID() bluetooth.UUID {
 func CharacteristicUUID() bluetooth.UUID {
 	uuid, _ := bluetooth.ParseUUID(CommandCharUUID)
 	return uuid
-}
\ No newline at end of file
+}
+
+// NotificationCharacteristicUUID returns the parsed Bluetooth characteristic UUID for camera notifications.
+func NotificationCharacteristicUUID() bluetooth.UUID {
+	uuid, _ := bluetooth.ParseUUID(NotifyCharUUID)
+	return uuid
+}
@@ -0,0 +1,124 @@
+package sony_remote_ble
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies what an Event describes.
+type EventType int
+
+const (
+	// EventStateChanged is emitted whenever the connection state changes
+	EventStateChanged EventType = iota
+	// EventDeviceFound is emitted for every Sony camera advertisement seen while scanning
+	EventDeviceFound
+	// EventCommandSent is emitted after a command was written to the camera
+	EventCommandSent
+	// EventCommandFailed is emitted when a command could not be written
+	EventCommandFailed
+	// EventNotification is emitted when the camera sends a status notification
+	EventNotification
+)
+
+// String returns the lower-case name of the event type, e.g. "state_changed".
+func (t EventType) String() string {
+	switch t {
+	case EventStateChanged:
+		return "state_changed"
+	case EventDeviceFound:
+		return "device_found"
+	case EventCommandSent:
+		return "command_sent"
+	case EventCommandFailed:
+		return "command_failed"
+	case EventNotification:
+		return "notification"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes something that happened on a Client. Only the fields
+// relevant to the event type are set.
+type Event struct {
+	// Type identifies the kind of event
+	Type EventType
+	// Time is when the event occurred
+	Time time.Time
+	// State is the new connection state (EventStateChanged)
+	State ConnectionState
+	// Previous is the state before the change (EventStateChanged)
+	Previous ConnectionState
+	// Device is the camera that was found (EventDeviceFound)
+	Device DeviceInfo
+	// Command is the command that was sent or failed (EventCommandSent, EventCommandFailed)
+	Command SonyCommand
+	// Latency is how long the write took (EventCommandSent, EventCommandFailed)
+	Latency time.Duration
+	// Reconnect is how long reopening a link closed for being idle delayed
+	// the write; zero when the link was open (EventCommandSent, EventCommandFailed)
+	Reconnect time.Duration
+	// Notification is the decoded camera notification (EventNotification)
+	Notification Notification
+	// Err is the error that caused a failure (EventStateChanged to Error, EventCommandFailed)
+	Err error
+}
+
+// eventHub fans events out to registered listeners.
+type eventHub struct {
+	mu        sync.Mutex
+	nextID    int
+	listeners map[int]func(Event)
+}
+
+// OnEvent registers fn to be called for every event emitted by the client and
+// returns a function that removes the listener again.
+//
+// Listeners are called synchronously from the goroutine that produced the event,
+// which may be a Bluetooth callback, so they must return quickly and must not
+// call back into the client. Hand work off to another goroutine if needed.
+//
+// Example:
+//
+//	remove := client.OnEvent(func(ev sony_remote_ble.Event) {
+//		if ev.Type == sony_remote_ble.EventStateChanged {
+//			log.Printf("state: %s -> %s", ev.Previous, ev.State)
+//		}
+//	})
+//	defer remove()
+func (c *Client) OnEvent(fn func(Event)) (remove func()) {
+	c.events.mu.Lock()
+	defer c.events.mu.Unlock()
+
+	if c.events.listeners == nil {
+		c.events.listeners = make(map[int]func(Event))
+	}
+	id := c.events.nextID
+	c.events.nextID++
+	c.events.listeners[id] = fn
+
+	return func() {
+		c.events.mu.Lock()
+		defer c.events.mu.Unlock()
+		delete(c.events.listeners, id)
+	}
+}
+
+// emit delivers an event to every listener.
+func (c *Client) emit(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	c.events.mu.Lock()
+	listeners := make([]func(Event), 0, len(c.events.listeners))
+	for _, fn := range c.events.listeners {
+		listeners = append(listeners, fn)
+	}
+	c.events.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(ev)
+	}
+}
@@ -0,0 +1,151 @@
+package sony_remote_ble
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrFocusTimeout is returned by TakePhotoWhenFocused when focus did not lock
+// and the policy does not fire anyway.
+var ErrFocusTimeout = errors.New("focus did not lock")
+
+// FocusPolicy decides what TakePhotoWhenFocused does when focus does not lock
+// within the timeout.
+type FocusPolicy struct {
+	// Retries is how many more times the half press is released and repeated
+	Retries int
+	// FireAnyway takes the photo when focus never locked instead of aborting
+	FireAnyway bool
+}
+
+// Policies for TakePhotoWhenFocused.
+var (
+	// FocusAbort gives up without taking a photo
+	FocusAbort = FocusPolicy{}
+	// FocusRetry half-presses up to twice more, then gives up
+	FocusRetry = FocusPolicy{Retries: 2}
+	// FocusFire takes the photo unfocused rather than miss the moment
+	FocusFire = FocusPolicy{FireAnyway: true}
+)
+
+// FocusOutcome tells which path TakePhotoWhenFocused took.
+type FocusOutcome int
+
+const (
+	// FocusLocked means focus locked and the photo was taken
+	FocusLocked FocusOutcome = iota
+	// FocusFiredUnlocked means focus never locked and the photo was taken anyway
+	FocusFiredUnlocked
+	// FocusAborted means focus never locked and no photo was taken
+	FocusAborted
+)
+
+// String returns a human-readable name for the outcome.
+func (o FocusOutcome) String() string {
+	switch o {
+	case FocusLocked:
+		return "locked"
+	case FocusFiredUnlocked:
+		return "fired unlocked"
+	case FocusAborted:
+		return "aborted"
+	default:
+		return "unknown"
+	}
+}
+
+// FocusResult reports how TakePhotoWhenFocused went.
+type FocusResult struct {
+	// Outcome is the path that was taken
+	Outcome FocusOutcome
+	// Attempts is how many half presses were made
+	Attempts int
+	// FocusLatency is the time from the last half press to focus locking;
+	// zero unless focus locked
+	FocusLatency time.Duration
+}
+
+// TakePhotoWhenFocused half-presses the shutter, waits up to timeout for the
+// camera to report focus and only then takes the photo, so that no frame is
+// taken out of focus. If focus does not lock, policy decides whether to
+// half-press again, fire anyway or give up with ErrFocusTimeout; the result
+// tells which path was taken. The rest of the photo follows the timing
+// profile, as in TakePhoto.
+//
+// The camera must send notifications; ErrNoNotifications is returned for
+// cameras that do not. If ctx ends while waiting for focus, the half press is
+// released and ctx's error returned.
+//
+// Example:
+//
+//	result, err := client.TakePhotoWhenFocused(ctx, 2*time.Second, sony_remote_ble.FocusRetry)
+//	if errors.Is(err, sony_remote_ble.ErrFocusTimeout) {
+//		log.Printf("no focus after %d attempts", result.Attempts)
+//	} else if err != nil {
+//		log.Fatal(err)
+//	}
+func (c *Client) TakePhotoWhenFocused(ctx context.Context, timeout time.Duration, policy FocusPolicy) (FocusResult, error) {
+	var result FocusResult
+	if c.State() != Connected {
+		return result, ErrNotConnected
+	}
+	if !c.SupportsNotifications() {
+		return result, ErrNoNotifications
+	}
+	profile := c.profile.Resolve(c.commandDelay)
+
+	n, remove := c.watchNotices()
+	defer remove()
+
+	locked := false
+	for !locked && result.Attempts <= policy.Retries {
+		if result.Attempts > 0 {
+			// Let the camera give up on the last attempt before trying again
+			if err := c.SendCommand(Commands["focus_up"]); err != nil {
+				return result, err
+			}
+			if err := sleepContext(ctx, c.commandDelay); err != nil {
+				return result, err
+			}
+		}
+		n.drain()
+
+		result.Attempts++
+		start := time.Now()
+		if err := c.SendCommand(Commands["focus_down"]); err != nil {
+			return result, err
+		}
+		timer := time.NewTimer(timeout)
+		select {
+		case <-n.focus:
+			locked = true
+			result.FocusLatency = time.Since(start)
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			c.SendCommand(Commands["focus_up"])
+			return result, ctx.Err()
+		}
+		timer.Stop()
+	}
+
+	switch {
+	case locked:
+		result.Outcome = FocusLocked
+	case policy.FireAnyway:
+		result.Outcome = FocusFiredUnlocked
+	default:
+		result.Outcome = FocusAborted
+		if err := c.SendCommand(Commands["focus_up"]); err != nil {
+			return result, err
+		}
+		return result, ErrFocusTimeout
+	}
+
+	var shutter <-chan struct{}
+	if profile.Adaptive {
+		shutter = n.shutter
+	}
+	return result, c.fire(profile, shutter)
+}
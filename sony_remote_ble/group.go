@@ -0,0 +1,401 @@
+package sony_remote_ble
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// DefaultArmDelay is how long CameraGroup.TakePhoto waits between the
+// half-press that arms every camera and the full press that fires them.
+const DefaultArmDelay = 300 * time.Millisecond
+
+// ErrDuplicateMember is returned by CameraGroup.Add for a name already in the group.
+var ErrDuplicateMember = errors.New("camera already in group")
+
+// GroupCamera is what a CameraGroup needs from each member. Every Camera is a
+// GroupCamera.
+type GroupCamera interface {
+	State() ConnectionState
+	Connect(address bluetooth.Address) error
+	Disconnect() error
+	SendCommand(cmd SonyCommand) error
+}
+
+// CameraGroup fires several cameras together, for multi-angle and volumetric
+// captures. Each member is a separate Camera with its own connection, so the
+// members can be reached through different adapters or transports.
+//
+// Commands are written to all members at once: one goroutine per member waits
+// at a common start signal and writes as soon as it is released. Every
+// broadcast returns a GroupResult telling when the command reached each camera
+// and which cameras missed it.
+//
+// Example:
+//
+//	group := sony_remote_ble.NewCameraGroup()
+//	group.Add("left", left, leftAddress)
+//	group.Add("right", right, rightAddress)
+//	if err := group.Connect(); err != nil {
+//		log.Fatal(err)
+//	}
+//	defer group.Disconnect()
+//
+//	result, err := group.TakePhoto()
+//	if err != nil {
+//		log.Printf("missed: %v", result.Failed())
+//	}
+//	log.Printf("skew: %v", result.Skew())
+type CameraGroup struct {
+	mu           sync.Mutex
+	members      []groupMember
+	armDelay     time.Duration
+	commandDelay time.Duration
+}
+
+type groupMember struct {
+	name    string
+	camera  GroupCamera
+	address bluetooth.Address
+	// latency is the calibrated time from starting a write to the exposure
+	latency time.Duration
+}
+
+// MemberResult reports how a broadcast command reached one camera.
+type MemberResult struct {
+	// Name identifies the camera in the group
+	Name string
+	// Sent is when the write to this camera started
+	Sent time.Time
+	// Latency is how long the write took, including any Reconnect
+	Latency time.Duration
+	// Reconnect is how long reopening the camera's link delayed the write, if
+	// the link was closed for being idle; see Client.SetIdleDisconnect
+	Reconnect time.Duration
+	// Delay is how long the write was held back to make up for faster cameras,
+	// see SetLatency
+	Delay time.Duration
+	// Skew is how much later this camera acted on the command than the
+	// earliest camera of the broadcast; zero for failed writes. A camera acts
+	// when its write completes, or for cameras with a calibrated latency, that
+	// latency after its write started.
+	Skew time.Duration
+	// Err is the error the write failed with, or nil
+	Err error
+}
+
+// GroupResult reports how a broadcast command reached each camera of a group.
+type GroupResult struct {
+	// Command is the name of the command that was broadcast
+	Command string
+	// Start is when the members were released to write
+	Start time.Time
+	// Members holds one result per camera in the order they were added
+	Members []MemberResult
+}
+
+// Skew returns the spread between the earliest and the latest camera to act
+// on the command.
+func (r GroupResult) Skew() time.Duration {
+	var skew time.Duration
+	for _, m := range r.Members {
+		if m.Err == nil {
+			skew = max(skew, m.Skew)
+		}
+	}
+	return skew
+}
+
+// Failed returns the names of the cameras whose write failed.
+func (r GroupResult) Failed() []string {
+	var names []string
+	for _, m := range r.Members {
+		if m.Err != nil {
+			names = append(names, m.Name)
+		}
+	}
+	return names
+}
+
+// Err joins the errors of the cameras whose write failed, each prefixed with
+// the camera's name, or returns nil if every write succeeded.
+func (r GroupResult) Err() error {
+	var errs []error
+	for _, m := range r.Members {
+		if m.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.Name, m.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewCameraGroup creates an empty group with DefaultArmDelay and DefaultCommandDelay.
+func NewCameraGroup() *CameraGroup {
+	return &CameraGroup{
+		armDelay:     DefaultArmDelay,
+		commandDelay: DefaultCommandDelay,
+	}
+}
+
+// Add adds a camera to the group under name. Connect connects it to address;
+// cameras that are already connected can be added with the zero address.
+func (g *CameraGroup) Add(name string, camera GroupCamera, address bluetooth.Address) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, m := range g.members {
+		if m.name == name {
+			return fmt.Errorf("%w: %s", ErrDuplicateMember, name)
+		}
+	}
+	g.members = append(g.members, groupMember{name: name, camera: camera, address: address})
+	return nil
+}
+
+// Remove removes the named camera from the group without disconnecting it.
+func (g *CameraGroup) Remove(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, m := range g.members {
+		if m.name == name {
+			g.members = append(g.members[:i:i], g.members[i+1:]...)
+			return
+		}
+	}
+}
+
+// Names returns the names of the cameras in the order they were added.
+func (g *CameraGroup) Names() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	names := make([]string, len(g.members))
+	for i, m := range g.members {
+		names[i] = m.name
+	}
+	return names
+}
+
+// Camera returns the named camera.
+func (g *CameraGroup) Camera(name string) (GroupCamera, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, m := range g.members {
+		if m.name == name {
+			return m.camera, true
+		}
+	}
+	return nil, false
+}
+
+// SetLatency records the calibrated latency of the named camera, the time
+// from sending a command to the exposure as measured by Calibrate. Every
+// broadcast then holds the writes of the faster cameras back so that the
+// exposures, rather than the writes, line up.
+func (g *CameraGroup) SetLatency(name string, latency time.Duration) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i := range g.members {
+		if g.members[i].name == name {
+			g.members[i].latency = latency
+			return nil
+		}
+	}
+	return fmt.Errorf("no camera %q in group", name)
+}
+
+// SetArmDelay changes how long TakePhoto waits between arming and firing.
+// Give the slowest body enough time to acquire focus; the default is DefaultArmDelay.
+func (g *CameraGroup) SetArmDelay(delay time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.armDelay = delay
+}
+
+// SetCommandDelay changes the pause between the steps of TakePhoto and
+// ToggleRecord after the cameras fired. The default is DefaultCommandDelay.
+func (g *CameraGroup) SetCommandDelay(delay time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.commandDelay = delay
+}
+
+// snapshot returns the members and delays for one operation.
+func (g *CameraGroup) snapshot() ([]groupMember, time.Duration, time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]groupMember(nil), g.members...), g.armDelay, g.commandDelay
+}
+
+// Connect connects every camera that is not connected yet, all at once, and
+// returns the joined errors of the cameras that could not be connected.
+func (g *CameraGroup) Connect() error {
+	members, _, _ := g.snapshot()
+	return g.each(members, func(m groupMember) error {
+		if m.camera.State() == Connected {
+			return nil
+		}
+		return m.camera.Connect(m.address)
+	})
+}
+
+// Disconnect disconnects every camera and returns the joined errors.
+func (g *CameraGroup) Disconnect() error {
+	members, _, _ := g.snapshot()
+	return g.each(members, func(m groupMember) error {
+		return m.camera.Disconnect()
+	})
+}
+
+// each runs fn for every member concurrently and joins the errors.
+func (g *CameraGroup) each(members []groupMember, fn func(groupMember) error) error {
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, m := range members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(m); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", m.name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Broadcast writes cmd to every camera as simultaneously as possible.
+func (g *CameraGroup) Broadcast(cmd SonyCommand) GroupResult {
+	members, _, _ := g.snapshot()
+	return broadcast(members, cmd)
+}
+
+// broadcast releases one goroutine per member at the same instant, so the
+// writes are only spread by scheduling and the transports themselves.
+func broadcast(members []groupMember, cmd SonyCommand) GroupResult {
+	result := GroupResult{Command: cmd.Name, Members: make([]MemberResult, len(members))}
+	acted := make([]time.Time, len(members))
+
+	var slowest time.Duration
+	for _, m := range members {
+		slowest = max(slowest, m.latency)
+	}
+
+	var ready, finished sync.WaitGroup
+	start := make(chan struct{})
+	for i, m := range members {
+		ready.Add(1)
+		finished.Add(1)
+		go func() {
+			defer finished.Done()
+			ready.Done()
+			<-start
+			delay := slowest - m.latency
+			if delay > 0 {
+				time.Sleep(delay)
+			}
+			var reconnect time.Duration
+			remove := func() {}
+			if source, ok := m.camera.(interface {
+				OnEvent(fn func(Event)) (remove func())
+			}); ok {
+				remove = source.OnEvent(func(ev Event) {
+					if ev.Type == EventCommandSent || ev.Type == EventCommandFailed {
+						reconnect = ev.Reconnect
+					}
+				})
+			}
+			sent := time.Now()
+			err := m.camera.SendCommand(cmd)
+			done := time.Now()
+			remove()
+			acted[i] = done
+			if m.latency > 0 {
+				acted[i] = sent.Add(m.latency)
+			}
+			result.Members[i] = MemberResult{Name: m.name, Sent: sent, Latency: done.Sub(sent), Reconnect: reconnect, Delay: delay, Err: err}
+		}()
+	}
+	ready.Wait()
+	result.Start = time.Now()
+	close(start)
+	finished.Wait()
+
+	var first time.Time
+	for i, m := range result.Members {
+		if m.Err == nil && (first.IsZero() || acted[i].Before(first)) {
+			first = acted[i]
+		}
+	}
+	for i := range result.Members {
+		if result.Members[i].Err == nil {
+			result.Members[i].Skew = acted[i].Sub(first)
+		}
+	}
+	return result
+}
+
+// Arm half-presses the shutter on every camera so that they focus and are
+// ready to fire. Release the half-press with Broadcast of "shutter_half_up"
+// if the group is not fired.
+func (g *CameraGroup) Arm() GroupResult {
+	return g.Broadcast(Commands["shutter_half_down"])
+}
+
+// TakePhoto fires every camera together: it arms them with a parallel
+// half-press, waits the arm delay, sends the full press to all cameras at
+// once and then releases the shutters.
+//
+// The returned result describes the full press, which is the moment the
+// photos are taken. A camera that failed to arm is still fired. The error
+// joins every failure of every step, prefixed with the camera's name.
+//
+// Example:
+//
+//	result, err := group.TakePhoto()
+//	for _, m := range result.Members {
+//		fmt.Printf("%s: +%v %v\n", m.Name, m.Skew, m.Err)
+//	}
+func (g *CameraGroup) TakePhoto() (GroupResult, error) {
+	members, armDelay, delay := g.snapshot()
+	return fire(members, []SonyCommand{Commands["shutter_half_down"]}, armDelay,
+		Commands["shutter_full_down"], delay,
+		[]SonyCommand{Commands["shutter_full_up"], Commands["shutter_half_up"]})
+}
+
+// ToggleRecord starts or stops video recording on every camera together.
+// The returned result describes the press of the record button.
+func (g *CameraGroup) ToggleRecord() (GroupResult, error) {
+	members, _, delay := g.snapshot()
+	return fire(members, nil, 0, Commands["record_down"], delay, []SonyCommand{Commands["record_up"]})
+}
+
+// fire broadcasts the arming commands, waits armDelay, broadcasts trigger
+// and then the release commands with delay between them.
+func fire(members []groupMember, arm []SonyCommand, armDelay time.Duration, trigger SonyCommand, delay time.Duration, release []SonyCommand) (GroupResult, error) {
+	var steps []GroupResult
+	for _, cmd := range arm {
+		steps = append(steps, broadcast(members, cmd))
+	}
+	if len(arm) > 0 && armDelay > 0 {
+		time.Sleep(armDelay)
+	}
+
+	result := broadcast(members, trigger)
+	steps = append(steps, result)
+	for _, cmd := range release {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		steps = append(steps, broadcast(members, cmd))
+	}
+
+	var errs []error
+	for _, step := range steps {
+		if err := step.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", step.Command, err))
+		}
+	}
+	return result, errors.Join(errs...)
+}
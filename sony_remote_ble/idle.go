@@ -0,0 +1,164 @@
+package sony_remote_ble
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultReconnectTimeout bounds how long a command waits for an idle link to
+// reopen when no reconnect timeout is configured.
+const DefaultReconnectTimeout = 10 * time.Second
+
+// ErrReconnectTimeout is returned by commands when the link closed for being
+// idle does not reopen within the reconnect timeout.
+var ErrReconnectTimeout = errors.New("reconnect timed out")
+
+// IdleDisconnect configures SetIdleDisconnect.
+type IdleDisconnect struct {
+	// After is how long the link may go without a command before it is
+	// closed; zero keeps it open
+	After time.Duration
+	// ReconnectTimeout bounds how long a command waits for the link to reopen
+	// (DefaultReconnectTimeout)
+	ReconnectTimeout time.Duration
+}
+
+// SetIdleDisconnect makes the client close the Bluetooth link after it went
+// idle.After without a command, which saves the battery of cameras left on
+// for hours. The client remembers the camera and stays Connected, and the
+// next command reopens the link, rediscovers the remote control service and
+// then sends, so callers need not notice. The time spent reopening is
+// reported as Event.Reconnect; if the camera cannot be reached within the
+// reconnect timeout the command fails and the client moves to Error.
+//
+// The link is kept open while a button is held, for example during a bulb
+// exposure. Notifications are not delivered while it is closed. The setting
+// applies to the current and later connections; the zero value disables it.
+//
+// Example:
+//
+//	client.SetIdleDisconnect(sony_remote_ble.IdleDisconnect{After: 5 * time.Minute})
+func (c *Client) SetIdleDisconnect(idle IdleDisconnect) {
+	if idle.ReconnectTimeout <= 0 {
+		idle.ReconnectTimeout = DefaultReconnectTimeout
+	}
+	c.linkMu.Lock()
+	defer c.linkMu.Unlock()
+	c.idleDisconnect = idle
+	c.touch()
+}
+
+// Idle reports whether the client closed the link for being idle and will
+// reopen it with the next command.
+func (c *Client) Idle() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.idle
+}
+
+// touch restarts the idle countdown after the link was used. The caller holds c.linkMu.
+func (c *Client) touch() {
+	c.lastUse = time.Now()
+	if c.idleDisconnect.After <= 0 || c.State() != Connected {
+		if c.idleTimer != nil {
+			c.idleTimer.Stop()
+		}
+		return
+	}
+	if c.idleTimer == nil {
+		c.idleTimer = time.AfterFunc(c.idleDisconnect.After, c.closeIdle)
+		return
+	}
+	c.idleTimer.Reset(c.idleDisconnect.After)
+}
+
+// closeIdle closes the link if it is still idle, and otherwise waits for the
+// rest of the idle time again.
+func (c *Client) closeIdle() {
+	c.linkMu.Lock()
+	defer c.linkMu.Unlock()
+
+	after := c.idleDisconnect.After
+	if after <= 0 || c.State() != Connected || c.Idle() {
+		return
+	}
+	if len(c.held) > 0 {
+		c.idleTimer.Reset(after)
+		return
+	}
+	if remaining := after - time.Since(c.lastUse); remaining > 0 {
+		c.idleTimer.Reset(remaining)
+		return
+	}
+
+	logger := c.logger.With("address", c.address.String())
+	if c.hasNotify {
+		c.link.EnableNotifications(nil)
+	}
+	if err := c.link.Disconnect(); err != nil {
+		// The link is no use either way; the next command opens a new one
+		logger.Warn("idle disconnect failed", "error", err)
+	}
+	c.link = nil
+	c.mu.Lock()
+	c.idle = true
+	c.mu.Unlock()
+	logger.Info("disconnected while idle", "idle", after)
+}
+
+// wake reopens the link if it was closed for being idle, and returns how long
+// that took. The caller holds c.linkMu.
+func (c *Client) wake() (time.Duration, error) {
+	if !c.Idle() {
+		return 0, nil
+	}
+	logger := c.logger.With("address", c.address.String())
+	logger.Info("reconnecting")
+	start := time.Now()
+
+	type opened struct {
+		link Link
+		err  error
+	}
+	done := make(chan opened, 1)
+	address := c.address
+	go func() {
+		link, err := c.transport.Connect(address)
+		done <- opened{link, err}
+	}()
+
+	timer := time.NewTimer(c.idleDisconnect.ReconnectTimeout)
+	defer timer.Stop()
+	var result opened
+	select {
+	case result = <-done:
+	case <-timer.C:
+		// Close the link should it still open, since nothing will use it
+		go func() {
+			if late := <-done; late.err == nil {
+				late.link.Disconnect()
+			}
+		}()
+		result.err = fmt.Errorf("%w after %s", ErrReconnectTimeout, c.idleDisconnect.ReconnectTimeout)
+	}
+	elapsed := time.Since(start)
+
+	c.mu.Lock()
+	c.idle = false
+	c.mu.Unlock()
+	if result.err != nil {
+		logger.Warn("reconnect failed", "duration", elapsed, "error", result.err)
+		return elapsed, c.fail(result.err)
+	}
+
+	c.link = result.link
+	if c.hasNotify {
+		if err := c.link.EnableNotifications(c.handleNotification); err != nil {
+			logger.Debug("notifications unavailable", "error", err)
+			c.hasNotify = false
+		}
+	}
+	logger.Info("reconnected", "duration", elapsed)
+	return elapsed, nil
+}
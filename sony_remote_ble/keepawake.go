@@ -0,0 +1,305 @@
+package sony_remote_ble
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultKeepAwakeInterval is the idle time after which KeepAwake signals the
+// camera when no interval is configured. Bodies set to power off sooner need a
+// shorter interval.
+const DefaultKeepAwakeInterval = 30 * time.Second
+
+// KeepAwakeSignal is what KeepAwake sends to keep an idle camera awake.
+type KeepAwakeSignal int
+
+const (
+	// KeepAwakeHalfPress briefly half-presses and releases the shutter. It
+	// counts as activity like any button press, but may start autofocus.
+	KeepAwakeHalfPress KeepAwakeSignal = iota
+	// KeepAwakeRelease sends a lone half-press release, which presses nothing.
+	// It is gentler, but some bodies may not count it as activity.
+	KeepAwakeRelease
+)
+
+// String returns the name of the signal as used in configuration files.
+func (s KeepAwakeSignal) String() string {
+	switch s {
+	case KeepAwakeHalfPress:
+		return "half_press"
+	case KeepAwakeRelease:
+		return "release"
+	default:
+		return "unknown"
+	}
+}
+
+// commands returns the frames of the signal.
+func (s KeepAwakeSignal) commands() []SonyCommand {
+	if s == KeepAwakeRelease {
+		return []SonyCommand{Commands["shutter_half_up"]}
+	}
+	return []SonyCommand{Commands["shutter_half_down"], Commands["shutter_half_up"]}
+}
+
+// KeepAwakeOptions configures a KeepAwake.
+type KeepAwakeOptions struct {
+	// Interval is how long the camera may go without a command before it is
+	// signalled (DefaultKeepAwakeInterval)
+	Interval time.Duration
+	// Signal is what is sent
+	Signal KeepAwakeSignal
+	// Exclusive, if set, runs each signal with exclusive use of the camera, so
+	// that it never lands in the middle of another goroutine's sequence. An
+	// error from Exclusive, for example because the camera is in use, skips
+	// the signal until the next interval.
+	Exclusive func(send func() error) error
+}
+
+// KeepAwakeStatus describes a KeepAwake.
+type KeepAwakeStatus struct {
+	// Running is true while Run is active
+	Running bool
+	// Paused tells why no signal is due, such as "recording"; empty when signals are due
+	Paused string
+	// Next is when the next signal is due if nothing else is sent
+	Next time.Time
+	// LastSignal is when the last signal was sent; zero if none was
+	LastSignal time.Time
+	// Signals counts the signals sent
+	Signals int
+	// Err is the error of the last signal, or nil
+	Err error
+}
+
+// KeepAwake stops a camera from powering off while idle, which would drop the
+// Bluetooth link between widely spaced frames or during long pauses on set.
+// Whenever no command was sent for the interval, it sends a harmless signal.
+// It stays quiet while a button is held, for example during a sequence or a
+// bulb exposure, and while the camera is recording.
+//
+// Recording is followed from the camera's notifications. For cameras that
+// send none, KeepAwake counts the record presses sent through cam instead, so
+// a recording started on the camera body goes unnoticed.
+type KeepAwake struct {
+	cam  Camera
+	opts KeepAwakeOptions
+
+	mu           sync.Mutex
+	running      bool
+	lastActivity time.Time
+	// held holds the buttons pressed and not yet released, by down frame
+	held       map[string]bool
+	recording  bool
+	lastSignal time.Time
+	signals    int
+	err        error
+}
+
+// NewKeepAwake creates a keep-awake for cam. Call Run to start it.
+//
+// Example:
+//
+//	keepAwake := sony_remote_ble.NewKeepAwake(client, sony_remote_ble.KeepAwakeOptions{
+//		Interval: time.Minute,
+//	})
+//	go keepAwake.Run(ctx)
+func NewKeepAwake(cam Camera, opts KeepAwakeOptions) *KeepAwake {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultKeepAwakeInterval
+	}
+	return &KeepAwake{
+		cam:  cam,
+		opts: opts,
+		held: make(map[string]bool),
+	}
+}
+
+// Run signals the camera whenever it is idle until ctx ends. Signals are only
+// sent while the camera is connected.
+func (k *KeepAwake) Run(ctx context.Context) error {
+	k.mu.Lock()
+	k.running = true
+	k.lastActivity = time.Now()
+	k.mu.Unlock()
+	defer func() {
+		k.mu.Lock()
+		k.running = false
+		k.mu.Unlock()
+	}()
+
+	remove := k.cam.OnEvent(k.observe)
+	defer remove()
+
+	for {
+		k.mu.Lock()
+		due := k.lastActivity.Add(k.opts.Interval)
+		k.mu.Unlock()
+
+		if wait := time.Until(due); wait > 0 {
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil
+			}
+			continue
+		}
+		k.signal()
+	}
+}
+
+// Status returns the current status.
+func (k *KeepAwake) Status() KeepAwakeStatus {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return KeepAwakeStatus{
+		Running:    k.running,
+		Paused:     k.pausedLocked(),
+		Next:       k.lastActivity.Add(k.opts.Interval),
+		LastSignal: k.lastSignal,
+		Signals:    k.signals,
+		Err:        k.err,
+	}
+}
+
+// pausedLocked tells why no signal should be sent. The caller holds k.mu.
+func (k *KeepAwake) pausedLocked() string {
+	switch {
+	case !k.running:
+		return "stopped"
+	case k.cam.State() != Connected:
+		return "not connected"
+	case k.recording:
+		return "recording"
+	case len(k.held) > 0:
+		return "button held"
+	}
+	return ""
+}
+
+// signal sends the signal unless the camera is paused, and starts the next interval.
+func (k *KeepAwake) signal() {
+	var err error
+	send := func() error {
+		k.mu.Lock()
+		paused := k.pausedLocked()
+		k.mu.Unlock()
+		if paused != "" {
+			return nil
+		}
+
+		for i, cmd := range k.opts.Signal.commands() {
+			if i > 0 {
+				time.Sleep(k.cam.CommandDelay())
+			}
+			if err = k.cam.SendCommand(cmd); err != nil {
+				return err
+			}
+		}
+		k.mu.Lock()
+		k.lastSignal = time.Now()
+		k.signals++
+		k.mu.Unlock()
+		return nil
+	}
+
+	// A signal that Exclusive skips leaves err unset
+	if k.opts.Exclusive != nil {
+		k.opts.Exclusive(send)
+	} else {
+		send()
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.err = err
+	// The frames of the signal usually did this already
+	k.lastActivity = time.Now()
+}
+
+// observe tracks commands, held buttons and recording from the camera's events.
+func (k *KeepAwake) observe(ev Event) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	switch ev.Type {
+	case EventCommandSent:
+		k.lastActivity = ev.Time
+		if !k.cam.SupportsNotifications() && k.togglesRecording(ev.Command.Code) {
+			k.recording = !k.recording
+		}
+		if down, pressed, ok := buttonFrame(ev.Command.Code); ok {
+			if pressed {
+				k.held[down] = true
+			} else {
+				delete(k.held, down)
+			}
+		}
+	case EventNotification:
+		if ev.Notification.Kind == NotifyRecording {
+			k.recording = ev.Notification.Active
+		}
+	case EventStateChanged:
+		// A new connection starts with every button up
+		if ev.State == Connected {
+			clear(k.held)
+			k.recording = false
+			k.lastActivity = ev.Time
+		}
+	}
+}
+
+// togglesRecording tells whether code starts or stops a recording: a press of
+// the record button, or a lone record toggle. The toggle shares its frame with
+// the release of the record button, which does not count. The caller holds k.mu.
+func (k *KeepAwake) togglesRecording(code []byte) bool {
+	down := frameKey(Commands["record_down"].Code)
+	switch frameKey(code) {
+	case down:
+		return true
+	case frameKey(Commands["record_toggle"].Code):
+		return !k.held[down]
+	}
+	return false
+}
+
+// buttonReleases maps the frame of every button release to the frame of its
+// press, and buttonPresses holds the press frames. Zoom frames are keyed
+// without their speed byte.
+var buttonReleases, buttonPresses = func() (map[string]string, map[string]bool) {
+	releases, presses := make(map[string]string), make(map[string]bool)
+	for name, cmd := range Commands {
+		button, ok := strings.CutSuffix(name, "_down")
+		if !ok {
+			continue
+		}
+		up, ok := Commands[button+"_up"]
+		if !ok {
+			continue
+		}
+		down := frameKey(cmd.Code)
+		presses[down] = true
+		releases[frameKey(up.Code)] = down
+	}
+	return releases, presses
+}()
+
+// buttonFrame tells whether code presses or releases a button, and returns
+// the press frame that identifies the button.
+func buttonFrame(code []byte) (down string, pressed, ok bool) {
+	key := frameKey(code)
+	if buttonPresses[key] {
+		return key, true, true
+	}
+	if down, ok := buttonReleases[key]; ok {
+		return down, false, true
+	}
+	return "", false, false
+}
+
+// frameKey identifies a command frame by its first two bytes, leaving out
+// parameters such as the zoom speed.
+func frameKey(code []byte) string {
+	return hex.EncodeToString(code[:min(len(code), 2)])
+}
@@ -0,0 +1,82 @@
+package sony_remote_ble_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/sonyremotetest"
+)
+
+// runKeepAwake connects cam and runs a keep-awake on it until the test ends.
+func runKeepAwake(t *testing.T, cam *sonyremotetest.Camera) *sony_remote_ble.KeepAwake {
+	t.Helper()
+	cam.SkipSleeps()
+	if err := cam.Connect(sonyremotetest.Address); err != nil {
+		t.Fatal(err)
+	}
+	keepAwake := sony_remote_ble.NewKeepAwake(cam, sony_remote_ble.KeepAwakeOptions{Interval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		keepAwake.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	// Run listens for events right after it reports running
+	for !keepAwake.Status().Running {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	return keepAwake
+}
+
+func TestKeepAwakePausesForRecordPressesWithoutNotifications(t *testing.T) {
+	cam := sonyremotetest.New()
+	cam.SetNotifications(false)
+	keepAwake := runKeepAwake(t, cam)
+
+	steps := []struct {
+		send   func() error
+		paused string
+	}{
+		{func() error { return cam.Press("record", 0) }, "recording"},
+		{func() error { return cam.Press("record", 0) }, ""},
+		{func() error { return cam.SendCommand(sony_remote_ble.Commands["record_toggle"]) }, "recording"},
+		{func() error { return cam.SendCommand(sony_remote_ble.Commands["record_toggle"]) }, ""},
+	}
+	for i, step := range steps {
+		if err := step.send(); err != nil {
+			t.Fatal(err)
+		}
+		if paused := keepAwake.Status().Paused; paused != step.paused {
+			t.Fatalf("step %d: paused %q, want %q", i, paused, step.paused)
+		}
+	}
+}
+
+func TestKeepAwakeFollowsNotificationsWhenAvailable(t *testing.T) {
+	cam := sonyremotetest.New()
+	keepAwake := runKeepAwake(t, cam)
+
+	// Without a notification the press may not have started anything
+	if err := cam.Press("record", 0); err != nil {
+		t.Fatal(err)
+	}
+	if paused := keepAwake.Status().Paused; paused != "" {
+		t.Fatalf("paused %q after a press the camera did not confirm", paused)
+	}
+
+	cam.Notify([]byte{0x02, 0xd5, 0x20})
+	if paused := keepAwake.Status().Paused; paused != "recording" {
+		t.Fatalf("paused %q, want recording", paused)
+	}
+	cam.Notify([]byte{0x02, 0xd5, 0x00})
+	if paused := keepAwake.Status().Paused; paused != "" {
+		t.Fatalf("paused %q after recording stopped", paused)
+	}
+}
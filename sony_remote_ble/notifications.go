@@ -0,0 +1,88 @@
+package sony_remote_ble
+
+import "fmt"
+
+// NotificationKind identifies the camera status reported by a notification.
+type NotificationKind int
+
+const (
+	// NotifyUnknown is a notification this package does not decode
+	NotifyUnknown NotificationKind = iota
+	// NotifyFocus reports whether focus is acquired
+	NotifyFocus
+	// NotifyShutter reports whether the shutter is active
+	NotifyShutter
+	// NotifyRecording reports whether video recording is running
+	NotifyRecording
+)
+
+// String returns the lower-case name of the notification kind.
+func (k NotificationKind) String() string {
+	switch k {
+	case NotifyFocus:
+		return "focus"
+	case NotifyShutter:
+		return "shutter"
+	case NotifyRecording:
+		return "recording"
+	default:
+		return "unknown"
+	}
+}
+
+// Notification is a decoded status notification from the camera.
+type Notification struct {
+	// Kind identifies which status changed
+	Kind NotificationKind
+	// Active is true when the status turned on (focus acquired, shutter open,
+	// recording started) and false when it turned off
+	Active bool
+	// Raw is the notification frame as received
+	Raw []byte
+}
+
+// String describes the notification, e.g. "focus acquired" or "recording stopped".
+func (n Notification) String() string {
+	switch n.Kind {
+	case NotifyFocus:
+		if n.Active {
+			return "focus acquired"
+		}
+		return "focus lost"
+	case NotifyShutter:
+		if n.Active {
+			return "shutter active"
+		}
+		return "shutter released"
+	case NotifyRecording:
+		if n.Active {
+			return "recording started"
+		}
+		return "recording stopped"
+	default:
+		return fmt.Sprintf("unknown notification % x", n.Raw)
+	}
+}
+
+// ParseNotification decodes a frame received on the notification characteristic.
+// Frames are three bytes: 0x02, a status code and 0x20 (on) or 0x00 (off).
+// Unrecognised frames are returned with Kind NotifyUnknown.
+func ParseNotification(data []byte) Notification {
+	n := Notification{Raw: append([]byte(nil), data...)}
+	if len(data) != 3 || data[0] != 0x02 {
+		return n
+	}
+
+	switch data[1] {
+	case 0x3f:
+		n.Kind = NotifyFocus
+	case 0xa0:
+		n.Kind = NotifyShutter
+	case 0xd5:
+		n.Kind = NotifyRecording
+	default:
+		return n
+	}
+	n.Active = data[2] == 0x20
+	return n
+}
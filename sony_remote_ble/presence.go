@@ -0,0 +1,275 @@
+package sony_remote_ble
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPresenceSmoothing is the weight of the newest RSSI reading in the
+	// moving average when no smoothing is configured.
+	DefaultPresenceSmoothing = 0.3
+	// DefaultPresenceTimeout is how long a camera may go without advertising
+	// before it is gone when no timeout is configured. Cameras advertise
+	// several times a second, so this rides out a few missed advertisements.
+	DefaultPresenceTimeout = 10 * time.Second
+)
+
+// PresenceEventType identifies what a PresenceEvent describes.
+type PresenceEventType int
+
+const (
+	// PresenceAppeared is reported for the first advertisement of a camera
+	// that was gone, such as one that was just switched on
+	PresenceAppeared PresenceEventType = iota
+	// PresenceNear is reported when the smoothed RSSI rises to the near threshold
+	PresenceNear
+	// PresenceFar is reported when the smoothed RSSI of a near camera falls
+	// below the far threshold
+	PresenceFar
+	// PresenceGone is reported when a camera stopped advertising for the timeout
+	PresenceGone
+)
+
+// String returns the lower-case name of the event type, e.g. "appeared".
+func (t PresenceEventType) String() string {
+	switch t {
+	case PresenceAppeared:
+		return "appeared"
+	case PresenceNear:
+		return "near"
+	case PresenceFar:
+		return "far"
+	case PresenceGone:
+		return "gone"
+	default:
+		return "unknown"
+	}
+}
+
+// PresenceOptions configures a PresenceWatcher.
+type PresenceOptions struct {
+	// Near is the smoothed RSSI in dBm at or above which a camera is near;
+	// zero disables PresenceNear and PresenceFar
+	Near int16
+	// Far is the smoothed RSSI in dBm below which a near camera is far again.
+	// The gap to Near is the hysteresis that keeps a camera at the edge from
+	// flapping; Far is raised to Near if above it.
+	Far int16
+	// Smoothing is the weight of the newest reading in the exponential moving
+	// average of the RSSI, above 0 and at most 1; lower values smooth more
+	// (DefaultPresenceSmoothing)
+	Smoothing float64
+	// Timeout is how long a camera may go without advertising before it is
+	// gone (DefaultPresenceTimeout)
+	Timeout time.Duration
+}
+
+// PresenceEvent reports a change in the presence of a camera.
+type PresenceEvent struct {
+	// Type identifies the change
+	Type PresenceEventType
+	// Device is the last advertisement of the camera
+	Device DeviceInfo
+	// RSSI is the smoothed signal strength in dBm
+	RSSI float64
+	// Time is when the change was seen
+	Time time.Time
+}
+
+// Presence describes a camera a PresenceWatcher has seen.
+type Presence struct {
+	// Device is the last advertisement of the camera
+	Device DeviceInfo
+	// RSSI is the smoothed signal strength in dBm
+	RSSI float64
+	// Near is true between PresenceNear and PresenceFar
+	Near bool
+	// LastSeen is when the camera last advertised
+	LastSeen time.Time
+	// Held is true while Hold keeps the camera present
+	Held bool
+}
+
+// PresenceWatcher turns the advertisements of cameras into presence events:
+// a camera appears with its first advertisement, comes near and goes far as
+// its smoothed RSSI crosses the thresholds, and is gone once it stops
+// advertising. It is safe for concurrent use.
+//
+// Most cameras stop advertising while connected, so hold the connected
+// camera with Hold to keep it from going gone.
+type PresenceWatcher struct {
+	opts PresenceOptions
+
+	mu      sync.Mutex
+	cameras map[string]*Presence
+}
+
+// NewPresenceWatcher creates a presence watcher. Feed it with Observe and
+// Expire, or let Watch scan for it.
+//
+// Example:
+//
+//	watcher := sony_remote_ble.NewPresenceWatcher(sony_remote_ble.PresenceOptions{
+//		Near: -60,
+//		Far:  -75,
+//	})
+//	err := watcher.Watch(ctx, scanner, func(ev sony_remote_ble.PresenceEvent) {
+//		log.Printf("%s %s (%.0f dBm)", ev.Device.Name, ev.Type, ev.RSSI)
+//	})
+func NewPresenceWatcher(opts PresenceOptions) *PresenceWatcher {
+	if opts.Smoothing <= 0 || opts.Smoothing > 1 {
+		opts.Smoothing = DefaultPresenceSmoothing
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultPresenceTimeout
+	}
+	opts.Far = min(opts.Far, opts.Near)
+	return &PresenceWatcher{
+		opts:    opts,
+		cameras: make(map[string]*Presence),
+	}
+}
+
+// Observe records an advertisement seen at the given time and returns the
+// events it causes, in order.
+func (w *PresenceWatcher) Observe(device DeviceInfo, at time.Time) []PresenceEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var events []PresenceEvent
+	key := strings.ToUpper(device.AddressStr)
+	p, seen := w.cameras[key]
+	if !seen {
+		p = &Presence{RSSI: float64(device.RSSI)}
+		w.cameras[key] = p
+	} else {
+		p.RSSI += w.opts.Smoothing * (float64(device.RSSI) - p.RSSI)
+	}
+	p.Device = device
+	p.LastSeen = at
+
+	event := func(t PresenceEventType) {
+		events = append(events, PresenceEvent{Type: t, Device: device, RSSI: p.RSSI, Time: at})
+	}
+	if !seen {
+		event(PresenceAppeared)
+	}
+	if w.opts.Near != 0 {
+		switch {
+		case !p.Near && p.RSSI >= float64(w.opts.Near):
+			p.Near = true
+			event(PresenceNear)
+		case p.Near && p.RSSI < float64(w.opts.Far):
+			p.Near = false
+			event(PresenceFar)
+		}
+	}
+	return events
+}
+
+// Expire reports the cameras that have not advertised for the timeout as of
+// now as gone, and forgets them.
+func (w *PresenceWatcher) Expire(now time.Time) []PresenceEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var events []PresenceEvent
+	for _, key := range w.sortedKeys() {
+		p := w.cameras[key]
+		if p.Held || now.Sub(p.LastSeen) < w.opts.Timeout {
+			continue
+		}
+		delete(w.cameras, key)
+		events = append(events, PresenceEvent{Type: PresenceGone, Device: p.Device, RSSI: p.RSSI, Time: now})
+	}
+	return events
+}
+
+// Hold keeps the camera at address present while held, since most cameras
+// stop advertising while connected, and starts its timeout afresh when
+// released. Cameras that were never seen are not affected.
+func (w *PresenceWatcher) Hold(address string, held bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p, ok := w.cameras[strings.ToUpper(address)]
+	if !ok {
+		return
+	}
+	if p.Held && !held {
+		p.LastSeen = time.Now()
+	}
+	p.Held = held
+}
+
+// Cameras returns the cameras currently present, ordered by address.
+func (w *PresenceWatcher) Cameras() []Presence {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cameras := make([]Presence, 0, len(w.cameras))
+	for _, key := range w.sortedKeys() {
+		cameras = append(cameras, *w.cameras[key])
+	}
+	return cameras
+}
+
+// sortedKeys returns the keys of w.cameras in order. The caller holds w.mu.
+func (w *PresenceWatcher) sortedKeys() []string {
+	keys := make([]string, 0, len(w.cameras))
+	for key := range w.cameras {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Watch scans with cam until ctx ends and calls fn for every presence event.
+// cam scans the whole time, so it cannot be used for a connection meanwhile;
+// use a client of its own. Watch returns nil when ctx ends, or the error that
+// stopped the scan.
+func (w *PresenceWatcher) Watch(ctx context.Context, cam Camera, fn func(PresenceEvent)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	deviceChan := make(chan DeviceInfo, 16)
+	if err := cam.ScanForDevices(ctx, deviceChan); err != nil {
+		return err
+	}
+	defer cam.StopScan()
+
+	// The scan ends on its own only when it fails
+	failed := make(chan error, 1)
+	remove := cam.OnEvent(func(ev Event) {
+		if ev.Type == EventStateChanged && ev.State == Error {
+			select {
+			case failed <- ev.Err:
+			default:
+			}
+		}
+	})
+	defer remove()
+
+	ticker := time.NewTicker(w.opts.Timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case device := <-deviceChan:
+			for _, ev := range w.Observe(device, time.Now()) {
+				fn(ev)
+			}
+		case now := <-ticker.C:
+			for _, ev := range w.Expire(now) {
+				fn(ev)
+			}
+		case err := <-failed:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package sony_remote_ble
+
+import (
+	"strings"
+	"time"
+)
+
+// TimingProfile holds the pauses TakePhoto and Zoom leave between commands.
+// Camera bodies differ in how quickly they accept frames: some drop the
+// release when it follows the full press too closely, others need longer to
+// focus. Zero fields use the client's command delay, so the zero profile
+// behaves like a plain SendCommandSequence of TakePhotoSequence.
+type TimingProfile struct {
+	// HalfPressSettle is the pause between the half press and the full press,
+	// which gives the camera time to focus
+	HalfPressSettle time.Duration
+	// FullPressHold is how long the full press is held before it is released
+	FullPressHold time.Duration
+	// ReleaseGap is the pause between releasing the full press and the half press
+	ReleaseGap time.Duration
+	// ZoomStop is the pause after a zoom is released during which the lens
+	// stops; zero does not pause
+	ZoomStop time.Duration
+	// Adaptive ends the settle and hold pauses early when the camera reports
+	// focus and the shutter, so that they only bound the wait. It has no
+	// effect on cameras without notifications.
+	Adaptive bool
+}
+
+// Resolve returns the profile with zero pauses replaced by delay.
+// ZoomStop is left unchanged.
+func (p TimingProfile) Resolve(delay time.Duration) TimingProfile {
+	for _, d := range []*time.Duration{&p.HalfPressSettle, &p.FullPressHold, &p.ReleaseGap} {
+		if *d == 0 {
+			*d = delay
+		}
+	}
+	return p
+}
+
+// TimingProfiles holds the built-in profiles keyed by model code. They are
+// conservative starting points: bodies with older processors get longer
+// pauses and the power-zoom compacts a pause to let the lens stop. Models
+// that are not listed use the zero profile.
+var TimingProfiles = map[string]TimingProfile{
+	"ILCE-7M3":  olderBodyProfile,
+	"ILCE-7RM3": olderBodyProfile,
+	"ILCE-7RM4": olderBodyProfile,
+	"ILCE-9":    olderBodyProfile,
+	"ILCE-6100": olderBodyProfile,
+	"ILCE-6400": olderBodyProfile,
+	"ILCE-6600": olderBodyProfile,
+	"ILCE-7C":   olderBodyProfile,
+	"DSC-RX100M7": {
+		HalfPressSettle: 100 * time.Millisecond,
+		FullPressHold:   100 * time.Millisecond,
+		ReleaseGap:      100 * time.Millisecond,
+		ZoomStop:        200 * time.Millisecond,
+	},
+	"ZV-1": {
+		ZoomStop: 200 * time.Millisecond,
+	},
+}
+
+// olderBodyProfile suits bodies that drop frames sent at DefaultCommandDelay.
+var olderBodyProfile = TimingProfile{
+	HalfPressSettle: 100 * time.Millisecond,
+	FullPressHold:   100 * time.Millisecond,
+	ReleaseGap:      100 * time.Millisecond,
+}
+
+// ProfileFor returns the built-in profile for the model in an advertised
+// device name, or the zero profile if the name has no listed model.
+//
+// Example:
+//
+//	client.SetTimingProfile(sony_remote_ble.ProfileFor(device.Name))
+func ProfileFor(name string) TimingProfile {
+	model := ModelFromName(name)
+	for code, profile := range TimingProfiles {
+		if strings.EqualFold(code, model) {
+			return profile
+		}
+	}
+	return TimingProfile{}
+}
+
+// pauseUntil waits for d, or less if until receives first. A nil until always
+// waits for d.
+func pauseUntil(until <-chan struct{}, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-until:
+	}
+}
+
+// notices wakes a waiter when the camera reports focus or the shutter.
+type notices struct {
+	focus   chan struct{}
+	shutter chan struct{}
+}
+
+// drain discards notices that are already pending.
+func (n notices) drain() {
+	for _, ch := range []chan struct{}{n.focus, n.shutter} {
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// signal wakes a waiter on ch without blocking.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,99 @@
+package sony_remote_ble
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultRecordTimeout bounds how long SetRecording waits for the camera to
+// report that recording started or stopped.
+const DefaultRecordTimeout = 5 * time.Second
+
+// ErrRecordingUnknown is returned by SetRecording for cameras that send no
+// notifications, whose recording state cannot be known.
+var ErrRecordingUnknown = errors.New("recording state unknown: camera does not send notifications")
+
+// Recording reports whether the camera is recording video. known is false
+// when the camera sends no notifications, because the record button only
+// toggles and the client cannot tell which way.
+//
+// Cameras only report changes, so the client assumes that no recording is
+// running when it connects; a recording started before is noticed once it
+// stops or once SetRecording has checked it.
+func (c *Client) Recording() (recording, known bool) {
+	if !c.SupportsNotifications() {
+		return false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.recording, true
+}
+
+// SetRecording starts or stops video recording and waits for the camera to
+// report the change, so that a disconnect right after does not cut it short.
+// The camera only exposes a record toggle, so SetRecording presses it only
+// when the recording state differs from on, and returns ErrRecordingUnknown
+// for cameras that send no notifications.
+//
+// Until the camera has reported its recording state on this connection, the
+// state is only assumed; SetRecording then presses the toggle anyway and, if
+// the camera reports the opposite of on, presses it once more. Stopping a
+// camera that was not recording thus records a short clip.
+//
+// Example:
+//
+//	if err := client.SetRecording(ctx, false); errors.Is(err, sony_remote_ble.ErrRecordingUnknown) {
+//		log.Print("cannot tell whether the camera is recording")
+//	}
+func (c *Client) SetRecording(ctx context.Context, on bool) error {
+	if c.State() != Connected {
+		return ErrNotConnected
+	}
+	recording, known := c.Recording()
+	if !known {
+		return ErrRecordingUnknown
+	}
+	c.mu.Lock()
+	reported := c.recordingReported
+	c.mu.Unlock()
+	if reported && recording == on {
+		return nil
+	}
+
+	reports := make(chan bool, 2)
+	remove := c.OnEvent(func(ev Event) {
+		if ev.Type == EventNotification && ev.Notification.Kind == NotifyRecording {
+			select {
+			case reports <- ev.Notification.Active:
+			default:
+			}
+		}
+	})
+	defer remove()
+
+	timer := time.NewTimer(DefaultRecordTimeout)
+	defer timer.Stop()
+	// The second press corrects a wrong assumption about the state
+	for range 2 {
+		if err := c.Press("record", DefaultCommandDelay); err != nil {
+			return err
+		}
+		select {
+		case active := <-reports:
+			if active == on {
+				return nil
+			}
+		case <-timer.C:
+			state := "stopped"
+			if on {
+				state = "started"
+			}
+			return fmt.Errorf("camera did not report that recording %s within %s", state, DefaultRecordTimeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return fmt.Errorf("camera did not reach the requested recording state")
+}
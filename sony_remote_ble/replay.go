@@ -0,0 +1,288 @@
+package sony_remote_ble
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// ErrReplayMismatch is returned by a replayed link when the client writes a
+// frame that differs from the capture, or writes more than the capture holds.
+var ErrReplayMismatch = errors.New("write does not match capture")
+
+// ErrCaptureEnd is returned by ReplayTransport.Connect when the capture holds
+// no further connection.
+var ErrCaptureEnd = errors.New("capture has no further connection")
+
+// ReplayTransport plays a capture back into a Client, so that a session
+// recorded with Client.Record can be reproduced without the camera.
+//
+// Scans report the advertisements recorded before the next connection attempt
+// with their original spacing. Connections succeed or fail as they did in the
+// capture. Once connected, the client's writes are checked against the
+// recorded writes in order: a different frame fails with ErrReplayMismatch and
+// a write that failed in the capture fails again. Notifications are delivered
+// with the same delay after the preceding write as in the capture.
+//
+// Example:
+//
+//	f, err := os.Open("bug-1234.jsonl")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	capture, err := sony_remote_ble.ReadCapture(f)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	client := sony_remote_ble.NewClientWithTransport(sony_remote_ble.NewReplayTransport(capture))
+type ReplayTransport struct {
+	records []CaptureRecord
+
+	mu sync.Mutex
+	// cursor is the index of the next record to play
+	cursor int
+	// scanStop ends the running scan; scanStopped remembers a stop that
+	// arrived while no scan was running
+	scanStop    chan struct{}
+	scanStopped bool
+}
+
+// NewReplayTransport creates a transport that plays back capture.
+func NewReplayTransport(capture *Capture) *ReplayTransport {
+	return &ReplayTransport{records: capture.Records}
+}
+
+// Scan implements Transport.
+func (t *ReplayTransport) Scan(found func(DeviceInfo)) error {
+	t.mu.Lock()
+	if t.scanStopped {
+		t.scanStopped = false
+		t.mu.Unlock()
+		return nil
+	}
+	stop := make(chan struct{})
+	t.scanStop = stop
+	var adverts []CaptureRecord
+	for _, rec := range t.records[t.cursor:] {
+		if rec.Type == CaptureState && rec.State == Connecting.String() {
+			break
+		}
+		if rec.Type == CaptureAdvertisement {
+			adverts = append(adverts, rec)
+		}
+	}
+	t.mu.Unlock()
+
+	start := time.Now()
+	for _, rec := range adverts {
+		due := start.Add(seconds(rec.T - adverts[0].T))
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(time.Until(due)):
+		}
+		address := ParseAddress(rec.Address)
+		found(DeviceInfo{Name: rec.Name, Address: address, AddressStr: rec.Address, RSSI: rec.RSSI})
+	}
+	<-stop
+	return nil
+}
+
+// StopScan implements Transport.
+func (t *ReplayTransport) StopScan() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.scanStop == nil {
+		t.scanStopped = true
+		return nil
+	}
+	close(t.scanStop)
+	t.scanStop = nil
+	return nil
+}
+
+// Connect implements Transport. It takes as long as the recorded attempt did.
+func (t *ReplayTransport) Connect(address bluetooth.Address) (Link, error) {
+	t.mu.Lock()
+	attempt := -1
+	result := -1
+	for i := t.cursor; i < len(t.records) && result < 0; i++ {
+		rec := t.records[i]
+		if rec.Type != CaptureState {
+			continue
+		}
+		switch {
+		case rec.State == Connecting.String():
+			attempt = i
+		case rec.State == Connected.String(), rec.Previous == Connecting.String():
+			result = i
+		}
+	}
+	if result < 0 {
+		t.mu.Unlock()
+		return nil, ErrCaptureEnd
+	}
+	rec := t.records[result]
+	t.cursor = result + 1
+	t.mu.Unlock()
+
+	if attempt >= 0 {
+		time.Sleep(seconds(rec.T - t.records[attempt].T))
+	}
+	if rec.State != Connected.String() {
+		if rec.Error != "" {
+			return nil, errors.New(rec.Error)
+		}
+		return nil, fmt.Errorf("connection failed in capture")
+	}
+
+	l := &replayLink{
+		t:     t,
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+		base:  time.Now(),
+		baseT: rec.T,
+	}
+	go l.play()
+	return l, nil
+}
+
+// replayLink plays the records of one recorded connection.
+type replayLink struct {
+	t    *ReplayTransport
+	wake chan struct{}
+	done chan struct{}
+
+	// notify, base and baseT are guarded by t.mu; base is when the record at
+	// baseT was replayed, which anchors the timing of the records after it
+	notify    func([]byte)
+	base      time.Time
+	baseT     float64
+	closeOnce sync.Once
+}
+
+// play delivers notifications at their recorded times until the recorded
+// connection ends or the link is closed. Writes are advanced by Write.
+func (l *replayLink) play() {
+	t := l.t
+	for {
+		t.mu.Lock()
+		if t.cursor >= len(t.records) {
+			t.mu.Unlock()
+			return
+		}
+		rec := t.records[t.cursor]
+		var wait <-chan time.Time
+		switch rec.Type {
+		case CaptureNotify:
+			due := l.base.Add(seconds(rec.T - l.baseT))
+			if delay := time.Until(due); delay > 0 {
+				wait = time.After(delay)
+				break
+			}
+			t.cursor++
+			fn := l.notify
+			t.mu.Unlock()
+			if frame, err := rec.Frame(); err == nil && fn != nil {
+				fn(frame)
+			}
+			continue
+		case CaptureWrite:
+			// Wait for the client to catch up
+		case CaptureState:
+			// The recorded connection ended
+			t.mu.Unlock()
+			return
+		default:
+			t.cursor++
+			t.mu.Unlock()
+			continue
+		}
+		t.mu.Unlock()
+
+		select {
+		case <-l.done:
+			return
+		case <-l.wake:
+		case <-wait:
+		}
+	}
+}
+
+// Write implements Link. Notifications the capture received before this
+// write are delivered first if they are still pending.
+func (l *replayLink) Write(frame []byte) error {
+	t := l.t
+	t.mu.Lock()
+	index := -1
+	var pending [][]byte
+	for i := t.cursor; i < len(t.records); i++ {
+		rec := t.records[i]
+		if rec.Type == CaptureState {
+			break
+		}
+		if rec.Type == CaptureNotify {
+			if f, err := rec.Frame(); err == nil {
+				pending = append(pending, f)
+			}
+		}
+		if rec.Type == CaptureWrite {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		t.mu.Unlock()
+		return fmt.Errorf("%w: sent %x after the last recorded write", ErrReplayMismatch, frame)
+	}
+	rec := t.records[index]
+	t.cursor = index + 1
+	l.base = time.Now()
+	l.baseT = rec.T
+	fn := l.notify
+	t.mu.Unlock()
+
+	if fn != nil {
+		for _, f := range pending {
+			fn(f)
+		}
+	}
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+
+	if rec.Bytes != hex.EncodeToString(frame) {
+		return fmt.Errorf("%w: sent %x, capture has %s (%s)", ErrReplayMismatch, frame, rec.Bytes, rec.Name)
+	}
+	time.Sleep(seconds(rec.Latency))
+	if rec.Error != "" {
+		// The client adds the command name again
+		return errors.New(strings.TrimPrefix(rec.Error, "failed to send command "+rec.Name+": "))
+	}
+	return nil
+}
+
+// EnableNotifications implements Link.
+func (l *replayLink) EnableNotifications(fn func([]byte)) error {
+	l.t.mu.Lock()
+	l.notify = fn
+	l.t.mu.Unlock()
+	return nil
+}
+
+// Disconnect implements Link.
+func (l *replayLink) Disconnect() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+// seconds converts a capture timestamp into a duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
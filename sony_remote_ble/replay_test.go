@@ -0,0 +1,250 @@
+package sony_remote_ble_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/simulator"
+)
+
+// session drives a client through connection failures, photos, recording,
+// rejected writes and a lost link, checking the errors on the way. It runs
+// the same against the simulator and against the replay of its capture.
+func session(t *testing.T, client *sony_remote_ble.Client, address string) {
+	t.Helper()
+	ctx := context.Background()
+	addr := sony_remote_ble.ParseAddress(address)
+	c1 := sony_remote_ble.Commands["c1_down"]
+
+	if err := client.Connect(addr); err == nil || err.Error() != simulator.ErrConnectFailed.Error() {
+		t.Fatalf("first connect: got %v, want %v", err, simulator.ErrConnectFailed)
+	}
+	if err := client.Connect(addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.TakePhoto(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetRecording(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+
+	err := client.SendCommand(c1)
+	if err == nil || !strings.HasSuffix(err.Error(), simulator.ErrWriteRejected.Error()) {
+		t.Fatalf("rejected write: got %v, want %v", err, simulator.ErrWriteRejected)
+	}
+	if err := client.SendCommand(c1); err != nil {
+		t.Fatalf("write after the rejected one: %v", err)
+	}
+	// The client assumes nothing is recording after a reconnect, so stop first
+	if err := client.SetRecording(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	err = client.SendCommand(c1)
+	if err == nil || !strings.HasSuffix(err.Error(), simulator.ErrLinkLost.Error()) {
+		t.Fatalf("write on a lost link: got %v, want %v", err, simulator.ErrLinkLost)
+	}
+	if err := client.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.TakePhoto(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// record runs fn against a client on transport and returns its capture.
+func record(t *testing.T, transport sony_remote_ble.Transport, fn func(*sony_remote_ble.Client)) *sony_remote_ble.Capture {
+	t.Helper()
+	client := sony_remote_ble.NewClientWithTransport(transport)
+	var buf bytes.Buffer
+	stop := client.Record(&buf)
+	fn(client)
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	capture, err := sony_remote_ble.ReadCapture(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return capture
+}
+
+// traffic describes the records of a capture without their times.
+func traffic(capture *sony_remote_ble.Capture) []string {
+	var lines []string
+	for _, rec := range capture.Records {
+		lines = append(lines, strings.Join([]string{rec.Type, rec.Previous, rec.State, rec.Address, rec.Name, rec.Bytes, rec.Error}, " "))
+	}
+	return lines
+}
+
+func TestReplayReproducesSimulatorSession(t *testing.T) {
+	cam := simulator.New(simulator.Options{
+		FocusLatency:   5 * time.Millisecond,
+		ShutterLatency: 5 * time.Millisecond,
+		RecordLatency:  20 * time.Millisecond,
+	})
+	address := cam.Address().String()
+
+	// The faults are armed as the session reaches them
+	cam.FailConnects(1)
+	original := record(t, cam, func(client *sony_remote_ble.Client) {
+		remove := client.OnEvent(func(ev sony_remote_ble.Event) {
+			if ev.Type != sony_remote_ble.EventNotification || ev.Notification.Kind != sony_remote_ble.NotifyRecording {
+				return
+			}
+			if ev.Notification.Active {
+				cam.RejectWrites(1)
+				return
+			}
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				cam.DropLink()
+			}()
+		})
+		defer remove()
+		session(t, client, address)
+	})
+	if cam.Recording() || cam.Shots() != 2 {
+		t.Errorf("simulator recording %t with %d shots after the session, want stopped with 2", cam.Recording(), cam.Shots())
+	}
+
+	want := traffic(original)
+	var notifications int
+	for _, line := range want {
+		if strings.HasPrefix(line, sony_remote_ble.CaptureNotify) {
+			notifications++
+		}
+	}
+	if notifications < 10 {
+		t.Fatalf("capture holds %d notifications, want the focus, shutter and recording ones: %q", notifications, want)
+	}
+
+	replayed := record(t, sony_remote_ble.NewReplayTransport(original), func(client *sony_remote_ble.Client) {
+		session(t, client, address)
+	})
+	got := traffic(replayed)
+	if len(got) != len(want) {
+		t.Fatalf("replay has %d records, capture %d:\n%s\nwant\n%s", len(got), len(want), strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d: replayed %q, captured %q", i, got[i], want[i])
+		}
+	}
+}
+
+// handmade is a capture of one connection: a half press answered by focus,
+// a shutter notification received long after, and a full press.
+const handmade = `{"t":0,"type":"header","version":1}
+{"t":0,"type":"state","state":"Connecting","previous":"Disconnected"}
+{"t":0.1,"type":"state","state":"Connected","previous":"Connecting","address":"C0:FF:EE:00:00:01"}
+{"t":0.2,"type":"write","name":"Focus Down","bytes":"0107"}
+{"t":0.22,"type":"notify","bytes":"023f20"}
+{"t":30,"type":"notify","bytes":"02a020"}
+{"t":30.1,"type":"write","name":"Shutter Full Down","bytes":"0109"}
+{"t":30.2,"type":"state","state":"Disconnected","previous":"Connected"}
+`
+
+// replayHandmade connects a client to a replay of the handmade capture and
+// collects the notifications it receives.
+func replayHandmade(t *testing.T) (*sony_remote_ble.Client, func() []string) {
+	t.Helper()
+	capture, err := sony_remote_ble.ReadCapture(strings.NewReader(handmade))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := sony_remote_ble.NewClientWithTransport(sony_remote_ble.NewReplayTransport(capture))
+
+	var mu sync.Mutex
+	var received []string
+	client.OnEvent(func(ev sony_remote_ble.Event) {
+		if ev.Type == sony_remote_ble.EventNotification {
+			mu.Lock()
+			received = append(received, ev.Notification.String())
+			mu.Unlock()
+		}
+	})
+	if err := client.Connect(sony_remote_ble.ParseAddress("C0:FF:EE:00:00:01")); err != nil {
+		t.Fatal(err)
+	}
+	return client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), received...)
+	}
+}
+
+func TestReplayDeliversNotificationsAtTheirTime(t *testing.T) {
+	client, received := replayHandmade(t)
+	if err := client.SendCommand(sony_remote_ble.Commands["focus_down"]); err != nil {
+		t.Fatal(err)
+	}
+	if got := received(); len(got) != 0 {
+		t.Fatalf("notifications %q before they were due", got)
+	}
+
+	// The focus notification follows 20ms after the write, the shutter one
+	// only half a minute later
+	deadline := time.Now().Add(time.Second)
+	for len(received()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("focus notification not delivered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := received(); len(got) != 1 {
+		t.Fatalf("got notifications %q, want only the focus one", got)
+	}
+
+	// A write flushes the notifications recorded before it
+	if err := client.SendCommand(sony_remote_ble.Commands["shutter_full_down"]); err != nil {
+		t.Fatal(err)
+	}
+	if got := received(); len(got) != 2 {
+		t.Fatalf("got notifications %q after the full press, want focus and shutter", got)
+	}
+}
+
+func TestReplayRejectsWritesThatDiffer(t *testing.T) {
+	client, received := replayHandmade(t)
+
+	err := client.SendCommand(sony_remote_ble.Commands["record_down"])
+	if !errors.Is(err, sony_remote_ble.ErrReplayMismatch) || !strings.Contains(err.Error(), "capture has 0107") {
+		t.Fatalf("got %v, want a mismatch against 0107", err)
+	}
+	// The mismatch used up the recorded write, and the next matches the one after it
+	if err := client.SendCommand(sony_remote_ble.Commands["shutter_full_down"]); err != nil {
+		t.Fatal(err)
+	}
+	if got := received(); len(got) != 2 {
+		t.Fatalf("got notifications %q, want both recorded before the full press", got)
+	}
+
+	err = client.SendCommand(sony_remote_ble.Commands["shutter_full_up"])
+	if !errors.Is(err, sony_remote_ble.ErrReplayMismatch) || !strings.Contains(err.Error(), "after the last recorded write") {
+		t.Fatalf("got %v, want a mismatch after the last write", err)
+	}
+
+	if err := client.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(sony_remote_ble.ParseAddress("C0:FF:EE:00:00:01")); !errors.Is(err, sony_remote_ble.ErrCaptureEnd) {
+		t.Fatalf("connect past the capture: got %v, want %v", err, sony_remote_ble.ErrCaptureEnd)
+	}
+}
@@ -0,0 +1,474 @@
+// Package simulator implements the camera side of the Sony remote protocol in
+// memory, so that clients can be developed and demonstrated without a camera.
+//
+// A Camera is a sony_remote_ble.Transport. It advertises while scanned for,
+// accepts command frames, tracks which buttons are held, and answers with the
+// focus, shutter and recording notifications a real body sends:
+//
+//	cam := simulator.New(simulator.DefaultOptions())
+//	client := sony_remote_ble.NewClientWithTransport(cam)
+//	client.Connect(cam.Address())
+//	client.TakePhoto()
+//	fmt.Println(cam.Shots()) // 1
+//
+// Faults can be injected at any time with DropLink, RejectWrites,
+// FailConnects and SetAdvertising, and movement with SetRSSI.
+package simulator
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"tinygo.org/x/bluetooth"
+)
+
+var (
+	// ErrLinkLost is returned by writes after DropLink, like a write to a camera
+	// that went out of range or was switched off
+	ErrLinkLost = errors.New("simulated link lost")
+	// ErrWriteRejected is returned by writes failed with RejectWrites
+	ErrWriteRejected = errors.New("simulated write rejected")
+	// ErrConnectFailed is returned by connections failed with FailConnects
+	ErrConnectFailed = errors.New("simulated connection failed")
+)
+
+// Notification frames sent by the camera: 0x02, a status code and 0x20 for on
+// or 0x00 for off.
+const (
+	statusFocus     = 0x3f
+	statusShutter   = 0xa0
+	statusRecording = 0xd5
+)
+
+// Options configures a simulated camera.
+type Options struct {
+	// Name is the advertised name
+	Name string
+	// Address is the Bluetooth address the camera advertises
+	Address string
+	// RSSI is the advertised signal strength in dBm
+	RSSI int16
+	// NoNotifications simulates a body without the notification characteristic
+	NoNotifications bool
+
+	// AdvertiseInterval is the time between advertisements while scanned for
+	AdvertiseInterval time.Duration
+	// ConnectLatency is how long connecting takes
+	ConnectLatency time.Duration
+	// WriteLatency is how long each command write takes
+	WriteLatency time.Duration
+	// FocusLatency is how long the camera takes to acquire focus after a half press
+	FocusLatency time.Duration
+	// ShutterLatency is how long the shutter takes to open after a full press
+	ShutterLatency time.Duration
+	// RecordLatency is how long recording takes to start or stop after the record button
+	RecordLatency time.Duration
+}
+
+// DefaultOptions returns a camera with timings close to a real body.
+func DefaultOptions() Options {
+	return Options{
+		Name:              "ILCE-7M4 Simulator",
+		Address:           "C0:FF:EE:00:00:01",
+		RSSI:              -55,
+		AdvertiseInterval: 200 * time.Millisecond,
+		ConnectLatency:    300 * time.Millisecond,
+		WriteLatency:      2 * time.Millisecond,
+		FocusLatency:      150 * time.Millisecond,
+		ShutterLatency:    30 * time.Millisecond,
+		RecordLatency:     400 * time.Millisecond,
+	}
+}
+
+// Camera is a simulated camera. It is safe for concurrent use.
+type Camera struct {
+	opts    Options
+	address bluetooth.Address
+
+	mu sync.Mutex
+	// scanStop ends the running scan; scanStopped remembers a stop that
+	// arrived while no scan was running
+	scanStop    chan struct{}
+	scanStopped bool
+	advertising bool
+	rssi        int16
+	link        *link
+
+	// pressed holds the down frames of the buttons currently held
+	pressed   map[string]bool
+	focused   bool
+	shutter   bool
+	recording bool
+	shots     int
+	frames    [][]byte
+
+	failConnects int
+	rejectWrites int
+}
+
+// New creates a simulated camera. Empty Name and Address fields take the
+// values from DefaultOptions; zero latencies make the camera respond at once.
+func New(opts Options) *Camera {
+	defaults := DefaultOptions()
+	if opts.Name == "" {
+		opts.Name = defaults.Name
+	}
+	if opts.Address == "" {
+		opts.Address = defaults.Address
+	}
+	if opts.AdvertiseInterval <= 0 {
+		opts.AdvertiseInterval = defaults.AdvertiseInterval
+	}
+	return &Camera{
+		opts:        opts,
+		address:     sony_remote_ble.ParseAddress(opts.Address),
+		advertising: true,
+		rssi:        opts.RSSI,
+		pressed:     make(map[string]bool),
+	}
+}
+
+// Address returns the address the camera advertises.
+func (c *Camera) Address() bluetooth.Address {
+	return c.address
+}
+
+// Device returns the camera as a scan would report it.
+func (c *Camera) Device() sony_remote_ble.DeviceInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return sony_remote_ble.DeviceInfo{
+		Name:       c.opts.Name,
+		Address:    c.address,
+		AddressStr: c.address.String(),
+		RSSI:       c.rssi,
+	}
+}
+
+// Scan implements sony_remote_ble.Transport.
+func (c *Camera) Scan(found func(sony_remote_ble.DeviceInfo)) error {
+	c.mu.Lock()
+	if c.scanStopped {
+		c.scanStopped = false
+		c.mu.Unlock()
+		return nil
+	}
+	stop := make(chan struct{})
+	c.scanStop = stop
+	c.mu.Unlock()
+
+	ticker := time.NewTicker(c.opts.AdvertiseInterval)
+	defer ticker.Stop()
+	for {
+		c.mu.Lock()
+		advertising := c.advertising && c.link == nil
+		c.mu.Unlock()
+		if advertising {
+			found(c.Device())
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// StopScan implements sony_remote_ble.Transport.
+func (c *Camera) StopScan() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.scanStop == nil {
+		c.scanStopped = true
+		return nil
+	}
+	close(c.scanStop)
+	c.scanStop = nil
+	return nil
+}
+
+// Connect implements sony_remote_ble.Transport.
+func (c *Camera) Connect(address bluetooth.Address) (sony_remote_ble.Link, error) {
+	time.Sleep(c.opts.ConnectLatency)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if address.String() != c.address.String() || !c.advertising {
+		return nil, fmt.Errorf("failed to connect: no simulated camera at %s", address.String())
+	}
+	if c.failConnects > 0 {
+		c.failConnects--
+		return nil, ErrConnectFailed
+	}
+
+	if c.link != nil {
+		c.link.dropped = true
+	}
+	c.link = &link{cam: c}
+	c.release()
+	return c.link, nil
+}
+
+// DropLink breaks the current connection. Writes fail with ErrLinkLost until
+// the client connects again, and no more notifications arrive.
+func (c *Camera) DropLink() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.link != nil {
+		c.link.dropped = true
+		c.link = nil
+	}
+	c.release()
+}
+
+// RejectWrites makes the next n command writes fail with ErrWriteRejected.
+func (c *Camera) RejectWrites(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rejectWrites = n
+}
+
+// FailConnects makes the next n connection attempts fail with ErrConnectFailed.
+func (c *Camera) FailConnects(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failConnects = n
+}
+
+// SetRSSI changes the signal strength in dBm the camera is seen with, as if
+// it was carried closer or further away.
+func (c *Camera) SetRSSI(rssi int16) {
+	c.mu.Lock()
+	c.rssi = rssi
+	c.mu.Unlock()
+}
+
+// SetAdvertising switches the camera's Bluetooth on or off. A camera that is
+// off is not found by scans and cannot be connected to; switching it off also
+// drops the current connection.
+func (c *Camera) SetAdvertising(on bool) {
+	c.mu.Lock()
+	c.advertising = on
+	c.mu.Unlock()
+	if !on {
+		c.DropLink()
+	}
+}
+
+// Pressed reports whether a button, such as "shutter_full" or "c1", is held.
+// Buttons that share a frame, like "focus" and "shutter_half", are held together.
+func (c *Camera) Pressed(button string) bool {
+	down, ok := sony_remote_ble.Commands[button+"_down"]
+	if !ok {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pressed[frameKey(down.Code)]
+}
+
+// Focused reports whether the camera has acquired focus.
+func (c *Camera) Focused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.focused
+}
+
+// Recording reports whether the camera is recording video.
+func (c *Camera) Recording() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.recording
+}
+
+// Shots returns the number of photos taken.
+func (c *Camera) Shots() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.shots
+}
+
+// Frames returns every command frame received, in order.
+func (c *Camera) Frames() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	frames := make([][]byte, len(c.frames))
+	for i, f := range c.frames {
+		frames[i] = append([]byte(nil), f...)
+	}
+	return frames
+}
+
+// release lets go of every button, as a camera does when the remote goes away.
+func (c *Camera) release() {
+	clear(c.pressed)
+	c.focused = false
+	c.shutter = false
+}
+
+// handle applies a command frame. The caller holds c.mu.
+func (c *Camera) handle(l *link, frame []byte) {
+	c.frames = append(c.frames, append([]byte(nil), frame...))
+	key := frameKey(frame)
+
+	if button, down, ok := buttonOf(key); ok {
+		downKey := key
+		if !down {
+			downKey = buttonDown[button]
+		}
+		c.pressed[downKey] = down
+	}
+
+	switch key {
+	case "0107", "0115": // shutter half press, autofocus
+		c.schedule(l, c.opts.FocusLatency, func() (byte, bool, bool) {
+			return statusFocus, true, c.set(&c.focused, true)
+		})
+	case "0106", "0114":
+		c.schedule(l, c.opts.FocusLatency, func() (byte, bool, bool) {
+			return statusFocus, false, c.set(&c.focused, false)
+		})
+	case "0109": // full press
+		c.shots++
+		c.schedule(l, c.opts.ShutterLatency, func() (byte, bool, bool) {
+			return statusShutter, true, c.set(&c.shutter, true)
+		})
+	case "0108":
+		// Closing with the same latency keeps the notifications in order
+		c.schedule(l, c.opts.ShutterLatency, func() (byte, bool, bool) {
+			return statusShutter, false, c.set(&c.shutter, false)
+		})
+	case "010e": // record button released, or the record toggle
+		c.schedule(l, c.opts.RecordLatency, func() (byte, bool, bool) {
+			c.recording = !c.recording
+			return statusRecording, c.recording, true
+		})
+	}
+}
+
+// schedule changes the camera state after delay and sends the matching
+// notification if the state changed and the link is still up.
+func (c *Camera) schedule(l *link, delay time.Duration, change func() (status byte, on, changed bool)) {
+	time.AfterFunc(delay, func() {
+		c.mu.Lock()
+		if l.dropped || l.closed {
+			c.mu.Unlock()
+			return
+		}
+		status, on, changed := change()
+		notify := l.notify
+		c.mu.Unlock()
+
+		if changed && notify != nil {
+			value := byte(0x00)
+			if on {
+				value = 0x20
+			}
+			notify([]byte{0x02, status, value})
+		}
+	})
+}
+
+// set updates a state flag and reports whether it changed.
+func (c *Camera) set(flag *bool, value bool) bool {
+	changed := *flag != value
+	*flag = value
+	return changed
+}
+
+// link is one connection to the simulated camera. Its fields are guarded by
+// the camera's mutex.
+type link struct {
+	cam     *Camera
+	notify  func([]byte)
+	dropped bool
+	closed  bool
+}
+
+// Write implements sony_remote_ble.Link.
+func (l *link) Write(frame []byte) error {
+	c := l.cam
+	time.Sleep(c.opts.WriteLatency)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case l.dropped:
+		return ErrLinkLost
+	case l.closed:
+		return errors.New("simulated link closed")
+	case c.rejectWrites > 0:
+		c.rejectWrites--
+		return ErrWriteRejected
+	}
+	c.handle(l, frame)
+	return nil
+}
+
+// EnableNotifications implements sony_remote_ble.Link.
+func (l *link) EnableNotifications(fn func([]byte)) error {
+	c := l.cam
+	if c.opts.NoNotifications {
+		return sony_remote_ble.ErrNoNotifications
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l.notify = fn
+	return nil
+}
+
+// Disconnect implements sony_remote_ble.Link.
+func (l *link) Disconnect() error {
+	c := l.cam
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l.closed = true
+	if c.link == l {
+		c.link = nil
+		c.release()
+	}
+	return nil
+}
+
+// frameKey identifies a command by its first two bytes, so that zoom frames
+// match whatever speed they carry.
+func frameKey(frame []byte) string {
+	if len(frame) > 2 {
+		frame = frame[:2]
+	}
+	return hex.EncodeToString(frame)
+}
+
+// buttonDown maps each button to the key of its down frame, and buttonFrames
+// maps the keys of down and up frames to their button.
+var buttonDown, buttonFrames = func() (map[string]string, map[string]buttonFrame) {
+	down := make(map[string]string)
+	frames := make(map[string]buttonFrame)
+	for _, button := range sony_remote_ble.Buttons() {
+		d := frameKey(sony_remote_ble.Commands[button+"_down"].Code)
+		u := frameKey(sony_remote_ble.Commands[button+"_up"].Code)
+		down[button] = d
+		if _, ok := frames[d]; !ok {
+			frames[d] = buttonFrame{button, true}
+		}
+		if _, ok := frames[u]; !ok {
+			frames[u] = buttonFrame{button, false}
+		}
+	}
+	return down, frames
+}()
+
+type buttonFrame struct {
+	button string
+	down   bool
+}
+
+func buttonOf(key string) (button string, down, ok bool) {
+	f, ok := buttonFrames[key]
+	return f.button, f.down, ok
+}
@@ -0,0 +1,970 @@
+// Package sonyremotetest provides a fake sony_remote_ble.Camera for testing
+// code that controls cameras, without Bluetooth and without a camera.
+//
+// The fake records every command it is sent, with the time it was sent, and
+// offers assertions on them. Connections and commands can be failed on
+// demand, commands can be scripted to answer with notifications, recording
+// can follow the record button with SetRecordToggle and the link can be
+// closed for being idle with GoIdle:
+//
+//	func TestStarTrails(t *testing.T) {
+//		cam := sonyremotetest.New()
+//		cam.SkipSleeps()
+//		cam.Connect(sonyremotetest.Address)
+//
+//		if err := StarTrails(cam, 3, 30*time.Second); err != nil {
+//			t.Fatal(err)
+//		}
+//		cam.AssertSentCount(t, "shutter_full_down", 3)
+//		cam.AssertGap(t, "shutter_full_down", "shutter_full_up", 30*time.Second, 31*time.Second)
+//	}
+package sonyremotetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"tinygo.org/x/bluetooth"
+)
+
+// Address is the address of the camera a new fake advertises.
+var Address = sony_remote_ble.ParseAddress("C0:FF:EE:00:00:02")
+
+// Sent is a command the fake was asked to send.
+type Sent struct {
+	// Name is the command's key in sony_remote_ble.Commands, such as
+	// "shutter_full_down", or its bytes in hex for commands not in the map
+	Name string
+	// Command is the command as passed to the fake
+	Command sony_remote_ble.SonyCommand
+	// At is when the command was sent
+	At time.Time
+	// Err is the error the send failed with, or nil if it was written
+	Err error
+}
+
+// Camera is a fake sony_remote_ble.Camera that records the commands it is
+// sent. Create it with New. It is safe for concurrent use.
+type Camera struct {
+	mu           sync.Mutex
+	state        sony_remote_ble.ConnectionState
+	deviceName   string
+	lastError    error
+	commandDelay time.Duration
+	profile      sony_remote_ble.TimingProfile
+	notify       bool
+	devices      []sony_remote_ble.DeviceInfo
+	sent         []Sent
+	// stopScan ends the running scan, if any
+	stopScan chan struct{}
+
+	connectErr error
+	commandErr map[string]error
+	handlers   map[string]func(sony_remote_ble.SonyCommand) error
+	// responses holds the scripted notifications by command frame in hex
+	responses map[string][][]byte
+
+	// recording is the recording state last reported to the client, and
+	// recordingReports counts the reports since connecting; cameraRecording
+	// is whether the camera records, which the client may not know, and
+	// recordToggle whether releasing the record button changes it
+	recording        bool
+	recordingReports int
+	cameraRecording  bool
+	recordToggle     bool
+	// focusLocks counts the focus acquired notifications delivered
+	focusLocks int
+
+	idleDisconnect sony_remote_ble.IdleDisconnect
+	idle           bool
+	// reconnect is how long the next command takes to reopen an idle link
+	reconnect time.Duration
+
+	// skipSleeps makes the fake's own waits advance skipped instead of
+	// sleeping; skipped is added to the wall clock for recorded times
+	skipSleeps bool
+	skipped    time.Duration
+
+	listenersMu sync.Mutex
+	nextID      int
+	listeners   map[int]func(sony_remote_ble.Event)
+}
+
+// Camera implements the same interface as the Bluetooth client.
+var _ sony_remote_ble.Camera = (*Camera)(nil)
+
+// New creates a disconnected fake camera that advertises as "ILCE-7M4" at
+// Address and supports notifications.
+func New() *Camera {
+	return &Camera{
+		state:        sony_remote_ble.Disconnected,
+		commandDelay: sony_remote_ble.DefaultCommandDelay,
+		notify:       true,
+		devices: []sony_remote_ble.DeviceInfo{{
+			Name:       "ILCE-7M4",
+			Address:    Address,
+			AddressStr: Address.String(),
+			RSSI:       -55,
+		}},
+		commandErr: make(map[string]error),
+		handlers:   make(map[string]func(sony_remote_ble.SonyCommand) error),
+		responses:  make(map[string][][]byte),
+		listeners:  make(map[int]func(sony_remote_ble.Event)),
+	}
+}
+
+// SkipSleeps makes the waits inside TakePhoto, Bulb, Press, Zoom and
+// SendCommandSequence return immediately. The recorded times still advance
+// by the skipped duration, so gaps between commands are the same as without
+// SkipSleeps and a 30 second Bulb takes no time in a test.
+func (c *Camera) SkipSleeps() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.skipSleeps = true
+}
+
+// SetDevices replaces the cameras reported by ScanForDevices.
+func (c *Camera) SetDevices(devices ...sony_remote_ble.DeviceInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.devices = devices
+}
+
+// SetNotifications sets whether connections support notifications, as
+// reported by SupportsNotifications. New fakes support them.
+func (c *Camera) SetNotifications(supported bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notify = supported
+}
+
+// FailConnect makes every following Connect fail with err, moving the fake
+// into the Error state. Pass nil to let connections succeed again.
+func (c *Camera) FailConnect(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connectErr = err
+}
+
+// FailCommand makes every following send of the named command fail with err,
+// wrapped the way the client wraps write errors. The name is a key of
+// sony_remote_ble.Commands, or "" for every command. Pass a nil err to let
+// the command succeed again.
+func (c *Camera) FailCommand(name string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		delete(c.commandErr, name)
+		return
+	}
+	c.commandErr[name] = err
+}
+
+// OnCommand calls fn whenever the named command is sent, before it is
+// recorded as written. An error from fn fails the send. fn runs without
+// locks held and may call back into the fake, for example to Notify or to
+// FailCommand the next command. Pass a nil fn to remove the handler.
+func (c *Camera) OnCommand(name string, fn func(cmd sony_remote_ble.SonyCommand) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if fn == nil {
+		delete(c.handlers, name)
+		return
+	}
+	c.handlers[name] = fn
+}
+
+// Respond scripts the fake to send the given notification frames after each
+// successful send of the named command, as a camera answers a half press
+// with focus acquired:
+//
+//	cam.Respond("shutter_half_down", []byte{0x02, 0x3f, 0x20})
+//
+// Responses follow the bytes of the command, as the camera only sees those,
+// so the half press above also answers the "focus_down" that TakePhoto
+// sends. The name is a key of sony_remote_ble.Commands, or the bytes of a
+// command in hex. Calling Respond again for the same frame replaces the
+// frames; calling it without frames removes them.
+func (c *Camera) Respond(name string, frames ...[]byte) {
+	key := name
+	if cmd, ok := sony_remote_ble.Commands[name]; ok {
+		key = hex.EncodeToString(cmd.Code)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(frames) == 0 {
+		delete(c.responses, key)
+		return
+	}
+	c.responses[key] = frames
+}
+
+// Notify delivers a notification frame to listeners as if the camera had
+// sent it. It does nothing while not connected, while the link is idle or
+// when notifications are not supported.
+func (c *Camera) Notify(raw []byte) {
+	if !c.SupportsNotifications() || c.Idle() {
+		return
+	}
+	n := sony_remote_ble.ParseNotification(raw)
+	c.mu.Lock()
+	switch {
+	case n.Kind == sony_remote_ble.NotifyRecording:
+		c.recording = n.Active
+		c.recordingReports++
+	case n.Kind == sony_remote_ble.NotifyFocus && n.Active:
+		c.focusLocks++
+	}
+	c.mu.Unlock()
+	c.emit(sony_remote_ble.Event{Type: sony_remote_ble.EventNotification, Notification: n})
+}
+
+// SetRecordToggle makes releasing the record button, which "record_up" and
+// "record_toggle" both do, start or stop recording and notify the change, as
+// a camera does. New fakes leave recording to the test, which reports it with
+// Notify.
+func (c *Camera) SetRecordToggle(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.recordToggle = enabled
+}
+
+// SetCameraRecording sets whether the camera records without notifying the
+// client, as when recording was started on the camera body before the client
+// connected.
+func (c *Camera) SetCameraRecording(on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cameraRecording = on
+}
+
+// CameraRecording reports whether the camera records, whatever the client
+// was told. It only changes with SetRecordToggle or SetCameraRecording.
+func (c *Camera) CameraRecording() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cameraRecording
+}
+
+// GoIdle closes the link as the client does once it went unused for the time
+// passed to SetIdleDisconnect. The next command reopens it, which takes
+// reconnect and fails with the FailConnect error if one is set. GoIdle does
+// nothing while not connected or with idle disconnect disabled.
+func (c *Camera) GoIdle(reconnect time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state != sony_remote_ble.Connected || c.idleDisconnect.After <= 0 {
+		return
+	}
+	c.idle = true
+	c.reconnect = reconnect
+}
+
+// IdleDisconnect returns the setting last passed to SetIdleDisconnect.
+func (c *Camera) IdleDisconnect() sony_remote_ble.IdleDisconnect {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.idleDisconnect
+}
+
+// Drop moves a connected fake into the Error state with err, as when the
+// link to a real camera is lost.
+func (c *Camera) Drop(err error) {
+	c.mu.Lock()
+	if c.state != sony_remote_ble.Connected {
+		c.mu.Unlock()
+		return
+	}
+	c.deviceName = ""
+	c.mu.Unlock()
+	c.fail(err)
+}
+
+// Sent returns every command the fake was asked to send, in order,
+// including failed sends.
+func (c *Camera) Sent() []Sent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Sent(nil), c.sent...)
+}
+
+// Written returns the names of the commands that were written successfully,
+// in order.
+func (c *Camera) Written() []string {
+	var names []string
+	for _, s := range c.Sent() {
+		if s.Err == nil {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+// Reset forgets the recorded commands. Scripted responses and injected
+// errors stay in place.
+func (c *Camera) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = nil
+}
+
+// AssertSent fails the test unless the named command was written at least once.
+func (c *Camera) AssertSent(t testing.TB, name string) {
+	t.Helper()
+	if c.count(name) == 0 {
+		t.Errorf("%s was not sent; sent %s", name, c.describe())
+	}
+}
+
+// AssertNotSent fails the test if the named command was written.
+func (c *Camera) AssertNotSent(t testing.TB, name string) {
+	t.Helper()
+	if n := c.count(name); n > 0 {
+		t.Errorf("%s was sent %d times; sent %s", name, n, c.describe())
+	}
+}
+
+// AssertSentCount fails the test unless the named command was written exactly n times.
+func (c *Camera) AssertSentCount(t testing.TB, name string, n int) {
+	t.Helper()
+	if got := c.count(name); got != n {
+		t.Errorf("%s was sent %d times, want %d; sent %s", name, got, n, c.describe())
+	}
+}
+
+// AssertOrder fails the test unless the named commands were written in the
+// given order. Other commands may be written before, between and after them.
+func (c *Camera) AssertOrder(t testing.TB, names ...string) {
+	t.Helper()
+	next := 0
+	for _, name := range c.Written() {
+		if next < len(names) && name == names[next] {
+			next++
+		}
+	}
+	if next < len(names) {
+		t.Errorf("commands not sent in order %s: %s missing after %s; sent %s",
+			strings.Join(names, ", "), names[next], strings.Join(names[:next], ", "), c.describe())
+	}
+}
+
+// AssertGap fails the test unless second was written between min and max
+// after first. The first write of first is used, and the next write of second
+// after it, so the same name may be passed twice to check the spacing between
+// repeated commands.
+func (c *Camera) AssertGap(t testing.TB, first, second string, min, max time.Duration) {
+	t.Helper()
+	var from time.Time
+	for _, s := range c.Sent() {
+		if s.Err != nil {
+			continue
+		}
+		if from.IsZero() {
+			if s.Name == first {
+				from = s.At
+			}
+			continue
+		}
+		if s.Name == second {
+			gap := s.At.Sub(from)
+			if gap < min || gap > max {
+				t.Errorf("%s was sent %v after %s, want between %v and %v", second, gap, first, min, max)
+			}
+			return
+		}
+	}
+	if from.IsZero() {
+		t.Errorf("%s was not sent; sent %s", first, c.describe())
+		return
+	}
+	t.Errorf("%s was not sent after %s; sent %s", second, first, c.describe())
+}
+
+// count returns how often the named command was written.
+func (c *Camera) count(name string) int {
+	n := 0
+	for _, written := range c.Written() {
+		if written == name {
+			n++
+		}
+	}
+	return n
+}
+
+// describe lists the written commands for failure messages.
+func (c *Camera) describe() string {
+	written := c.Written()
+	if len(written) == 0 {
+		return "nothing"
+	}
+	return "[" + strings.Join(written, " ") + "]"
+}
+
+// State implements sony_remote_ble.Camera.
+func (c *Camera) State() sony_remote_ble.ConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// DeviceName implements sony_remote_ble.Camera.
+func (c *Camera) DeviceName() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deviceName
+}
+
+// LastError implements sony_remote_ble.Camera.
+func (c *Camera) LastError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastError
+}
+
+// CommandDelay implements sony_remote_ble.Camera.
+func (c *Camera) CommandDelay() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.commandDelay
+}
+
+// SetCommandDelay implements sony_remote_ble.Camera.
+func (c *Camera) SetCommandDelay(delay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commandDelay = delay
+}
+
+// TimingProfile implements sony_remote_ble.Camera.
+func (c *Camera) TimingProfile() sony_remote_ble.TimingProfile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.profile
+}
+
+// SetTimingProfile implements sony_remote_ble.Camera. The fake waits the full
+// pauses of adaptive profiles.
+func (c *Camera) SetTimingProfile(profile sony_remote_ble.TimingProfile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.profile = profile
+}
+
+// ScanForDevices implements sony_remote_ble.Camera. It reports the cameras
+// set with SetDevices, then keeps scanning until StopScan is called or ctx ends.
+func (c *Camera) ScanForDevices(ctx context.Context, deviceChan chan<- sony_remote_ble.DeviceInfo) error {
+	c.mu.Lock()
+	c.lastError = nil
+	if c.stopScan != nil {
+		close(c.stopScan)
+	}
+	stop := make(chan struct{})
+	c.stopScan = stop
+	devices := append([]sony_remote_ble.DeviceInfo(nil), c.devices...)
+	c.mu.Unlock()
+	c.setState(sony_remote_ble.Scanning, nil)
+
+	go func() {
+		for _, device := range devices {
+			c.emit(sony_remote_ble.Event{Type: sony_remote_ble.EventDeviceFound, Device: device})
+			select {
+			case deviceChan <- device:
+			case <-stop:
+				return
+			case <-ctx.Done():
+				c.endScan(stop)
+				return
+			}
+		}
+		select {
+		case <-stop:
+		case <-ctx.Done():
+			c.endScan(stop)
+		}
+	}()
+	return nil
+}
+
+// StopScan implements sony_remote_ble.Camera.
+func (c *Camera) StopScan() {
+	c.endScan(nil)
+}
+
+// endScan ends the scan started with stop, leaving a newer scan running, or
+// the running scan for a nil stop.
+func (c *Camera) endScan(stop chan struct{}) {
+	c.mu.Lock()
+	if stop != nil && c.stopScan != stop {
+		c.mu.Unlock()
+		return
+	}
+	if c.stopScan != nil {
+		close(c.stopScan)
+		c.stopScan = nil
+	}
+	scanning := c.state == sony_remote_ble.Scanning
+	c.mu.Unlock()
+	if scanning {
+		c.setState(sony_remote_ble.Disconnected, nil)
+	}
+}
+
+// Connect implements sony_remote_ble.Camera. It succeeds for any address
+// unless FailConnect was called.
+func (c *Camera) Connect(address bluetooth.Address) error {
+	c.mu.Lock()
+	c.lastError = nil
+	err := c.connectErr
+	c.mu.Unlock()
+	c.setState(sony_remote_ble.Connecting, nil)
+
+	if err != nil {
+		return c.fail(err)
+	}
+	c.mu.Lock()
+	c.deviceName = address.String()
+	c.resetLink()
+	c.mu.Unlock()
+	c.setState(sony_remote_ble.Connected, nil)
+	return nil
+}
+
+// Disconnect implements sony_remote_ble.Camera.
+func (c *Camera) Disconnect() error {
+	c.mu.Lock()
+	c.deviceName = ""
+	c.resetLink()
+	c.mu.Unlock()
+	c.setState(sony_remote_ble.Disconnected, nil)
+	return nil
+}
+
+// resetLink forgets what the client learnt on the last connection. The
+// caller holds c.mu.
+func (c *Camera) resetLink() {
+	c.recording = false
+	c.recordingReports = 0
+	c.idle = false
+}
+
+// SupportsNotifications implements sony_remote_ble.Camera.
+func (c *Camera) SupportsNotifications() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state == sony_remote_ble.Connected && c.notify
+}
+
+// SetIdleDisconnect implements sony_remote_ble.Camera. The fake keeps no
+// timer; GoIdle closes the link when a test wants it closed.
+func (c *Camera) SetIdleDisconnect(idle sony_remote_ble.IdleDisconnect) {
+	if idle.ReconnectTimeout <= 0 {
+		idle.ReconnectTimeout = sony_remote_ble.DefaultReconnectTimeout
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.idleDisconnect = idle
+	if idle.After <= 0 {
+		c.idle = false
+	}
+}
+
+// Idle implements sony_remote_ble.Camera.
+func (c *Camera) Idle() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.idle
+}
+
+// wake reopens a link closed by GoIdle and returns how long that took.
+func (c *Camera) wake() (time.Duration, error) {
+	c.mu.Lock()
+	idle, reconnect, err := c.idle, c.reconnect, c.connectErr
+	c.idle = false
+	c.mu.Unlock()
+	if !idle {
+		return 0, nil
+	}
+	c.sleep(reconnect)
+	if err != nil {
+		return reconnect, c.fail(err)
+	}
+	return reconnect, nil
+}
+
+// SendCommand implements sony_remote_ble.Camera. Sends while not connected
+// fail with sony_remote_ble.ErrNotConnected, like the client's.
+func (c *Camera) SendCommand(cmd sony_remote_ble.SonyCommand) error {
+	name := commandName(cmd)
+	c.mu.Lock()
+	connected := c.state == sony_remote_ble.Connected
+	err := c.commandErr[name]
+	if err == nil {
+		err = c.commandErr[""]
+	}
+	handler := c.handlers[name]
+	c.mu.Unlock()
+
+	if !connected {
+		c.record(name, cmd, sony_remote_ble.ErrNotConnected)
+		c.emit(sony_remote_ble.Event{Type: sony_remote_ble.EventCommandFailed, Command: cmd, Err: sony_remote_ble.ErrNotConnected})
+		return sony_remote_ble.ErrNotConnected
+	}
+	reconnect, wakeErr := c.wake()
+	if wakeErr != nil {
+		err = fmt.Errorf("failed to send command %s: %w", cmd.Name, wakeErr)
+		c.record(name, cmd, err)
+		c.emit(sony_remote_ble.Event{Type: sony_remote_ble.EventCommandFailed, Command: cmd, Reconnect: reconnect, Err: err})
+		return err
+	}
+	if err == nil && handler != nil {
+		err = handler(cmd)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to send command %s: %w", cmd.Name, err)
+		c.mu.Lock()
+		c.lastError = err
+		c.mu.Unlock()
+		c.record(name, cmd, err)
+		c.emit(sony_remote_ble.Event{Type: sony_remote_ble.EventCommandFailed, Command: cmd, Reconnect: reconnect, Err: err})
+		return err
+	}
+
+	c.record(name, cmd, nil)
+	c.emit(sony_remote_ble.Event{Type: sony_remote_ble.EventCommandSent, Command: cmd, Reconnect: reconnect})
+	c.mu.Lock()
+	frames := c.responses[hex.EncodeToString(cmd.Code)]
+	toggle := c.recordToggle
+	c.mu.Unlock()
+	for _, frame := range frames {
+		c.Notify(frame)
+	}
+	if toggle && bytes.Equal(cmd.Code, recordRelease) {
+		c.toggleRecording()
+	}
+	return nil
+}
+
+// recordRelease is the frame that releases the record button, on which the
+// camera starts or stops recording.
+var recordRelease = sony_remote_ble.Commands["record_up"].Code
+
+// toggleRecording starts or stops the camera's recording and notifies the change.
+func (c *Camera) toggleRecording() {
+	c.mu.Lock()
+	c.cameraRecording = !c.cameraRecording
+	on := c.cameraRecording
+	c.mu.Unlock()
+	frame := []byte{0x02, 0xd5, 0x00}
+	if on {
+		frame[2] = 0x20
+	}
+	c.Notify(frame)
+}
+
+// SendCommandSequence implements sony_remote_ble.Camera.
+func (c *Camera) SendCommandSequence(commands []sony_remote_ble.SonyCommand, delay time.Duration) error {
+	for _, cmd := range commands {
+		if err := c.SendCommand(cmd); err != nil {
+			return err
+		}
+		c.sleep(delay)
+	}
+	return nil
+}
+
+// TakePhoto implements sony_remote_ble.Camera.
+func (c *Camera) TakePhoto() error {
+	profile := c.TimingProfile().Resolve(c.CommandDelay())
+	if err := c.SendCommand(sony_remote_ble.Commands["focus_down"]); err != nil {
+		return err
+	}
+	c.sleep(profile.HalfPressSettle)
+	return c.fire(profile)
+}
+
+// TakePhotoWhenFocused implements sony_remote_ble.Camera. Focus locks when a
+// focus acquired notification arrives with the half press, as scripted with
+// Respond("focus_down", ...); otherwise the attempt waits out timeout, which
+// SkipSleeps skips.
+func (c *Camera) TakePhotoWhenFocused(ctx context.Context, timeout time.Duration, policy sony_remote_ble.FocusPolicy) (sony_remote_ble.FocusResult, error) {
+	var result sony_remote_ble.FocusResult
+	if c.State() != sony_remote_ble.Connected {
+		return result, sony_remote_ble.ErrNotConnected
+	}
+	if !c.SupportsNotifications() {
+		return result, sony_remote_ble.ErrNoNotifications
+	}
+	delay := c.CommandDelay()
+	profile := c.TimingProfile().Resolve(delay)
+
+	locked := false
+	for !locked && result.Attempts <= policy.Retries {
+		if result.Attempts > 0 {
+			if err := c.SendCommand(sony_remote_ble.Commands["focus_up"]); err != nil {
+				return result, err
+			}
+			c.sleep(delay)
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		result.Attempts++
+		c.mu.Lock()
+		locks := c.focusLocks
+		c.mu.Unlock()
+		start := c.now()
+		if err := c.SendCommand(sony_remote_ble.Commands["focus_down"]); err != nil {
+			return result, err
+		}
+		c.mu.Lock()
+		locked = c.focusLocks > locks
+		c.mu.Unlock()
+		if locked {
+			result.FocusLatency = c.now().Sub(start)
+		} else {
+			c.sleep(timeout)
+		}
+	}
+
+	switch {
+	case locked:
+		result.Outcome = sony_remote_ble.FocusLocked
+	case policy.FireAnyway:
+		result.Outcome = sony_remote_ble.FocusFiredUnlocked
+	default:
+		result.Outcome = sony_remote_ble.FocusAborted
+		if err := c.SendCommand(sony_remote_ble.Commands["focus_up"]); err != nil {
+			return result, err
+		}
+		return result, sony_remote_ble.ErrFocusTimeout
+	}
+	return result, c.fire(profile)
+}
+
+// fire completes a photo after the half press: it presses fully, releases
+// and lets go of the half press with the pauses of profile.
+func (c *Camera) fire(profile sony_remote_ble.TimingProfile) error {
+	steps := []struct {
+		cmd   string
+		pause time.Duration
+	}{
+		{"shutter_full_down", profile.FullPressHold},
+		{"shutter_full_up", profile.ReleaseGap},
+		{"focus_up", c.CommandDelay()},
+	}
+	for _, step := range steps {
+		if err := c.SendCommand(sony_remote_ble.Commands[step.cmd]); err != nil {
+			return err
+		}
+		c.sleep(step.pause)
+	}
+	return nil
+}
+
+// Press implements sony_remote_ble.Camera.
+func (c *Camera) Press(button string, hold time.Duration) error {
+	down, ok := sony_remote_ble.Commands[button+"_down"]
+	if !ok {
+		return fmt.Errorf("%w: %s", sony_remote_ble.ErrUnknownButton, button)
+	}
+	up, ok := sony_remote_ble.Commands[button+"_up"]
+	if !ok {
+		return fmt.Errorf("%w: %s", sony_remote_ble.ErrUnknownButton, button)
+	}
+
+	if err := c.SendCommand(down); err != nil {
+		return err
+	}
+	c.sleep(hold)
+	return c.SendCommand(up)
+}
+
+// Bulb implements sony_remote_ble.Camera.
+func (c *Camera) Bulb(exposure time.Duration) error {
+	if err := c.SendCommandSequence(sony_remote_ble.BulbSequence(), c.CommandDelay()); err != nil {
+		return err
+	}
+	c.sleep(exposure)
+	return c.SendCommandSequence(sony_remote_ble.BulbReleaseSequence(), c.CommandDelay())
+}
+
+// Zoom implements sony_remote_ble.Camera.
+func (c *Camera) Zoom(in bool, speed byte, hold time.Duration) error {
+	release := sony_remote_ble.Commands["zoom_out_up"]
+	if in {
+		release = sony_remote_ble.Commands["zoom_in_up"]
+	}
+
+	if err := c.SendCommand(sony_remote_ble.ZoomCommand(in, speed)); err != nil {
+		return err
+	}
+	c.sleep(hold)
+	if err := c.SendCommand(release); err != nil {
+		return err
+	}
+	c.sleep(c.TimingProfile().ZoomStop)
+	return nil
+}
+
+// Recording implements sony_remote_ble.Camera. Like the client, it reports
+// what the camera notified since connecting, and known is false without
+// notifications.
+func (c *Camera) Recording() (recording, known bool) {
+	if !c.SupportsNotifications() {
+		return false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.recording, true
+}
+
+// SetRecording implements sony_remote_ble.Camera. Like the client, it presses
+// the record button unless the camera reported being in the state on, and
+// once more if the camera then reports the opposite. The camera reports with
+// SetRecordToggle, or with notifications scripted for "record_up". They
+// arrive during the press, so SetRecording fails right away instead of after
+// sony_remote_ble.DefaultRecordTimeout when no report follows.
+func (c *Camera) SetRecording(ctx context.Context, on bool) error {
+	if c.State() != sony_remote_ble.Connected {
+		return sony_remote_ble.ErrNotConnected
+	}
+	recording, known := c.Recording()
+	if !known {
+		return sony_remote_ble.ErrRecordingUnknown
+	}
+	c.mu.Lock()
+	reports := c.recordingReports
+	c.mu.Unlock()
+	if reports > 0 && recording == on {
+		return nil
+	}
+
+	for range 2 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.Press("record", sony_remote_ble.DefaultCommandDelay); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		reported := c.recordingReports > reports
+		reports, recording = c.recordingReports, c.recording
+		c.mu.Unlock()
+		if !reported {
+			return fmt.Errorf("camera did not report recording after the record button")
+		}
+		if recording == on {
+			return nil
+		}
+	}
+	return fmt.Errorf("camera did not reach the requested recording state")
+}
+
+// OnEvent implements sony_remote_ble.Camera.
+func (c *Camera) OnEvent(fn func(sony_remote_ble.Event)) (remove func()) {
+	c.listenersMu.Lock()
+	defer c.listenersMu.Unlock()
+	id := c.nextID
+	c.nextID++
+	c.listeners[id] = fn
+
+	return func() {
+		c.listenersMu.Lock()
+		defer c.listenersMu.Unlock()
+		delete(c.listeners, id)
+	}
+}
+
+// emit delivers an event to every listener.
+func (c *Camera) emit(ev sony_remote_ble.Event) {
+	if ev.Time.IsZero() {
+		ev.Time = c.now()
+	}
+
+	c.listenersMu.Lock()
+	listeners := make([]func(sony_remote_ble.Event), 0, len(c.listeners))
+	for _, fn := range c.listeners {
+		listeners = append(listeners, fn)
+	}
+	c.listenersMu.Unlock()
+
+	for _, fn := range listeners {
+		fn(ev)
+	}
+}
+
+// setState moves the fake into a new state and emits EventStateChanged if it changed.
+func (c *Camera) setState(state sony_remote_ble.ConnectionState, err error) {
+	c.mu.Lock()
+	previous := c.state
+	c.state = state
+	c.mu.Unlock()
+
+	if previous != state {
+		c.emit(sony_remote_ble.Event{Type: sony_remote_ble.EventStateChanged, State: state, Previous: previous, Err: err})
+	}
+}
+
+// fail records err as the last error, moves the fake into the Error state and returns err.
+func (c *Camera) fail(err error) error {
+	c.mu.Lock()
+	c.lastError = err
+	c.mu.Unlock()
+	c.setState(sony_remote_ble.Error, err)
+	return err
+}
+
+func (c *Camera) record(name string, cmd sony_remote_ble.SonyCommand, err error) {
+	at := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, Sent{Name: name, Command: cmd, At: at, Err: err})
+}
+
+// now returns the wall clock plus the waits skipped so far.
+func (c *Camera) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Add(c.skipped)
+}
+
+// sleep waits for d, or only advances the recorded time after SkipSleeps.
+func (c *Camera) sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.mu.Lock()
+	skip := c.skipSleeps
+	if skip {
+		c.skipped += d
+	}
+	c.mu.Unlock()
+	if !skip {
+		time.Sleep(d)
+	}
+}
+
+// commandNames maps command names to their keys in sony_remote_ble.Commands.
+var commandNames = func() map[string]string {
+	names := make(map[string]string, len(sony_remote_ble.Commands))
+	for key, cmd := range sony_remote_ble.Commands {
+		names[cmd.Name] = key
+	}
+	return names
+}()
+
+// commandName returns the Commands key of cmd, or its bytes in hex.
+func commandName(cmd sony_remote_ble.SonyCommand) string {
+	if name, ok := commandNames[cmd.Name]; ok {
+		return name
+	}
+	return hex.EncodeToString(cmd.Code)
+}
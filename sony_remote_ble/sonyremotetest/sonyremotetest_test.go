@@ -0,0 +1,368 @@
+package sonyremotetest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble"
+	"github.com/smazurov/sony_remote_ble/sony_remote_ble/sonyremotetest"
+)
+
+// recorder collects the failures of an assertion instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// check runs assert against a recorder and fails t unless it failed exactly
+// when it should have, with a message containing want.
+func check(t *testing.T, what string, fail bool, want string, assert func(testing.TB)) {
+	t.Helper()
+	r := &recorder{TB: t}
+	assert(r)
+	switch {
+	case !fail && len(r.errors) > 0:
+		t.Errorf("%s: unexpected failure %q", what, r.errors)
+	case fail && len(r.errors) == 0:
+		t.Errorf("%s: did not fail", what)
+	case fail && !strings.Contains(r.errors[0], want):
+		t.Errorf("%s: failed with %q, want it to mention %q", what, r.errors[0], want)
+	}
+}
+
+// connected returns a connected fake that skips its sleeps.
+func connected(t *testing.T) *sonyremotetest.Camera {
+	t.Helper()
+	cam := sonyremotetest.New()
+	cam.SkipSleeps()
+	if err := cam.Connect(sonyremotetest.Address); err != nil {
+		t.Fatal(err)
+	}
+	return cam
+}
+
+// starTrails is the function tested in the package example.
+func starTrails(cam sony_remote_ble.Camera, frames int, exposure time.Duration) error {
+	for range frames {
+		if err := cam.Bulb(exposure); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestPackageExample(t *testing.T) {
+	cam := connected(t)
+
+	start := time.Now()
+	if err := starTrails(cam, 3, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("three 30 second exposures took %v with SkipSleeps", elapsed)
+	}
+	cam.AssertSentCount(t, "shutter_full_down", 3)
+	cam.AssertGap(t, "shutter_full_down", "shutter_full_up", 30*time.Second, 31*time.Second)
+}
+
+func TestRespondAnswersCommandsSharingTheFrame(t *testing.T) {
+	cam := connected(t)
+	cam.Respond("shutter_half_down", []byte{0x02, 0x3f, 0x20})
+
+	var focused int
+	remove := cam.OnEvent(func(ev sony_remote_ble.Event) {
+		if ev.Type == sony_remote_ble.EventNotification && ev.Notification.Kind == sony_remote_ble.NotifyFocus && ev.Notification.Active {
+			focused++
+		}
+	})
+	defer remove()
+
+	// TakePhoto half presses with focus_down, which has the same bytes
+	if err := cam.TakePhoto(); err != nil {
+		t.Fatal(err)
+	}
+	if focused != 1 {
+		t.Fatalf("TakePhoto got %d focus notifications, want 1", focused)
+	}
+	if err := cam.SendCommand(sony_remote_ble.Commands["shutter_half_down"]); err != nil {
+		t.Fatal(err)
+	}
+	if focused != 2 {
+		t.Fatalf("half press got %d focus notifications in total, want 2", focused)
+	}
+
+	// Failed sends and removed responses answer nothing
+	cam.FailCommand("focus_down", fmt.Errorf("link lost"))
+	if err := cam.TakePhoto(); err == nil {
+		t.Fatal("TakePhoto succeeded with focus_down failing")
+	}
+	cam.FailCommand("focus_down", nil)
+	cam.Respond("focus_down")
+	if err := cam.TakePhoto(); err != nil {
+		t.Fatal(err)
+	}
+	if focused != 2 {
+		t.Fatalf("got %d focus notifications in total, want 2", focused)
+	}
+}
+
+func TestAssertSent(t *testing.T) {
+	cam := connected(t)
+	if err := cam.Press("record", 0); err != nil {
+		t.Fatal(err)
+	}
+	cam.FailCommand("c1_down", fmt.Errorf("busy"))
+	cam.Press("c1", 0)
+
+	check(t, "sent", false, "", func(tb testing.TB) { cam.AssertSent(tb, "record_down") })
+	check(t, "never sent", true, "shutter_full_down was not sent; sent [record_down record_up]", func(tb testing.TB) {
+		cam.AssertSent(tb, "shutter_full_down")
+	})
+	check(t, "failed send", true, "c1_down was not sent", func(tb testing.TB) { cam.AssertSent(tb, "c1_down") })
+
+	check(t, "not sent", false, "", func(tb testing.TB) { cam.AssertNotSent(tb, "c1_down") })
+	check(t, "sent but should not", true, "record_up was sent 1 times", func(tb testing.TB) {
+		cam.AssertNotSent(tb, "record_up")
+	})
+
+	check(t, "count", false, "", func(tb testing.TB) { cam.AssertSentCount(tb, "record_down", 1) })
+	check(t, "wrong count", true, "record_down was sent 1 times, want 2", func(tb testing.TB) {
+		cam.AssertSentCount(tb, "record_down", 2)
+	})
+
+	cam.Reset()
+	check(t, "after reset", true, "record_down was not sent; sent nothing", func(tb testing.TB) {
+		cam.AssertSent(tb, "record_down")
+	})
+}
+
+func TestAssertOrder(t *testing.T) {
+	cam := connected(t)
+	if err := cam.TakePhoto(); err != nil {
+		t.Fatal(err)
+	}
+
+	check(t, "full order", false, "", func(tb testing.TB) {
+		cam.AssertOrder(tb, "focus_down", "shutter_full_down", "shutter_full_up", "focus_up")
+	})
+	check(t, "with gaps", false, "", func(tb testing.TB) { cam.AssertOrder(tb, "focus_down", "focus_up") })
+	check(t, "reversed", true, "shutter_full_down missing after focus_up", func(tb testing.TB) {
+		cam.AssertOrder(tb, "focus_up", "shutter_full_down")
+	})
+	check(t, "never sent", true, "record_down missing after focus_down", func(tb testing.TB) {
+		cam.AssertOrder(tb, "focus_down", "record_down")
+	})
+}
+
+func TestAssertGap(t *testing.T) {
+	cam := connected(t)
+	cam.SetCommandDelay(100 * time.Millisecond)
+	for range 2 {
+		if err := cam.Bulb(2 * time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each bulb sends shutter_half_down, shutter_full_down, the exposure and
+	// then shutter_full_up and shutter_half_up, a command delay after each
+	check(t, "within", false, "", func(tb testing.TB) {
+		cam.AssertGap(tb, "shutter_full_down", "shutter_full_up", 2*time.Second, 2200*time.Millisecond)
+	})
+	check(t, "repeated command", false, "", func(tb testing.TB) {
+		cam.AssertGap(tb, "shutter_full_down", "shutter_full_down", 2300*time.Millisecond, 2500*time.Millisecond)
+	})
+	check(t, "too short", true, "want between 3s and 4s", func(tb testing.TB) {
+		cam.AssertGap(tb, "shutter_full_down", "shutter_full_up", 3*time.Second, 4*time.Second)
+	})
+	check(t, "too long", true, "shutter_full_up was sent", func(tb testing.TB) {
+		cam.AssertGap(tb, "shutter_full_down", "shutter_full_up", 0, time.Second)
+	})
+	check(t, "first never sent", true, "record_down was not sent", func(tb testing.TB) {
+		cam.AssertGap(tb, "record_down", "shutter_full_up", 0, time.Second)
+	})
+	check(t, "second never sent", true, "record_down was not sent after shutter_half_down", func(tb testing.TB) {
+		cam.AssertGap(tb, "shutter_half_down", "record_down", 0, time.Second)
+	})
+}
+
+func TestScanEndsWithItsContext(t *testing.T) {
+	cam := sonyremotetest.New()
+	devices := make(chan sony_remote_ble.DeviceInfo, 1)
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	if err := cam.ScanForDevices(first, devices); err != nil {
+		t.Fatal(err)
+	}
+	<-devices
+
+	// A new scan replaces the first, whose context ending must not stop it
+	second, cancelSecond := context.WithCancel(context.Background())
+	defer cancelSecond()
+	if err := cam.ScanForDevices(second, devices); err != nil {
+		t.Fatal(err)
+	}
+	<-devices
+	cancelFirst()
+	time.Sleep(20 * time.Millisecond)
+	if state := cam.State(); state != sony_remote_ble.Scanning {
+		t.Fatalf("state %v after the replaced scan's context ended, want scanning", state)
+	}
+
+	cancelSecond()
+	deadline := time.Now().Add(time.Second)
+	for cam.State() != sony_remote_ble.Disconnected {
+		if time.Now().After(deadline) {
+			t.Fatalf("state %v after the scan's context ended, want disconnected", cam.State())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTakePhotoWhenFocused(t *testing.T) {
+	cam := connected(t)
+	ctx := context.Background()
+
+	// Without a scripted focus lock every attempt waits out the timeout
+	start := time.Now()
+	result, err := cam.TakePhotoWhenFocused(ctx, 2*time.Second, sony_remote_ble.FocusRetry)
+	if !errors.Is(err, sony_remote_ble.ErrFocusTimeout) || result.Outcome != sony_remote_ble.FocusAborted || result.Attempts != 3 {
+		t.Fatalf("got %+v, %v; want aborted after 3 attempts", result, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("three 2 second attempts took %v with SkipSleeps", elapsed)
+	}
+	cam.AssertSentCount(t, "focus_down", 3)
+	cam.AssertNotSent(t, "shutter_full_down")
+	cam.AssertGap(t, "focus_down", "focus_up", 2*time.Second, 3*time.Second)
+
+	cam.Reset()
+	result, err = cam.TakePhotoWhenFocused(ctx, time.Second, sony_remote_ble.FocusFire)
+	if err != nil || result.Outcome != sony_remote_ble.FocusFiredUnlocked {
+		t.Fatalf("got %+v, %v; want fired unlocked", result, err)
+	}
+	cam.AssertOrder(t, "focus_down", "shutter_full_down", "shutter_full_up", "focus_up")
+
+	// A focus lock answering the half press fires at once
+	cam.Reset()
+	cam.Respond("focus_down", []byte{0x02, 0x3f, 0x20})
+	result, err = cam.TakePhotoWhenFocused(ctx, time.Second, sony_remote_ble.FocusAbort)
+	if err != nil || result.Outcome != sony_remote_ble.FocusLocked || result.Attempts != 1 {
+		t.Fatalf("got %+v, %v; want locked on the first attempt", result, err)
+	}
+	cam.AssertOrder(t, "focus_down", "shutter_full_down", "shutter_full_up", "focus_up")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := cam.TakePhotoWhenFocused(cancelled, time.Second, sony_remote_ble.FocusAbort); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled context: got %v", err)
+	}
+	cam.SetNotifications(false)
+	if _, err := cam.TakePhotoWhenFocused(ctx, time.Second, sony_remote_ble.FocusAbort); !errors.Is(err, sony_remote_ble.ErrNoNotifications) {
+		t.Errorf("without notifications: got %v, want %v", err, sony_remote_ble.ErrNoNotifications)
+	}
+}
+
+func TestSetRecording(t *testing.T) {
+	cam := connected(t)
+	ctx := context.Background()
+
+	// A camera that never reports fails the request
+	if err := cam.SetRecording(ctx, true); err == nil {
+		t.Fatal("SetRecording succeeded without the camera reporting")
+	}
+
+	cam.Reset()
+	cam.SetRecordToggle(true)
+	if err := cam.SetRecording(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	if recording, known := cam.Recording(); !recording || !known || !cam.CameraRecording() {
+		t.Fatalf("recording %t (known %t), camera %t after starting", recording, known, cam.CameraRecording())
+	}
+	if err := cam.SetRecording(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	cam.AssertSentCount(t, "record_down", 1)
+
+	// A recording the client did not see start takes a second press to stop
+	if err := cam.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cam.Connect(sonyremotetest.Address); err != nil {
+		t.Fatal(err)
+	}
+	cam.Reset()
+	if recording, _ := cam.Recording(); recording {
+		t.Fatal("reports recording after reconnecting, want the client's assumption")
+	}
+	if err := cam.SetRecording(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	cam.AssertSentCount(t, "record_down", 2)
+	if !cam.CameraRecording() {
+		t.Error("camera stopped after asking to record")
+	}
+
+	cam.SetNotifications(false)
+	if err := cam.SetRecording(ctx, false); !errors.Is(err, sony_remote_ble.ErrRecordingUnknown) {
+		t.Errorf("without notifications: got %v, want %v", err, sony_remote_ble.ErrRecordingUnknown)
+	}
+	if _, known := cam.Recording(); known {
+		t.Error("recording state known without notifications")
+	}
+}
+
+func TestIdleDisconnect(t *testing.T) {
+	cam := connected(t)
+
+	// GoIdle needs idle disconnect enabled
+	cam.GoIdle(time.Second)
+	if cam.Idle() {
+		t.Fatal("idle with idle disconnect disabled")
+	}
+	cam.SetIdleDisconnect(sony_remote_ble.IdleDisconnect{After: time.Minute})
+	if got := cam.IdleDisconnect(); got.After != time.Minute || got.ReconnectTimeout != sony_remote_ble.DefaultReconnectTimeout {
+		t.Fatalf("setting %+v, want the default reconnect timeout filled in", got)
+	}
+
+	var reconnects []time.Duration
+	remove := cam.OnEvent(func(ev sony_remote_ble.Event) {
+		if ev.Type == sony_remote_ble.EventCommandSent || ev.Type == sony_remote_ble.EventCommandFailed {
+			reconnects = append(reconnects, ev.Reconnect)
+		}
+	})
+	defer remove()
+
+	cam.GoIdle(300 * time.Millisecond)
+	if !cam.Idle() || cam.State() != sony_remote_ble.Connected {
+		t.Fatalf("idle %t in state %v, want idle and still connected", cam.Idle(), cam.State())
+	}
+	cam.Notify([]byte{0x02, 0x3f, 0x20})
+	if err := cam.Press("c1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if cam.Idle() || len(reconnects) != 2 || reconnects[0] != 300*time.Millisecond || reconnects[1] != 0 {
+		t.Fatalf("idle %t with reconnects %v, want the link reopened by the first command", cam.Idle(), reconnects)
+	}
+	cam.AssertGap(t, "c1_down", "c1_up", 0, time.Millisecond)
+
+	// A camera that cannot be reached fails the command and the connection
+	cam.GoIdle(0)
+	cam.FailConnect(fmt.Errorf("out of range"))
+	if err := cam.Press("c1", 0); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Fatalf("got %v, want the reconnect error", err)
+	}
+	if cam.State() != sony_remote_ble.Error || cam.Idle() {
+		t.Errorf("state %v, idle %t after the failed reconnect", cam.State(), cam.Idle())
+	}
+}
@@ -0,0 +1,133 @@
+package sony_remote_ble
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// ErrNoNotifications is returned by Link.EnableNotifications when the camera
+// does not expose the notification characteristic.
+var ErrNoNotifications = errors.New("camera does not support notifications")
+
+// Transport carries the remote control protocol between a Client and cameras.
+// NewClient uses the host's Bluetooth adapter; NewClientWithTransport accepts
+// other implementations, such as a ReplayTransport that plays back a capture.
+//
+// A Client calls Scan from one goroutine while StopScan may be called from
+// another. Implementations must be safe for that.
+type Transport interface {
+	// Scan reports every advertisement to found and blocks until StopScan is
+	// called or scanning fails.
+	Scan(found func(DeviceInfo)) error
+	// StopScan makes a running Scan return.
+	StopScan() error
+	// Connect connects to the camera at address and discovers its remote
+	// control service. The returned Link is ready for writes.
+	Connect(address bluetooth.Address) (Link, error)
+}
+
+// Link is an open connection to a camera's remote control service.
+type Link interface {
+	// Write sends a frame to the command characteristic without waiting for a response.
+	Write(frame []byte) error
+	// EnableNotifications delivers every frame received on the notification
+	// characteristic to fn, or stops delivery when fn is nil. It returns
+	// ErrNoNotifications for cameras without the characteristic.
+	EnableNotifications(fn func([]byte)) error
+	// Disconnect closes the connection.
+	Disconnect() error
+}
+
+// adapterTransport reaches cameras through a Bluetooth adapter.
+type adapterTransport struct {
+	adapter *bluetooth.Adapter
+	logger  *slog.Logger
+}
+
+func (t *adapterTransport) setLogger(logger *slog.Logger) {
+	t.logger = logger
+}
+
+func (t *adapterTransport) Scan(found func(DeviceInfo)) error {
+	return t.adapter.Scan(func(_ *bluetooth.Adapter, result bluetooth.ScanResult) {
+		found(DeviceInfo{
+			Name:       result.LocalName(),
+			Address:    result.Address,
+			AddressStr: result.Address.String(),
+			RSSI:       result.RSSI,
+		})
+	})
+}
+
+func (t *adapterTransport) StopScan() error {
+	return t.adapter.StopScan()
+}
+
+func (t *adapterTransport) Connect(address bluetooth.Address) (Link, error) {
+	logger := t.logger.With("address", address.String())
+
+	// Connect to device
+	device, err := t.adapter.Connect(address, bluetooth.ConnectionParams{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	logger.Debug("link established")
+
+	// Discover services
+	logger.Debug("discovering services", "uuid", SonyServiceUUID)
+	services, err := device.DiscoverServices([]bluetooth.UUID{ServiceUUID()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover services: %w", err)
+	}
+
+	if len(services) == 0 {
+		return nil, errors.New("Sony camera service not found")
+	}
+
+	// Discover characteristics
+	logger.Debug("discovering characteristics", "uuid", CommandCharUUID)
+	chars, err := services[0].DiscoverCharacteristics([]bluetooth.UUID{CharacteristicUUID()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover characteristics: %w", err)
+	}
+
+	if len(chars) == 0 {
+		return nil, errors.New("command characteristic not found")
+	}
+
+	link := &adapterLink{device: device, char: chars[0]}
+	if notifyChars, err := services[0].DiscoverCharacteristics([]bluetooth.UUID{NotificationCharacteristicUUID()}); err == nil && len(notifyChars) > 0 {
+		link.notifyChar = notifyChars[0]
+		link.hasNotify = true
+	} else {
+		logger.Debug("notification characteristic not found", "uuid", NotifyCharUUID)
+	}
+	return link, nil
+}
+
+// adapterLink is a connection made by an adapterTransport.
+type adapterLink struct {
+	device     bluetooth.Device
+	char       bluetooth.DeviceCharacteristic
+	notifyChar bluetooth.DeviceCharacteristic
+	hasNotify  bool
+}
+
+func (l *adapterLink) Write(frame []byte) error {
+	_, err := l.char.WriteWithoutResponse(frame)
+	return err
+}
+
+func (l *adapterLink) EnableNotifications(fn func([]byte)) error {
+	if !l.hasNotify {
+		return ErrNoNotifications
+	}
+	return l.notifyChar.EnableNotifications(fn)
+}
+
+func (l *adapterLink) Disconnect() error {
+	return l.device.Disconnect()
+}